@@ -10,6 +10,7 @@ type EventListener struct {
 	r            *ProtocolLXD
 	chActive     chan bool
 	disconnected bool
+	reconnect    bool
 	err          error
 
 	targets     []*EventTarget
@@ -67,6 +68,16 @@ func (e *EventListener) RemoveHandler(target *EventTarget) error {
 	return fmt.Errorf("Couldn't find this function and event types combination")
 }
 
+// DisableReconnect makes the listener get disconnected as soon as the
+// connection to LXD is lost, rather than have the event stream resume where
+// it was left once LXD is reachable again
+func (e *EventListener) DisableReconnect() {
+	e.r.eventListenersLock.Lock()
+	defer e.r.eventListenersLock.Unlock()
+
+	e.reconnect = false
+}
+
 // Disconnect must be used once done listening for events
 func (e *EventListener) Disconnect() {
 	if e.disconnected {
@@ -74,6 +74,7 @@ type ContainerServer interface {
 	DeleteContainerConsoleLog(containerName string, args *ContainerConsoleLogArgs) (err error)
 
 	GetContainerFile(containerName string, path string) (content io.ReadCloser, resp *ContainerFileResponse, err error)
+	GetContainerFileNoFollow(containerName string, path string) (content io.ReadCloser, resp *ContainerFileResponse, err error)
 	CreateContainerFile(containerName string, path string, args ContainerFileArgs) (err error)
 	DeleteContainerFile(containerName string, path string) (err error)
 
@@ -86,9 +87,21 @@ type ContainerServer interface {
 	MigrateContainerSnapshot(containerName string, name string, container api.ContainerSnapshotPost) (op *Operation, err error)
 	DeleteContainerSnapshot(containerName string, name string) (op *Operation, err error)
 
+	GetContainerBackupNames(containerName string) (names []string, err error)
+	GetContainerBackups(containerName string) (backups []api.ContainerBackup, err error)
+	GetContainerBackup(containerName string, name string) (backup *api.ContainerBackup, ETag string, err error)
+	CreateContainerBackup(containerName string, backup api.ContainerBackupsPost) (op *Operation, err error)
+	RenameContainerBackup(containerName string, name string, backup api.ContainerBackupPost) (err error)
+	DeleteContainerBackup(containerName string, name string) (err error)
+	GetContainerBackupFile(containerName string, name string, req *BackupFileRequest) (resp *BackupFileResponse, err error)
+	CreateContainerFromBackup(args ContainerBackupArgs) (op *Operation, err error)
+
 	GetContainerState(name string) (state *api.ContainerState, ETag string, err error)
 	UpdateContainerState(name string, state api.ContainerStatePut, ETag string) (op *Operation, err error)
 
+	GetContainerProcesses(name string) (processes []api.ContainerProcess, err error)
+	SignalContainerProcess(name string, signal api.ContainerProcessSignalPost) (err error)
+
 	GetContainerLogfiles(name string) (logfiles []string, err error)
 	GetContainerLogfile(name string, filename string) (content io.ReadCloser, err error)
 	DeleteContainerLogfile(name string, filename string) (err error)
@@ -127,6 +140,15 @@ type ContainerServer interface {
 	RenameNetwork(name string, network api.NetworkPost) (err error)
 	DeleteNetwork(name string) (err error)
 
+	// Network ACL functions ("network_acl" API extension)
+	GetNetworkACLNames() (names []string, err error)
+	GetNetworkACLs() (acls []api.NetworkACL, err error)
+	GetNetworkACL(name string) (acl *api.NetworkACL, ETag string, err error)
+	CreateNetworkACL(acl api.NetworkACLsPost) (err error)
+	UpdateNetworkACL(name string, acl api.NetworkACLPut, ETag string) (err error)
+	RenameNetworkACL(name string, acl api.NetworkACLPost) (err error)
+	DeleteNetworkACL(name string) (err error)
+
 	// Operation functions
 	GetOperationUUIDs() (uuids []string, err error)
 	GetOperations() (operations []api.Operation, err error)
@@ -163,6 +185,14 @@ type ContainerServer interface {
 	CopyStoragePoolVolume(pool string, source ContainerServer, sourcePool string, volume api.StorageVolume, args *StoragePoolVolumeCopyArgs) (op *RemoteOperation, err error)
 	MoveStoragePoolVolume(pool string, source ContainerServer, sourcePool string, volume api.StorageVolume, args *StoragePoolVolumeMoveArgs) (op *RemoteOperation, err error)
 
+	// Storage volume snapshot functions ("storage_api_volume_snapshots" API extension)
+	GetStoragePoolVolumeSnapshotNames(pool string, volType string, volName string) (names []string, err error)
+	GetStoragePoolVolumeSnapshots(pool string, volType string, volName string) (snapshots []api.StorageVolumeSnapshot, err error)
+	GetStoragePoolVolumeSnapshot(pool string, volType string, volName string, name string) (snapshot *api.StorageVolumeSnapshot, ETag string, err error)
+	CreateStoragePoolVolumeSnapshot(pool string, volType string, volName string, snapshot api.StorageVolumeSnapshotsPost) (op *Operation, err error)
+	RenameStoragePoolVolumeSnapshot(pool string, volType string, volName string, name string, snapshot api.StorageVolumeSnapshotPost) (err error)
+	DeleteStoragePoolVolumeSnapshot(pool string, volType string, volName string, name string) (err error)
+
 	// Internal functions (for internal use)
 	RawQuery(method string, path string, data interface{}, queryETag string) (resp *api.Response, ETag string, err error)
 	RawWebsocket(path string) (conn *websocket.Conn, err error)
@@ -268,6 +298,34 @@ type ContainerCopyArgs struct {
 
 	// The transfer mode, can be "pull" (default), "push" or "relay"
 	Mode string
+
+	// If set, an existing target container is refreshed, only transferring
+	// its missing snapshots and the differences of its filesystem
+	Refresh bool
+}
+
+// The ContainerBackupArgs struct is used when creating a container from a backup
+type ContainerBackupArgs struct {
+	// The backup file
+	BackupFile io.Reader
+}
+
+// The BackupFileRequest struct is used for a backup download request
+type BackupFileRequest struct {
+	// Writer for the backup file
+	BackupFile io.WriteSeeker
+
+	// Progress handler (called whenever some progress is made)
+	ProgressHandler func(progress ioprogress.ProgressData)
+
+	// A canceler that can be used to interrupt some part of the backup download request
+	Canceler *cancel.Canceler
+}
+
+// The BackupFileResponse struct is used as the response for backup downloads
+type BackupFileResponse struct {
+	// Size of the backup file
+	Size int64
 }
 
 // The ContainerSnapshotCopyArgs struct is used to pass additional options during container copy
@@ -336,7 +394,7 @@ type ContainerFileArgs struct {
 	// File type (file or directory)
 	Type string
 
-	// File write mode (overwrite or append)
+	// File write mode (overwrite, append or atomic)
 	WriteMode string
 }
 
@@ -351,7 +409,7 @@ type ContainerFileResponse struct {
 	// File permissions
 	Mode int
 
-	// File type (file or directory)
+	// File type (file, directory or symlink)
 	Type string
 
 	// If a directory, the list of files inside it
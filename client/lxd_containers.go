@@ -6,12 +6,15 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 
 	"github.com/gorilla/websocket"
 
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/cancel"
+	"github.com/lxc/lxd/shared/ioprogress"
 )
 
 // Container handling functions
@@ -232,6 +235,16 @@ func (r *ProtocolLXD) CopyContainer(source ContainerServer, container api.Contai
 			return nil, fmt.Errorf("The source server is missing the required \"container_push_target\" API extension")
 		}
 
+		if args.Refresh {
+			if !r.HasExtension("container_incremental_copy") {
+				return nil, fmt.Errorf("The target server is missing the required \"container_incremental_copy\" API extension")
+			}
+
+			if !source.HasExtension("container_incremental_copy") {
+				return nil, fmt.Errorf("The source server is missing the required \"container_incremental_copy\" API extension")
+			}
+		}
+
 		// Allow overriding the target name
 		if args.Name != "" {
 			req.Name = args.Name
@@ -239,6 +252,7 @@ func (r *ProtocolLXD) CopyContainer(source ContainerServer, container api.Contai
 
 		req.Source.Live = args.Live
 		req.Source.ContainerOnly = args.ContainerOnly
+		req.Source.Refresh = args.Refresh
 	}
 
 	if req.Source.Live {
@@ -578,6 +592,12 @@ func (r *ProtocolLXD) ExecContainer(containerName string, exec api.ContainerExec
 		}
 	}
 
+	if exec.User > 0 || exec.Group > 0 || exec.Cwd != "" {
+		if !r.HasExtension("container_exec_user_group_cwd") {
+			return nil, fmt.Errorf("The server is missing the required \"container_exec_user_group_cwd\" API extension")
+		}
+	}
+
 	// Send the request
 	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/exec", url.QueryEscape(containerName)), exec, "")
 	if err != nil {
@@ -698,12 +718,30 @@ func (r *ProtocolLXD) ExecContainer(containerName string, exec api.ContainerExec
 	return op, nil
 }
 
-// GetContainerFile retrieves the provided path from the container
+// GetContainerFile retrieves the provided path from the container, following symlinks
 func (r *ProtocolLXD) GetContainerFile(containerName string, path string) (io.ReadCloser, *ContainerFileResponse, error) {
+	return r.getContainerFile(containerName, path, true)
+}
+
+// GetContainerFileNoFollow retrieves the provided path from the container, returning symlinks as such
+func (r *ProtocolLXD) GetContainerFileNoFollow(containerName string, path string) (io.ReadCloser, *ContainerFileResponse, error) {
+	if !r.HasExtension("file_symlinks") {
+		return nil, nil, fmt.Errorf("The server is missing the required \"file_symlinks\" API extension")
+	}
+
+	return r.getContainerFile(containerName, path, false)
+}
+
+func (r *ProtocolLXD) getContainerFile(containerName string, path string, follow bool) (io.ReadCloser, *ContainerFileResponse, error) {
+	query := map[string]string{"path": path}
+	if !follow {
+		query["follow"] = "false"
+	}
+
 	// Prepare the HTTP request
 	requestURL, err := shared.URLEncode(
 		fmt.Sprintf("%s/1.0/containers/%s/files", r.httpHost, url.QueryEscape(containerName)),
-		map[string]string{"path": path})
+		query)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -728,6 +766,11 @@ func (r *ProtocolLXD) GetContainerFile(containerName string, path string) (io.Re
 	if resp.StatusCode != http.StatusOK {
 		_, _, err := r.parseResponse(resp)
 		if err != nil {
+			// Let callers tell a missing path apart from other failures
+			if resp.StatusCode == http.StatusNotFound {
+				return nil, nil, &os.PathError{Op: "get", Path: path, Err: os.ErrNotExist}
+			}
+
 			return nil, nil, err
 		}
 	}
@@ -786,6 +829,12 @@ func (r *ProtocolLXD) CreateContainerFile(containerName string, path string, arg
 		}
 	}
 
+	if args.WriteMode == "atomic" {
+		if !r.HasExtension("file_atomic_write") {
+			return fmt.Errorf("The server is missing the required \"file_atomic_write\" API extension")
+		}
+	}
+
 	// Prepare the HTTP request
 	req, err := http.NewRequest("POST", fmt.Sprintf("%s/1.0/containers/%s/files?path=%s", r.httpHost, url.QueryEscape(containerName), url.QueryEscape(path)), args.Content)
 	if err != nil {
@@ -1180,6 +1229,222 @@ func (r *ProtocolLXD) DeleteContainerSnapshot(containerName string, name string)
 	return op, nil
 }
 
+// GetContainerBackupNames returns a list of backup names for the container
+func (r *ProtocolLXD) GetContainerBackupNames(containerName string) ([]string, error) {
+	if !r.HasExtension("container_backup") {
+		return nil, fmt.Errorf("The server is missing the required \"container_backup\" API extension")
+	}
+
+	urls := []string{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/backups", url.QueryEscape(containerName)), nil, "", &urls)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse it
+	names := []string{}
+	for _, uri := range urls {
+		fields := strings.Split(uri, fmt.Sprintf("/containers/%s/backups/", url.QueryEscape(containerName)))
+		names = append(names, fields[len(fields)-1])
+	}
+
+	return names, nil
+}
+
+// GetContainerBackups returns a list of backups for the container
+func (r *ProtocolLXD) GetContainerBackups(containerName string) ([]api.ContainerBackup, error) {
+	if !r.HasExtension("container_backup") {
+		return nil, fmt.Errorf("The server is missing the required \"container_backup\" API extension")
+	}
+
+	backups := []api.ContainerBackup{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/backups?recursion=1", url.QueryEscape(containerName)), nil, "", &backups)
+	if err != nil {
+		return nil, err
+	}
+
+	return backups, nil
+}
+
+// GetContainerBackup returns a Backup struct for the provided container and backup names
+func (r *ProtocolLXD) GetContainerBackup(containerName string, name string) (*api.ContainerBackup, string, error) {
+	if !r.HasExtension("container_backup") {
+		return nil, "", fmt.Errorf("The server is missing the required \"container_backup\" API extension")
+	}
+
+	backup := api.ContainerBackup{}
+
+	// Fetch the raw value
+	etag, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/backups/%s", url.QueryEscape(containerName), url.QueryEscape(name)), nil, "", &backup)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &backup, etag, nil
+}
+
+// CreateContainerBackup requests that LXD creates a new backup for the container
+func (r *ProtocolLXD) CreateContainerBackup(containerName string, backup api.ContainerBackupsPost) (*Operation, error) {
+	if !r.HasExtension("container_backup") {
+		return nil, fmt.Errorf("The server is missing the required \"container_backup\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/backups", url.QueryEscape(containerName)), backup, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// RenameContainerBackup requests that LXD renames the backup
+func (r *ProtocolLXD) RenameContainerBackup(containerName string, name string, backup api.ContainerBackupPost) error {
+	if !r.HasExtension("container_backup") {
+		return fmt.Errorf("The server is missing the required \"container_backup\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("POST", fmt.Sprintf("/containers/%s/backups/%s", url.QueryEscape(containerName), url.QueryEscape(name)), backup, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteContainerBackup requests that LXD deletes the container backup
+func (r *ProtocolLXD) DeleteContainerBackup(containerName string, name string) error {
+	if !r.HasExtension("container_backup") {
+		return fmt.Errorf("The server is missing the required \"container_backup\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("DELETE", fmt.Sprintf("/containers/%s/backups/%s", url.QueryEscape(containerName), url.QueryEscape(name)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetContainerBackupFile requests the container backup content
+func (r *ProtocolLXD) GetContainerBackupFile(containerName string, name string, req *BackupFileRequest) (*BackupFileResponse, error) {
+	if !r.HasExtension("container_backup") {
+		return nil, fmt.Errorf("The server is missing the required \"container_backup\" API extension")
+	}
+
+	// Build the URL
+	uri := fmt.Sprintf("%s/1.0/containers/%s/backups/%s/export", r.httpHost, url.QueryEscape(containerName), url.QueryEscape(name))
+
+	// Prepare the download request
+	request, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.httpUserAgent != "" {
+		request.Header.Set("User-Agent", r.httpUserAgent)
+	}
+
+	// Start the request
+	response, doneCh, err := cancel.CancelableDownload(req.Canceler, r.http, request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	defer close(doneCh)
+
+	if response.StatusCode != http.StatusOK {
+		_, _, err := r.parseResponse(response)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Handle the data
+	body := response.Body
+	if req.ProgressHandler != nil {
+		body = &ioprogress.ProgressReader{
+			ReadCloser: response.Body,
+			Tracker: &ioprogress.ProgressTracker{
+				Length: response.ContentLength,
+				Handler: func(percent int64, speed int64) {
+					req.ProgressHandler(ioprogress.ProgressData{Text: fmt.Sprintf("%d%% (%s/s)", percent, shared.GetByteSizeString(speed, 2))})
+				},
+			},
+		}
+	}
+
+	size, err := io.Copy(req.BackupFile, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := BackupFileResponse{}
+	resp.Size = size
+
+	return &resp, nil
+}
+
+// CreateContainerFromBackup is a convenience function to make it easier to
+// create a container from a backup
+func (r *ProtocolLXD) CreateContainerFromBackup(args ContainerBackupArgs) (*Operation, error) {
+	if !r.HasExtension("container_backup") {
+		return nil, fmt.Errorf("The server is missing the required \"container_backup\" API extension")
+	}
+
+	if args.BackupFile == nil {
+		return nil, fmt.Errorf("Backup file is required")
+	}
+
+	// Prepare the HTTP request
+	reqURL := fmt.Sprintf("%s/1.0/containers", r.httpHost)
+	req, err := http.NewRequest("POST", reqURL, args.BackupFile)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	// Set the user agent
+	if r.httpUserAgent != "" {
+		req.Header.Set("User-Agent", r.httpUserAgent)
+	}
+
+	// Send the request
+	resp, err := r.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Handle errors
+	response, _, err := r.parseResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get to the operation
+	respOperation, err := response.MetadataAsOperation()
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup an Operation wrapper
+	op := Operation{
+		Operation: *respOperation,
+		r:         r,
+		chActive:  make(chan bool),
+	}
+
+	return &op, nil
+}
+
 // GetContainerState returns a ContainerState entry for the provided container name
 func (r *ProtocolLXD) GetContainerState(name string) (*api.ContainerState, string, error) {
 	state := api.ContainerState{}
@@ -1515,3 +1780,35 @@ func (r *ProtocolLXD) DeleteContainerConsoleLog(containerName string, args *Cont
 
 	return nil
 }
+
+// GetContainerProcesses returns the processes running inside the container
+func (r *ProtocolLXD) GetContainerProcesses(name string) ([]api.ContainerProcess, error) {
+	if !r.HasExtension("container_processes") {
+		return nil, fmt.Errorf("The server is missing the required \"container_processes\" API extension")
+	}
+
+	processes := []api.ContainerProcess{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/processes", url.QueryEscape(name)), nil, "", &processes)
+	if err != nil {
+		return nil, err
+	}
+
+	return processes, nil
+}
+
+// SignalContainerProcess sends a signal to a process running inside the container
+func (r *ProtocolLXD) SignalContainerProcess(name string, signal api.ContainerProcessSignalPost) error {
+	if !r.HasExtension("container_processes") {
+		return fmt.Errorf("The server is missing the required \"container_processes\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("POST", fmt.Sprintf("/containers/%s/processes", url.QueryEscape(name)), signal, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
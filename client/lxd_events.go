@@ -2,6 +2,11 @@ package lxd
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
 
 	"github.com/lxc/lxd/shared"
 )
@@ -9,6 +14,10 @@ import (
 // Event handling functions
 
 // GetEvents connects to the LXD monitoring interface
+//
+// If LXD supports the event_history extension, the listener reconnects on
+// its own when the connection is lost and gets the events it missed, unless
+// DisableReconnect is called.
 func (r *ProtocolLXD) GetEvents() (*EventListener, error) {
 	// Prevent anything else from interacting with the listeners
 	r.eventListenersLock.Lock()
@@ -16,8 +25,9 @@ func (r *ProtocolLXD) GetEvents() (*EventListener, error) {
 
 	// Setup a new listener
 	listener := EventListener{
-		r:        r,
-		chActive: make(chan bool),
+		r:         r,
+		chActive:  make(chan bool),
+		reconnect: true,
 	}
 
 	if r.eventListeners != nil {
@@ -40,6 +50,10 @@ func (r *ProtocolLXD) GetEvents() (*EventListener, error) {
 
 	// And spawn the listener
 	go func() {
+		// Last event received, used to resume after a reconnection
+		lastID := int64(0)
+		bootID := ""
+
 		for {
 			r.eventListenersLock.Lock()
 			if len(r.eventListeners) == 0 {
@@ -54,19 +68,25 @@ func (r *ProtocolLXD) GetEvents() (*EventListener, error) {
 
 			_, data, err := conn.ReadMessage()
 			if err != nil {
-				// Prevent anything else from interacting with the listeners
-				r.eventListenersLock.Lock()
-				defer r.eventListenersLock.Unlock()
-
-				// Tell all the current listeners about the failure
-				for _, listener := range r.eventListeners {
-					listener.err = err
-					listener.disconnected = true
-					close(listener.chActive)
+				conn.Close()
+
+				// Listeners which opted out of reconnecting are told
+				// right away, the others get the event stream resumed
+				// where it was left
+				if r.HasExtension("event_history") && r.eventsDisconnect(err, false) {
+					var newConn *websocket.Conn
+					newConn, err = r.eventsReconnect(lastID, bootID)
+					if newConn != nil {
+						conn = newConn
+						continue
+					}
+
+					if err == nil {
+						return
+					}
 				}
 
-				// And remove them all from the list
-				r.eventListeners = []*EventListener{}
+				r.eventsDisconnect(err, true)
 				return
 			}
 
@@ -84,6 +104,17 @@ func (r *ProtocolLXD) GetEvents() (*EventListener, error) {
 			}
 			messageType := message["type"].(string)
 
+			// Keep track of the last event
+			id, ok := message["id"].(float64)
+			if ok {
+				lastID = int64(id)
+			}
+
+			boot, ok := message["boot_id"].(string)
+			if ok {
+				bootID = boot
+			}
+
 			// Send the message to all handlers
 			r.eventListenersLock.Lock()
 			for _, listener := range r.eventListeners {
@@ -103,3 +134,73 @@ func (r *ProtocolLXD) GetEvents() (*EventListener, error) {
 
 	return &listener, nil
 }
+
+// eventsDisconnect tells the listeners that the connection failed and removes
+// them. Unless all is set, the listeners which didn't opt out of reconnecting
+// are kept. It returns whether any listener is left.
+func (r *ProtocolLXD) eventsDisconnect(err error, all bool) bool {
+	r.eventListenersLock.Lock()
+	defer r.eventListenersLock.Unlock()
+
+	remaining := []*EventListener{}
+	for _, listener := range r.eventListeners {
+		if listener.reconnect && !all {
+			remaining = append(remaining, listener)
+			continue
+		}
+
+		listener.err = err
+		listener.disconnected = true
+		close(listener.chActive)
+	}
+
+	if len(remaining) == 0 {
+		r.eventListeners = nil
+		return false
+	}
+
+	r.eventListeners = remaining
+	return true
+}
+
+// eventsReconnect tries to re-establish the event websocket, asking LXD to
+// replay any event more recent than the last one received. It returns a nil
+// connection and error if all the listeners went away in the meantime.
+func (r *ProtocolLXD) eventsReconnect(lastID int64, bootID string) (*websocket.Conn, error) {
+	path := "/events"
+	if lastID > 0 {
+		path = fmt.Sprintf("/events?since=%d&boot_id=%s", lastID, url.QueryEscape(bootID))
+	}
+
+	var err error
+	delay := time.Second
+	for i := 0; i < 10; i++ {
+		time.Sleep(delay)
+
+		// Give up if nobody is listening anymore
+		r.eventListenersLock.Lock()
+		if len(r.eventListeners) == 0 {
+			r.eventListeners = nil
+			r.eventListenersLock.Unlock()
+			return nil, nil
+		}
+		r.eventListenersLock.Unlock()
+
+		var conn *websocket.Conn
+		conn, err = r.websocket(path)
+		if err == nil {
+			return conn, nil
+		}
+
+		// LXD is back but refused to resume, some events were lost
+		if err == websocket.ErrBadHandshake {
+			return nil, fmt.Errorf("Failed to resume the event stream, events were missed")
+		}
+
+		if delay < 30*time.Second {
+			delay *= 2
+		}
+	}
+
+	return nil, err
+}
@@ -202,3 +202,107 @@ func (r *ProtocolLXD) RenameStoragePoolVolume(pool string, volType string, name
 
 	return nil
 }
+
+// GetStoragePoolVolumeSnapshotNames returns the names of all snapshots of a storage volume
+func (r *ProtocolLXD) GetStoragePoolVolumeSnapshotNames(pool string, volType string, volName string) ([]string, error) {
+	if !r.HasExtension("storage_api_volume_snapshots") {
+		return nil, fmt.Errorf("The server is missing the required \"storage_api_volume_snapshots\" API extension")
+	}
+
+	urls := []string{}
+
+	// Fetch the raw value
+	path := fmt.Sprintf("/storage-pools/%s/volumes/%s/%s/snapshots", url.QueryEscape(pool), url.QueryEscape(volType), url.QueryEscape(volName))
+	_, err := r.queryStruct("GET", path, nil, "", &urls)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse it
+	names := []string{}
+	for _, uri := range urls {
+		fields := strings.Split(uri, path+"/")
+		names = append(names, fields[len(fields)-1])
+	}
+
+	return names, nil
+}
+
+// GetStoragePoolVolumeSnapshots returns a list of snapshots for the storage volume
+func (r *ProtocolLXD) GetStoragePoolVolumeSnapshots(pool string, volType string, volName string) ([]api.StorageVolumeSnapshot, error) {
+	if !r.HasExtension("storage_api_volume_snapshots") {
+		return nil, fmt.Errorf("The server is missing the required \"storage_api_volume_snapshots\" API extension")
+	}
+
+	snapshots := []api.StorageVolumeSnapshot{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/storage-pools/%s/volumes/%s/%s/snapshots?recursion=1", url.QueryEscape(pool), url.QueryEscape(volType), url.QueryEscape(volName)), nil, "", &snapshots)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+// GetStoragePoolVolumeSnapshot returns a snapshot for the storage volume
+func (r *ProtocolLXD) GetStoragePoolVolumeSnapshot(pool string, volType string, volName string, name string) (*api.StorageVolumeSnapshot, string, error) {
+	if !r.HasExtension("storage_api_volume_snapshots") {
+		return nil, "", fmt.Errorf("The server is missing the required \"storage_api_volume_snapshots\" API extension")
+	}
+
+	snapshot := api.StorageVolumeSnapshot{}
+
+	// Fetch the raw value
+	etag, err := r.queryStruct("GET", fmt.Sprintf("/storage-pools/%s/volumes/%s/%s/snapshots/%s", url.QueryEscape(pool), url.QueryEscape(volType), url.QueryEscape(volName), url.QueryEscape(name)), nil, "", &snapshot)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &snapshot, etag, nil
+}
+
+// CreateStoragePoolVolumeSnapshot requests that LXD creates a new snapshot of the storage volume
+func (r *ProtocolLXD) CreateStoragePoolVolumeSnapshot(pool string, volType string, volName string, snapshot api.StorageVolumeSnapshotsPost) (*Operation, error) {
+	if !r.HasExtension("storage_api_volume_snapshots") {
+		return nil, fmt.Errorf("The server is missing the required \"storage_api_volume_snapshots\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("/storage-pools/%s/volumes/%s/%s/snapshots", url.QueryEscape(pool), url.QueryEscape(volType), url.QueryEscape(volName)), snapshot, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// RenameStoragePoolVolumeSnapshot renames a snapshot of the storage volume
+func (r *ProtocolLXD) RenameStoragePoolVolumeSnapshot(pool string, volType string, volName string, name string, snapshot api.StorageVolumeSnapshotPost) error {
+	if !r.HasExtension("storage_api_volume_snapshots") {
+		return fmt.Errorf("The server is missing the required \"storage_api_volume_snapshots\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("POST", fmt.Sprintf("/storage-pools/%s/volumes/%s/%s/snapshots/%s", url.QueryEscape(pool), url.QueryEscape(volType), url.QueryEscape(volName), url.QueryEscape(name)), snapshot, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteStoragePoolVolumeSnapshot deletes a snapshot of the storage volume
+func (r *ProtocolLXD) DeleteStoragePoolVolumeSnapshot(pool string, volType string, volName string, name string) error {
+	if !r.HasExtension("storage_api_volume_snapshots") {
+		return fmt.Errorf("The server is missing the required \"storage_api_volume_snapshots\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("DELETE", fmt.Sprintf("/storage-pools/%s/volumes/%s/%s/snapshots/%s", url.QueryEscape(pool), url.QueryEscape(volType), url.QueryEscape(volName), url.QueryEscape(name)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"syscall"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxc/config"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/gnuflag"
+	"github.com/lxc/lxd/shared/i18n"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/termios"
+)
+
+type consoleCmd struct {
+	showLog bool
+}
+
+func (c *consoleCmd) showByDefault() bool {
+	return true
+}
+
+func (c *consoleCmd) usage() string {
+	return i18n.G(
+		`Usage: lxc console [<remote>:]<container> [--show-log]
+
+Attach to container consoles.
+
+This command allows you to interact with the boot console of a container
+as well as retrieve past log entries from it.
+
+To detach from the console, press <ctrl>+a q.`)
+}
+
+func (c *consoleCmd) flags() {
+	gnuflag.BoolVar(&c.showLog, "show-log", false, i18n.G("Retrieve the container's console log"))
+}
+
+func (c *consoleCmd) sendTermSize(control *websocket.Conn) error {
+	width, height, err := termios.GetSize(int(syscall.Stdout))
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf("Window size is now: %dx%d", width, height)
+
+	w, err := control.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+
+	msg := api.ContainerConsoleControl{}
+	msg.Command = "window-resize"
+	msg.Args = make(map[string]string)
+	msg.Args["width"] = strconv.Itoa(width)
+	msg.Args["height"] = strconv.Itoa(height)
+
+	buf, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+
+	w.Close()
+	return err
+}
+
+type readWriteCloser struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// stdinMirror forwards the user input to the console, looking for the
+// <ctrl>+a q detach sequence.
+type stdinMirror struct {
+	r                 io.Reader
+	consoleDisconnect chan<- bool
+	foundEscape       *bool
+}
+
+// The terminal is in raw mode so we only ever read a single byte at a time
+func (er stdinMirror) Read(p []byte) (int, error) {
+	n, err := er.r.Read(p)
+	if n == 0 {
+		return n, err
+	}
+
+	v := rune(p[0])
+	if v == '\u0001' && !*er.foundEscape {
+		*er.foundEscape = true
+		return 0, err
+	}
+
+	if v == 'q' && *er.foundEscape {
+		select {
+		case er.consoleDisconnect <- true:
+			return 0, err
+		default:
+			return 0, err
+		}
+	}
+
+	*er.foundEscape = false
+	return n, err
+}
+
+func (c *consoleCmd) run(conf *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errArgs
+	}
+
+	remote, name, err := conf.ParseRemote(args[0])
+	if err != nil {
+		return err
+	}
+
+	d, err := conf.GetContainerServer(remote)
+	if err != nil {
+		return err
+	}
+
+	// Show the console log
+	if c.showLog {
+		log, err := d.GetContainerConsoleLog(name, &lxd.ContainerConsoleLogArgs{})
+		if err != nil {
+			return err
+		}
+		defer log.Close()
+
+		_, err = io.Copy(os.Stdout, log)
+		return err
+	}
+
+	// Attach to the console
+	cfd := int(syscall.Stdin)
+	if !termios.IsTerminal(cfd) {
+		return fmt.Errorf(i18n.G("The console can only be attached to from a terminal"))
+	}
+
+	oldttystate, err := termios.MakeRaw(cfd)
+	if err != nil {
+		return err
+	}
+	defer termios.Restore(cfd, oldttystate)
+
+	width, height, err := termios.GetSize(int(syscall.Stdout))
+	if err != nil {
+		return err
+	}
+
+	req := api.ContainerConsolePost{
+		Width:  width,
+		Height: height,
+	}
+
+	consoleDisconnect := make(chan bool)
+	sendDisconnect := make(chan bool)
+	defer close(sendDisconnect)
+
+	consoleArgs := lxd.ContainerConsoleArgs{
+		Terminal: &readWriteCloser{stdinMirror{os.Stdin,
+			sendDisconnect, new(bool)}, os.Stdout},
+		Control:           c.controlSocketHandler,
+		ConsoleDisconnect: consoleDisconnect,
+	}
+
+	go func() {
+		<-sendDisconnect
+		close(consoleDisconnect)
+	}()
+
+	fmt.Printf(i18n.G("To detach from the console, press: <ctrl>+a q") + "\n\r")
+
+	op, err := d.ConsoleContainer(name, req, &consoleArgs)
+	if err != nil {
+		return err
+	}
+
+	return op.Wait()
+}
@@ -0,0 +1,26 @@
+// +build windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lxc/lxd/shared/logger"
+)
+
+func (c *consoleCmd) controlSocketHandler(control *websocket.Conn) {
+	ch := make(chan os.Signal, 10)
+	signal.Notify(ch, os.Interrupt)
+
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	defer control.WriteMessage(websocket.CloseMessage, closeMsg)
+
+	for {
+		sig := <-ch
+
+		logger.Debugf("Received '%s signal', updating window geometry.", sig)
+	}
+}
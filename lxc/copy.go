@@ -13,7 +13,8 @@ import (
 )
 
 type copyCmd struct {
-	ephem bool
+	ephem   bool
+	refresh bool
 }
 
 func (c *copyCmd) showByDefault() bool {
@@ -22,14 +23,19 @@ func (c *copyCmd) showByDefault() bool {
 
 func (c *copyCmd) usage() string {
 	return i18n.G(
-		`Usage: lxc copy [<remote>:]<source>[/<snapshot>] [[<remote>:]<destination>] [--ephemeral|e]
+		`Usage: lxc copy [<remote>:]<source>[/<snapshot>] [[<remote>:]<destination>] [--ephemeral|e] [--refresh]
 
-Copy containers within or in between LXD instances.`)
+Copy containers within or in between LXD instances.
+
+When using --refresh, an existing destination container is updated rather
+than re-created: only its missing snapshots and the differences of its
+filesystem are transferred.`)
 }
 
 func (c *copyCmd) flags() {
 	gnuflag.BoolVar(&c.ephem, "ephemeral", false, i18n.G("Ephemeral container"))
 	gnuflag.BoolVar(&c.ephem, "e", false, i18n.G("Ephemeral container"))
+	gnuflag.BoolVar(&c.refresh, "refresh", false, i18n.G("Only transfer the differences with an existing container"))
 }
 
 func (c *copyCmd) copyContainer(conf *config.Config, sourceResource string, destResource string, keepVolatile bool, ephemeral int) error {
@@ -76,6 +82,10 @@ func (c *copyCmd) copyContainer(conf *config.Config, sourceResource string, dest
 
 	var op *lxd.RemoteOperation
 	if shared.IsSnapshot(sourceName) {
+		if c.refresh {
+			return fmt.Errorf(i18n.G("--refresh can only be used with containers"))
+		}
+
 		// Prepare the container creation request
 		args := lxd.ContainerSnapshotCopyArgs{
 			Name: destName,
@@ -116,7 +126,8 @@ func (c *copyCmd) copyContainer(conf *config.Config, sourceResource string, dest
 	} else {
 		// Prepare the container creation request
 		args := lxd.ContainerCopyArgs{
-			Name: destName,
+			Name:    destName,
+			Refresh: c.refresh,
 		}
 
 		// Copy of a container into a new container
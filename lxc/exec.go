@@ -37,6 +37,9 @@ func (f *envList) Set(value string) error {
 type execCmd struct {
 	modeFlag string
 	envArgs  envList
+	cwd      string
+	user     uint
+	group    uint
 }
 
 func (c *execCmd) showByDefault() bool {
@@ -45,7 +48,7 @@ func (c *execCmd) showByDefault() bool {
 
 func (c *execCmd) usage() string {
 	return i18n.G(
-		`Usage: lxc exec [<remote>:]<container> [--mode=auto|interactive|non-interactive] [--env KEY=VALUE...] [--] <command line>
+		`Usage: lxc exec [<remote>:]<container> [--mode=auto|interactive|non-interactive] [--env KEY=VALUE...] [--cwd=PATH] [--user=UID] [--group=GID] [--] <command line>
 
 Execute commands in containers.
 
@@ -61,6 +64,9 @@ Mode defaults to non-interactive, interactive mode is selected if both stdin AND
 func (c *execCmd) flags() {
 	gnuflag.Var(&c.envArgs, "env", i18n.G("Environment variable to set (e.g. HOME=/home/foo)"))
 	gnuflag.StringVar(&c.modeFlag, "mode", "auto", i18n.G("Override the terminal mode (auto, interactive or non-interactive)"))
+	gnuflag.StringVar(&c.cwd, "cwd", "", i18n.G("Directory to run the command in (default /root)"))
+	gnuflag.UintVar(&c.user, "user", 0, i18n.G("User ID to run the command as (default 0)"))
+	gnuflag.UintVar(&c.group, "group", 0, i18n.G("Group ID to run the command as (default 0)"))
 }
 
 func (c *execCmd) sendTermSize(control *websocket.Conn) error {
@@ -166,6 +172,9 @@ func (c *execCmd) run(conf *config.Config, args []string) error {
 		Environment: env,
 		Width:       width,
 		Height:      height,
+		Cwd:         c.cwd,
+		User:        uint32(c.user),
+		Group:       uint32(c.group),
 	}
 
 	execArgs := lxd.ContainerExecArgs{
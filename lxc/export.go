@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxc/config"
+	"github.com/lxc/lxd/lxc/utils"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/gnuflag"
+	"github.com/lxc/lxd/shared/i18n"
+)
+
+type exportCmd struct {
+	containerOnly    bool
+	optimizedStorage bool
+}
+
+func (c *exportCmd) showByDefault() bool {
+	return true
+}
+
+func (c *exportCmd) usage() string {
+	return i18n.G(
+		`Usage: lxc export [<remote>:]<container> [target] [--container-only] [--optimized-storage]
+
+Export containers as backup tarballs.
+
+The tarball holds the container configuration along with all its snapshots
+and can be restored with "lxc import".
+
+*Examples*
+lxc export u1 backup0.tar.gz
+    Download a backup tarball of the u1 container.`)
+}
+
+func (c *exportCmd) flags() {
+	gnuflag.BoolVar(&c.containerOnly, "container-only", false, i18n.G("Whether or not to only backup the container (without snapshots)"))
+	gnuflag.BoolVar(&c.optimizedStorage, "optimized-storage", false, i18n.G("Use storage driver optimized format (can only be restored on a similar pool)"))
+}
+
+func (c *exportCmd) run(conf *config.Config, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errArgs
+	}
+
+	remote, name, err := conf.ParseRemote(args[0])
+	if err != nil {
+		return err
+	}
+
+	d, err := conf.GetContainerServer(remote)
+	if err != nil {
+		return err
+	}
+
+	req := api.ContainerBackupsPost{
+		Name:             "",
+		ExpiryDate:       time.Now().Add(24 * time.Hour),
+		ContainerOnly:    c.containerOnly,
+		OptimizedStorage: c.optimizedStorage,
+	}
+
+	op, err := d.CreateContainerBackup(name, req)
+	if err != nil {
+		return fmt.Errorf(i18n.G("Create backup: %v"), err)
+	}
+
+	err = op.Wait()
+	if err != nil {
+		return err
+	}
+
+	// Get name of backup
+	backupName := path.Base(op.Resources["backups"][0])
+
+	// Delete the backup from the server once done
+	defer d.DeleteContainerBackup(name, backupName)
+
+	var targetName string
+	if len(args) > 1 {
+		targetName = args[1]
+	} else {
+		targetName = "backup.tar.gz"
+	}
+
+	target, err := os.Create(shared.HostPath(targetName))
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	// Prepare the download request
+	progress := utils.ProgressRenderer{Format: i18n.G("Exporting the backup: %s")}
+	backupFileRequest := lxd.BackupFileRequest{
+		BackupFile:      io.WriteSeeker(target),
+		ProgressHandler: progress.UpdateProgress,
+	}
+
+	// Export tarball
+	_, err = d.GetContainerBackupFile(name, backupName, &backupFileRequest)
+	if err != nil {
+		os.Remove(targetName)
+		progress.Done("")
+		return fmt.Errorf(i18n.G("Fetch container backup file: %v"), err)
+	}
+
+	progress.Done(i18n.G("Backup exported successfully!"))
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -24,6 +25,12 @@ type fileCmd struct {
 	uid  int
 	gid  int
 	mode string
+
+	recursive bool
+	mkdirs    bool
+
+	// Write mode of the pushed files, only set by edit
+	writeMode string
 }
 
 func (c *fileCmd) showByDefault() bool {
@@ -36,12 +43,15 @@ func (c *fileCmd) usage() string {
 
 Manage files in containers.
 
-lxc file pull [<remote>:]<container>/<path> [[<remote>:]<container>/<path>...] <target path>
+lxc file pull [-r|--recursive] [-p|--create-dirs] [<remote>:]<container>/<path> [[<remote>:]<container>/<path>...] <target path>
     Pull files from containers.
 
-lxc file push [--uid=UID] [--gid=GID] [--mode=MODE] <source path> [<source path>...] [<remote>:]<container>/<path>
+lxc file push [-r|--recursive] [-p|--create-dirs] [--uid=UID] [--gid=GID] [--mode=MODE] <source path> [<source path>...] [<remote>:]<container>/<path>
     Push files into containers.
 
+lxc file delete [<remote>:]<container>/<path> [[<remote>:]<container>/<path>...]
+    Delete files in containers.
+
 lxc file edit [<remote>:]<container>/<path>
     Edit files in containers using the default text editor.
 
@@ -50,13 +60,177 @@ lxc file push /etc/hosts foo/etc/hosts
    To push /etc/hosts into the container "foo".
 
 lxc file pull foo/etc/hosts .
-   To pull /etc/hosts from the container and write it to the current directory.`)
+   To pull /etc/hosts from the container and write it to the current directory.
+
+lxc file push -r -p ./config foo/etc/myapp
+   To push the ./config directory into the container "foo" as /etc/myapp/config,
+   creating /etc/myapp if needed.`)
 }
 
 func (c *fileCmd) flags() {
 	gnuflag.IntVar(&c.uid, "uid", -1, i18n.G("Set the file's uid on push"))
 	gnuflag.IntVar(&c.gid, "gid", -1, i18n.G("Set the file's gid on push"))
 	gnuflag.StringVar(&c.mode, "mode", "", i18n.G("Set the file's perms on push"))
+	gnuflag.BoolVar(&c.recursive, "recursive", false, i18n.G("Recursively transfer files"))
+	gnuflag.BoolVar(&c.recursive, "r", false, i18n.G("Recursively transfer files"))
+	gnuflag.BoolVar(&c.mkdirs, "create-dirs", false, i18n.G("Create any directories necessary"))
+	gnuflag.BoolVar(&c.mkdirs, "p", false, i18n.G("Create any directories necessary"))
+}
+
+func (c *fileCmd) recursiveMkdir(d lxd.ContainerServer, container string, p string, mode os.FileMode, uid int64, gid int64) error {
+	// Look for the deepest directory which already exists
+	missing := []string{}
+	for cur := path.Clean("/" + p); cur != "/"; cur = path.Dir(cur) {
+		buf, resp, err := d.GetContainerFile(container, cur)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+
+			missing = append([]string{cur}, missing...)
+			continue
+		}
+
+		if buf != nil {
+			buf.Close()
+		}
+
+		if resp.Type != "directory" {
+			return fmt.Errorf(i18n.G("%s is not a directory"), cur)
+		}
+
+		break
+	}
+
+	// And create the ones below it
+	for _, cur := range missing {
+		args := lxd.ContainerFileArgs{
+			UID:  uid,
+			GID:  gid,
+			Mode: int(mode.Perm()),
+			Type: "directory",
+		}
+
+		logger.Infof("Creating %s (%s)", cur, args.Type)
+		err := d.CreateContainerFile(container, cur, args)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *fileCmd) recursivePushFile(d lxd.ContainerServer, container string, source string, target string) error {
+	source = filepath.Clean(source)
+	sourceDir, _ := filepath.Split(source)
+	sourceLen := len(sourceDir)
+
+	sendFile := func(p string, fInfo os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf(i18n.G("Failed to walk path for %s: %s"), p, err)
+		}
+
+		// Detect unsupported files
+		if !fInfo.Mode().IsRegular() && !fInfo.Mode().IsDir() && fInfo.Mode()&os.ModeSymlink != os.ModeSymlink {
+			return fmt.Errorf(i18n.G("'%s' isn't a supported file type"), p)
+		}
+
+		// Prepare for file transfer
+		targetPath := path.Join(target, filepath.ToSlash(p[sourceLen:]))
+		mode, uid, gid := shared.GetOwnerMode(fInfo)
+		args := lxd.ContainerFileArgs{
+			UID:  int64(uid),
+			GID:  int64(gid),
+			Mode: int(mode.Perm()),
+		}
+
+		if fInfo.IsDir() {
+			args.Type = "directory"
+		} else if fInfo.Mode()&os.ModeSymlink == os.ModeSymlink {
+			// The content of a symlink is its target
+			symlinkTarget, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+
+			args.Type = "symlink"
+			args.Content = bytes.NewReader([]byte(symlinkTarget))
+		} else {
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			args.Type = "file"
+			args.Content = f
+		}
+
+		logger.Infof("Pushing %s to %s (%s)", p, targetPath, args.Type)
+		return d.CreateContainerFile(container, targetPath, args)
+	}
+
+	return filepath.Walk(source, sendFile)
+}
+
+func (c *fileCmd) recursivePullFile(d lxd.ContainerServer, container string, p string, targetDir string) error {
+	buf, resp, err := d.GetContainerFileNoFollow(container, p)
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(targetDir, path.Base(p))
+	logger.Infof("Pulling %s from %s (%s)", target, p, resp.Type)
+
+	switch resp.Type {
+	case "directory":
+		err := os.Mkdir(target, os.FileMode(resp.Mode))
+		if err != nil && !os.IsExist(err) {
+			return err
+		}
+
+		for _, ent := range resp.Entries {
+			err := c.recursivePullFile(d, container, path.Join(p, ent), target)
+			if err != nil {
+				return err
+			}
+		}
+	case "file":
+		defer buf.Close()
+
+		f, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		err = os.Chmod(target, os.FileMode(resp.Mode))
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(f, buf)
+		if err != nil {
+			return err
+		}
+	case "symlink":
+		defer buf.Close()
+
+		linkTarget, err := ioutil.ReadAll(buf)
+		if err != nil {
+			return err
+		}
+
+		err = os.Symlink(string(linkTarget), target)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf(i18n.G("Unknown file type '%s'"), resp.Type)
+	}
+
+	return nil
 }
 
 func (c *fileCmd) push(conf *config.Config, sendFilePerms bool, args []string) error {
@@ -114,6 +288,33 @@ func (c *fileCmd) push(conf *config.Config, sendFilePerms bool, args []string) e
 		}
 	}
 
+	// Recursive transfers always go into a directory
+	if c.recursive {
+		if c.uid != -1 || c.gid != -1 || c.mode != "" {
+			return fmt.Errorf(i18n.G("Can't supply uid/gid/mode in recursive mode"))
+		}
+
+		if c.mkdirs {
+			err := c.recursiveMkdir(d, container, targetPath, 0755, int64(uid), int64(gid))
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, fname := range sourcefilenames {
+			if fname == "-" {
+				return fmt.Errorf(i18n.G("Can't read from stdin in recursive mode"))
+			}
+
+			err := c.recursivePushFile(d, container, fname, targetPath)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
 	if (targetfilename != "") && (len(sourcefilenames) > 1) {
 		return errArgs
 	}
@@ -143,10 +344,12 @@ func (c *fileCmd) push(conf *config.Config, sendFilePerms bool, args []string) e
 		}
 
 		args := lxd.ContainerFileArgs{
-			Content: f,
-			UID:     -1,
-			GID:     -1,
-			Mode:    -1,
+			Content:   f,
+			UID:       -1,
+			GID:       -1,
+			Mode:      -1,
+			Type:      "file",
+			WriteMode: c.writeMode,
 		}
 
 		if sendFilePerms {
@@ -174,6 +377,13 @@ func (c *fileCmd) push(conf *config.Config, sendFilePerms bool, args []string) e
 			args.Mode = int(mode.Perm())
 		}
 
+		if c.mkdirs {
+			err := c.recursiveMkdir(d, container, path.Dir(fpath), 0755, args.UID, args.GID)
+			if err != nil {
+				return err
+			}
+		}
+
 		logger.Infof("Pushing %s to %s (%s)", f.Name(), fpath, args.Type)
 		err = d.CreateContainerFile(container, fpath, args)
 		if err != nil {
@@ -208,11 +418,19 @@ func (c *fileCmd) pull(conf *config.Config, args []string) error {
 		if !targetIsDir && len(args)-1 > 1 {
 			return fmt.Errorf(i18n.G("More than one file to download, but target is not a directory"))
 		}
-	} else if strings.HasSuffix(target, string(os.PathSeparator)) || len(args)-1 > 1 {
+	} else if strings.HasSuffix(target, string(os.PathSeparator)) || len(args)-1 > 1 || c.recursive {
 		if err := os.MkdirAll(target, 0755); err != nil {
 			return err
 		}
 		targetIsDir = true
+	} else if c.mkdirs {
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+	}
+
+	if c.recursive && !targetIsDir {
+		return fmt.Errorf(i18n.G("The target must be a directory in recursive mode"))
 	}
 
 	for _, f := range args[:len(args)-1] {
@@ -231,11 +449,24 @@ func (c *fileCmd) pull(conf *config.Config, args []string) error {
 			return err
 		}
 
-		buf, _, err := d.GetContainerFile(container, pathSpec[1])
+		if c.recursive {
+			err := c.recursivePullFile(d, container, pathSpec[1], target)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		buf, resp, err := d.GetContainerFile(container, pathSpec[1])
 		if err != nil {
 			return err
 		}
 
+		if resp.Type == "directory" {
+			return fmt.Errorf(i18n.G("%s is a directory, use --recursive to pull it"), pathSpec[1])
+		}
+
 		var targetPath string
 		if targetIsDir {
 			targetPath = path.Join(target, path.Base(pathSpec[1]))
@@ -251,12 +482,46 @@ func (c *fileCmd) pull(conf *config.Config, args []string) error {
 		} else {
 			f, err = os.Create(targetPath)
 			if err != nil {
+				buf.Close()
 				return err
 			}
-			defer f.Close()
 		}
 
 		_, err = io.Copy(f, buf)
+		buf.Close()
+		if f != os.Stdout {
+			f.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *fileCmd) delete(conf *config.Config, args []string) error {
+	if len(args) < 1 {
+		return errArgs
+	}
+
+	for _, f := range args {
+		pathSpec := strings.SplitN(f, "/", 2)
+		if len(pathSpec) != 2 {
+			return fmt.Errorf(i18n.G("Invalid path %s"), f)
+		}
+
+		remote, container, err := conf.ParseRemote(pathSpec[0])
+		if err != nil {
+			return err
+		}
+
+		d, err := conf.GetContainerServer(remote)
+		if err != nil {
+			return err
+		}
+
+		err = d.DeleteContainerFile(container, pathSpec[1])
 		if err != nil {
 			return err
 		}
@@ -270,38 +535,71 @@ func (c *fileCmd) edit(conf *config.Config, args []string) error {
 		return errArgs
 	}
 
-	// If stdin isn't a terminal, read text from it
-	if !termios.IsTerminal(int(syscall.Stdin)) {
-		return c.push(conf, false, append([]string{os.Stdin.Name()}, args[0]))
+	pathSpec := strings.SplitN(args[0], "/", 2)
+	if len(pathSpec) != 2 {
+		return fmt.Errorf(i18n.G("Invalid path %s"), args[0])
 	}
 
-	// Create temp file
-	f, err := ioutil.TempFile("", "lxd_file_edit_")
+	remote, container, err := conf.ParseRemote(pathSpec[0])
+	if err != nil {
+		return err
+	}
+
+	d, err := conf.GetContainerServer(remote)
 	if err != nil {
-		return fmt.Errorf("Unable to create a temporary file: %v", err)
+		return err
+	}
+
+	// If stdin isn't a terminal, read text from it, still replacing the
+	// file atomically when the server supports it
+	if !termios.IsTerminal(int(syscall.Stdin)) {
+		if d.HasExtension("file_atomic_write") {
+			c.writeMode = "atomic"
+		}
+
+		return c.push(conf, false, append([]string{os.Stdin.Name()}, args[0]))
 	}
-	fname := f.Name()
-	f.Close()
-	os.Remove(fname)
-	defer os.Remove(shared.HostPath(fname))
 
-	// Extract current value
-	err = c.pull(conf, append([]string{args[0]}, fname))
+	// Extract current value and ownership
+	buf, resp, err := d.GetContainerFile(container, pathSpec[1])
 	if err != nil {
 		return err
 	}
 
-	_, err = shared.TextEditor(shared.HostPath(fname), []byte{})
+	if resp.Type != "file" {
+		if buf != nil {
+			buf.Close()
+		}
+
+		return fmt.Errorf(i18n.G("'%s' isn't a regular file"), pathSpec[1])
+	}
+
+	content, err := ioutil.ReadAll(buf)
+	buf.Close()
 	if err != nil {
 		return err
 	}
 
-	err = c.push(conf, false, append([]string{fname}, args[0]))
+	content, err = shared.TextEditor("", content)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// Write the file back keeping its ownership and mode, in one go if the
+	// server supports it
+	fileArgs := lxd.ContainerFileArgs{
+		Content: bytes.NewReader(content),
+		UID:     resp.UID,
+		GID:     resp.GID,
+		Mode:    resp.Mode,
+		Type:    "file",
+	}
+
+	if d.HasExtension("file_atomic_write") {
+		fileArgs.WriteMode = "atomic"
+	}
+
+	return d.CreateContainerFile(container, pathSpec[1], fileArgs)
 }
 
 func (c *fileCmd) run(conf *config.Config, args []string) error {
@@ -314,6 +612,8 @@ func (c *fileCmd) run(conf *config.Config, args []string) error {
 		return c.push(conf, true, args[1:])
 	case "pull":
 		return c.pull(conf, args[1:])
+	case "delete":
+		return c.delete(conf, args[1:])
 	case "edit":
 		return c.edit(conf, args[1:])
 	default:
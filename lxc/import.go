@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+
+	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxc/config"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/i18n"
+)
+
+type importCmd struct{}
+
+func (c *importCmd) showByDefault() bool {
+	return true
+}
+
+func (c *importCmd) usage() string {
+	return i18n.G(
+		`Usage: lxc import [<remote>:] <backup file>
+
+Import backups of containers including their snapshots.
+
+*Examples*
+lxc import backup0.tar.gz
+    Create a new container using backup0.tar.gz as the source.`)
+}
+
+func (c *importCmd) flags() {}
+
+func (c *importCmd) run(conf *config.Config, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errArgs
+	}
+
+	remote := conf.DefaultRemote
+	backupFile := args[0]
+	if len(args) == 2 {
+		var err error
+		remote, _, err = conf.ParseRemote(args[0])
+		if err != nil {
+			return err
+		}
+
+		backupFile = args[1]
+	}
+
+	d, err := conf.GetContainerServer(remote)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(shared.HostPath(backupFile))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	createArgs := lxd.ContainerBackupArgs{
+		BackupFile: file,
+	}
+
+	op, err := d.CreateContainerFromBackup(createArgs)
+	if err != nil {
+		return err
+	}
+
+	return op.Wait()
+}
@@ -201,13 +201,16 @@ type command interface {
 
 var commands = map[string]command{
 	"config":  &configCmd{},
+	"console": &consoleCmd{},
 	"copy":    &copyCmd{},
 	"delete":  &deleteCmd{},
 	"exec":    &execCmd{},
+	"export":  &exportCmd{},
 	"file":    &fileCmd{},
 	"finger":  &fingerCmd{},
 	"help":    &helpCmd{},
 	"image":   &imageCmd{},
+	"import":  &importCmd{},
 	"info":    &infoCmd{},
 	"init":    &initCmd{},
 	"launch":  &launchCmd{},
@@ -221,6 +224,7 @@ var commands = map[string]command{
 		name:        "pause",
 	},
 	"profile": &profileCmd{},
+	"ps":      &psCmd{},
 	"publish": &publishCmd{},
 	"remote":  &remoteCmd{},
 	"restart": &actionCmd{
@@ -247,6 +251,7 @@ var commands = map[string]command{
 		name:        "stop",
 		timeout:     -1,
 	},
+	"top":     &topCmd{},
 	"version": &versionCmd{},
 }
 
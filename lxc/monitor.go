@@ -49,7 +49,10 @@ Message types to listen for can be specified with --type.
 
 *Examples*
 lxc monitor --type=logging
-    Only show log message.`)
+    Only show log message.
+
+lxc monitor --type=lifecycle
+    Only show container, snapshot, profile, image and configuration changes.`)
 }
 
 func (c *monitorCmd) flags() {
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/lxc/lxd/lxc/config"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/i18n"
+)
+
+type psCmd struct{}
+
+func (c *psCmd) showByDefault() bool {
+	return false
+}
+
+func (c *psCmd) usage() string {
+	return i18n.G(
+		`Usage: lxc ps [<remote>:]<container>
+
+List the processes running in a container.
+
+The processes are read from the host, so this works even if the container
+doesn't ship a "ps" command.`)
+}
+
+func (c *psCmd) flags() {}
+
+func (c *psCmd) run(conf *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errArgs
+	}
+
+	remote, name, err := conf.ParseRemote(args[0])
+	if err != nil {
+		return err
+	}
+
+	d, err := conf.GetContainerServer(remote)
+	if err != nil {
+		return err
+	}
+
+	processes, err := d.GetContainerProcesses(name)
+	if err != nil {
+		return err
+	}
+
+	data := [][]string{}
+	for _, process := range processes {
+		data = append(data, processRow(process, ""))
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetRowLine(false)
+	table.SetBorder(false)
+	table.SetHeader(processHeader(false))
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
+
+// processHeader returns the table header used by "lxc ps" and "lxc top".
+func processHeader(withCPU bool) []string {
+	header := []string{
+		i18n.G("PID"),
+		i18n.G("UID"),
+		i18n.G("CPU TIME"),
+		i18n.G("MEMORY"),
+	}
+
+	if withCPU {
+		header = append(header, i18n.G("CPU%"))
+	}
+
+	return append(header, i18n.G("COMMAND"))
+}
+
+// processRow renders a process as a table row, the CPU usage column only
+// being included if not empty.
+func processRow(process api.ContainerProcess, cpu string) []string {
+	cpuTime := time.Duration(process.CPUTime) / time.Millisecond * time.Millisecond
+
+	row := []string{
+		fmt.Sprintf("%d", process.ContainerPID),
+		fmt.Sprintf("%d", process.UID),
+		cpuTime.String(),
+		shared.GetByteSizeString(process.MemoryRSS, 2),
+	}
+
+	if cpu != "" {
+		row = append(row, cpu)
+	}
+
+	return append(row, strings.Join(process.Command, " "))
+}
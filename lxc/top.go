@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/lxc/lxd/lxc/config"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/gnuflag"
+	"github.com/lxc/lxd/shared/i18n"
+)
+
+type topCmd struct {
+	interval int
+	count    int
+}
+
+func (c *topCmd) showByDefault() bool {
+	return false
+}
+
+func (c *topCmd) usage() string {
+	return i18n.G(
+		`Usage: lxc top [<remote>:]<container> [--interval=SECONDS] [--count=N]
+
+Show the processes running in a container, sorted by CPU usage.
+
+The list is refreshed every --interval seconds (default 2), until
+interrupted or --count refreshes were shown.`)
+}
+
+func (c *topCmd) flags() {
+	gnuflag.IntVar(&c.interval, "interval", 2, i18n.G("Number of seconds between refreshes"))
+	gnuflag.IntVar(&c.count, "count", 0, i18n.G("Number of refreshes before exiting (0 means forever)"))
+}
+
+func (c *topCmd) run(conf *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errArgs
+	}
+
+	if c.interval < 1 {
+		return fmt.Errorf(i18n.G("The interval must be at least one second"))
+	}
+
+	remote, name, err := conf.ParseRemote(args[0])
+	if err != nil {
+		return err
+	}
+
+	d, err := conf.GetContainerServer(remote)
+	if err != nil {
+		return err
+	}
+
+	// CPU time of each process at the previous refresh
+	previous := map[int64]int64{}
+	last := time.Now()
+
+	for i := 0; c.count == 0 || i < c.count; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(c.interval) * time.Second)
+		}
+
+		processes, err := d.GetContainerProcesses(name)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		elapsed := now.Sub(last).Nanoseconds()
+		last = now
+
+		usage := map[int64]float64{}
+		for _, process := range processes {
+			prev, ok := previous[process.PID]
+			if ok && elapsed > 0 {
+				usage[process.PID] = float64(process.CPUTime-prev) * 100 / float64(elapsed)
+			}
+		}
+
+		previous = map[int64]int64{}
+		for _, process := range processes {
+			previous[process.PID] = process.CPUTime
+		}
+
+		sort.SliceStable(processes, func(i, j int) bool {
+			return usage[processes[i].PID] > usage[processes[j].PID]
+		})
+
+		data := [][]string{}
+		for _, process := range processes {
+			data = append(data, processRow(process, fmt.Sprintf("%.1f", usage[process.PID])))
+		}
+
+		c.render(processes, data)
+	}
+
+	return nil
+}
+
+func (c *topCmd) render(processes []api.ContainerProcess, data [][]string) {
+	// Clear the screen and move the cursor back to the top
+	fmt.Print("\033[H\033[2J")
+
+	fmt.Printf(i18n.G("Processes: %d")+"\n\n", len(processes))
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetRowLine(false)
+	table.SetBorder(false)
+	table.SetHeader(processHeader(true))
+	table.AppendBulk(data)
+	table.Render()
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 	"reflect"
+	"sort"
 
 	"gopkg.in/lxc/go-lxc.v2"
 
@@ -26,6 +27,13 @@ var api10 = []Command{
 	containerSnapshotsCmd,
 	containerSnapshotCmd,
 	containerExecCmd,
+	containerConsoleCmd,
+	containerProcessesCmd,
+	containerMetadataCmd,
+	containerMetadataTemplatesCmd,
+	containerBackupsCmd,
+	containerBackupCmd,
+	containerBackupExportCmd,
 	aliasCmd,
 	aliasesCmd,
 	eventsCmd,
@@ -33,17 +41,29 @@ var api10 = []Command{
 	imagesCmd,
 	imagesExportCmd,
 	imagesSecretCmd,
+	metricsCmd,
 	operationsCmd,
 	operationCmd,
 	operationWait,
 	operationWebsocket,
 	networksCmd,
 	networkCmd,
+	networkLeasesCmd,
+	networkACLsCmd,
+	networkACLCmd,
 	api10Cmd,
 	certificatesCmd,
 	certificateFingerprintCmd,
 	profilesCmd,
 	profileCmd,
+	storagePoolsCmd,
+	storagePoolCmd,
+	storagePoolResourcesCmd,
+	storagePoolVolumesCmd,
+	storagePoolVolumesTypeCmd,
+	storagePoolVolumeTypeCmd,
+	storagePoolVolumeSnapshotsTypeCmd,
+	storagePoolVolumeSnapshotTypeCmd,
 }
 
 func api10Get(d *Daemon, r *http.Request) Response {
@@ -91,6 +111,11 @@ func api10Get(d *Daemon, r *http.Request) Response {
 		architectures = append(architectures, architectureName)
 	}
 
+	webhookFailures, err := webhooksFailures(d)
+	if err != nil {
+		return InternalError(err)
+	}
+
 	env := api.ServerEnvironment{
 		Addresses:              addresses,
 		Architectures:          architectures,
@@ -105,7 +130,8 @@ func api10Get(d *Daemon, r *http.Request) Response {
 		StorageVersion:         d.Storage.GetStorageTypeVersion(),
 		Server:                 "lxd",
 		ServerPid:              os.Getpid(),
-		ServerVersion:          version.Version}
+		ServerVersion:          version.Version,
+		WebhookFailures:        webhookFailures}
 
 	fullSrv := api.Server{ServerUntrusted: srv}
 	fullSrv.Environment = env
@@ -127,7 +153,7 @@ func api10Put(d *Daemon, r *http.Request) Response {
 
 	// Deal with special keys
 	for k, v := range req.Config {
-		config := daemonConfig[k]
+		config := daemonConfigDynamicKey(k)
 		if config != nil && config.hiddenValue && v == true {
 			req.Config[k] = oldConfig[k]
 		}
@@ -159,8 +185,8 @@ func api10Put(d *Daemon, r *http.Request) Response {
 
 		value := valueRaw.(string)
 
-		confKey, ok := daemonConfig[key]
-		if !ok {
+		confKey := daemonConfigDynamicKey(key)
+		if confKey == nil {
 			return BadRequest(fmt.Errorf("Bad server config key: '%s'", key))
 		}
 
@@ -170,6 +196,17 @@ func api10Put(d *Daemon, r *http.Request) Response {
 		}
 	}
 
+	// Only list the keys, as some values are secrets
+	if len(changedConfig) > 0 {
+		keys := []string{}
+		for key := range changedConfig {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		eventSendLifecycle("config-updated", fmt.Sprintf("/%s", version.APIVersion), map[string]interface{}{"keys": keys}, eventRequestor(r))
+	}
+
 	return EmptySyncResponse
 }
 
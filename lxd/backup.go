@@ -0,0 +1,400 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/osarch"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// backup represents a container backup.
+type backup struct {
+	state     *state.State
+	container container
+
+	// Properties
+	id               int
+	name             string
+	creationDate     time.Time
+	expiryDate       time.Time
+	containerOnly    bool
+	optimizedStorage bool
+}
+
+// backupInfo is the content of the index.yaml file at the root of a backup
+// tarball.
+type backupInfo struct {
+	Name      string                   `yaml:"name"`
+	Backend   string                   `yaml:"backend"`
+	Optimized bool                     `yaml:"optimized"`
+	Container *api.Container           `yaml:"container"`
+	Snapshots []*api.ContainerSnapshot `yaml:"snapshots"`
+}
+
+// backupLoadByName loads the backup with the given name of a container.
+func backupLoadByName(s *state.State, c container, name string) (*backup, error) {
+	args, err := s.DB.ContainerBackupGet(c.Id(), name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &backup{
+		state:            s,
+		container:        c,
+		id:               args.ID,
+		name:             args.Name,
+		creationDate:     args.CreationDate,
+		expiryDate:       args.ExpiryDate,
+		containerOnly:    args.ContainerOnly,
+		optimizedStorage: args.OptimizedStorage,
+	}, nil
+}
+
+// Name returns the name of the backup.
+func (b *backup) Name() string {
+	return b.name
+}
+
+// Path returns the path of the backup tarball.
+func (b *backup) Path() string {
+	return shared.VarPath("backups", b.container.Name(), b.name)
+}
+
+// Rename renames the backup.
+func (b *backup) Rename(newName string) error {
+	if shared.PathExists(b.Path()) {
+		err := os.Rename(b.Path(), shared.VarPath("backups", b.container.Name(), newName))
+		if err != nil {
+			return err
+		}
+	}
+
+	err := b.state.DB.ContainerBackupRename(b.id, newName)
+	if err != nil {
+		return err
+	}
+
+	b.name = newName
+	return nil
+}
+
+// Delete removes the backup tarball and its database record.
+func (b *backup) Delete() error {
+	err := os.Remove(b.Path())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return b.state.DB.ContainerBackupRemove(b.id)
+}
+
+// Render returns the API representation of the backup.
+func (b *backup) Render() *api.ContainerBackup {
+	return &api.ContainerBackup{
+		Name:             b.name,
+		CreationDate:     b.creationDate,
+		ExpiryDate:       b.expiryDate,
+		ContainerOnly:    b.containerOnly,
+		OptimizedStorage: b.optimizedStorage,
+	}
+}
+
+// backupCreate creates a backup of the container, made of an index.yaml file
+// describing the container and its snapshots along with their content, and
+// stores it as a compressed tarball.
+func backupCreate(s *state.State, args db.ContainerBackupArgs, sourceContainer container) error {
+	// Create the database entry
+	err := s.DB.ContainerBackupCreate(args)
+	if err != nil {
+		if err == db.DbErrAlreadyDefined {
+			return fmt.Errorf("Backup '%s' already exists", args.Name)
+		}
+
+		return err
+	}
+
+	b, err := backupLoadByName(s, sourceContainer, args.Name)
+	if err != nil {
+		return err
+	}
+
+	err = backupCreateTarball(b)
+	if err != nil {
+		s.DB.ContainerBackupRemove(b.id)
+		return err
+	}
+
+	return nil
+}
+
+func backupCreateTarball(b *backup) error {
+	backupsPath := shared.VarPath("backups", b.container.Name())
+	err := os.MkdirAll(backupsPath, 0700)
+	if err != nil {
+		return err
+	}
+
+	// Create a temporary directory to build the backup in
+	tmpPath, err := ioutil.TempDir(shared.VarPath("backups"), "lxd_backup_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpPath)
+
+	backupPath := filepath.Join(tmpPath, "backup")
+	err = os.MkdirAll(backupPath, 0700)
+	if err != nil {
+		return err
+	}
+
+	// Dump the container and its snapshots
+	err = b.container.Storage().ContainerBackupCreate(backupPath, *b, b.container)
+	if err != nil {
+		return err
+	}
+
+	// Describe them in the index
+	info := backupInfo{
+		Name:      b.container.Name(),
+		Backend:   b.container.Storage().GetStorageTypeName(),
+		Optimized: b.optimizedStorage,
+		Snapshots: []*api.ContainerSnapshot{},
+	}
+
+	render, err := b.container.Render()
+	if err != nil {
+		return err
+	}
+	info.Container = render.(*api.Container)
+
+	if !b.containerOnly {
+		snapshots, err := b.container.Snapshots()
+		if err != nil {
+			return err
+		}
+
+		for _, snap := range snapshots {
+			render, err := snap.Render()
+			if err != nil {
+				return err
+			}
+
+			info.Snapshots = append(info.Snapshots, render.(*api.ContainerSnapshot))
+		}
+	}
+
+	data, err := yaml.Marshal(&info)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(backupPath, "index.yaml"), data, 0644)
+	if err != nil {
+		return err
+	}
+
+	// Pack and compress the whole thing
+	tarballPath := filepath.Join(tmpPath, "backup.tar")
+	output, err := shared.RunCommand("tar", "-cf", tarballPath, "--numeric-owner", "--xattrs", "-C", tmpPath, "backup")
+	if err != nil {
+		return fmt.Errorf("Failed to create the backup tarball: %s", strings.TrimSpace(output))
+	}
+
+	compress := daemonConfig["images.compression_algorithm"].Get()
+	if compress != "none" {
+		compressedPath, err := compressFile(tarballPath, compress)
+		if err != nil {
+			return err
+		}
+
+		tarballPath = compressedPath
+	}
+
+	return os.Rename(tarballPath, b.Path())
+}
+
+// backupGetInfo unpacks the backup tarball into path and parses its index.
+func backupGetInfo(s *state.State, tarball string, path string) (*backupInfo, error) {
+	err := shared.Unpack(tarball, path, false, s.OS.RunningInUserNS)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(path, "backup", "index.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid backup tarball: %v", err)
+	}
+
+	info := backupInfo{}
+	err = yaml.Unmarshal(data, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Name == "" || info.Container == nil {
+		return nil, fmt.Errorf("Invalid backup index")
+	}
+
+	// The names end up in paths, check them before anything gets created
+	err = containerValidName(info.Name)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid container name in backup index: %v", err)
+	}
+
+	for _, snap := range info.Snapshots {
+		if snap == nil {
+			return nil, fmt.Errorf("Invalid backup index")
+		}
+
+		_, snapName, _ := containerGetParentAndSnapshotName(snap.Name)
+		err = backupValidSnapshotName(snapName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &info, nil
+}
+
+// backupValidSnapshotName checks that a snapshot name from a backup index
+// can't be used to escape the backup or container directories.
+func backupValidSnapshotName(name string) error {
+	if name == "" || strings.Contains(name, "/") || strings.Contains(name, "..") {
+		return fmt.Errorf("Invalid snapshot name in backup index: '%s'", name)
+	}
+
+	return nil
+}
+
+// backupSnapshotToContainerArgs returns the database arguments needed to
+// re-create a snapshot described in a backup index.
+func backupSnapshotToContainerArgs(containerName string, snap *api.ContainerSnapshot) (db.ContainerArgs, error) {
+	architecture, err := osarch.ArchitectureId(snap.Architecture)
+	if err != nil {
+		return db.ContainerArgs{}, err
+	}
+
+	_, snapName, _ := containerGetParentAndSnapshotName(snap.Name)
+
+	return db.ContainerArgs{
+		Name:         containerName + shared.SnapshotDelimiter + snapName,
+		Ctype:        db.CTypeSnapshot,
+		Config:       snap.Config,
+		Profiles:     snap.Profiles,
+		Ephemeral:    snap.Ephemeral,
+		Devices:      types.Devices(snap.Devices),
+		Architecture: architecture,
+		Stateful:     snap.Stateful,
+		ExpiryDate:   snap.ExpiryDate,
+	}, nil
+}
+
+// containerCreateFromBackup creates a new container, along with its
+// snapshots, from a backup unpacked at path.
+func containerCreateFromBackup(d *Daemon, info *backupInfo, path string) (container, error) {
+	architecture, err := osarch.ArchitectureId(info.Container.Architecture)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only keep the volatile keys which are needed to restore the container
+	config := map[string]string{}
+	for key, value := range info.Container.Config {
+		if strings.HasPrefix(key, "volatile.") && !shared.StringInSlice(key[9:], []string{"base_image", "last_state.idmap"}) {
+			continue
+		}
+
+		config[key] = value
+	}
+
+	args := db.ContainerArgs{
+		Architecture: architecture,
+		Config:       config,
+		Ctype:        db.CTypeRegular,
+		Devices:      types.Devices(info.Container.Devices),
+		Ephemeral:    info.Container.Ephemeral,
+		Name:         info.Name,
+		Profiles:     info.Container.Profiles,
+	}
+
+	c, err := containerCreateAsEmpty(d, args)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.Storage().ContainerBackupLoad(filepath.Join(path, "backup"), *info, c)
+	if err != nil {
+		c.Delete()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// pruneExpiredContainerBackupsTask deletes the backups which are past their
+// expiry date. It's started by the Daemon and runs once an hour.
+func pruneExpiredContainerBackupsTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		err := pruneExpiredContainerBackups(ctx, d)
+		if err != nil {
+			logger.Error("Failed to prune expired container backups", log.Ctx{"err": err})
+		}
+	}
+
+	return f, task.Every(time.Hour)
+}
+
+func pruneExpiredContainerBackups(ctx context.Context, d *Daemon) error {
+	s := d.State()
+
+	expired, err := s.DB.ContainerBackupsExpired(time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	for _, name := range expired {
+		// Abort if the daemon is shutting down
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		fields := strings.SplitN(name, "/", 2)
+
+		c, err := containerLoadByName(s, d.Storage, fields[0])
+		if err != nil {
+			return err
+		}
+
+		b, err := backupLoadByName(s, c, fields[1])
+		if err != nil {
+			return err
+		}
+
+		err = b.Delete()
+		if err != nil {
+			return err
+		}
+
+		logger.Info("Deleted expired container backup", log.Ctx{"container": fields[0], "backup": fields[1]})
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Snapshot names from a backup index must not lead outside of the backup or
+// container directories.
+func TestBackupValidSnapshotName(t *testing.T) {
+	cases := map[string]bool{
+		"snap0":     true,
+		"2018-01-1": true,
+		"":          false,
+		"../x":      false,
+		"..":        false,
+		"a/b":       false,
+	}
+
+	for name, valid := range cases {
+		assert.Equal(t, valid, backupValidSnapshotName(name) == nil, name)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"os/exec"
 	"strconv"
@@ -186,6 +187,26 @@ func containerValidConfigKey(key string, value string) error {
 		return nil
 	case "security.privileged":
 		return isBool(key, value)
+	case "snapshots.schedule":
+		if value == "" {
+			return nil
+		}
+
+		_, err := snapshotScheduleParse(value)
+		if err != nil {
+			return fmt.Errorf("Invalid snapshot schedule: %v", err)
+		}
+
+		return nil
+	case "snapshots.pattern":
+		if strings.Contains(value, "/") {
+			return fmt.Errorf("Snapshot names may not contain slashes")
+		}
+
+		return nil
+	case "snapshots.expiry":
+		_, err := shared.GetSnapshotExpiry(time.Now(), value)
+		return err
 	case "security.nesting":
 		return isBool(key, value)
 	case "security.idmap.base":
@@ -276,6 +297,10 @@ func containerValidDeviceConfigKey(t, k string) bool {
 			return true
 		case "hwaddr":
 			return true
+		case "ipv4.address":
+			return true
+		case "ipv6.address":
+			return true
 		case "mtu":
 			return true
 		case "name":
@@ -284,6 +309,14 @@ func containerValidDeviceConfigKey(t, k string) bool {
 			return true
 		case "parent":
 			return true
+		case "security.mac_filtering":
+			return true
+		case "security.ipv4_filtering":
+			return true
+		case "security.ipv6_filtering":
+			return true
+		case "security.acls":
+			return true
 		default:
 			return false
 		}
@@ -299,6 +332,8 @@ func containerValidDeviceConfigKey(t, k string) bool {
 			return true
 		case "path":
 			return true
+		case "pool":
+			return true
 		case "readonly":
 			return true
 		case "size":
@@ -310,6 +345,15 @@ func containerValidDeviceConfigKey(t, k string) bool {
 		default:
 			return false
 		}
+	case "proxy":
+		switch k {
+		case "connect":
+			return true
+		case "listen":
+			return true
+		default:
+			return false
+		}
 	case "none":
 		return false
 	default:
@@ -348,6 +392,14 @@ func isRootDiskDevice(device types.Device) bool {
 	return false
 }
 
+func isCustomVolumeDevice(device types.Device) bool {
+	if device["type"] == "disk" && device["pool"] != "" && device["source"] != "" && device["path"] != "/" {
+		return true
+	}
+
+	return false
+}
+
 func containerGetRootDiskDevice(devices types.Devices) (string, types.Device, error) {
 	var devName string
 	var dev types.Device
@@ -370,6 +422,18 @@ func containerGetRootDiskDevice(devices types.Devices) (string, types.Device, er
 	return "", types.Device{}, fmt.Errorf("No root device could be found.")
 }
 
+// containerStorageGet returns the storage driver for an existing container,
+// using the storage pool of its root disk if it has one and falling back to
+// detecting the backend from the container's path otherwise.
+func containerStorageGet(s *state.State, st storage, name string, devices types.Devices) (storage, error) {
+	_, rootDiskDevice, err := containerGetRootDiskDevice(devices)
+	if err == nil && rootDiskDevice["pool"] != "" {
+		return storagePoolInit(s, st, rootDiskDevice["pool"])
+	}
+
+	return storageForFilename(s, st, shared.VarPath("containers", strings.Split(name, "/")[0]))
+}
+
 func containerValidDevices(devices types.Devices, profile bool, expanded bool) error {
 	// Empty device list
 	if devices == nil {
@@ -383,7 +447,7 @@ func containerValidDevices(devices types.Devices, profile bool, expanded bool) e
 			return fmt.Errorf("Missing device type for device '%s'", name)
 		}
 
-		if !shared.StringInSlice(m["type"], []string{"none", "nic", "disk", "unix-char", "unix-block"}) {
+		if !shared.StringInSlice(m["type"], []string{"none", "nic", "disk", "unix-char", "unix-block", "proxy"}) {
 			return fmt.Errorf("Invalid device type for device '%s'", name)
 		}
 
@@ -405,6 +469,38 @@ func containerValidDevices(devices types.Devices, profile bool, expanded bool) e
 			if shared.StringInSlice(m["nictype"], []string{"bridged", "physical", "macvlan"}) && m["parent"] == "" {
 				return fmt.Errorf("Missing parent for %s type nic.", m["nictype"])
 			}
+
+			for _, key := range []string{"ipv4.address", "ipv6.address"} {
+				if m[key] == "" {
+					continue
+				}
+
+				if m["nictype"] != "bridged" && m["nictype"] != "p2p" {
+					return fmt.Errorf("The %s property is only supported on bridged and p2p nics.", key)
+				}
+
+				ip := net.ParseIP(m[key])
+				if ip == nil || (key == "ipv4.address") != (ip.To4() != nil) {
+					return fmt.Errorf("Invalid %s: %s", key, m[key])
+				}
+			}
+
+			if deviceNetworkFilteringEnabled(m) && m["nictype"] != "bridged" && m["nictype"] != "p2p" {
+				return fmt.Errorf("Spoofing protection is only supported on bridged and p2p nics.")
+			}
+
+			if m["security.acls"] != "" {
+				if m["nictype"] != "bridged" {
+					return fmt.Errorf("Network ACLs are only supported on bridged nics.")
+				}
+
+				for _, name := range strings.Split(m["security.acls"], ",") {
+					err := networkACLValidName(strings.TrimSpace(name))
+					if err != nil {
+						return err
+					}
+				}
+			}
 		} else if m["type"] == "disk" {
 			if !expanded && !shared.StringInSlice(m["path"], diskDevicePaths) {
 				diskDevicePaths = append(diskDevicePaths, m["path"])
@@ -431,6 +527,18 @@ func containerValidDevices(devices types.Devices, profile bool, expanded bool) e
 			if (m["path"] == "/" || !shared.IsDir(m["source"])) && m["recursive"] != "" {
 				return fmt.Errorf("The recursive option is only supported for additional bind-mounted paths.")
 			}
+
+			if m["pool"] != "" && m["path"] != "/" && m["source"] == "" {
+				return fmt.Errorf("Disks on a storage pool must either be the root disk or a custom volume.")
+			}
+
+			if strings.Contains(m["pool"], "/") {
+				return fmt.Errorf("Storage pool names may not contain slashes.")
+			}
+
+			if m["pool"] != "" && strings.Contains(m["source"], "/") {
+				return fmt.Errorf("Storage volume names may not contain slashes.")
+			}
 		} else if shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
 			if m["path"] == "" {
 				return fmt.Errorf("Unix device entry is missing the required \"path\" property.")
@@ -454,6 +562,11 @@ func containerValidDevices(devices types.Devices, profile bool, expanded bool) e
 					return fmt.Errorf("Path specified for unix-block device is a character device.")
 				}
 			}
+		} else if m["type"] == "proxy" {
+			err := proxyValidate(m)
+			if err != nil {
+				return err
+			}
 		} else if m["type"] == "none" {
 			continue
 		} else {
@@ -502,9 +615,9 @@ type container interface {
 	ConfigKeySet(key string, value string) error
 
 	// File handling
-	FilePull(srcpath string, dstpath string) (int64, int64, os.FileMode, error)
+	FilePull(srcpath string, dstpath string, follow bool) (int64, int64, os.FileMode, string, []string, error)
 	FileExists(path string) error
-	FilePush(srcpath string, dstpath string, uid int64, gid int64, mode int) error
+	FilePush(fileType string, srcpath string, dstpath string, uid int64, gid int64, mode int, write string) error
 	FileRemove(path string) error
 
 	/* Command execution:
@@ -519,7 +632,16 @@ type container interface {
 	         *      (the PID returned in the first return argument). It can however
 	         *      be used to e.g. forward signals.)
 	*/
-	Exec(command []string, env map[string]string, stdin *os.File, stdout *os.File, stderr *os.File, wait bool) (*exec.Cmd, int, int, error)
+	Exec(command []string, env map[string]string, stdin *os.File, stdout *os.File, stderr *os.File, wait bool, cwd string, uid uint32, gid uint32) (*exec.Cmd, int, int, error)
+
+	// Console - Allocate and run a console tty.
+	//
+	// terminal  - Bidirectional file descriptor.
+	//
+	// This function will not return until the console has been exited by
+	// the user.
+	Console(terminal *os.File) *exec.Cmd
+	ConsoleLog(opts lxc.ConsoleLogOptions) (string, error)
 
 	// Status
 	Render() (interface{}, error)
@@ -541,6 +663,7 @@ type container interface {
 	Name() string
 	Architecture() int
 	CreationDate() time.Time
+	ExpiryDate() time.Time
 	ExpandedConfig() map[string]string
 	ExpandedDevices() types.Devices
 	LocalConfig() map[string]string
@@ -555,6 +678,7 @@ type container interface {
 	TemplatesPath() string
 	StatePath() string
 	LogFilePath() string
+	ConsoleBufferLogPath() string
 	LogPath() string
 
 	// FIXME: Those should be internal functions
@@ -665,6 +789,104 @@ func containerCreateAsCopy(s *state.State, storage storage, args db.ContainerArg
 	return c, nil
 }
 
+// containerRefresh updates an existing container from a local source
+// container. Only the snapshots it's missing get copied, followed by the
+// differences of the container itself, and the snapshots which don't exist on
+// the source anymore get deleted.
+func containerRefresh(s *state.State, c container, sourceContainer container) error {
+	sourceSnapshots, err := sourceContainer.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	sourceNames := []string{}
+	for _, snap := range sourceSnapshots {
+		sourceNames = append(sourceNames, shared.ExtractSnapshotName(snap.Name()))
+	}
+
+	snapshots, err := c.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for _, snap := range snapshots {
+		name := shared.ExtractSnapshotName(snap.Name())
+		if shared.StringInSlice(name, sourceNames) {
+			names = append(names, name)
+			continue
+		}
+
+		err := snap.Delete()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.StorageStart()
+	if err != nil {
+		return err
+	}
+	defer c.StorageStop()
+
+	// Copy the missing snapshots, oldest first, by syncing the container
+	// with each of them and snapshotting it.
+	for _, snap := range sourceSnapshots {
+		name := shared.ExtractSnapshotName(snap.Name())
+		if shared.StringInSlice(name, names) {
+			continue
+		}
+
+		err := containerRefreshSync(c, snap)
+		if err != nil {
+			return err
+		}
+
+		args := db.ContainerArgs{
+			Name:         c.Name() + shared.SnapshotDelimiter + name,
+			Ctype:        db.CTypeSnapshot,
+			Config:       snap.LocalConfig(),
+			Profiles:     snap.Profiles(),
+			Ephemeral:    snap.IsEphemeral(),
+			Devices:      snap.LocalDevices(),
+			Architecture: snap.Architecture(),
+			ExpiryDate:   snap.ExpiryDate(),
+		}
+
+		_, err = containerCreateAsSnapshot(s, c.Storage(), args, c)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = containerRefreshSync(c, sourceContainer)
+	if err != nil {
+		return err
+	}
+
+	// The files now have the ownership of the source's idmap, which
+	// the target needs to know about to remap them on start
+	return c.ConfigKeySet("volatile.last_state.idmap", sourceContainer.LocalConfig()["volatile.last_state.idmap"])
+}
+
+// containerRefreshSync makes the filesystem of the container identical to
+// the one of the source container. Local refreshes always go through rsync,
+// the storage drivers' incremental streams are only used by migrations.
+func containerRefreshSync(c container, sourceContainer container) error {
+	err := sourceContainer.StorageStart()
+	if err != nil {
+		return err
+	}
+	defer sourceContainer.StorageStop()
+
+	output, err := storageRsyncCopy(sourceContainer.Path(), c.Path())
+	if err != nil {
+		return fmt.Errorf("Failed to sync %s: %s: %s", c.Name(), err, output)
+	}
+
+	return nil
+}
+
 func containerCreateAsSnapshot(s *state.State, storage storage, args db.ContainerArgs, sourceContainer container) (container, error) {
 	// Deal with state
 	if args.Stateful {
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+func containerBackupsGet(d *Daemon, r *http.Request) Response {
+	cname := mux.Vars(r)["name"]
+
+	c, err := containerLoadByName(d.State(), d.Storage, cname)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	names, err := d.db.ContainerBackups(c.Id())
+	if err != nil {
+		return SmartError(err)
+	}
+
+	recursion := util.IsRecursionRequest(r)
+
+	resultString := []string{}
+	resultMap := []*api.ContainerBackup{}
+
+	for _, name := range names {
+		if !recursion {
+			url := fmt.Sprintf("/%s/containers/%s/backups/%s", version.APIVersion, cname, name)
+			resultString = append(resultString, url)
+		} else {
+			b, err := backupLoadByName(d.State(), c, name)
+			if err != nil {
+				continue
+			}
+
+			resultMap = append(resultMap, b.Render())
+		}
+	}
+
+	if !recursion {
+		return SyncResponse(true, resultString)
+	}
+
+	return SyncResponse(true, resultMap)
+}
+
+func containerBackupsPost(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	c, err := containerLoadByName(d.State(), d.Storage, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	req := api.ContainerBackupsPost{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Name == "" {
+		// come up with a name
+		i := d.db.ContainerNextBackup(c.Id())
+		req.Name = fmt.Sprintf("backup%d", i)
+	}
+
+	// Validate the name
+	if strings.Contains(req.Name, "/") {
+		return BadRequest(fmt.Errorf("Backup names may not contain slashes"))
+	}
+
+	if req.OptimizedStorage && !shared.StringInSlice(c.Storage().GetStorageTypeName(), []string{"btrfs", "zfs"}) {
+		return BadRequest(fmt.Errorf("Optimized backups aren't supported by the %s storage driver", c.Storage().GetStorageTypeName()))
+	}
+
+	backup := func(op *operation) error {
+		args := db.ContainerBackupArgs{
+			ContainerID:      c.Id(),
+			Name:             req.Name,
+			CreationDate:     time.Now().UTC(),
+			ExpiryDate:       req.ExpiryDate,
+			ContainerOnly:    req.ContainerOnly,
+			OptimizedStorage: req.OptimizedStorage,
+		}
+
+		return backupCreate(d.State(), args, c)
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+	resources["backups"] = []string{req.Name}
+
+	op, err := operationCreate(operationClassTask, resources, nil, backup, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+func containerBackupGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	backupName := mux.Vars(r)["backupName"]
+
+	c, err := containerLoadByName(d.State(), d.Storage, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	b, err := backupLoadByName(d.State(), c, backupName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, b.Render())
+}
+
+func containerBackupPost(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	backupName := mux.Vars(r)["backupName"]
+
+	c, err := containerLoadByName(d.State(), d.Storage, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	req := api.ContainerBackupPost{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Name == "" {
+		return BadRequest(fmt.Errorf("No name provided"))
+	}
+
+	if strings.Contains(req.Name, "/") {
+		return BadRequest(fmt.Errorf("Backup names may not contain slashes"))
+	}
+
+	b, err := backupLoadByName(d.State(), c, backupName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Check that the name isn't already in use
+	_, err = d.db.ContainerBackupGet(c.Id(), req.Name)
+	if err == nil {
+		return Conflict
+	}
+
+	err = b.Rename(req.Name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/containers/%s/backups/%s", version.APIVersion, name, req.Name))
+}
+
+func containerBackupDelete(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	backupName := mux.Vars(r)["backupName"]
+
+	c, err := containerLoadByName(d.State(), d.Storage, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	b, err := backupLoadByName(d.State(), c, backupName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = b.Delete()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+func containerBackupExportGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	backupName := mux.Vars(r)["backupName"]
+
+	c, err := containerLoadByName(d.State(), d.Storage, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	b, err := backupLoadByName(d.State(), c, backupName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// The compression algorithm may have changed since the backup was made
+	_, ext, err := shared.DetectCompression(b.Path())
+	if err != nil {
+		ext = ".tar"
+	}
+
+	ent := fileResponseEntry{
+		path:     b.Path(),
+		filename: fmt.Sprintf("%s%s", b.Name(), ext),
+	}
+
+	return FileResponse(r, []fileResponseEntry{ent}, nil, false)
+}
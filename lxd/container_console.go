@@ -0,0 +1,426 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"gopkg.in/lxc/go-lxc.v2"
+
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+type consoleWs struct {
+	// container currently worked on
+	container container
+
+	// uid to chown pty to
+	rootUid int64
+
+	// gid to chown pty to
+	rootGid int64
+
+	// websocket connections to bridge pty fds to
+	conns map[int]*websocket.Conn
+
+	// locks needed to access the "conns" member
+	connsLock sync.Mutex
+
+	// channel to wait until all websockets are properly connected
+	allConnected chan bool
+
+	// channel to wait until the control socket is connected
+	controlConnected chan bool
+
+	// map file descriptors to secret
+	fds map[int]string
+
+	// terminal width
+	width int
+
+	// terminal height
+	height int
+}
+
+func (s *consoleWs) Metadata() interface{} {
+	fds := shared.Jmap{}
+	for fd, secret := range s.fds {
+		if fd == -1 {
+			fds["control"] = secret
+		} else {
+			fds[strconv.Itoa(fd)] = secret
+		}
+	}
+
+	return shared.Jmap{"fds": fds}
+}
+
+func (s *consoleWs) Connect(op *operation, r *http.Request, w http.ResponseWriter) error {
+	secret := r.FormValue("secret")
+	if secret == "" {
+		return fmt.Errorf("missing secret")
+	}
+
+	for fd, fdSecret := range s.fds {
+		if secret == fdSecret {
+			conn, err := shared.WebsocketUpgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return err
+			}
+
+			s.connsLock.Lock()
+			s.conns[fd] = conn
+			s.connsLock.Unlock()
+
+			if fd == -1 {
+				s.controlConnected <- true
+				return nil
+			}
+
+			s.connsLock.Lock()
+			for i, c := range s.conns {
+				if i != -1 && c == nil {
+					s.connsLock.Unlock()
+					return nil
+				}
+			}
+			s.connsLock.Unlock()
+
+			s.allConnected <- true
+			return nil
+		}
+	}
+
+	/* If we didn't find the right secret, the user provided a bad one,
+	 * which 403, not 404, since this operation actually exists */
+	return os.ErrPermission
+}
+
+func (s *consoleWs) Do(op *operation) error {
+	<-s.allConnected
+
+	master, slave, err := shared.OpenPty(s.rootUid, s.rootGid)
+	if err != nil {
+		return err
+	}
+
+	if s.width > 0 && s.height > 0 {
+		shared.SetSize(int(master.Fd()), s.width, s.height)
+	}
+
+	controlExit := make(chan bool)
+	consolePidChan := make(chan int, 1)
+	var wgEOF sync.WaitGroup
+
+	wgEOF.Add(1)
+	go func() {
+		select {
+		case <-s.controlConnected:
+			break
+
+		case <-controlExit:
+			return
+		}
+
+		consolePid, ok := <-consolePidChan
+		if !ok {
+			return
+		}
+
+		for {
+			s.connsLock.Lock()
+			conn := s.conns[-1]
+			s.connsLock.Unlock()
+
+			_, r, err := conn.NextReader()
+			if err != nil {
+				// The client either detached or went away, in
+				// both cases get rid of the console process.
+				logger.Debugf("Got error getting next reader %s", err)
+				err := syscall.Kill(consolePid, syscall.SIGKILL)
+				if err != nil {
+					logger.Debugf("Failed to send SIGKILL to pid %d.", consolePid)
+				} else {
+					logger.Debugf("Sent SIGKILL to pid %d.", consolePid)
+				}
+				return
+			}
+
+			buf, err := ioutil.ReadAll(r)
+			if err != nil {
+				logger.Debugf("Failed to read message %s", err)
+				break
+			}
+
+			command := api.ContainerConsoleControl{}
+
+			if err := json.Unmarshal(buf, &command); err != nil {
+				logger.Debugf("Failed to unmarshal control socket command: %s", err)
+				continue
+			}
+
+			if command.Command == "window-resize" {
+				winchWidth, err := strconv.Atoi(command.Args["width"])
+				if err != nil {
+					logger.Debugf("Unable to extract window width: %s", err)
+					continue
+				}
+
+				winchHeight, err := strconv.Atoi(command.Args["height"])
+				if err != nil {
+					logger.Debugf("Unable to extract window height: %s", err)
+					continue
+				}
+
+				err = shared.SetSize(int(master.Fd()), winchWidth, winchHeight)
+				if err != nil {
+					logger.Debugf("Failed to set window size to: %dx%d", winchWidth, winchHeight)
+					continue
+				}
+			}
+		}
+	}()
+
+	go func() {
+		s.connsLock.Lock()
+		conn := s.conns[0]
+		s.connsLock.Unlock()
+
+		logger.Debugf("Starting to mirror websocket")
+		readDone, writeDone := shared.WebsocketMirror(conn, master, master, nil, nil)
+
+		<-readDone
+		<-writeDone
+		logger.Debugf("Finished to mirror websocket")
+
+		conn.Close()
+		wgEOF.Done()
+	}()
+
+	finisher := func(cmdErr error) error {
+		slave.Close()
+
+		s.connsLock.Lock()
+		conn := s.conns[-1]
+		s.connsLock.Unlock()
+
+		if conn == nil {
+			controlExit <- true
+		} else {
+			conn.Close()
+		}
+
+		wgEOF.Wait()
+
+		master.Close()
+
+		return cmdErr
+	}
+
+	cmd := s.container.Console(slave)
+	err = cmd.Start()
+	if err != nil {
+		close(consolePidChan)
+		return finisher(err)
+	}
+
+	consolePidChan <- cmd.Process.Pid
+
+	err = cmd.Wait()
+	if err == nil {
+		return finisher(nil)
+	}
+
+	exitErr, ok := err.(*exec.ExitError)
+	if ok {
+		status, ok := exitErr.Sys().(syscall.WaitStatus)
+
+		// A SIGKILL means that the client detached
+		if ok && status.Signaled() && status.Signal() == syscall.SIGKILL {
+			return finisher(nil)
+		}
+	}
+
+	return finisher(err)
+}
+
+func containerConsolePost(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	c, err := containerLoadByName(d.State(), d.Storage, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	post := api.ContainerConsolePost{}
+	buf, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if err := json.Unmarshal(buf, &post); err != nil {
+		return BadRequest(err)
+	}
+
+	if !c.IsRunning() {
+		return BadRequest(fmt.Errorf("Container is not running."))
+	}
+
+	if c.IsFrozen() {
+		return BadRequest(fmt.Errorf("Container is frozen."))
+	}
+
+	ws := &consoleWs{}
+	ws.fds = map[int]string{}
+
+	idmapset, err := c.IdmapSet()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	if idmapset != nil {
+		ws.rootUid, ws.rootGid = idmapset.ShiftIntoNs(0, 0)
+	}
+
+	ws.conns = map[int]*websocket.Conn{}
+	ws.conns[-1] = nil
+	ws.conns[0] = nil
+	for i := -1; i < len(ws.conns)-1; i++ {
+		ws.fds[i], err = shared.RandomCryptoString()
+		if err != nil {
+			return InternalError(err)
+		}
+	}
+
+	ws.allConnected = make(chan bool, 1)
+	ws.controlConnected = make(chan bool, 1)
+	ws.container = c
+	ws.width = post.Width
+	ws.height = post.Height
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{ws.container.Name()}
+
+	op, err := operationCreate(operationClassWebsocket, resources, ws.Metadata(), ws.Do, nil, ws.Connect)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+func containerConsoleLogGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	if !util.RuntimeLiblxcVersionAtLeast(3, 0, 0) {
+		return BadRequest(fmt.Errorf("Querying the console buffer requires liblxc >= 3.0"))
+	}
+
+	c, err := containerLoadByName(d.State(), d.Storage, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	ent := fileResponseEntry{}
+	ent.filename = "console.log"
+
+	if !c.IsRunning() {
+		// Hand back the content of the log file the ring buffer
+		// got dumped to when the container stopped.
+		if shared.PathExists(c.ConsoleBufferLogPath()) {
+			ent.path = c.ConsoleBufferLogPath()
+		}
+
+		return FileResponse(r, []fileResponseEntry{ent}, nil, false)
+	}
+
+	// Query the container's console ring buffer
+	console := lxc.ConsoleLogOptions{
+		ClearLog:       false,
+		ReadLog:        true,
+		ReadMax:        0,
+		WriteToLogFile: true,
+	}
+
+	logContents, err := c.ConsoleLog(console)
+	if err != nil {
+		errno, isErrno := shared.GetErrno(err)
+		if !isErrno {
+			return SmartError(err)
+		}
+
+		// The ring buffer is empty
+		if errno == syscall.ENODATA {
+			return FileResponse(r, []fileResponseEntry{ent}, nil, false)
+		}
+
+		return SmartError(err)
+	}
+
+	ent.buffer = []byte(logContents)
+	return FileResponse(r, []fileResponseEntry{ent}, nil, false)
+}
+
+func containerConsoleLogDelete(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	if !util.RuntimeLiblxcVersionAtLeast(3, 0, 0) {
+		return BadRequest(fmt.Errorf("Clearing the console buffer requires liblxc >= 3.0"))
+	}
+
+	c, err := containerLoadByName(d.State(), d.Storage, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	truncateConsoleLogFile := func(path string) error {
+		// Check that this is a regular file. We don't want to try
+		// and truncate /dev/null or something.
+		st, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		if !st.Mode().IsRegular() {
+			return fmt.Errorf("The console log is not a regular file")
+		}
+
+		return os.Truncate(path, 0)
+	}
+
+	if !c.IsRunning() {
+		return SmartError(truncateConsoleLogFile(c.ConsoleBufferLogPath()))
+	}
+
+	// Clear the container's console ring buffer
+	console := lxc.ConsoleLogOptions{
+		ClearLog:       true,
+		ReadLog:        false,
+		ReadMax:        0,
+		WriteToLogFile: false,
+	}
+
+	_, err = c.ConsoleLog(console)
+	if err != nil {
+		errno, isErrno := shared.GetErrno(err)
+		if !isErrno || errno != syscall.ENODATA {
+			return SmartError(err)
+		}
+	}
+
+	return SmartError(truncateConsoleLogFile(c.ConsoleBufferLogPath()))
+}
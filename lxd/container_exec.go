@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,6 +19,7 @@ import (
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
 
 	log "github.com/lxc/lxd/shared/log15"
 )
@@ -26,6 +28,9 @@ type execWs struct {
 	command   []string
 	container container
 	env       map[string]string
+	cwd       string
+	uid       uint32
+	gid       uint32
 
 	rootUid          int64
 	rootGid          int64
@@ -291,7 +296,7 @@ func (s *execWs) Do(op *operation) error {
 		return cmdErr
 	}
 
-	cmd, _, attachedPid, err := s.container.Exec(s.command, s.env, stdin, stdout, stderr, false)
+	cmd, _, attachedPid, err := s.container.Exec(s.command, s.env, stdin, stdout, stderr, false, s.cwd, s.uid, s.gid)
 	if err != nil {
 		return err
 	}
@@ -420,6 +425,9 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 		ws.command = post.Command
 		ws.container = c
 		ws.env = env
+		ws.cwd = post.Cwd
+		ws.uid = post.User
+		ws.gid = post.Group
 
 		ws.width = post.Width
 		ws.height = post.Height
@@ -436,8 +444,33 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 	}
 
 	run := func(op *operation) error {
-		_, cmdResult, _, cmdErr := c.Exec(post.Command, env, nil, nil, nil, true)
-		metadata := shared.Jmap{"return": cmdResult}
+		metadata := shared.Jmap{}
+
+		var stdout *os.File
+		var stderr *os.File
+		if post.RecordOutput {
+			// Keep the output around in the container's log directory
+			// so it can be retrieved once the command is done.
+			stdout, err = os.OpenFile(execOutputPath(c, op.id, "stdout"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+			if err != nil {
+				return err
+			}
+			defer stdout.Close()
+
+			stderr, err = os.OpenFile(execOutputPath(c, op.id, "stderr"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+			if err != nil {
+				return err
+			}
+			defer stderr.Close()
+
+			metadata["output"] = shared.Jmap{
+				"1": fmt.Sprintf("/%s/containers/%s/logs/%s", version.APIVersion, c.Name(), filepath.Base(stdout.Name())),
+				"2": fmt.Sprintf("/%s/containers/%s/logs/%s", version.APIVersion, c.Name(), filepath.Base(stderr.Name())),
+			}
+		}
+
+		_, cmdResult, _, cmdErr := c.Exec(post.Command, env, nil, stdout, stderr, true, post.Cwd, post.User, post.Group)
+		metadata["return"] = cmdResult
 
 		err = op.UpdateMetadata(metadata)
 		if err != nil {
@@ -456,3 +489,9 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 
 	return OperationResponse(op)
 }
+
+// execOutputPath returns the path of the file used to record the given output
+// stream of a command run through the given operation.
+func execOutputPath(c container, id string, stream string) string {
+	return filepath.Join(c.LogPath(), fmt.Sprintf("exec_%s.%s", id, stream))
+}
@@ -30,6 +30,8 @@ func containerFileHandler(d *Daemon, r *http.Request) Response {
 		return containerFileGet(c, path, r)
 	case "POST":
 		return containerFilePut(c, path, r)
+	case "DELETE":
+		return containerFileDelete(c, path, r)
 	default:
 		return NotFound
 	}
@@ -49,9 +51,13 @@ func containerFileGet(c container, path string, r *http.Request) Response {
 	}
 	defer temp.Close()
 
-	// Pul the file from the container
-	uid, gid, mode, err := c.FilePull(path, temp.Name())
+	// Symlinks are followed unless the client wants the link itself
+	follow := r.FormValue("follow") != "false"
+
+	// Pull the file from the container
+	uid, gid, mode, fileType, dirEnts, err := c.FilePull(path, temp.Name(), follow)
 	if err != nil {
+		os.Remove(temp.Name())
 		return SmartError(err)
 	}
 
@@ -59,6 +65,16 @@ func containerFileGet(c container, path string, r *http.Request) Response {
 		"X-LXD-uid":  fmt.Sprintf("%d", uid),
 		"X-LXD-gid":  fmt.Sprintf("%d", gid),
 		"X-LXD-mode": fmt.Sprintf("%04o", mode),
+		"X-LXD-type": fileType,
+	}
+
+	if fileType == "directory" {
+		os.Remove(temp.Name())
+		if dirEnts == nil {
+			dirEnts = []string{}
+		}
+
+		return SyncResponseHeaders(true, dirEnts, headers)
 	}
 
 	// Make a file response struct
@@ -71,10 +87,22 @@ func containerFileGet(c container, path string, r *http.Request) Response {
 }
 
 func containerFilePut(c container, path string, r *http.Request) Response {
-	// Extract file ownership and mode from headers
-	uid, gid, mode, _, _ := shared.ParseLXDFileHeaders(r.Header)
+	// Extract file ownership, mode, type and write mode from headers
+	uid, gid, mode, fileType, write := shared.ParseLXDFileHeaders(r.Header)
+
+	if !shared.StringInSlice(fileType, []string{"file", "directory", "symlink"}) {
+		return BadRequest(fmt.Errorf("Bad file type %s", fileType))
+	}
+
+	if !shared.StringInSlice(write, []string{"overwrite", "append", "atomic"}) {
+		return BadRequest(fmt.Errorf("Bad file write mode %s", write))
+	}
+
+	if write != "overwrite" && fileType != "file" {
+		return BadRequest(fmt.Errorf("The %s write mode is only supported for files", write))
+	}
 
-	// Write file content to a tempfile
+	// Write file content to a tempfile, for symlinks that's the target
 	temp, err := ioutil.TempFile("", "lxd_forkputfile_")
 	if err != nil {
 		return InternalError(err)
@@ -84,15 +112,26 @@ func containerFilePut(c container, path string, r *http.Request) Response {
 		os.Remove(temp.Name())
 	}()
 
-	_, err = io.Copy(temp, r.Body)
-	if err != nil {
-		return InternalError(err)
+	if fileType != "directory" {
+		_, err = io.Copy(temp, r.Body)
+		if err != nil {
+			return InternalError(err)
+		}
 	}
 
 	// Transfer the file into the container
-	err = c.FilePush(temp.Name(), path, uid, gid, mode)
+	err = c.FilePush(fileType, temp.Name(), path, uid, gid, mode, write)
 	if err != nil {
-		return InternalError(err)
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+func containerFileDelete(c container, path string, r *http.Request) Response {
+	err := c.FileRemove(path)
+	if err != nil {
+		return SmartError(err)
 	}
 
 	return EmptySyncResponse
@@ -57,7 +57,8 @@ func validLogFileName(fname string) bool {
 	return fname == "lxc.log" ||
 		fname == "lxc.conf" ||
 		strings.HasPrefix(fname, "migration_") ||
-		strings.HasPrefix(fname, "snapshot_")
+		strings.HasPrefix(fname, "snapshot_") ||
+		strings.HasPrefix(fname, "exec_")
 }
 
 func containerLogGet(d *Daemon, r *http.Request) Response {
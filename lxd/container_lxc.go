@@ -34,6 +34,7 @@ import (
 	"github.com/lxc/lxd/shared/idmap"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/osarch"
+	"github.com/lxc/lxd/shared/version"
 
 	log "github.com/lxc/lxd/shared/log15"
 )
@@ -267,6 +268,7 @@ func containerLXCCreate(s *state.State, storage storage, args db.ContainerArgs)
 		cType:        args.Ctype,
 		stateful:     args.Stateful,
 		creationDate: args.CreationDate,
+		expiryDate:   args.ExpiryDate,
 		profiles:     args.Profiles,
 		localConfig:  args.Config,
 		localDevices: args.Devices,
@@ -333,6 +335,38 @@ func containerLXCCreate(s *state.State, storage storage, args db.ContainerArgs)
 		return nil, err
 	}
 
+	err = storagePoolDevicesCheck(s, c.expandedDevices)
+	if err != nil {
+		c.Delete()
+		logger.Error("Failed creating container", ctxMap)
+		return nil, err
+	}
+
+	err = networkDevicesCheck(s, c.name, c.expandedDevices)
+	if err != nil {
+		c.Delete()
+		logger.Error("Failed creating container", ctxMap)
+		return nil, err
+	}
+
+	// Use the storage pool of the root disk, if any
+	_, rootDiskDevice, err := containerGetRootDiskDevice(c.expandedDevices)
+	if err != nil {
+		c.Delete()
+		logger.Error("Failed creating container", ctxMap)
+		return nil, err
+	}
+
+	if rootDiskDevice["pool"] != "" {
+		storage, err = storagePoolInit(s, storage, rootDiskDevice["pool"])
+		if err != nil {
+			c.Delete()
+			logger.Error("Failed creating container", ctxMap)
+			return nil, fmt.Errorf("Failed to initialize storage pool '%s': %v", rootDiskDevice["pool"], err)
+		}
+		c.storage = storage
+	}
+
 	// Setup initial idmap config
 	var idmap *idmap.IdmapSet
 	base := int64(0)
@@ -427,6 +461,7 @@ func containerLXCLoad(s *state.State, storage storage, args db.ContainerArgs) (c
 		architecture: args.Architecture,
 		cType:        args.Ctype,
 		creationDate: args.CreationDate,
+		expiryDate:   args.ExpiryDate,
 		profiles:     args.Profiles,
 		localConfig:  args.Config,
 		localDevices: args.Devices,
@@ -435,18 +470,18 @@ func containerLXCLoad(s *state.State, storage storage, args db.ContainerArgs) (c
 	// Setup finalizer
 	runtime.SetFinalizer(c, containerLXCUnload)
 
-	// Detect the storage backend
-	storage, err := storageForFilename(s, storage, shared.VarPath("containers", strings.Split(c.name, "/")[0]))
+	// Load the config
+	err := c.init()
 	if err != nil {
 		return nil, err
 	}
-	c.storage = storage
 
-	// Load the config
-	err = c.init()
+	// Detect the storage backend
+	storage, err = containerStorageGet(s, storage, c.name, c.expandedDevices)
 	if err != nil {
 		return nil, err
 	}
+	c.storage = storage
 
 	return c, nil
 }
@@ -458,6 +493,7 @@ type containerLXC struct {
 	cType        db.ContainerType
 	creationDate time.Time
 	ephemeral    bool
+	expiryDate   time.Time
 	id           int
 	name         string
 	stateful     bool
@@ -1046,6 +1082,25 @@ func (c *containerLXC) initLXC(config bool) error {
 		return err
 	}
 
+	if util.RuntimeLiblxcVersionAtLeast(3, 0, 0) {
+		// Keep the console output in a ring buffer, dumped to a
+		// file when requested or when the container stops.
+		err = lxcSetConfigItem(cc, "lxc.console.buffer.size", "auto")
+		if err != nil {
+			return err
+		}
+
+		err = lxcSetConfigItem(cc, "lxc.console.size", "auto")
+		if err != nil {
+			return err
+		}
+
+		err = lxcSetConfigItem(cc, "lxc.console.logfile", c.ConsoleBufferLogPath())
+		if err != nil {
+			return err
+		}
+	}
+
 	// Setup the hostname
 	err = lxcSetConfigItem(cc, "lxc.uts.name", c.Name())
 	if err != nil {
@@ -1427,6 +1482,9 @@ func (c *containerLXC) initLXC(config bool) error {
 			isReadOnly := shared.IsTrue(m["readonly"])
 			isRecursive := shared.IsTrue(m["recursive"])
 			isFile := !shared.IsDir(srcPath) && !deviceIsBlockdev(srcPath)
+			if isCustomVolumeDevice(m) {
+				isFile = false
+			}
 
 			// Deal with a rootfs
 			if tgtPath == "" {
@@ -1590,6 +1648,10 @@ func (c *containerLXC) startCommon() (string, error) {
 		m := c.expandedDevices[name]
 		switch m["type"] {
 		case "disk":
+			if isCustomVolumeDevice(m) {
+				continue
+			}
+
 			if m["source"] != "" && !shared.PathExists(shared.HostPath(m["source"])) {
 				return "", fmt.Errorf("Missing source '%s' for disk '%s'", m["source"], name)
 			}
@@ -1604,6 +1666,54 @@ func (c *containerLXC) startCommon() (string, error) {
 		}
 	}
 
+	// The network may have been reconfigured since the nics were set up
+	err = networkDevicesCheck(c.state, c.name, c.expandedDevices)
+	if err != nil {
+		return "", err
+	}
+
+	// Update the static DHCP entries of the managed bridges
+	err = networkUpdateStaticDevices(c.state, c.expandedDevices)
+	if err != nil {
+		return "", err
+	}
+
+	// Install the spoofing protection rules ahead of the veth being created
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if m["type"] != "nic" || !deviceNetworkFilteringEnabled(m) {
+			continue
+		}
+
+		m, err = c.fillNetworkDevice(name, m)
+		if err != nil {
+			return "", err
+		}
+
+		err = deviceNetworkFiltersApply(m["host_name"], m)
+		if err != nil {
+			return "", fmt.Errorf("Failed to set up filtering for nic '%s': %s", name, err)
+		}
+	}
+
+	// Same for the network ACLs
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if m["type"] != "nic" || m["security.acls"] == "" {
+			continue
+		}
+
+		m, err = c.fillNetworkDevice(name, m)
+		if err != nil {
+			return "", err
+		}
+
+		err = networkACLApply(c.state, m["host_name"], m)
+		if err != nil {
+			return "", fmt.Errorf("Failed to set up network ACLs for nic '%s': %s", name, err)
+		}
+	}
+
 	// Load any required kernel modules
 	kernelModules := c.expandedConfig["linux.kernel_modules"]
 	if kernelModules != "" {
@@ -1837,6 +1947,14 @@ func (c *containerLXC) Start(stateful bool) error {
 			return err
 		}
 
+		err = c.startProxyDevices()
+		if err != nil {
+			logger.Error("Failed starting container", ctxMap)
+			op.Done(err)
+			c.Stop(false)
+			return err
+		}
+
 		logger.Info("Started container", ctxMap)
 
 		return err
@@ -1908,6 +2026,15 @@ func (c *containerLXC) Start(stateful bool) error {
 			err, lxcLog)
 	}
 
+	// Start the proxies now that the container's namespaces exist
+	err = c.startProxyDevices()
+	if err != nil {
+		logger.Error("Failed starting container", ctxMap)
+		op.Done(err)
+		c.Stop(false)
+		return err
+	}
+
 	logger.Info("Started container", ctxMap)
 
 	return nil
@@ -1997,6 +2124,9 @@ func (c *containerLXC) OnStart() error {
 		return err
 	}
 
+	// Sent from here so that every start is covered, not just API ones
+	eventSendLifecycle("container-started", fmt.Sprintf("/%s/containers/%s", version.APIVersion, c.name), nil, nil)
+
 	return nil
 }
 
@@ -2190,6 +2320,13 @@ func (c *containerLXC) OnStop(target string) error {
 			"stateful":  false}
 
 		logger.Info(fmt.Sprintf("Container initiated %s", target), ctxMap)
+
+		action := "container-shutdown"
+		if target == "reboot" {
+			action = "container-restarted"
+		}
+
+		eventSendLifecycle(action, fmt.Sprintf("/%s/containers/%s", version.APIVersion, c.name), nil, nil)
 	}
 
 	go func(c *containerLXC, target string, op *lxcContainerOperation) {
@@ -2222,6 +2359,15 @@ func (c *containerLXC) OnStop(target string) error {
 			logger.Error("Unable to remove disk devices", log.Ctx{"container": c.Name(), "err": err})
 		}
 
+		// Release the custom volumes
+		c.detachCustomVolumes()
+
+		// Remove the spoofing protection rules and network ACLs
+		c.removeNetworkFilters()
+
+		// Stop the proxies
+		c.removeProxyDevices()
+
 		// Reboot the container
 		if target == "reboot" {
 			// Start the container again
@@ -2361,6 +2507,7 @@ func (c *containerLXC) Render() (interface{}, error) {
 			Ephemeral:       c.ephemeral,
 			ExpandedConfig:  c.expandedConfig,
 			ExpandedDevices: c.expandedDevices,
+			ExpiryDate:      c.expiryDate,
 			Name:            c.name,
 			Profiles:        c.profiles,
 			Stateful:        c.stateful,
@@ -2406,6 +2553,7 @@ func (c *containerLXC) RenderState() (*api.ContainerState, error) {
 
 	if c.IsRunning() {
 		pid := c.InitPID()
+		status.CPU = c.cpuState()
 		status.Disk = c.diskState()
 		status.Memory = c.memoryState()
 		status.Network = c.networkState()
@@ -2577,6 +2725,13 @@ func (c *containerLXC) Delete() error {
 			return err
 		}
 
+		// Remove all backups
+		err = os.RemoveAll(shared.VarPath("backups", c.Name()))
+		if err != nil {
+			logger.Warn("Failed to delete backups", log.Ctx{"name": c.Name(), "err": err})
+			return err
+		}
+
 		// Clean things up
 		c.cleanup()
 
@@ -2598,6 +2753,22 @@ func (c *containerLXC) Delete() error {
 		return err
 	}
 
+	// Drop the static DHCP entries of the container
+	if !c.IsSnapshot() {
+		err := networkUpdateStaticDevices(c.state, c.expandedDevices)
+		if err != nil {
+			logger.Warn("Failed to update static DHCP entries", log.Ctx{"name": c.Name(), "err": err})
+		}
+	}
+
+	// Remove the logs, along with any recorded exec output
+	if !c.IsSnapshot() {
+		err := os.RemoveAll(c.LogPath())
+		if err != nil {
+			logger.Warn("Failed to remove container logs", log.Ctx{"name": c.Name(), "err": err})
+		}
+	}
+
 	logger.Info("Deleted container", ctxMap)
 
 	return nil
@@ -2634,6 +2805,15 @@ func (c *containerLXC) Rename(newName string) error {
 		}
 	}
 
+	// Rename the backups path
+	if !c.IsSnapshot() && shared.PathExists(shared.VarPath("backups", oldName)) {
+		err := os.Rename(shared.VarPath("backups", oldName), shared.VarPath("backups", newName))
+		if err != nil {
+			logger.Error("Failed renaming container", ctxMap)
+			return err
+		}
+	}
+
 	// Rename the storage entry
 	if c.IsSnapshot() {
 		if err := c.storage.ContainerSnapshotRename(c, newName); err != nil {
@@ -2683,6 +2863,14 @@ func (c *containerLXC) Rename(newName string) error {
 
 	c.cConfig = false
 
+	// Update the static DHCP entries of the container
+	if !c.IsSnapshot() {
+		err := networkUpdateStaticDevices(c.state, c.expandedDevices)
+		if err != nil {
+			logger.Warn("Failed to update static DHCP entries", ctxMap)
+		}
+	}
+
 	logger.Info("Renamed container", ctxMap)
 
 	return nil
@@ -2921,6 +3109,23 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 		return err
 	}
 
+	err = storagePoolDevicesCheck(c.state, c.expandedDevices)
+	if err != nil {
+		return err
+	}
+
+	err = networkDevicesCheck(c.state, c.name, c.expandedDevices)
+	if err != nil {
+		return err
+	}
+
+	// The storage pool of an existing container can't be changed
+	_, oldRootDiskDevice, _ := containerGetRootDiskDevice(oldExpandedDevices)
+	_, newRootDiskDevice, _ := containerGetRootDiskDevice(c.expandedDevices)
+	if oldRootDiskDevice["pool"] != newRootDiskDevice["pool"] {
+		return fmt.Errorf("The storage pool of the root disk can't be changed.")
+	}
+
 	// Run through initLXC to catch anything we missed
 	if c.c != nil {
 		c.c.Release()
@@ -3299,6 +3504,11 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 				if err != nil {
 					return err
 				}
+			} else if m["type"] == "proxy" {
+				err = c.removeProxyDevice(k, m)
+				if err != nil {
+					return err
+				}
 			}
 		}
 
@@ -3317,6 +3527,11 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 				if err != nil {
 					return err
 				}
+			} else if m["type"] == "proxy" {
+				err = c.insertProxyDevice(k, m)
+				if err != nil {
+					return err
+				}
 			}
 		}
 
@@ -3461,6 +3676,14 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 	// Success, update the closure to mark that the changes should be kept.
 	undoChanges = false
 
+	// Update the static DHCP entries of the container
+	if !c.IsSnapshot() {
+		err = networkUpdateStaticDevices(c.state, oldExpandedDevices, c.expandedDevices)
+		if err != nil {
+			logger.Warn("Failed to update static DHCP entries", log.Ctx{"name": c.Name(), "err": err})
+		}
+	}
+
 	return nil
 }
 
@@ -4092,15 +4315,21 @@ func (c *containerLXC) FileExists(path string) error {
 	return nil
 }
 
-func (c *containerLXC) FilePull(srcpath string, dstpath string) (int64, int64, os.FileMode, error) {
+func (c *containerLXC) FilePull(srcpath string, dstpath string, follow bool) (int64, int64, os.FileMode, string, []string, error) {
 	// Setup container storage if needed
 	if !c.IsRunning() {
 		err := c.StorageStart()
 		if err != nil {
-			return -1, -1, 0, err
+			return -1, -1, 0, "", nil, err
 		}
 	}
 
+	// Symlinks are followed unless asked otherwise
+	followMode := "follow"
+	if !follow {
+		followMode = "nofollow"
+	}
+
 	// Get the file from the container
 	out, err := shared.RunCommand(
 		c.state.OS.ExecPath,
@@ -4109,19 +4338,22 @@ func (c *containerLXC) FilePull(srcpath string, dstpath string) (int64, int64, o
 		fmt.Sprintf("%d", c.InitPID()),
 		dstpath,
 		srcpath,
+		followMode,
 	)
 
 	// Tear down container storage if needed
 	if !c.IsRunning() {
 		err := c.StorageStop()
 		if err != nil {
-			return -1, -1, 0, err
+			return -1, -1, 0, "", nil, err
 		}
 	}
 
 	uid := int64(-1)
 	gid := int64(-1)
 	mode := -1
+	fileType := "unknown"
+	var dirEnts []string
 	var errStr string
 
 	// Process forkgetfile response
@@ -4139,17 +4371,17 @@ func (c *containerLXC) FilePull(srcpath string, dstpath string) (int64, int64, o
 		if strings.HasPrefix(line, "errno: ") {
 			errno := strings.TrimPrefix(line, "errno: ")
 			if errno == "2" {
-				return -1, -1, 0, os.ErrNotExist
+				return -1, -1, 0, "", nil, os.ErrNotExist
 			}
 
-			return -1, -1, 0, fmt.Errorf(errStr)
+			return -1, -1, 0, "", nil, fmt.Errorf(errStr)
 		}
 
 		// Extract the uid
 		if strings.HasPrefix(line, "uid: ") {
 			uid, err = strconv.ParseInt(strings.TrimPrefix(line, "uid: "), 10, 64)
 			if err != nil {
-				return -1, -1, 0, err
+				return -1, -1, 0, "", nil, err
 			}
 
 			continue
@@ -4159,7 +4391,7 @@ func (c *containerLXC) FilePull(srcpath string, dstpath string) (int64, int64, o
 		if strings.HasPrefix(line, "gid: ") {
 			gid, err = strconv.ParseInt(strings.TrimPrefix(line, "gid: "), 10, 64)
 			if err != nil {
-				return -1, -1, 0, err
+				return -1, -1, 0, "", nil, err
 			}
 
 			continue
@@ -4169,22 +4401,36 @@ func (c *containerLXC) FilePull(srcpath string, dstpath string) (int64, int64, o
 		if strings.HasPrefix(line, "mode: ") {
 			mode, err = strconv.Atoi(strings.TrimPrefix(line, "mode: "))
 			if err != nil {
-				return -1, -1, 0, err
+				return -1, -1, 0, "", nil, err
 			}
 
 			continue
 		}
 
+		// Extract the type
+		if strings.HasPrefix(line, "type: ") {
+			fileType = strings.TrimPrefix(line, "type: ")
+			continue
+		}
+
+		// Extract the directory entries, \n in names got swapped for \0
+		if strings.HasPrefix(line, "entry: ") {
+			ent := strings.TrimPrefix(line, "entry: ")
+			dirEnts = append(dirEnts, strings.Replace(ent, "\x00", "\n", -1))
+			continue
+		}
+
 		logger.Debugf("forkgetfile: %s", line)
 	}
 
 	if err != nil {
-		return -1, -1, 0, fmt.Errorf(
-			"Error calling 'lxd forkgetfile %s %d %s %s': err='%v'",
+		return -1, -1, 0, "", nil, fmt.Errorf(
+			"Error calling 'lxd forkgetfile %s %d %s %s %s': err='%v'",
 			c.RootfsPath(),
 			c.InitPID(),
 			dstpath,
 			srcpath,
+			followMode,
 			err)
 	}
 
@@ -4192,7 +4438,7 @@ func (c *containerLXC) FilePull(srcpath string, dstpath string) (int64, int64, o
 	if !c.IsRunning() {
 		idmapset, err := c.LastIdmapSet()
 		if err != nil {
-			return -1, -1, 0, err
+			return -1, -1, 0, "", nil, err
 		}
 
 		if idmapset != nil {
@@ -4200,14 +4446,20 @@ func (c *containerLXC) FilePull(srcpath string, dstpath string) (int64, int64, o
 		}
 	}
 
-	return uid, gid, os.FileMode(mode), nil
+	return uid, gid, os.FileMode(mode), fileType, dirEnts, nil
 }
 
-func (c *containerLXC) FilePush(srcpath string, dstpath string, uid int64, gid int64, mode int) error {
+func (c *containerLXC) FilePush(fileType string, srcpath string, dstpath string, uid int64, gid int64, mode int, write string) error {
 	var rootUid int64
 	var rootGid int64
 	var errStr string
 
+	// Directories need the execute bit to be traversed
+	defaultMode := 0640
+	if fileType == "directory" {
+		defaultMode = 0750
+	}
+
 	// Map uid and gid if needed
 	if !c.IsRunning() {
 		idmapset, err := c.LastIdmapSet()
@@ -4237,12 +4489,14 @@ func (c *containerLXC) FilePush(srcpath string, dstpath string, uid int64, gid i
 		fmt.Sprintf("%d", c.InitPID()),
 		srcpath,
 		dstpath,
+		fileType,
+		write,
 		fmt.Sprintf("%d", uid),
 		fmt.Sprintf("%d", gid),
 		fmt.Sprintf("%d", mode),
 		fmt.Sprintf("%d", rootUid),
 		fmt.Sprintf("%d", rootGid),
-		fmt.Sprintf("%d", int(os.FileMode(0640)&os.ModePerm)),
+		fmt.Sprintf("%d", defaultMode),
 	)
 
 	// Tear down container storage if needed
@@ -4253,7 +4507,7 @@ func (c *containerLXC) FilePush(srcpath string, dstpath string, uid int64, gid i
 		}
 	}
 
-	// Process forkgetfile response
+	// Process forkputfile response
 	for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
 		if line == "" {
 			continue
@@ -4277,17 +4531,19 @@ func (c *containerLXC) FilePush(srcpath string, dstpath string, uid int64, gid i
 
 	if err != nil {
 		return fmt.Errorf(
-			"Error calling 'lxd forkputfile %s %d %s %s %d %d %d %d %d %d': err='%v'",
+			"Error calling 'lxd forkputfile %s %d %s %s %s %s %d %d %d %d %d %d': err='%v'",
 			c.RootfsPath(),
 			c.InitPID(),
 			srcpath,
 			dstpath,
+			fileType,
+			write,
 			uid,
 			gid,
 			mode,
 			rootUid,
 			rootGid,
-			int(os.FileMode(0640)&os.ModePerm),
+			defaultMode,
 			err)
 	}
 
@@ -4356,14 +4612,57 @@ func (c *containerLXC) FileRemove(path string) error {
 	return nil
 }
 
-func (c *containerLXC) Exec(command []string, env map[string]string, stdin *os.File, stdout *os.File, stderr *os.File, wait bool) (*exec.Cmd, int, int, error) {
+func (c *containerLXC) Console(terminal *os.File) *exec.Cmd {
+	args := []string{
+		c.state.OS.ExecPath,
+		"forkconsole",
+		c.name,
+		c.state.OS.LxcPath,
+		filepath.Join(c.LogPath(), "lxc.conf"),
+		"tty=0",
+		"escape=-1"}
+
+	cmd := exec.Cmd{}
+	cmd.Path = c.state.OS.ExecPath
+	cmd.Args = args
+	cmd.Stdin = terminal
+	cmd.Stdout = terminal
+	cmd.Stderr = terminal
+	return &cmd
+}
+
+func (c *containerLXC) ConsoleLog(opts lxc.ConsoleLogOptions) (string, error) {
+	// Load the go-lxc struct
+	err := c.initLXC(false)
+	if err != nil {
+		return "", err
+	}
+
+	msg, err := c.c.ConsoleLog(opts)
+	if err != nil {
+		return "", err
+	}
+
+	return string(msg), nil
+}
+
+func (c *containerLXC) Exec(command []string, env map[string]string, stdin *os.File, stdout *os.File, stderr *os.File, wait bool, cwd string, uid uint32, gid uint32) (*exec.Cmd, int, int, error) {
 	envSlice := []string{}
 
 	for k, v := range env {
 		envSlice = append(envSlice, fmt.Sprintf("%s=%s", k, v))
 	}
 
-	args := []string{c.state.OS.ExecPath, "forkexec", c.name, c.state.OS.LxcPath, filepath.Join(c.LogPath(), "lxc.conf")}
+	args := []string{
+		c.state.OS.ExecPath,
+		"forkexec",
+		c.name,
+		c.state.OS.LxcPath,
+		filepath.Join(c.LogPath(), "lxc.conf"),
+		cwd,
+		fmt.Sprintf("%d", uid),
+		fmt.Sprintf("%d", gid),
+	}
 
 	args = append(args, "--")
 	args = append(args, "env")
@@ -4427,6 +4726,19 @@ func (c *containerLXC) Exec(command []string, env map[string]string, stdin *os.F
 	return nil, 0, attachedPid, nil
 }
 
+func (c *containerLXC) cpuState() api.ContainerStateCPU {
+	cpu := api.ContainerStateCPU{}
+
+	// CPU time in nanoseconds
+	value, err := c.CGroupGet("cpuacct.usage")
+	valueInt, err1 := strconv.ParseInt(value, 10, 64)
+	if err == nil && err1 == nil {
+		cpu.Usage = valueInt
+	}
+
+	return cpu
+}
+
 func (c *containerLXC) diskState() map[string]api.ContainerStateDisk {
 	disk := map[string]api.ContainerStateDisk{}
 
@@ -5318,10 +5630,30 @@ func (c *containerLXC) fillNetworkDevice(name string, m types.Device) (types.Dev
 		newDevice["name"] = volatileName
 	}
 
-	// Fill in the host name (but don't generate a static one ourselves)
+	// Fill in the host name (but don't generate a static one ourselves,
+	// unless the filtering rules need to know it in advance)
 	if m["host_name"] == "" && shared.StringInSlice(m["nictype"], []string{"bridged", "p2p"}) {
 		configKey := fmt.Sprintf("volatile.%s.host_name", name)
-		newDevice["host_name"] = c.localConfig[configKey]
+		volatileHostName := c.localConfig[configKey]
+		if volatileHostName == "" && (deviceNetworkFilteringEnabled(m) || m["security.acls"] != "") {
+			volatileHostName = deviceNextVeth()
+
+			// Update the database
+			err = updateKey(configKey, volatileHostName)
+			if err != nil {
+				// Check if something else filled it in behind our back
+				value, err1 := c.db.ContainerConfigGet(c.id, configKey)
+				if err1 != nil || value == "" {
+					return nil, err
+				}
+
+				volatileHostName = value
+			}
+
+			c.localConfig[configKey] = volatileHostName
+			c.expandedConfig[configKey] = volatileHostName
+		}
+		newDevice["host_name"] = volatileHostName
 	}
 
 	return newDevice, nil
@@ -5349,15 +5681,34 @@ func (c *containerLXC) insertNetworkDevice(name string, m types.Device) (types.D
 		return nil, fmt.Errorf("Can't insert device into stopped container")
 	}
 
+	// Install the spoofing protection rules
+	if deviceNetworkFilteringEnabled(m) {
+		err = deviceNetworkFiltersApply(m["host_name"], m)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to set up filtering for nic '%s': %s", name, err)
+		}
+	}
+
+	// Install the network ACLs
+	if m["security.acls"] != "" {
+		err = networkACLApply(c.state, m["host_name"], m)
+		if err != nil {
+			c.removeNetworkDeviceFilters(m)
+			return nil, fmt.Errorf("Failed to set up network ACLs for nic '%s': %s", name, err)
+		}
+	}
+
 	// Create the interface
 	devName, err := c.createNetworkDevice(name, m)
 	if err != nil {
+		c.removeNetworkDeviceFilters(m)
 		return nil, err
 	}
 
 	// Add the interface to the container
 	err = c.c.AttachInterface(devName, m["name"])
 	if err != nil {
+		c.removeNetworkDeviceFilters(m)
 		return nil, fmt.Errorf("Failed to attach interface: %s: %s", devName, err)
 	}
 
@@ -5402,9 +5753,177 @@ func (c *containerLXC) removeNetworkDevice(name string, m types.Device) error {
 		deviceRemoveInterface(hostName)
 	}
 
+	// Remove the spoofing protection rules and network ACLs
+	c.removeNetworkDeviceFilters(m)
+
+	return nil
+}
+
+// removeNetworkDeviceFilters removes the spoofing protection rules and
+// network ACLs of the (filled) nic.
+func (c *containerLXC) removeNetworkDeviceFilters(m types.Device) {
+	if deviceNetworkFilteringEnabled(m) {
+		deviceNetworkFiltersRemove(m["host_name"], m)
+	}
+
+	if m["security.acls"] != "" {
+		err := networkACLRemove(m["host_name"])
+		if err != nil {
+			logger.Error("Unable to remove network ACLs", log.Ctx{"container": c.Name(), "device": m["name"], "err": err})
+		}
+	}
+}
+
+// removeNetworkFilters removes the spoofing protection rules and network
+// ACLs of all the container's nics.
+func (c *containerLXC) removeNetworkFilters() {
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if m["type"] != "nic" || (!deviceNetworkFilteringEnabled(m) && m["security.acls"] == "") {
+			continue
+		}
+
+		m, err := c.fillNetworkDevice(name, m)
+		if err != nil {
+			logger.Error("Unable to remove network filters", log.Ctx{"container": c.Name(), "device": name, "err": err})
+			continue
+		}
+
+		c.removeNetworkDeviceFilters(m)
+	}
+}
+
+// Proxy device handling
+func (c *containerLXC) proxyPidPath(name string) string {
+	return filepath.Join(c.DevicesPath(), fmt.Sprintf("proxy.%s", name))
+}
+
+func (c *containerLXC) insertProxyDevice(name string, m types.Device) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("Can't insert device into stopped container")
+	}
+
+	listen, err := proxyParseAddr(m["listen"])
+	if err != nil {
+		return err
+	}
+
+	connect, err := proxyParseAddr(m["connect"])
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(c.DevicesPath(), 0711)
+	if err != nil {
+		return err
+	}
+
+	// The host side socket is set up here and handed over to forkproxy,
+	// which then runs in the container's namespaces
+	file, err := proxyListen(listen)
+	if err != nil {
+		return fmt.Errorf("Failed to listen on %s: %s", listen, err)
+	}
+	defer file.Close()
+
+	logFile, err := os.OpenFile(filepath.Join(c.LogPath(), fmt.Sprintf("proxy.%s.log", name)), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(
+		c.state.OS.ExecPath,
+		"forkproxy",
+		fmt.Sprintf("%d", c.InitPID()),
+		connect.String(),
+		listen.String())
+	cmd.ExtraFiles = []*os.File{file}
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("Failed to start proxy '%s': %s", name, err)
+	}
+
+	err = ioutil.WriteFile(c.proxyPidPath(name), []byte(fmt.Sprintf("%d\n", cmd.Process.Pid)), 0600)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	// Reap it once it's killed
+	go cmd.Wait()
+
+	return nil
+}
+
+// removeProxyDevice kills the forkproxy process of the device, including one
+// left behind by a previous run of the daemon.
+func (c *containerLXC) removeProxyDevice(name string, m types.Device) error {
+	pidPath := c.proxyPidPath(name)
+
+	content, err := ioutil.ReadFile(pidPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err == nil && shared.PathExists(fmt.Sprintf("/proc/%d", pid)) {
+		cmdline, _ := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+		if strings.Contains(string(cmdline), "\x00forkproxy\x00") {
+			err = syscall.Kill(pid, syscall.SIGKILL)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Clean up the host side socket
+	listen, err := proxyParseAddr(m["listen"])
+	if err == nil {
+		proxyRemoveSocket(listen)
+	}
+
+	return os.Remove(pidPath)
+}
+
+func (c *containerLXC) startProxyDevices() error {
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if m["type"] != "proxy" {
+			continue
+		}
+
+		err := c.insertProxyDevice(name, m)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+func (c *containerLXC) removeProxyDevices() {
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if m["type"] != "proxy" {
+			continue
+		}
+
+		err := c.removeProxyDevice(name, m)
+		if err != nil {
+			logger.Error("Unable to remove proxy device", log.Ctx{"container": c.Name(), "device": name, "err": err})
+		}
+	}
+}
+
 // Disk device handling
 func (c *containerLXC) createDiskDevice(name string, m types.Device) (string, error) {
 	// Prepare all the paths
@@ -5413,6 +5932,15 @@ func (c *containerLXC) createDiskDevice(name string, m types.Device) (string, er
 	devName := fmt.Sprintf("disk.%s", strings.Replace(tgtPath, "/", "-", -1))
 	devPath := filepath.Join(c.DevicesPath(), devName)
 
+	// Custom volumes need to be made available on the host first
+	if isCustomVolumeDevice(m) {
+		volumePath, err := storagePoolVolumeAttach(c.state, c.storage, c, m["pool"], m["source"])
+		if err != nil {
+			return "", fmt.Errorf("Failed to attach storage volume '%s' on pool '%s': %v", m["source"], m["pool"], err)
+		}
+		srcPath = volumePath
+	}
+
 	// Check if read-only
 	isOptional := shared.IsTrue(m["optional"])
 	isReadOnly := shared.IsTrue(m["readonly"])
@@ -5565,9 +6093,33 @@ func (c *containerLXC) removeDiskDevice(name string, m types.Device) error {
 		return err
 	}
 
+	// Release the custom volume
+	if isCustomVolumeDevice(m) {
+		err = storagePoolVolumeDetach(c.state, c.storage, c, m["pool"], m["source"])
+		if err != nil {
+			return fmt.Errorf("Failed to detach storage volume '%s' on pool '%s': %v", m["source"], m["pool"], err)
+		}
+	}
+
 	return nil
 }
 
+// detachCustomVolumes releases the custom volumes used by the container's
+// disk devices once it's stopped.
+func (c *containerLXC) detachCustomVolumes() {
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if !isCustomVolumeDevice(m) {
+			continue
+		}
+
+		err := storagePoolVolumeDetach(c.state, c.storage, c, m["pool"], m["source"])
+		if err != nil {
+			logger.Error("Unable to detach storage volume", log.Ctx{"container": c.Name(), "device": name, "err": err})
+		}
+	}
+}
+
 func (c *containerLXC) removeDiskDevices() error {
 	// Check that we indeed have devices to remove
 	if !shared.PathExists(c.DevicesPath()) {
@@ -5655,6 +6207,8 @@ func (c *containerLXC) getDiskLimits() (map[string]deviceBlockLimit, error) {
 		source := shared.HostPath(m["source"])
 		if source == "" {
 			source = c.RootfsPath()
+		} else if isCustomVolumeDevice(m) {
+			source = storagePoolVolumeMountPoint(m["pool"], m["source"])
 		}
 
 		// Don't try to resolve the block device behind a non-existing path
@@ -5960,6 +6514,9 @@ func (c *containerLXC) Architecture() int {
 func (c *containerLXC) CreationDate() time.Time {
 	return c.creationDate
 }
+func (c *containerLXC) ExpiryDate() time.Time {
+	return c.expiryDate
+}
 func (c *containerLXC) ExpandedConfig() map[string]string {
 	return c.expandedConfig
 }
@@ -6088,6 +6645,10 @@ func (c *containerLXC) LogFilePath() string {
 	return filepath.Join(c.LogPath(), "lxc.log")
 }
 
+func (c *containerLXC) ConsoleBufferLogPath() string {
+	return filepath.Join(c.LogPath(), "console.log")
+}
+
 func (c *containerLXC) RootfsPath() string {
 	return filepath.Join(c.Path(), "rootfs")
 }
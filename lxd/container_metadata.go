@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+func containerMetadataGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	// Load the container
+	c, err := containerLoadByName(d.State(), d.Storage, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Start the storage if needed
+	if !c.IsRunning() {
+		err := c.StorageStart()
+		if err != nil {
+			return SmartError(err)
+		}
+		defer c.StorageStop()
+	}
+
+	// If missing, just return empty result
+	metadataPath := filepath.Join(c.Path(), "metadata.yaml")
+	if !shared.PathExists(metadataPath) {
+		return SyncResponse(true, api.ImageMetadata{})
+	}
+
+	// Read the metadata
+	data, err := ioutil.ReadFile(metadataPath)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	// Parse into the API struct
+	metadata := api.ImageMetadata{}
+	err = yaml.Unmarshal(data, &metadata)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, metadata)
+}
+
+func containerMetadataPut(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	// Load the container
+	c, err := containerLoadByName(d.State(), d.Storage, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Read the new metadata
+	metadata := api.ImageMetadata{}
+	if err := json.NewDecoder(r.Body).Decode(&metadata); err != nil {
+		return BadRequest(err)
+	}
+
+	// Start the storage if needed
+	if !c.IsRunning() {
+		err := c.StorageStart()
+		if err != nil {
+			return SmartError(err)
+		}
+		defer c.StorageStop()
+	}
+
+	// Write as YAML
+	data, err := yaml.Marshal(metadata)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	metadataPath := filepath.Join(c.Path(), "metadata.yaml")
+	err = ioutil.WriteFile(metadataPath, data, 0644)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+// Return a list of templates used in a container or the content of a template
+func containerMetadataTemplatesGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	// Load the container
+	c, err := containerLoadByName(d.State(), d.Storage, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Start the storage if needed
+	if !c.IsRunning() {
+		err := c.StorageStart()
+		if err != nil {
+			return SmartError(err)
+		}
+		defer c.StorageStop()
+	}
+
+	// Check if the template is specified
+	templateName := r.FormValue("path")
+	if templateName == "" {
+		// List templates
+		templates := []string{}
+
+		if !shared.PathExists(c.TemplatesPath()) {
+			return SyncResponse(true, templates)
+		}
+
+		filesInfo, err := ioutil.ReadDir(c.TemplatesPath())
+		if err != nil {
+			return InternalError(err)
+		}
+
+		for _, info := range filesInfo {
+			if !info.IsDir() {
+				templates = append(templates, info.Name())
+			}
+		}
+
+		return SyncResponse(true, templates)
+	}
+
+	// Check if the template exists
+	templatePath, err := containerTemplatePath(c, templateName)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if !shared.PathExists(templatePath) {
+		return NotFound
+	}
+
+	// Copy the template to a temporary file, as the container storage
+	// may be stopped by the time the response gets rendered.
+	template, err := os.Open(templatePath)
+	if err != nil {
+		return SmartError(err)
+	}
+	defer template.Close()
+
+	tempfile, err := ioutil.TempFile("", "lxd_template")
+	if err != nil {
+		return SmartError(err)
+	}
+	defer tempfile.Close()
+
+	_, err = io.Copy(tempfile, template)
+	if err != nil {
+		os.Remove(tempfile.Name())
+		return InternalError(err)
+	}
+
+	files := make([]fileResponseEntry, 1)
+	files[0].identifier = templateName
+	files[0].path = tempfile.Name()
+	files[0].filename = templateName
+	return FileResponse(r, files, nil, true)
+}
+
+// Add or update a container template file
+func containerMetadataTemplatesPostPut(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	// Load the container
+	c, err := containerLoadByName(d.State(), d.Storage, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Check that the template name is specified
+	templateName := r.FormValue("path")
+	if templateName == "" {
+		return BadRequest(fmt.Errorf("missing path argument"))
+	}
+
+	templatePath, err := containerTemplatePath(c, templateName)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// Start the storage if needed
+	if !c.IsRunning() {
+		err := c.StorageStart()
+		if err != nil {
+			return SmartError(err)
+		}
+		defer c.StorageStop()
+	}
+
+	if !shared.PathExists(c.TemplatesPath()) {
+		err := os.MkdirAll(c.TemplatesPath(), 0711)
+		if err != nil {
+			return SmartError(err)
+		}
+	}
+
+	if r.Method == "POST" && shared.PathExists(templatePath) {
+		return BadRequest(fmt.Errorf("Template already exists"))
+	}
+
+	if r.Method == "PUT" && !shared.PathExists(templatePath) {
+		return NotFound
+	}
+
+	// Write the new template
+	template, err := os.OpenFile(templatePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return SmartError(err)
+	}
+	defer template.Close()
+
+	_, err = io.Copy(template, r.Body)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+// Delete a container template
+func containerMetadataTemplatesDelete(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	// Load the container
+	c, err := containerLoadByName(d.State(), d.Storage, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Check that the template name is specified
+	templateName := r.FormValue("path")
+	if templateName == "" {
+		return BadRequest(fmt.Errorf("missing path argument"))
+	}
+
+	templatePath, err := containerTemplatePath(c, templateName)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// Start the storage if needed
+	if !c.IsRunning() {
+		err := c.StorageStart()
+		if err != nil {
+			return SmartError(err)
+		}
+		defer c.StorageStop()
+	}
+
+	if !shared.PathExists(templatePath) {
+		return NotFound
+	}
+
+	err = os.Remove(templatePath)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+// Return the full path of a container template.
+func containerTemplatePath(c container, filename string) (string, error) {
+	if strings.Contains(filename, "/") || filename == "." || filename == ".." {
+		return "", fmt.Errorf("Invalid template filename")
+	}
+
+	return filepath.Join(c.TemplatesPath(), filename), nil
+}
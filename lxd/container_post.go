@@ -2,12 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 
 	"github.com/gorilla/mux"
 
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
 )
 
 func containerPost(d *Daemon, r *http.Request) Response {
@@ -52,7 +54,14 @@ func containerPost(d *Daemon, r *http.Request) Response {
 	}
 
 	run := func(*operation) error {
-		return c.Rename(body.Name)
+		err := c.Rename(body.Name)
+		if err != nil {
+			return err
+		}
+
+		eventSendLifecycle("container-renamed", fmt.Sprintf("/%s/containers/%s", version.APIVersion, name),
+			map[string]interface{}{"new_name": body.Name}, eventRequestor(r))
+		return nil
 	}
 
 	resources := map[string][]string{}
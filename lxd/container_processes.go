@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// The unit of the CPU times in /proc/<pid>/stat is fixed to 1/100th of a
+// second for userspace, regardless of the kernel's internal tick rate.
+const procClockTicks = 100
+
+func containerProcessesGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	c, err := containerLoadByName(d.State(), d.Storage, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if !c.IsRunning() {
+		return BadRequest(fmt.Errorf("Container is not running."))
+	}
+
+	processes, err := containerProcesses(c)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, processes)
+}
+
+func containerProcessesPost(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	c, err := containerLoadByName(d.State(), d.Storage, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	req := api.ContainerProcessSignalPost{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Signal <= 0 || req.Signal > 64 {
+		return BadRequest(fmt.Errorf("Invalid signal %d", req.Signal))
+	}
+
+	if !c.IsRunning() {
+		return BadRequest(fmt.Errorf("Container is not running."))
+	}
+
+	processes, err := containerProcesses(c)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	for _, process := range processes {
+		if process.ContainerPID != req.PID {
+			continue
+		}
+
+		err := syscall.Kill(int(process.PID), syscall.Signal(req.Signal))
+		if err != nil {
+			if err == syscall.ESRCH {
+				return NotFound
+			}
+
+			return SmartError(err)
+		}
+
+		return EmptySyncResponse
+	}
+
+	return NotFound
+}
+
+// containerProcesses returns all the processes in the PID namespace of the
+// given running container, as seen from the host's /proc.
+func containerProcesses(c container) ([]api.ContainerProcess, error) {
+	pidNs, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/pid", c.InitPID()))
+	if err != nil {
+		return nil, err
+	}
+
+	idmapset, err := c.IdmapSet()
+	if err != nil {
+		return nil, err
+	}
+
+	dents, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	processes := []api.ContainerProcess{}
+	for _, dent := range dents {
+		pid, err := strconv.ParseInt(dent.Name(), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		// Skip anything outside of the container, this also skips
+		// processes which went away in the meantime.
+		ns, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/pid", pid))
+		if err != nil || ns != pidNs {
+			continue
+		}
+
+		process, err := procProcessGet(pid)
+		if err != nil {
+			continue
+		}
+
+		if idmapset != nil {
+			process.UID, _ = idmapset.ShiftFromNs(process.UID, 0)
+		}
+
+		processes = append(processes, *process)
+	}
+
+	sort.Slice(processes, func(i, j int) bool {
+		return processes[i].ContainerPID < processes[j].ContainerPID
+	})
+
+	return processes, nil
+}
+
+// procProcessGet extracts the details of the given host process from /proc.
+func procProcessGet(pid int64) (*api.ContainerProcess, error) {
+	process := api.ContainerProcess{PID: pid, ContainerPID: -1}
+
+	status, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	name := ""
+	for _, line := range strings.Split(string(status), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "Name:":
+			name = fields[1]
+		case "NSpid:":
+			// The last entry is the pid in the innermost namespace
+			process.ContainerPID, err = strconv.ParseInt(fields[len(fields)-1], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		case "Uid:":
+			process.UID, err = strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		case "VmRSS:":
+			rss, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+
+			process.MemoryRSS = rss * 1024
+		}
+	}
+
+	cmdline, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	process.Command = strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+	if len(cmdline) == 0 {
+		process.Command = []string{fmt.Sprintf("[%s]", name)}
+	}
+
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	// The command name may contain spaces, so skip past it before
+	// splitting the remaining fields, starting with the state (3rd field).
+	idx := strings.LastIndex(string(stat), ")")
+	if idx < 0 {
+		return nil, fmt.Errorf("Invalid stat file for pid %d", pid)
+	}
+
+	fields := strings.Fields(string(stat)[idx+1:])
+	if len(fields) < 13 {
+		return nil, fmt.Errorf("Invalid stat file for pid %d", pid)
+	}
+
+	utime, err := strconv.ParseInt(fields[11], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	stime, err := strconv.ParseInt(fields[12], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	process.CPUTime = (utime + stime) * (1000000000 / procClockTicks)
+
+	return &process, nil
+}
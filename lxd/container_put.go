@@ -14,6 +14,7 @@ import (
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/osarch"
+	"github.com/lxc/lxd/shared/version"
 
 	log "github.com/lxc/lxd/shared/log15"
 )
@@ -56,12 +57,20 @@ func containerPut(d *Daemon, r *http.Request) Response {
 				return err
 			}
 
+			eventSendLifecycle("container-updated", fmt.Sprintf("/%s/containers/%s", version.APIVersion, name), nil, eventRequestor(r))
 			return nil
 		}
 	} else {
 		// Snapshot Restore
 		do = func(op *operation) error {
-			return containerSnapRestore(d.State(), d.Storage, name, configRaw.Restore)
+			err := containerSnapRestore(d.State(), d.Storage, name, configRaw.Restore)
+			if err != nil {
+				return err
+			}
+
+			eventSendLifecycle("container-snapshot-restored", fmt.Sprintf("/%s/containers/%s", version.APIVersion, name),
+				map[string]interface{}{"snapshot_name": configRaw.Restore}, eventRequestor(r))
+			return nil
 		}
 	}
 
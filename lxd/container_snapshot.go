@@ -6,13 +6,21 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+	"gopkg.in/flosch/pongo2.v3"
 
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
 )
 
 func containerSnapshotsGet(d *Daemon, r *http.Request) Response {
@@ -79,8 +87,10 @@ func containerSnapshotsPost(d *Daemon, r *http.Request) Response {
 
 	if req.Name == "" {
 		// come up with a name
-		i := d.db.ContainerNextSnapshot(name)
-		req.Name = fmt.Sprintf("snap%d", i)
+		req.Name, err = containerSnapshotNextName(d.State(), c)
+		if err != nil {
+			return SmartError(err)
+		}
 	}
 
 	// Validate the name
@@ -88,28 +98,13 @@ func containerSnapshotsPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("Snapshot names may not contain slashes"))
 	}
 
-	fullName := name +
-		shared.SnapshotDelimiter +
-		req.Name
-
 	snapshot := func(op *operation) error {
-		args := db.ContainerArgs{
-			Name:         fullName,
-			Ctype:        db.CTypeSnapshot,
-			Config:       c.LocalConfig(),
-			Profiles:     c.Profiles(),
-			Ephemeral:    c.IsEphemeral(),
-			BaseImage:    c.ExpandedConfig()["volatile.base_image"],
-			Architecture: c.Architecture(),
-			Devices:      c.LocalDevices(),
-			Stateful:     req.Stateful,
-		}
-
-		_, err := containerCreateAsSnapshot(d.State(), d.Storage, args, c)
+		err := containerSnapshotCreate(d.State(), d.Storage, c, req)
 		if err != nil {
 			return err
 		}
 
+		eventSendLifecycle("container-snapshot-created", fmt.Sprintf("/%s/containers/%s/snapshots/%s", version.APIVersion, name, req.Name), nil, eventRequestor(r))
 		return nil
 	}
 
@@ -124,6 +119,190 @@ func containerSnapshotsPost(d *Daemon, r *http.Request) Response {
 	return OperationResponse(op)
 }
 
+// containerSnapshotNextName returns the name of the next snapshot of the
+// container, following its snapshots.pattern key.
+func containerSnapshotNextName(s *state.State, c container) (string, error) {
+	pattern := c.ExpandedConfig()["snapshots.pattern"]
+	if pattern == "" {
+		pattern = "snap%d"
+	}
+
+	tpl, err := pongo2.FromString(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	pattern, err = tpl.Execute(pongo2.Context{"creation_date": time.Now()})
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.Contains(pattern, "%d") {
+		_, err := s.DB.ContainerId(c.Name() + shared.SnapshotDelimiter + pattern)
+		if err != nil {
+			return pattern, nil
+		}
+
+		// The name is already in use, add an index to it
+		pattern = pattern + "-%d"
+	}
+
+	i := s.DB.ContainerNextSnapshot(c.Name(), pattern)
+	return strings.Replace(pattern, "%d", strconv.Itoa(i), 1), nil
+}
+
+// containerSnapshotCreate creates a new snapshot of the container. Unless set
+// in the request, the expiry date of the snapshot comes from the
+// snapshots.expiry key of the container.
+func containerSnapshotCreate(s *state.State, storage storage, c container, req api.ContainerSnapshotsPost) error {
+	var expiry time.Time
+	if req.ExpiryDate != nil {
+		expiry = *req.ExpiryDate
+	} else {
+		var err error
+		expiry, err = shared.GetSnapshotExpiry(time.Now(), c.ExpandedConfig()["snapshots.expiry"])
+		if err != nil {
+			return err
+		}
+	}
+
+	args := db.ContainerArgs{
+		Name:         c.Name() + shared.SnapshotDelimiter + req.Name,
+		Ctype:        db.CTypeSnapshot,
+		Config:       c.LocalConfig(),
+		Profiles:     c.Profiles(),
+		Ephemeral:    c.IsEphemeral(),
+		BaseImage:    c.ExpandedConfig()["volatile.base_image"],
+		Architecture: c.Architecture(),
+		Devices:      c.LocalDevices(),
+		Stateful:     req.Stateful,
+		ExpiryDate:   expiry,
+	}
+
+	_, err := containerCreateAsSnapshot(s, storage, args, c)
+	return err
+}
+
+// snapshotScheduleParse parses the value of a snapshots.schedule key, either
+// a standard 5 fields cron expression or a descriptor like "@daily".
+func snapshotScheduleParse(schedule string) (*task.CronSchedule, error) {
+	return task.ParseCron(schedule)
+}
+
+// autoCreateContainerSnapshotsTask takes snapshots of the containers which
+// have a snapshots.schedule key. The schedules are checked every minute.
+func autoCreateContainerSnapshotsTask(d *Daemon) (task.Func, task.Schedule) {
+	last := time.Now()
+	f := func(ctx context.Context) {
+		now := time.Now()
+		autoCreateContainerSnapshots(ctx, d, last, now)
+		last = now
+	}
+
+	return f, task.Every(time.Minute)
+}
+
+// autoCreateContainerSnapshots snapshots the containers whose schedule fired
+// between the last and the current check.
+func autoCreateContainerSnapshots(ctx context.Context, d *Daemon, last time.Time, now time.Time) {
+	s := d.State()
+
+	names, err := s.DB.ContainersList(db.CTypeRegular)
+	if err != nil {
+		logger.Error("Unable to retrieve the list of containers", log.Ctx{"err": err})
+		return
+	}
+
+	for _, name := range names {
+		// Abort if the daemon is shutting down
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		c, err := containerLoadByName(s, d.Storage, name)
+		if err != nil {
+			logger.Error("Error loading container", log.Ctx{"err": err, "name": name})
+			continue
+		}
+
+		schedule := c.ExpandedConfig()["snapshots.schedule"]
+		if schedule == "" {
+			continue
+		}
+
+		sched, err := snapshotScheduleParse(schedule)
+		if err != nil {
+			logger.Error("Invalid snapshot schedule", log.Ctx{"err": err, "name": name})
+			continue
+		}
+
+		next := sched.Next(last)
+		if next.IsZero() || next.After(now) {
+			continue
+		}
+
+		req := api.ContainerSnapshotsPost{}
+		req.Name, err = containerSnapshotNextName(s, c)
+		if err != nil {
+			logger.Error("Error creating snapshot name", log.Ctx{"err": err, "name": name})
+			continue
+		}
+
+		err = containerSnapshotCreate(s, d.Storage, c, req)
+		if err != nil {
+			logger.Error("Error creating scheduled snapshot", log.Ctx{"err": err, "name": name, "snapshot": req.Name})
+			continue
+		}
+
+		logger.Info("Created scheduled snapshot", log.Ctx{"name": name, "snapshot": req.Name})
+	}
+}
+
+// pruneExpiredContainerSnapshotsTask deletes the snapshots which are past
+// their expiry date. It runs every minute.
+func pruneExpiredContainerSnapshotsTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		pruneExpiredContainerSnapshots(ctx, d)
+	}
+
+	return f, task.Every(time.Minute)
+}
+
+func pruneExpiredContainerSnapshots(ctx context.Context, d *Daemon) {
+	s := d.State()
+
+	names, err := s.DB.ContainerSnapshotsExpired(time.Now())
+	if err != nil {
+		logger.Error("Unable to retrieve the list of expired snapshots", log.Ctx{"err": err})
+		return
+	}
+
+	for _, name := range names {
+		// Abort if the daemon is shutting down
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		sc, err := containerLoadByName(s, d.Storage, name)
+		if err != nil {
+			logger.Error("Error loading snapshot", log.Ctx{"err": err, "name": name})
+			continue
+		}
+
+		err = sc.Delete()
+		if err != nil {
+			logger.Error("Error deleting expired snapshot", log.Ctx{"err": err, "name": name})
+			continue
+		}
+
+		logger.Info("Deleted expired snapshot", log.Ctx{"name": name})
+	}
+}
+
 func snapshotHandler(d *Daemon, r *http.Request) Response {
 	containerName := mux.Vars(r)["name"]
 	snapshotName := mux.Vars(r)["snapshotName"]
@@ -144,7 +323,7 @@ func snapshotHandler(d *Daemon, r *http.Request) Response {
 	case "POST":
 		return snapshotPost(d, r, sc, containerName)
 	case "DELETE":
-		return snapshotDelete(sc, snapshotName)
+		return snapshotDelete(r, sc, containerName, snapshotName)
 	default:
 		return NotFound
 	}
@@ -202,7 +381,14 @@ func snapshotPost(d *Daemon, r *http.Request, sc container, containerName string
 	}
 
 	rename := func(op *operation) error {
-		return sc.Rename(fullName)
+		err := sc.Rename(fullName)
+		if err != nil {
+			return err
+		}
+
+		eventSendLifecycle("container-snapshot-renamed", fmt.Sprintf("/%s/containers/%s/snapshots/%s", version.APIVersion, containerName, mux.Vars(r)["snapshotName"]),
+			map[string]interface{}{"new_name": newName}, eventRequestor(r))
+		return nil
 	}
 
 	resources := map[string][]string{}
@@ -216,9 +402,15 @@ func snapshotPost(d *Daemon, r *http.Request, sc container, containerName string
 	return OperationResponse(op)
 }
 
-func snapshotDelete(sc container, name string) Response {
+func snapshotDelete(r *http.Request, sc container, containerName string, name string) Response {
 	remove := func(op *operation) error {
-		return sc.Delete()
+		err := sc.Delete()
+		if err != nil {
+			return err
+		}
+
+		eventSendLifecycle("container-snapshot-deleted", fmt.Sprintf("/%s/containers/%s/snapshots/%s", version.APIVersion, containerName, name), nil, eventRequestor(r))
+		return nil
 	}
 
 	resources := map[string][]string{}
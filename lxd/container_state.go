@@ -11,6 +11,7 @@ import (
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
 )
 
 func containerState(d *Daemon, r *http.Request) Response {
@@ -50,8 +51,10 @@ func containerStatePut(d *Daemon, r *http.Request) Response {
 	}
 
 	var do func(*operation) error
+	var action string
 	switch shared.ContainerAction(raw.Action) {
 	case shared.Start:
+		// The container-started event is sent by the container itself
 		do = func(op *operation) error {
 			if err = c.Start(raw.Stateful); err != nil {
 				return err
@@ -59,6 +62,7 @@ func containerStatePut(d *Daemon, r *http.Request) Response {
 			return nil
 		}
 	case shared.Stop:
+		action = "container-stopped"
 		if raw.Stateful {
 			do = func(op *operation) error {
 				err := c.Stop(raw.Stateful)
@@ -78,6 +82,7 @@ func containerStatePut(d *Daemon, r *http.Request) Response {
 				return nil
 			}
 		} else {
+			action = "container-shutdown"
 			do = func(op *operation) error {
 				if c.IsFrozen() {
 					err := c.Unfreeze()
@@ -95,6 +100,7 @@ func containerStatePut(d *Daemon, r *http.Request) Response {
 			}
 		}
 	case shared.Restart:
+		action = "container-restarted"
 		do = func(op *operation) error {
 			ephemeral := c.IsEphemeral()
 
@@ -144,10 +150,12 @@ func containerStatePut(d *Daemon, r *http.Request) Response {
 			return nil
 		}
 	case shared.Freeze:
+		action = "container-paused"
 		do = func(op *operation) error {
 			return c.Freeze()
 		}
 	case shared.Unfreeze:
+		action = "container-resumed"
 		do = func(op *operation) error {
 			return c.Unfreeze()
 		}
@@ -155,6 +163,22 @@ func containerStatePut(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("unknown action %s", raw.Action))
 	}
 
+	// Let event listeners know once the state change is done
+	run := do
+	do = func(op *operation) error {
+		err := run(op)
+		if err != nil {
+			return err
+		}
+
+		if action == "" {
+			return nil
+		}
+
+		eventSendLifecycle(action, fmt.Sprintf("/%s/containers/%s", version.APIVersion, name), nil, eventRequestor(r))
+		return nil
+	}
+
 	resources := map[string][]string{}
 	resources["containers"] = []string{name}
 
@@ -35,9 +35,10 @@ var containerStateCmd = Command{
 }
 
 var containerFileCmd = Command{
-	name: "containers/{name}/files",
-	get:  containerFileHandler,
-	post: containerFileHandler,
+	name:   "containers/{name}/files",
+	get:    containerFileHandler,
+	post:   containerFileHandler,
+	delete: containerFileHandler,
 }
 
 var containerSnapshotsCmd = Command{
@@ -53,11 +54,56 @@ var containerSnapshotCmd = Command{
 	delete: snapshotHandler,
 }
 
+var containerBackupsCmd = Command{
+	name: "containers/{name}/backups",
+	get:  containerBackupsGet,
+	post: containerBackupsPost,
+}
+
+var containerBackupCmd = Command{
+	name:   "containers/{name}/backups/{backupName}",
+	get:    containerBackupGet,
+	post:   containerBackupPost,
+	delete: containerBackupDelete,
+}
+
+var containerBackupExportCmd = Command{
+	name: "containers/{name}/backups/{backupName}/export",
+	get:  containerBackupExportGet,
+}
+
 var containerExecCmd = Command{
 	name: "containers/{name}/exec",
 	post: containerExecPost,
 }
 
+var containerMetadataCmd = Command{
+	name: "containers/{name}/metadata",
+	get:  containerMetadataGet,
+	put:  containerMetadataPut,
+}
+
+var containerMetadataTemplatesCmd = Command{
+	name:   "containers/{name}/metadata/templates",
+	get:    containerMetadataTemplatesGet,
+	post:   containerMetadataTemplatesPostPut,
+	put:    containerMetadataTemplatesPostPut,
+	delete: containerMetadataTemplatesDelete,
+}
+
+var containerProcessesCmd = Command{
+	name: "containers/{name}/processes",
+	get:  containerProcessesGet,
+	post: containerProcessesPost,
+}
+
+var containerConsoleCmd = Command{
+	name:   "containers/{name}/console",
+	get:    containerConsoleLogGet,
+	post:   containerConsolePost,
+	delete: containerConsoleLogDelete,
+}
+
 type containerAutostartList []container
 
 func (slice containerAutostartList) Len() int {
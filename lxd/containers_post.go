@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/dustinkirkland/golang-petname"
@@ -18,11 +21,12 @@ import (
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/osarch"
+	"github.com/lxc/lxd/shared/version"
 
 	log "github.com/lxc/lxd/shared/log15"
 )
 
-func createFromImage(d *Daemon, req *api.ContainersPost) Response {
+func createFromImage(d *Daemon, req *api.ContainersPost, requestor *api.EventLifecycleRequestor) Response {
 	var hash string
 	var err error
 
@@ -116,7 +120,12 @@ func createFromImage(d *Daemon, req *api.ContainersPost) Response {
 		}
 
 		_, err = containerCreateFromImage(d.State(), d.Storage, args, info.Fingerprint)
-		return err
+		if err != nil {
+			return err
+		}
+
+		eventSendLifecycle("container-created", fmt.Sprintf("/%s/containers/%s", version.APIVersion, req.Name), nil, requestor)
+		return nil
 	}
 
 	resources := map[string][]string{}
@@ -130,7 +139,7 @@ func createFromImage(d *Daemon, req *api.ContainersPost) Response {
 	return OperationResponse(op)
 }
 
-func createFromNone(d *Daemon, req *api.ContainersPost) Response {
+func createFromNone(d *Daemon, req *api.ContainersPost, requestor *api.EventLifecycleRequestor) Response {
 	args := db.ContainerArgs{
 		Config:    req.Config,
 		Ctype:     db.CTypeRegular,
@@ -150,7 +159,12 @@ func createFromNone(d *Daemon, req *api.ContainersPost) Response {
 
 	run := func(op *operation) error {
 		_, err := containerCreateAsEmpty(d, args)
-		return err
+		if err != nil {
+			return err
+		}
+
+		eventSendLifecycle("container-created", fmt.Sprintf("/%s/containers/%s", version.APIVersion, req.Name), nil, requestor)
+		return nil
 	}
 
 	resources := map[string][]string{}
@@ -164,7 +178,7 @@ func createFromNone(d *Daemon, req *api.ContainersPost) Response {
 	return OperationResponse(op)
 }
 
-func createFromMigration(d *Daemon, req *api.ContainersPost) Response {
+func createFromMigration(d *Daemon, req *api.ContainersPost, requestor *api.EventLifecycleRequestor) Response {
 	// Validate migration mode
 	if req.Source.Mode != "pull" {
 		return NotImplemented
@@ -190,53 +204,74 @@ func createFromMigration(d *Daemon, req *api.ContainersPost) Response {
 		Profiles:     req.Profiles,
 	}
 
-	/* Only create a container from an image if we're going to
-	 * rsync over the top of it. In the case of a better file
-	 * transfer mechanism, let's just use that.
-	 *
-	 * TODO: we could invent some negotiation here, where if the
-	 * source and sink both have the same image, we can clone from
-	 * it, but we have to know before sending the snapshot that
-	 * we're sending the whole thing or just a delta from the
-	 * image, so one extra negotiation round trip is needed. An
-	 * alternative is to move actual container object to a later
-	 * point and just negotiate it over the migration control
-	 * socket. Anyway, it'll happen later :)
-	 */
-	_, _, err = d.db.ImageGet(req.Source.BaseImage, false, true)
-	if err == nil && d.Storage.MigrationType() == migration.MigrationFSType_RSYNC {
-		c, err = containerCreateFromImage(d.State(), d.Storage, args, req.Source.BaseImage)
-		if err != nil {
-			return InternalError(err)
-		}
-	} else {
-		c, err = containerCreateAsEmpty(d, args)
-		if err != nil {
-			return InternalError(err)
-		}
-	}
-
 	var cert *x509.Certificate
 	if req.Source.Certificate != "" {
 		certBlock, _ := pem.Decode([]byte(req.Source.Certificate))
 		if certBlock == nil {
-			c.Delete()
 			return InternalError(fmt.Errorf("Invalid certificate"))
 		}
 
 		cert, err = x509.ParseCertificate(certBlock.Bytes)
 		if err != nil {
-			c.Delete()
 			return InternalError(err)
 		}
 	}
 
 	config, err := shared.GetTLSConfig("", "", "", cert)
 	if err != nil {
-		c.Delete()
 		return InternalError(err)
 	}
 
+	// When refreshing, only the differences with an existing container
+	// get transferred.
+	refresh := false
+	if req.Source.Refresh {
+		if req.Source.Live {
+			return BadRequest(fmt.Errorf("Live migration isn't supported when refreshing a container"))
+		}
+
+		c, err = containerLoadByName(d.State(), d.Storage, req.Name)
+		if err != nil && err != db.NoSuchObjectError {
+			return SmartError(err)
+		}
+
+		if err == nil {
+			if c.IsRunning() {
+				return BadRequest(fmt.Errorf("Cannot refresh a running container"))
+			}
+
+			refresh = true
+		}
+	}
+
+	if !refresh {
+		/* Only create a container from an image if we're going to
+		 * rsync over the top of it. In the case of a better file
+		 * transfer mechanism, let's just use that.
+		 *
+		 * TODO: we could invent some negotiation here, where if the
+		 * source and sink both have the same image, we can clone from
+		 * it, but we have to know before sending the snapshot that
+		 * we're sending the whole thing or just a delta from the
+		 * image, so one extra negotiation round trip is needed. An
+		 * alternative is to move actual container object to a later
+		 * point and just negotiate it over the migration control
+		 * socket. Anyway, it'll happen later :)
+		 */
+		_, _, err = d.db.ImageGet(req.Source.BaseImage, false, true)
+		if err == nil && d.Storage.MigrationType() == migration.MigrationFSType_RSYNC {
+			c, err = containerCreateFromImage(d.State(), d.Storage, args, req.Source.BaseImage)
+			if err != nil {
+				return InternalError(err)
+			}
+		} else {
+			c, err = containerCreateAsEmpty(d, args)
+			if err != nil {
+				return InternalError(err)
+			}
+		}
+	}
+
 	migrationArgs := MigrationSinkArgs{
 		Url: req.Source.Operation,
 		Dialer: websocket.Dialer{
@@ -244,18 +279,35 @@ func createFromMigration(d *Daemon, req *api.ContainersPost) Response {
 			NetDial:         shared.RFC3493Dialer},
 		Container: c,
 		Secrets:   req.Source.Websockets,
+		Refresh:   refresh,
 	}
 
 	sink, err := NewMigrationSink(&migrationArgs)
 	if err != nil {
-		c.Delete()
+		if !refresh {
+			c.Delete()
+		}
+
 		return InternalError(err)
 	}
 
 	resources := map[string][]string{}
 	resources["containers"] = []string{req.Name}
 
-	op, err := operationCreate(operationClassTask, resources, nil, sink.Do, nil, nil)
+	run := func(op *operation) error {
+		err := sink.Do(op)
+		if err != nil {
+			return err
+		}
+
+		if !refresh {
+			eventSendLifecycle("container-created", fmt.Sprintf("/%s/containers/%s", version.APIVersion, req.Name), nil, requestor)
+		}
+
+		return nil
+	}
+
+	op, err := operationCreate(operationClassTask, resources, nil, run, nil, nil)
 	if err != nil {
 		return InternalError(err)
 	}
@@ -263,7 +315,7 @@ func createFromMigration(d *Daemon, req *api.ContainersPost) Response {
 	return OperationResponse(op)
 }
 
-func createFromCopy(d *Daemon, req *api.ContainersPost) Response {
+func createFromCopy(d *Daemon, req *api.ContainersPost, requestor *api.EventLifecycleRequestor) Response {
 	if req.Source.Source == "" {
 		return BadRequest(fmt.Errorf("must specify a source container"))
 	}
@@ -333,9 +385,29 @@ func createFromCopy(d *Daemon, req *api.ContainersPost) Response {
 			return err
 		}
 
+		eventSendLifecycle("container-created", fmt.Sprintf("/%s/containers/%s", version.APIVersion, req.Name), nil, requestor)
 		return nil
 	}
 
+	// When refreshing, only the differences with an existing container
+	// get copied.
+	if req.Source.Refresh {
+		c, err := containerLoadByName(d.State(), d.Storage, req.Name)
+		if err != nil && err != db.NoSuchObjectError {
+			return SmartError(err)
+		}
+
+		if err == nil {
+			if c.IsRunning() {
+				return BadRequest(fmt.Errorf("Cannot refresh a running container"))
+			}
+
+			run = func(op *operation) error {
+				return containerRefresh(d.State(), c, source)
+			}
+		}
+	}
+
 	resources := map[string][]string{}
 	resources["containers"] = []string{req.Name, req.Source.Source}
 
@@ -347,9 +419,69 @@ func createFromCopy(d *Daemon, req *api.ContainersPost) Response {
 	return OperationResponse(op)
 }
 
+func createFromBackup(d *Daemon, data io.Reader, requestor *api.EventLifecycleRequestor) Response {
+	// Store the uploaded tarball
+	f, err := ioutil.TempFile(shared.VarPath("backups"), "lxd_backup_")
+	if err != nil {
+		return InternalError(err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, data)
+	f.Close()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	// Unpack it and parse the index
+	path, err := ioutil.TempDir(shared.VarPath("backups"), "lxd_restore_")
+	if err != nil {
+		return InternalError(err)
+	}
+
+	info, err := backupGetInfo(d.State(), f.Name(), path)
+	if err != nil {
+		os.RemoveAll(path)
+		return BadRequest(err)
+	}
+
+	if info.Optimized && !shared.StringInSlice(info.Backend, []string{"btrfs", "zfs"}) {
+		os.RemoveAll(path)
+		return BadRequest(fmt.Errorf("Invalid optimized backup for the %s storage driver", info.Backend))
+	}
+
+	run := func(op *operation) error {
+		defer os.RemoveAll(path)
+
+		_, err := containerCreateFromBackup(d, info, path)
+		if err != nil {
+			return err
+		}
+
+		eventSendLifecycle("container-created", fmt.Sprintf("/%s/containers/%s", version.APIVersion, info.Name), nil, requestor)
+		return nil
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{info.Name}
+
+	op, err := operationCreate(operationClassTask, resources, nil, run, nil, nil)
+	if err != nil {
+		os.RemoveAll(path)
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
 func containersPost(d *Daemon, r *http.Request) Response {
 	logger.Debugf("Responding to container create")
 
+	// A raw tarball means we're restoring a backup
+	if r.Header.Get("Content-Type") == "application/octet-stream" {
+		return createFromBackup(d, r.Body, eventRequestor(r))
+	}
+
 	req := api.ContainersPost{}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return BadRequest(err)
@@ -403,13 +535,15 @@ func containersPost(d *Daemon, r *http.Request) Response {
 
 	switch req.Source.Type {
 	case "image":
-		return createFromImage(d, &req)
+		return createFromImage(d, &req, eventRequestor(r))
 	case "none":
-		return createFromNone(d, &req)
+		return createFromNone(d, &req, eventRequestor(r))
 	case "migration":
-		return createFromMigration(d, &req)
+		return createFromMigration(d, &req, eventRequestor(r))
 	case "copy":
-		return createFromCopy(d, &req)
+		return createFromCopy(d, &req, eventRequestor(r))
+	case "backup":
+		return BadRequest(fmt.Errorf("Backups must be sent as the raw request body with the application/octet-stream content type"))
 	default:
 		return BadRequest(fmt.Errorf("unknown source type %s", req.Source.Type))
 	}
@@ -154,6 +154,8 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c Command) {
 			shared.DebugJson(captured)
 		}
 
+		start := time.Now()
+
 		var resp Response
 		resp = NotImplemented
 
@@ -185,6 +187,8 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c Command) {
 			}
 		}
 
+		metricsRequestRecord(uri, r.Method, time.Since(start))
+
 		/*
 		 * When we create a new lxc.Container, it adds a finalizer (via
 		 * SetFinalizer) that frees the struct. However, it sometimes
@@ -317,6 +321,9 @@ func (d *Daemon) init() error {
 		daemonConfig["core.proxy_ignore_hosts"].Get(),
 	)
 
+	/* Start delivering events to the configured webhooks */
+	webhooksUpdate(d)
+
 	/* Setup some mounts (nice to have) */
 	if !d.os.MockMode {
 		// Attempt to mount the shmounts tmpfs
@@ -335,6 +342,9 @@ func (d *Daemon) init() error {
 		readSavedClientCAList(d)
 	}
 
+	/* Bring up the managed networks */
+	networkStartup(d.State())
+
 	/* Setup the web server */
 	certInfo, err := shared.KeyPairAndCA(d.os.VarDir, "server", shared.CertServer)
 	if err != nil {
@@ -348,6 +358,8 @@ func (d *Daemon) init() error {
 		DevLxdServer:         DevLxdServer(d),
 		LocalUnixSocketGroup: d.config.Group,
 		NetworkAddress:       daemonConfig["core.https_address"].Get(),
+		MetricsServer:        MetricsServer(d),
+		MetricsAddress:       daemonConfig["core.metrics_address"].Get(),
 	}
 	d.endpoints, err = endpoints.Up(config)
 	if err != nil {
@@ -375,6 +387,15 @@ func (d *Daemon) Ready() error {
 	/* Auto-update instance types */
 	d.tasks.Add(instanceRefreshTypesTask(d))
 
+	/* Prune expired container backups */
+	d.tasks.Add(pruneExpiredContainerBackupsTask(d))
+
+	/* Take scheduled container snapshots */
+	d.tasks.Add(autoCreateContainerSnapshotsTask(d))
+
+	/* Prune expired container snapshots */
+	d.tasks.Add(pruneExpiredContainerSnapshotsTask(d))
+
 	// FIXME: There's no hard reason for which we should not run tasks in
 	//        mock mode. However it requires that we tweak the tasks so
 	//        they exit gracefully without blocking (something we should
@@ -6,6 +6,8 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"net"
+	"net/url"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -15,6 +17,7 @@ import (
 	"golang.org/x/crypto/scrypt"
 
 	dbapi "github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/task"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
 )
@@ -22,6 +25,11 @@ import (
 var daemonConfigLock sync.Mutex
 var daemonConfig map[string]*daemonConfigKey
 
+// The per-webhook keys get created as they're set, so they're kept out of
+// daemonConfig which must not change after daemonConfigInit.
+var daemonConfigWebhooksLock sync.Mutex
+var daemonConfigWebhooks = map[string]*daemonConfigKey{}
+
 type daemonConfigKey struct {
 	valueType    string
 	defaultValue string
@@ -47,6 +55,20 @@ func (k *daemonConfigKey) name() string {
 	}
 	daemonConfigLock.Unlock()
 
+	if name != "" {
+		return name
+	}
+
+	// Or in the webhook keys
+	daemonConfigWebhooksLock.Lock()
+	for key, value := range daemonConfigWebhooks {
+		if value == k {
+			name = key
+			break
+		}
+	}
+	daemonConfigWebhooksLock.Unlock()
+
 	return name
 }
 
@@ -184,13 +206,14 @@ func daemonConfigInit(db *sql.DB) error {
 		"core.https_allowed_headers": {valueType: "string"},
 		"core.https_allowed_methods": {valueType: "string"},
 		"core.https_allowed_origin":  {valueType: "string"},
+		"core.metrics_address":       {valueType: "string", validator: daemonConfigValidateMetricsAddress, setter: daemonConfigSetMetricsAddress},
 		"core.proxy_http":            {valueType: "string", setter: daemonConfigSetProxy},
 		"core.proxy_https":           {valueType: "string", setter: daemonConfigSetProxy},
 		"core.proxy_ignore_hosts":    {valueType: "string", setter: daemonConfigSetProxy},
 		"core.trust_password":        {valueType: "string", hiddenValue: true, setter: daemonConfigSetPassword},
 
 		"images.auto_update_cached":    {valueType: "bool", defaultValue: "true"},
-		"images.auto_update_interval":  {valueType: "int", defaultValue: "6", trigger: daemonConfigTriggerAutoUpdateInterval},
+		"images.auto_update_interval":  {valueType: "string", defaultValue: "6", validator: daemonConfigValidateAutoUpdateInterval, trigger: daemonConfigTriggerAutoUpdateInterval},
 		"images.compression_algorithm": {valueType: "string", validator: daemonConfigValidateCompression, defaultValue: "gzip"},
 		"images.remote_cache_expiry":   {valueType: "int", defaultValue: "10", trigger: daemonConfigTriggerExpiry},
 
@@ -207,32 +230,93 @@ func daemonConfigInit(db *sql.DB) error {
 		return err
 	}
 
-	daemonConfigLock.Lock()
 	for k, v := range dbValues {
-		_, ok := daemonConfig[k]
-		if !ok {
+		key := daemonConfigDynamicKey(k)
+		if key == nil {
 			logger.Error("Found unknown configuration key in database", log.Ctx{"key": k})
 			continue
 		}
 
-		daemonConfig[k].currentValue = v
+		daemonConfigLock.Lock()
+		key.currentValue = v
+		daemonConfigLock.Unlock()
 	}
-	daemonConfigLock.Unlock()
 
 	return nil
 }
 
+// daemonConfigDynamicKey returns the given configuration key, creating it
+// first if it's one of the per-webhook keys. It returns nil for unknown keys.
+func daemonConfigDynamicKey(name string) *daemonConfigKey {
+	key, ok := daemonConfig[name]
+	if ok {
+		return key
+	}
+
+	_, field, ok := daemonConfigWebhookKey(name)
+	if !ok {
+		return nil
+	}
+
+	daemonConfigWebhooksLock.Lock()
+	defer daemonConfigWebhooksLock.Unlock()
+
+	key, ok = daemonConfigWebhooks[name]
+	if ok {
+		return key
+	}
+
+	switch field {
+	case "url":
+		key = &daemonConfigKey{valueType: "string", validator: daemonConfigValidateWebhookURL, trigger: daemonConfigTriggerWebhooks}
+	case "types":
+		key = &daemonConfigKey{valueType: "string", defaultValue: webhookDefaultTypes, validator: daemonConfigValidateWebhookTypes, trigger: daemonConfigTriggerWebhooks}
+	case "secret":
+		key = &daemonConfigKey{valueType: "string", hiddenValue: true, trigger: daemonConfigTriggerWebhooks}
+	}
+
+	daemonConfigWebhooks[name] = key
+	return key
+}
+
+// daemonConfigWebhookKey splits a core.webhooks.<name>.<field> key into the
+// webhook name and field.
+func daemonConfigWebhookKey(key string) (string, string, bool) {
+	if !strings.HasPrefix(key, "core.webhooks.") {
+		return "", "", false
+	}
+
+	fields := strings.Split(strings.TrimPrefix(key, "core.webhooks."), ".")
+	if len(fields) != 2 || fields[0] == "" {
+		return "", "", false
+	}
+
+	if !shared.StringInSlice(fields[1], []string{"url", "types", "secret"}) {
+		return "", "", false
+	}
+
+	return fields[0], fields[1], true
+}
+
 func daemonConfigRender() map[string]interface{} {
 	config := map[string]interface{}{}
 
 	// Turn the config into a JSON-compatible map
-	for k, v := range daemonConfig {
-		value := v.Get()
-		if value != v.defaultValue {
-			if v.hiddenValue {
-				config[k] = true
-			} else {
-				config[k] = value
+	daemonConfigLock.Lock()
+	defer daemonConfigLock.Unlock()
+
+	daemonConfigWebhooksLock.Lock()
+	defer daemonConfigWebhooksLock.Unlock()
+
+	for _, keys := range []map[string]*daemonConfigKey{daemonConfig, daemonConfigWebhooks} {
+		for k, v := range keys {
+			value := v.Get()
+			if value != v.defaultValue {
+				if v.hiddenValue {
+					config[k] = true
+				} else {
+					config[k] = value
+				}
 			}
 		}
 	}
@@ -295,6 +379,15 @@ func daemonConfigSetAddress(d *Daemon, key string, value string) (string, error)
 	return value, nil
 }
 
+func daemonConfigSetMetricsAddress(d *Daemon, key string, value string) (string, error) {
+	err := d.endpoints.MetricsUpdateAddress(value)
+	if err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
 func daemonConfigSetProxy(d *Daemon, key string, value string) (string, error) {
 	// Get the current config
 	config := map[string]string{}
@@ -332,6 +425,21 @@ func daemonConfigTriggerAutoUpdateInterval(d *Daemon, key string, value string)
 	d.taskAutoUpdate.Reset()
 }
 
+func daemonConfigValidateAutoUpdateInterval(d *Daemon, key string, value string) error {
+	// Either a number of hours or a cron expression
+	_, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		return nil
+	}
+
+	_, err = task.ParseCron(value)
+	if err != nil {
+		return fmt.Errorf("Invalid value, expected a number of hours or a cron expression: %v", err)
+	}
+
+	return nil
+}
+
 func daemonConfigValidateCompression(d *Daemon, key string, value string) error {
 	if value == "none" {
 		return nil
@@ -340,3 +448,48 @@ func daemonConfigValidateCompression(d *Daemon, key string, value string) error
 	_, err := exec.LookPath(value)
 	return err
 }
+
+func daemonConfigValidateWebhookURL(d *Daemon, key string, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	if !shared.StringInSlice(u.Scheme, []string{"http", "https"}) || u.Host == "" {
+		return fmt.Errorf("Invalid value, expected an http or https URL: %s", value)
+	}
+
+	return nil
+}
+
+func daemonConfigValidateWebhookTypes(d *Daemon, key string, value string) error {
+	for _, eventType := range strings.Split(value, ",") {
+		if !shared.StringInSlice(eventType, []string{"logging", "operation", "lifecycle"}) {
+			return fmt.Errorf("Invalid event type: %s", eventType)
+		}
+	}
+
+	return nil
+}
+
+func daemonConfigTriggerWebhooks(d *Daemon, key string, value string) {
+	// Restart the webhooks with the new configuration
+	webhooksUpdate(d)
+}
+
+func daemonConfigValidateMetricsAddress(d *Daemon, key string, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	_, _, err := net.SplitHostPort(value)
+	if err != nil {
+		return fmt.Errorf("Invalid value, expected an address and port: %v", err)
+	}
+
+	return nil
+}
@@ -3,6 +3,8 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/lxc/lxd/lxd/types"
@@ -25,6 +27,7 @@ type ContainerArgs struct {
 	Ctype        ContainerType
 	Devices      types.Devices
 	Ephemeral    bool
+	ExpiryDate   time.Time
 	Name         string
 	Profiles     []string
 	Stateful     bool
@@ -76,14 +79,19 @@ func (n *Node) ContainerGet(name string) (ContainerArgs, error) {
 
 	ephemInt := -1
 	statefulInt := -1
-	q := "SELECT id, architecture, type, ephemeral, stateful, creation_date FROM containers WHERE name=?"
+	var expiryDate *time.Time
+	q := "SELECT id, architecture, type, ephemeral, stateful, creation_date, expiry_date FROM containers WHERE name=?"
 	arg1 := []interface{}{name}
-	arg2 := []interface{}{&args.Id, &args.Architecture, &args.Ctype, &ephemInt, &statefulInt, &args.CreationDate}
+	arg2 := []interface{}{&args.Id, &args.Architecture, &args.Ctype, &ephemInt, &statefulInt, &args.CreationDate, &expiryDate}
 	err := dbQueryRowScan(n.db, q, arg1, arg2)
 	if err != nil {
 		return args, err
 	}
 
+	if expiryDate != nil && expiryDate.Unix() > 0 {
+		args.ExpiryDate = *expiryDate
+	}
+
 	if args.Id == -1 {
 		return args, fmt.Errorf("Unknown container")
 	}
@@ -145,14 +153,19 @@ func (n *Node) ContainerCreate(args ContainerArgs) (int, error) {
 
 	args.CreationDate = time.Now().UTC()
 
-	str := fmt.Sprintf("INSERT INTO containers (name, architecture, type, ephemeral, creation_date, stateful) VALUES (?, ?, ?, ?, ?, ?)")
+	var expiryDate interface{}
+	if !args.ExpiryDate.IsZero() {
+		expiryDate = args.ExpiryDate.Unix()
+	}
+
+	str := fmt.Sprintf("INSERT INTO containers (name, architecture, type, ephemeral, creation_date, stateful, expiry_date) VALUES (?, ?, ?, ?, ?, ?, ?)")
 	stmt, err := tx.Prepare(str)
 	if err != nil {
 		tx.Rollback()
 		return 0, err
 	}
 	defer stmt.Close()
-	result, err := stmt.Exec(args.Name, args.Architecture, args.Ctype, ephemInt, args.CreationDate.Unix(), statefulInt)
+	result, err := stmt.Exec(args.Name, args.Architecture, args.Ctype, ephemInt, args.CreationDate.Unix(), statefulInt, expiryDate)
 	if err != nil {
 		tx.Rollback()
 		return 0, err
@@ -341,6 +354,33 @@ func (n *Node) ContainersList(cType ContainerType) ([]string, error) {
 	return ret, nil
 }
 
+// ContainersPowerState returns the last recorded power state of the regular
+// containers, keyed by name. The state is empty for containers which never
+// ran.
+func (n *Node) ContainersPowerState() (map[string]string, error) {
+	q := `
+SELECT containers.name, IFNULL(containers_config.value, '')
+  FROM containers
+  LEFT JOIN containers_config ON containers_config.container_id=containers.id
+   AND containers_config.key='volatile.last_state.power'
+ WHERE containers.type=?`
+	inargs := []interface{}{CTypeRegular}
+	var name string
+	var state string
+	outfmt := []interface{}{name, state}
+	result, err := queryScan(n.db, q, inargs, outfmt)
+	if err != nil {
+		return nil, err
+	}
+
+	states := map[string]string{}
+	for _, r := range result {
+		states[r[0].(string)] = r[1].(string)
+	}
+
+	return states, nil
+}
+
 func (n *Node) ContainersResetState() error {
 	// Reset all container states
 	_, err := exec(n.db, "DELETE FROM containers_config WHERE key='volatile.last_state.power'")
@@ -455,9 +495,21 @@ func (n *Node) ContainerGetSnapshots(name string) ([]string, error) {
 /*
  * Note, the code below doesn't deal with snapshots of snapshots.
  * To do that, we'll need to weed out based on # slashes in names
+ *
+ * The pattern is the snapshot name with a %d verb for the index, like "snap%d".
  */
-func (n *Node) ContainerNextSnapshot(name string) int {
-	base := name + shared.SnapshotDelimiter + "snap"
+func (n *Node) ContainerNextSnapshot(name string, pattern string) int {
+	// Only the first %d is a placeholder, anything else in the pattern is
+	// taken literally.
+	prefix := pattern
+	suffix := ""
+	fields := strings.SplitN(pattern, "%d", 2)
+	if len(fields) == 2 {
+		prefix = fields[0]
+		suffix = fields[1]
+	}
+
+	base := name + shared.SnapshotDelimiter + prefix
 	length := len(base)
 	q := fmt.Sprintf("SELECT name FROM containers WHERE type=? AND SUBSTR(name,1,?)=?")
 	var numstr string
@@ -471,13 +523,12 @@ func (n *Node) ContainerNextSnapshot(name string) int {
 
 	for _, r := range results {
 		numstr = r[0].(string)
-		if len(numstr) <= length {
+		if len(numstr) <= length || !strings.HasSuffix(numstr, suffix) {
 			continue
 		}
-		substr := numstr[length:]
-		var num int
-		count, err := fmt.Sscanf(substr, "%d", &num)
-		if err != nil || count != 1 {
+		substr := strings.TrimSuffix(numstr[length:], suffix)
+		num, err := strconv.Atoi(substr)
+		if err != nil || num < 0 {
 			continue
 		}
 		if num >= max {
@@ -487,3 +538,23 @@ func (n *Node) ContainerNextSnapshot(name string) int {
 
 	return max
 }
+
+// ContainerSnapshotsExpired returns the names of the snapshots whose expiry
+// date is before the given time.
+func (n *Node) ContainerSnapshotsExpired(now time.Time) ([]string, error) {
+	q := "SELECT name FROM containers WHERE type=? AND expiry_date IS NOT NULL AND expiry_date > 0 AND expiry_date <= ?"
+	inargs := []interface{}{CTypeSnapshot, now.Unix()}
+	var name string
+	outfmt := []interface{}{name}
+	results, err := queryScan(n.db, q, inargs, outfmt)
+	if err != nil {
+		return []string{}, err
+	}
+
+	response := []string{}
+	for _, r := range results {
+		response = append(response, r[0].(string))
+	}
+
+	return response, nil
+}
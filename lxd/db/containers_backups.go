@@ -0,0 +1,176 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ContainerBackupArgs is a value object holding all db-related details about
+// a container backup.
+type ContainerBackupArgs struct {
+	// Don't set manually
+	ID int
+
+	ContainerID      int
+	Name             string
+	CreationDate     time.Time
+	ExpiryDate       time.Time
+	ContainerOnly    bool
+	OptimizedStorage bool
+}
+
+// ContainerBackups returns the names of all the backups of the container
+// with the given ID.
+func (n *Node) ContainerBackups(containerID int) ([]string, error) {
+	q := "SELECT name FROM containers_backups WHERE container_id=?"
+	inargs := []interface{}{containerID}
+	var name string
+	outfmt := []interface{}{name}
+	results, err := queryScan(n.db, q, inargs, outfmt)
+	if err != nil {
+		return []string{}, err
+	}
+
+	response := []string{}
+	for _, r := range results {
+		response = append(response, r[0].(string))
+	}
+
+	return response, nil
+}
+
+// ContainerBackupGet returns the backup with the given name of the container
+// with the given ID.
+func (n *Node) ContainerBackupGet(containerID int, name string) (ContainerBackupArgs, error) {
+	args := ContainerBackupArgs{}
+	args.ContainerID = containerID
+	args.Name = name
+
+	containerOnlyInt := -1
+	optimizedStorageInt := -1
+	var create, expire *time.Time
+
+	q := `SELECT id, creation_date, expiry_date, container_only, optimized_storage
+              FROM containers_backups WHERE container_id=? AND name=?`
+	arg1 := []interface{}{containerID, name}
+	arg2 := []interface{}{&args.ID, &create, &expire, &containerOnlyInt, &optimizedStorageInt}
+	err := dbQueryRowScan(n.db, q, arg1, arg2)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return args, NoSuchObjectError
+		}
+
+		return args, err
+	}
+
+	if create != nil {
+		args.CreationDate = *create
+	}
+
+	if expire != nil {
+		args.ExpiryDate = *expire
+	}
+
+	if containerOnlyInt == 1 {
+		args.ContainerOnly = true
+	}
+
+	if optimizedStorageInt == 1 {
+		args.OptimizedStorage = true
+	}
+
+	return args, nil
+}
+
+// ContainerBackupsExpired returns the backups whose expiry date is before the
+// given time, as a list of "<container>/<backup>" names.
+func (n *Node) ContainerBackupsExpired(now time.Time) ([]string, error) {
+	q := `SELECT containers.name, containers_backups.name, containers_backups.container_id
+              FROM containers_backups JOIN containers ON containers_backups.container_id=containers.id`
+	var containerName, backupName string
+	var containerID int
+	outfmt := []interface{}{containerName, backupName, containerID}
+	results, err := queryScan(n.db, q, nil, outfmt)
+	if err != nil {
+		return []string{}, err
+	}
+
+	response := []string{}
+	for _, r := range results {
+		args, err := n.ContainerBackupGet(r[2].(int), r[1].(string))
+		if err != nil {
+			return []string{}, err
+		}
+
+		if args.ExpiryDate.IsZero() || args.ExpiryDate.After(now) {
+			continue
+		}
+
+		response = append(response, fmt.Sprintf("%s/%s", r[0].(string), r[1].(string)))
+	}
+
+	return response, nil
+}
+
+// ContainerBackupCreate adds a new container backup to the database.
+func (n *Node) ContainerBackupCreate(args ContainerBackupArgs) error {
+	_, err := n.ContainerBackupGet(args.ContainerID, args.Name)
+	if err == nil {
+		return DbErrAlreadyDefined
+	}
+
+	containerOnlyInt := 0
+	if args.ContainerOnly {
+		containerOnlyInt = 1
+	}
+
+	optimizedStorageInt := 0
+	if args.OptimizedStorage {
+		optimizedStorageInt = 1
+	}
+
+	_, err = exec(n.db, `INSERT INTO containers_backups
+            (container_id, name, creation_date, expiry_date, container_only, optimized_storage)
+            VALUES (?, ?, ?, ?, ?, ?)`,
+		args.ContainerID, args.Name, args.CreationDate, args.ExpiryDate, containerOnlyInt, optimizedStorageInt)
+	return err
+}
+
+// ContainerBackupRename renames the container backup with the given ID.
+func (n *Node) ContainerBackupRename(id int, newName string) error {
+	_, err := exec(n.db, "UPDATE containers_backups SET name=? WHERE id=?", newName, id)
+	return err
+}
+
+// ContainerBackupRemove removes the container backup with the given ID.
+func (n *Node) ContainerBackupRemove(id int) error {
+	_, err := exec(n.db, "DELETE FROM containers_backups WHERE id=?", id)
+	return err
+}
+
+// ContainerNextBackup returns the index to use for the next automatically
+// named backup of the container with the given ID.
+func (n *Node) ContainerNextBackup(containerID int) int {
+	names, err := n.ContainerBackups(containerID)
+	if err != nil {
+		return 0
+	}
+
+	max := 0
+	for _, name := range names {
+		var num int
+		count, err := fmt.Sscanf(name, "backup%d", &num)
+		if err != nil || count != 1 {
+			continue
+		}
+
+		if num >= max {
+			max = num + 1
+		}
+	}
+
+	return max
+}
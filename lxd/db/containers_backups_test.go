@@ -0,0 +1,81 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Container backups can be created, fetched, renamed and removed.
+func TestContainerBackup_Lifecycle(t *testing.T) {
+	node, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	containerID, err := node.ContainerCreate(db.ContainerArgs{Name: "c1"})
+	require.NoError(t, err)
+
+	err = node.ContainerBackupCreate(db.ContainerBackupArgs{
+		ContainerID:      containerID,
+		Name:             "backup0",
+		CreationDate:     time.Now().UTC(),
+		OptimizedStorage: true,
+	})
+	require.NoError(t, err)
+
+	names, err := node.ContainerBackups(containerID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backup0"}, names)
+	assert.Equal(t, 1, node.ContainerNextBackup(containerID))
+
+	backup, err := node.ContainerBackupGet(containerID, "backup0")
+	require.NoError(t, err)
+	assert.True(t, backup.OptimizedStorage)
+	assert.False(t, backup.ContainerOnly)
+	assert.True(t, backup.ExpiryDate.IsZero())
+
+	err = node.ContainerBackupRename(backup.ID, "other")
+	require.NoError(t, err)
+
+	_, err = node.ContainerBackupGet(containerID, "backup0")
+	assert.Equal(t, db.NoSuchObjectError, err)
+
+	err = node.ContainerBackupRemove(backup.ID)
+	require.NoError(t, err)
+
+	names, err = node.ContainerBackups(containerID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, names)
+}
+
+// Only backups with an expiry date in the past are reported as expired.
+func TestContainerBackupsExpired(t *testing.T) {
+	node, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	containerID, err := node.ContainerCreate(db.ContainerArgs{Name: "c1"})
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	backups := map[string]time.Time{
+		"never":   {},
+		"expired": now.Add(-time.Hour),
+		"valid":   now.Add(time.Hour),
+	}
+
+	for name, expiry := range backups {
+		err := node.ContainerBackupCreate(db.ContainerBackupArgs{
+			ContainerID:  containerID,
+			Name:         name,
+			CreationDate: now,
+			ExpiryDate:   expiry,
+		})
+		require.NoError(t, err)
+	}
+
+	expired, err := node.ContainerBackupsExpired(now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c1/expired"}, expired)
+}
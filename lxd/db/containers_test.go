@@ -0,0 +1,90 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The expiry date of a container is stored along with it.
+func TestContainerCreate_ExpiryDate(t *testing.T) {
+	node, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	_, err := node.ContainerCreate(db.ContainerArgs{Name: "c1/snap0", Ctype: db.CTypeSnapshot, ExpiryDate: expiry})
+	require.NoError(t, err)
+
+	_, err = node.ContainerCreate(db.ContainerArgs{Name: "c1/snap1", Ctype: db.CTypeSnapshot})
+	require.NoError(t, err)
+
+	args, err := node.ContainerGet("c1/snap0")
+	require.NoError(t, err)
+	assert.True(t, expiry.Equal(args.ExpiryDate))
+
+	args, err = node.ContainerGet("c1/snap1")
+	require.NoError(t, err)
+	assert.True(t, args.ExpiryDate.IsZero())
+}
+
+// The last power state of regular containers is reported by name.
+func TestContainersPowerState(t *testing.T) {
+	node, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	_, err := node.ContainerCreate(db.ContainerArgs{Name: "c1", Ctype: db.CTypeRegular, Config: map[string]string{"volatile.last_state.power": "RUNNING"}})
+	require.NoError(t, err)
+
+	_, err = node.ContainerCreate(db.ContainerArgs{Name: "c2", Ctype: db.CTypeRegular})
+	require.NoError(t, err)
+
+	_, err = node.ContainerCreate(db.ContainerArgs{Name: "c1/snap0", Ctype: db.CTypeSnapshot})
+	require.NoError(t, err)
+
+	states, err := node.ContainersPowerState()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"c1": "RUNNING", "c2": ""}, states)
+}
+
+// The next snapshot index is computed according to the given pattern.
+func TestContainerNextSnapshot(t *testing.T) {
+	node, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	for _, name := range []string{"c1/snap0", "c1/snap1", "c1/auto3-daily", "c1/other", "c1/100%-5"} {
+		_, err := node.ContainerCreate(db.ContainerArgs{Name: name, Ctype: db.CTypeSnapshot})
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 2, node.ContainerNextSnapshot("c1", "snap%d"))
+	assert.Equal(t, 4, node.ContainerNextSnapshot("c1", "auto%d-daily"))
+	assert.Equal(t, 0, node.ContainerNextSnapshot("c1", "backup%d"))
+	assert.Equal(t, 0, node.ContainerNextSnapshot("c2", "snap%d"))
+	assert.Equal(t, 6, node.ContainerNextSnapshot("c1", "100%-%d"))
+	assert.Equal(t, 0, node.ContainerNextSnapshot("c1", "%s%d"))
+}
+
+// Only snapshots with an expiry date in the past are reported as expired.
+func TestContainerSnapshotsExpired(t *testing.T) {
+	node, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	now := time.Now()
+	snapshots := map[string]time.Time{
+		"c1/never":   {},
+		"c1/expired": now.Add(-time.Hour),
+		"c1/valid":   now.Add(time.Hour),
+	}
+
+	for name, expiry := range snapshots {
+		_, err := node.ContainerCreate(db.ContainerArgs{Name: name, Ctype: db.CTypeSnapshot, ExpiryDate: expiry})
+		require.NoError(t, err)
+	}
+
+	expired, err := node.ContainerSnapshotsExpired(now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c1/expired"}, expired)
+}
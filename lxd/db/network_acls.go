@@ -0,0 +1,146 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// NetworkACLs returns the names of all the network ACLs.
+func (n *Node) NetworkACLs() ([]string, error) {
+	q := "SELECT name FROM networks_acls ORDER BY name"
+	inargs := []interface{}{}
+	var name string
+	outfmt := []interface{}{name}
+	result, err := queryScan(n.db, q, inargs, outfmt)
+	if err != nil {
+		return []string{}, err
+	}
+
+	response := []string{}
+	for _, r := range result {
+		response = append(response, r[0].(string))
+	}
+
+	return response, nil
+}
+
+// NetworkACLGet returns the ID and details of the network ACL with the given
+// name.
+func (n *Node) NetworkACLGet(name string) (int64, *api.NetworkACL, error) {
+	id := int64(-1)
+	description := sql.NullString{}
+	ingress := ""
+	egress := ""
+
+	q := "SELECT id, description, ingress, egress FROM networks_acls WHERE name=?"
+	arg1 := []interface{}{name}
+	arg2 := []interface{}{&id, &description, &ingress, &egress}
+	err := dbQueryRowScan(n.db, q, arg1, arg2)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return -1, nil, NoSuchObjectError
+		}
+
+		return -1, nil, err
+	}
+
+	acl := api.NetworkACL{
+		Name:   name,
+		UsedBy: []string{},
+	}
+	acl.Description = description.String
+
+	err = json.Unmarshal([]byte(ingress), &acl.Ingress)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	err = json.Unmarshal([]byte(egress), &acl.Egress)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	return id, &acl, nil
+}
+
+// NetworkACLCreate adds a new network ACL to the database.
+func (n *Node) NetworkACLCreate(name string, acl api.NetworkACLPut) (int64, error) {
+	ingress, egress, err := networkACLRulesMarshal(acl)
+	if err != nil {
+		return -1, err
+	}
+
+	result, err := exec(n.db, "INSERT INTO networks_acls (name, description, ingress, egress) VALUES (?, ?, ?, ?)",
+		name, acl.Description, ingress, egress)
+	if err != nil {
+		return -1, err
+	}
+
+	return result.LastInsertId()
+}
+
+// NetworkACLUpdate replaces the description and rules of an existing network
+// ACL.
+func (n *Node) NetworkACLUpdate(name string, acl api.NetworkACLPut) error {
+	id, _, err := n.NetworkACLGet(name)
+	if err != nil {
+		return err
+	}
+
+	ingress, egress, err := networkACLRulesMarshal(acl)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec(n.db, "UPDATE networks_acls SET description=?, ingress=?, egress=? WHERE id=?",
+		acl.Description, ingress, egress, id)
+	return err
+}
+
+// NetworkACLRename renames the network ACL with the given name.
+func (n *Node) NetworkACLRename(oldName string, newName string) error {
+	id, _, err := n.NetworkACLGet(oldName)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec(n.db, "UPDATE networks_acls SET name=? WHERE id=?", newName, id)
+	return err
+}
+
+// NetworkACLDelete removes the network ACL with the given name.
+func (n *Node) NetworkACLDelete(name string) error {
+	id, _, err := n.NetworkACLGet(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec(n.db, "DELETE FROM networks_acls WHERE id=?", id)
+	return err
+}
+
+func networkACLRulesMarshal(acl api.NetworkACLPut) (string, string, error) {
+	if acl.Ingress == nil {
+		acl.Ingress = []api.NetworkACLRule{}
+	}
+
+	if acl.Egress == nil {
+		acl.Egress = []api.NetworkACLRule{}
+	}
+
+	ingress, err := json.Marshal(acl.Ingress)
+	if err != nil {
+		return "", "", err
+	}
+
+	egress, err := json.Marshal(acl.Egress)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(ingress), string(egress), nil
+}
@@ -0,0 +1,61 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Network ACLs can be created, fetched, updated, renamed and deleted, their
+// rules being kept in order.
+func TestNetworkACL_Lifecycle(t *testing.T) {
+	node, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	acl := api.NetworkACLPut{
+		Description: "Web servers",
+		Ingress: []api.NetworkACLRule{
+			{Action: "allow", Protocol: "tcp", DestinationPort: "80,443"},
+			{Action: "drop", Source: "10.0.0.0/8"},
+		},
+	}
+
+	id, err := node.NetworkACLCreate("web", acl)
+	require.NoError(t, err)
+	assert.True(t, id > 0)
+
+	names, err := node.NetworkACLs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web"}, names)
+
+	aclID, info, err := node.NetworkACLGet("web")
+	require.NoError(t, err)
+	assert.Equal(t, id, aclID)
+	assert.Equal(t, "Web servers", info.Description)
+	assert.Equal(t, acl.Ingress, info.Ingress)
+	assert.Equal(t, []api.NetworkACLRule{}, info.Egress)
+
+	acl.Egress = []api.NetworkACLRule{{Action: "reject", Protocol: "udp"}}
+	err = node.NetworkACLUpdate("web", acl)
+	require.NoError(t, err)
+
+	_, info, err = node.NetworkACLGet("web")
+	require.NoError(t, err)
+	assert.Equal(t, acl.Egress, info.Egress)
+
+	err = node.NetworkACLRename("web", "www")
+	require.NoError(t, err)
+
+	_, _, err = node.NetworkACLGet("web")
+	assert.Equal(t, db.NoSuchObjectError, err)
+
+	err = node.NetworkACLDelete("www")
+	require.NoError(t, err)
+
+	names, err = node.NetworkACLs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, names)
+}
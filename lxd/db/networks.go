@@ -0,0 +1,203 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Networks returns the names of all the LXD-managed networks.
+func (n *Node) Networks() ([]string, error) {
+	q := "SELECT name FROM networks ORDER BY name"
+	inargs := []interface{}{}
+	var name string
+	outfmt := []interface{}{name}
+	result, err := queryScan(n.db, q, inargs, outfmt)
+	if err != nil {
+		return []string{}, err
+	}
+
+	response := []string{}
+	for _, r := range result {
+		response = append(response, r[0].(string))
+	}
+
+	return response, nil
+}
+
+// NetworkGet returns the ID and details of the managed network with the given
+// name.
+func (n *Node) NetworkGet(name string) (int64, *api.Network, error) {
+	id := int64(-1)
+	description := sql.NullString{}
+
+	q := "SELECT id, description FROM networks WHERE name=?"
+	arg1 := []interface{}{name}
+	arg2 := []interface{}{&id, &description}
+	err := dbQueryRowScan(n.db, q, arg1, arg2)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return -1, nil, NoSuchObjectError
+		}
+
+		return -1, nil, err
+	}
+
+	config, err := n.NetworkConfigGet(id)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	network := api.Network{
+		Name:    name,
+		Type:    "bridge",
+		Managed: true,
+	}
+	network.Config = config
+	network.Description = description.String
+	network.UsedBy = []string{}
+
+	return id, &network, nil
+}
+
+// NetworkConfigGet returns the configuration map of the managed network with
+// the given ID.
+func (n *Node) NetworkConfigGet(id int64) (map[string]string, error) {
+	var key, value string
+	query := "SELECT key, value FROM networks_config WHERE network_id=?"
+	inargs := []interface{}{id}
+	outfmt := []interface{}{key, value}
+	results, err := queryScan(n.db, query, inargs, outfmt)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get network config: %v", err)
+	}
+
+	config := map[string]string{}
+	for _, r := range results {
+		key = r[0].(string)
+		value = r[1].(string)
+
+		config[key] = value
+	}
+
+	return config, nil
+}
+
+// NetworkCreate adds a new managed network to the database.
+func (n *Node) NetworkCreate(name string, description string, config map[string]string) (int64, error) {
+	tx, err := begin(n.db)
+	if err != nil {
+		return -1, err
+	}
+
+	result, err := tx.Exec("INSERT INTO networks (name, description) VALUES (?, ?)", name, description)
+	if err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+
+	err = NetworkConfigAdd(tx, id, config)
+	if err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+
+	err = TxCommit(tx)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// NetworkUpdate replaces the description and configuration of an existing
+// managed network.
+func (n *Node) NetworkUpdate(name string, description string, config map[string]string) error {
+	id, _, err := n.NetworkGet(name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := begin(n.db)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE networks SET description=? WHERE id=?", description, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = NetworkConfigClear(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = NetworkConfigAdd(tx, id, config)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return TxCommit(tx)
+}
+
+// NetworkRename renames the managed network with the given name.
+func (n *Node) NetworkRename(oldName string, newName string) error {
+	id, _, err := n.NetworkGet(oldName)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec(n.db, "UPDATE networks SET name=? WHERE id=?", newName, id)
+	return err
+}
+
+// NetworkDelete removes the managed network with the given name, along with
+// its configuration.
+func (n *Node) NetworkDelete(name string) error {
+	id, _, err := n.NetworkGet(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec(n.db, "DELETE FROM networks WHERE id=?", id)
+	return err
+}
+
+func NetworkConfigClear(tx *sql.Tx, id int64) error {
+	_, err := tx.Exec("DELETE FROM networks_config WHERE network_id=?", id)
+	return err
+}
+
+func NetworkConfigAdd(tx *sql.Tx, id int64, config map[string]string) error {
+	stmt, err := tx.Prepare("INSERT INTO networks_config (network_id, key, value) VALUES(?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for k, v := range config {
+		if v == "" {
+			continue
+		}
+
+		_, err = stmt.Exec(id, k, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Managed networks can be created, fetched, updated, renamed and deleted.
+func TestNetwork_Lifecycle(t *testing.T) {
+	node, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	config := map[string]string{"ipv4.address": "10.0.3.1/24", "ipv4.nat": "true"}
+	id, err := node.NetworkCreate("lxdbr0", "Default bridge", config)
+	require.NoError(t, err)
+	assert.True(t, id > 0)
+
+	names, err := node.Networks()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"lxdbr0"}, names)
+
+	networkID, network, err := node.NetworkGet("lxdbr0")
+	require.NoError(t, err)
+	assert.Equal(t, id, networkID)
+	assert.Equal(t, "bridge", network.Type)
+	assert.True(t, network.Managed)
+	assert.Equal(t, "Default bridge", network.Description)
+	assert.Equal(t, config, network.Config)
+
+	err = node.NetworkUpdate("lxdbr0", "", map[string]string{"ipv6.address": "fd42::1/64"})
+	require.NoError(t, err)
+
+	_, network, err = node.NetworkGet("lxdbr0")
+	require.NoError(t, err)
+	assert.Equal(t, "", network.Description)
+	assert.Equal(t, map[string]string{"ipv6.address": "fd42::1/64"}, network.Config)
+
+	err = node.NetworkRename("lxdbr0", "lxdbr1")
+	require.NoError(t, err)
+
+	_, _, err = node.NetworkGet("lxdbr0")
+	assert.Equal(t, db.NoSuchObjectError, err)
+
+	err = node.NetworkDelete("lxdbr1")
+	require.NoError(t, err)
+
+	names, err = node.Networks()
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, names)
+}
+
+// Creating a second network with the same name fails.
+func TestNetworkCreate_Duplicate(t *testing.T) {
+	node, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	_, err := node.NetworkCreate("lxdbr0", "", nil)
+	require.NoError(t, err)
+
+	_, err = node.NetworkCreate("lxdbr0", "", nil)
+	assert.Error(t, err)
+}
@@ -80,9 +80,146 @@ var updates = map[int]schema.Update{
 	30: updateFromV29,
 	31: updateFromV30,
 	32: updateFromV31,
+	33: updateFromV32,
+	34: updateFromV33,
+	35: updateFromV34,
+	36: updateFromV35,
+	37: updateFromV36,
+	38: updateFromV37,
+	39: updateFromV38,
+	40: updateFromV39,
 }
 
 // Schema updates begin here
+func updateFromV39(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE IF NOT EXISTS networks_acls (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    ingress TEXT NOT NULL DEFAULT '[]',
+    egress TEXT NOT NULL DEFAULT '[]',
+    UNIQUE (name)
+);`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV38(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE IF NOT EXISTS networks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    UNIQUE (name)
+);
+CREATE TABLE IF NOT EXISTS networks_config (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_id INTEGER NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    value TEXT,
+    UNIQUE (network_id, key),
+    FOREIGN KEY (network_id) REFERENCES networks (id) ON DELETE CASCADE
+);`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV37(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE IF NOT EXISTS webhooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (name)
+);`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV36(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE IF NOT EXISTS storage_volumes_snapshots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    storage_volume_id INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    creation_date DATETIME,
+    UNIQUE (storage_volume_id, name),
+    FOREIGN KEY (storage_volume_id) REFERENCES storage_volumes (id) ON DELETE CASCADE
+);`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV35(tx *sql.Tx) error {
+	stmt := `
+ALTER TABLE containers ADD COLUMN expiry_date DATETIME;`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV34(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE IF NOT EXISTS containers_backups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    container_id INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    creation_date DATETIME,
+    expiry_date DATETIME,
+    container_only INTEGER NOT NULL DEFAULT 0,
+    optimized_storage INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (container_id, name),
+    FOREIGN KEY (container_id) REFERENCES containers (id) ON DELETE CASCADE
+);`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV33(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE IF NOT EXISTS storage_volumes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    storage_pool_id INTEGER NOT NULL,
+    type INTEGER NOT NULL,
+    description TEXT,
+    UNIQUE (storage_pool_id, name, type),
+    FOREIGN KEY (storage_pool_id) REFERENCES storage_pools (id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS storage_volumes_config (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    storage_volume_id INTEGER NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    value TEXT,
+    UNIQUE (storage_volume_id, key),
+    FOREIGN KEY (storage_volume_id) REFERENCES storage_volumes (id) ON DELETE CASCADE
+);`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func updateFromV32(tx *sql.Tx) error {
+	stmt := `
+CREATE TABLE IF NOT EXISTS storage_pools (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    driver VARCHAR(255) NOT NULL,
+    description TEXT,
+    UNIQUE (name)
+);
+CREATE TABLE IF NOT EXISTS storage_pools_config (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    storage_pool_id INTEGER NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    value TEXT,
+    UNIQUE (storage_pool_id, key),
+    FOREIGN KEY (storage_pool_id) REFERENCES storage_pools (id) ON DELETE CASCADE
+);`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
 func updateFromV31(tx *sql.Tx) error {
 	stmt := `
 CREATE TABLE IF NOT EXISTS patches (
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// StoragePools returns the names of all defined storage pools.
+func (n *Node) StoragePools() ([]string, error) {
+	q := "SELECT name FROM storage_pools"
+	inargs := []interface{}{}
+	var name string
+	outfmt := []interface{}{name}
+	result, err := queryScan(n.db, q, inargs, outfmt)
+	if err != nil {
+		return []string{}, err
+	}
+
+	response := []string{}
+	for _, r := range result {
+		response = append(response, r[0].(string))
+	}
+
+	return response, nil
+}
+
+// StoragePoolGet returns the ID and details of the storage pool with the
+// given name.
+func (n *Node) StoragePoolGet(name string) (int64, *api.StoragePool, error) {
+	id := int64(-1)
+	driver := ""
+	description := sql.NullString{}
+
+	q := "SELECT id, driver, description FROM storage_pools WHERE name=?"
+	arg1 := []interface{}{name}
+	arg2 := []interface{}{&id, &driver, &description}
+	err := dbQueryRowScan(n.db, q, arg1, arg2)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return -1, nil, NoSuchObjectError
+		}
+
+		return -1, nil, err
+	}
+
+	config, err := n.StoragePoolConfigGet(id)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	pool := api.StoragePool{
+		Name:   name,
+		Driver: driver,
+	}
+	pool.Config = config
+	pool.Description = description.String
+	pool.UsedBy = []string{}
+
+	return id, &pool, nil
+}
+
+// StoragePoolConfigGet returns the configuration map of the storage pool with
+// the given ID.
+func (n *Node) StoragePoolConfigGet(id int64) (map[string]string, error) {
+	var key, value string
+	query := "SELECT key, value FROM storage_pools_config WHERE storage_pool_id=?"
+	inargs := []interface{}{id}
+	outfmt := []interface{}{key, value}
+	results, err := queryScan(n.db, query, inargs, outfmt)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get storage pool config: %v", err)
+	}
+
+	config := map[string]string{}
+	for _, r := range results {
+		key = r[0].(string)
+		value = r[1].(string)
+
+		config[key] = value
+	}
+
+	return config, nil
+}
+
+// StoragePoolCreate adds a new storage pool to the database.
+func (n *Node) StoragePoolCreate(name string, description string, driver string, config map[string]string) (int64, error) {
+	tx, err := begin(n.db)
+	if err != nil {
+		return -1, err
+	}
+
+	result, err := tx.Exec("INSERT INTO storage_pools (name, description, driver) VALUES (?, ?, ?)", name, description, driver)
+	if err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+
+	err = StoragePoolConfigAdd(tx, id, config)
+	if err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+
+	err = TxCommit(tx)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// StoragePoolUpdate replaces the description and configuration of an
+// existing storage pool.
+func (n *Node) StoragePoolUpdate(name string, description string, config map[string]string) error {
+	id, _, err := n.StoragePoolGet(name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := begin(n.db)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE storage_pools SET description=? WHERE id=?", description, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = StoragePoolConfigClear(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = StoragePoolConfigAdd(tx, id, config)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return TxCommit(tx)
+}
+
+// StoragePoolDelete removes the storage pool with the given name, along with
+// its configuration.
+func (n *Node) StoragePoolDelete(name string) error {
+	id, _, err := n.StoragePoolGet(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec(n.db, "DELETE FROM storage_pools WHERE id=?", id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// StoragePoolConfigClear removes all the configuration of the storage pool
+// with the given ID.
+func StoragePoolConfigClear(tx *sql.Tx, id int64) error {
+	_, err := tx.Exec("DELETE FROM storage_pools_config WHERE storage_pool_id=?", id)
+	return err
+}
+
+// StoragePoolConfigAdd adds the given configuration to the storage pool with
+// the given ID.
+func StoragePoolConfigAdd(tx *sql.Tx, id int64, config map[string]string) error {
+	stmt, err := tx.Prepare("INSERT INTO storage_pools_config (storage_pool_id, key, value) VALUES(?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for k, v := range config {
+		if v == "" {
+			continue
+		}
+
+		_, err = stmt.Exec(id, k, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Storage pools can be created, fetched, updated and deleted.
+func TestStoragePool_Lifecycle(t *testing.T) {
+	node, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	config := map[string]string{"zfs.pool_name": "tank/lxd"}
+	id, err := node.StoragePoolCreate("fast", "Fast pool", "zfs", config)
+	require.NoError(t, err)
+	assert.True(t, id > 0)
+
+	names, err := node.StoragePools()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fast"}, names)
+
+	poolID, pool, err := node.StoragePoolGet("fast")
+	require.NoError(t, err)
+	assert.Equal(t, id, poolID)
+	assert.Equal(t, "zfs", pool.Driver)
+	assert.Equal(t, "Fast pool", pool.Description)
+	assert.Equal(t, config, pool.Config)
+
+	err = node.StoragePoolUpdate("fast", "", map[string]string{"zfs.pool_name": "tank/other"})
+	require.NoError(t, err)
+
+	_, pool, err = node.StoragePoolGet("fast")
+	require.NoError(t, err)
+	assert.Equal(t, "", pool.Description)
+	assert.Equal(t, map[string]string{"zfs.pool_name": "tank/other"}, pool.Config)
+
+	err = node.StoragePoolDelete("fast")
+	require.NoError(t, err)
+
+	_, _, err = node.StoragePoolGet("fast")
+	assert.Equal(t, db.NoSuchObjectError, err)
+}
+
+// Creating a second pool with the same name fails.
+func TestStoragePoolCreate_Duplicate(t *testing.T) {
+	node, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	_, err := node.StoragePoolCreate("default", "", "dir", nil)
+	require.NoError(t, err)
+
+	_, err = node.StoragePoolCreate("default", "", "dir", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,235 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Storage volume types.
+const (
+	StoragePoolVolumeTypeContainer = iota
+	StoragePoolVolumeTypeImage
+	StoragePoolVolumeTypeCustom
+)
+
+// Storage volume type names, as used in the REST API.
+const (
+	StoragePoolVolumeTypeNameContainer = "container"
+	StoragePoolVolumeTypeNameImage     = "image"
+	StoragePoolVolumeTypeNameCustom    = "custom"
+)
+
+// StoragePoolVolumeTypeToName converts a volume type to its API name.
+func StoragePoolVolumeTypeToName(volumeType int) (string, error) {
+	switch volumeType {
+	case StoragePoolVolumeTypeContainer:
+		return StoragePoolVolumeTypeNameContainer, nil
+	case StoragePoolVolumeTypeImage:
+		return StoragePoolVolumeTypeNameImage, nil
+	case StoragePoolVolumeTypeCustom:
+		return StoragePoolVolumeTypeNameCustom, nil
+	}
+
+	return "", fmt.Errorf("Invalid storage volume type")
+}
+
+// StoragePoolVolumeTypeFromName converts an API volume type name to its
+// type.
+func StoragePoolVolumeTypeFromName(name string) (int, error) {
+	switch name {
+	case StoragePoolVolumeTypeNameContainer:
+		return StoragePoolVolumeTypeContainer, nil
+	case StoragePoolVolumeTypeNameImage:
+		return StoragePoolVolumeTypeImage, nil
+	case StoragePoolVolumeTypeNameCustom:
+		return StoragePoolVolumeTypeCustom, nil
+	}
+
+	return -1, fmt.Errorf("Invalid storage volume type name: %s", name)
+}
+
+// StoragePoolVolumes returns the names of all volumes of the given type in
+// the storage pool with the given ID.
+func (n *Node) StoragePoolVolumes(poolID int64, volumeType int) ([]string, error) {
+	q := "SELECT name FROM storage_volumes WHERE storage_pool_id=? AND type=?"
+	inargs := []interface{}{poolID, volumeType}
+	var name string
+	outfmt := []interface{}{name}
+	result, err := queryScan(n.db, q, inargs, outfmt)
+	if err != nil {
+		return []string{}, err
+	}
+
+	response := []string{}
+	for _, r := range result {
+		response = append(response, r[0].(string))
+	}
+
+	return response, nil
+}
+
+// StoragePoolVolumeGet returns the ID and details of a storage volume.
+func (n *Node) StoragePoolVolumeGet(poolID int64, name string, volumeType int) (int64, *api.StorageVolume, error) {
+	id := int64(-1)
+	description := sql.NullString{}
+
+	q := "SELECT id, description FROM storage_volumes WHERE storage_pool_id=? AND name=? AND type=?"
+	arg1 := []interface{}{poolID, name, volumeType}
+	arg2 := []interface{}{&id, &description}
+	err := dbQueryRowScan(n.db, q, arg1, arg2)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return -1, nil, NoSuchObjectError
+		}
+
+		return -1, nil, err
+	}
+
+	config, err := n.StoragePoolVolumeConfigGet(id)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	typeName, err := StoragePoolVolumeTypeToName(volumeType)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	volume := api.StorageVolume{
+		Name: name,
+		Type: typeName,
+	}
+	volume.Config = config
+	volume.Description = description.String
+	volume.UsedBy = []string{}
+
+	return id, &volume, nil
+}
+
+// StoragePoolVolumeConfigGet returns the configuration map of the storage
+// volume with the given ID.
+func (n *Node) StoragePoolVolumeConfigGet(id int64) (map[string]string, error) {
+	var key, value string
+	query := "SELECT key, value FROM storage_volumes_config WHERE storage_volume_id=?"
+	inargs := []interface{}{id}
+	outfmt := []interface{}{key, value}
+	results, err := queryScan(n.db, query, inargs, outfmt)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get storage volume config: %v", err)
+	}
+
+	config := map[string]string{}
+	for _, r := range results {
+		key = r[0].(string)
+		value = r[1].(string)
+
+		config[key] = value
+	}
+
+	return config, nil
+}
+
+// StoragePoolVolumeCreate adds a new storage volume to the database.
+func (n *Node) StoragePoolVolumeCreate(poolID int64, name string, description string, volumeType int, config map[string]string) (int64, error) {
+	tx, err := begin(n.db)
+	if err != nil {
+		return -1, err
+	}
+
+	result, err := tx.Exec("INSERT INTO storage_volumes (storage_pool_id, name, description, type) VALUES (?, ?, ?, ?)", poolID, name, description, volumeType)
+	if err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+
+	err = StoragePoolVolumeConfigAdd(tx, id, config)
+	if err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+
+	err = TxCommit(tx)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// StoragePoolVolumeUpdate replaces the description and configuration of an
+// existing storage volume.
+func (n *Node) StoragePoolVolumeUpdate(id int64, description string, config map[string]string) error {
+	tx, err := begin(n.db)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE storage_volumes SET description=? WHERE id=?", description, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = StoragePoolVolumeConfigClear(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = StoragePoolVolumeConfigAdd(tx, id, config)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return TxCommit(tx)
+}
+
+// StoragePoolVolumeRename renames the storage volume with the given ID.
+func (n *Node) StoragePoolVolumeRename(id int64, newName string) error {
+	_, err := exec(n.db, "UPDATE storage_volumes SET name=? WHERE id=?", newName, id)
+	return err
+}
+
+// StoragePoolVolumeDelete removes the storage volume with the given ID, along
+// with its configuration.
+func (n *Node) StoragePoolVolumeDelete(id int64) error {
+	_, err := exec(n.db, "DELETE FROM storage_volumes WHERE id=?", id)
+	return err
+}
+
+func StoragePoolVolumeConfigClear(tx *sql.Tx, id int64) error {
+	_, err := tx.Exec("DELETE FROM storage_volumes_config WHERE storage_volume_id=?", id)
+	return err
+}
+
+func StoragePoolVolumeConfigAdd(tx *sql.Tx, id int64, config map[string]string) error {
+	stmt, err := tx.Prepare("INSERT INTO storage_volumes_config (storage_volume_id, key, value) VALUES(?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for k, v := range config {
+		if v == "" {
+			continue
+		}
+
+		_, err = stmt.Exec(id, k, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
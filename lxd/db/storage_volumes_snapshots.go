@@ -0,0 +1,128 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// StorageVolumeSnapshotArgs is a value object holding all db-related details
+// about a snapshot of a custom storage volume.
+type StorageVolumeSnapshotArgs struct {
+	// Don't set manually
+	ID int64
+
+	VolumeID     int64
+	Name         string
+	Description  string
+	CreationDate time.Time
+}
+
+// StoragePoolVolumeSnapshots returns the names of all the snapshots of the
+// storage volume with the given ID, from the oldest to the most recent one.
+func (n *Node) StoragePoolVolumeSnapshots(volumeID int64) ([]string, error) {
+	q := "SELECT name FROM storage_volumes_snapshots WHERE storage_volume_id=? ORDER BY id"
+	inargs := []interface{}{volumeID}
+	var name string
+	outfmt := []interface{}{name}
+	results, err := queryScan(n.db, q, inargs, outfmt)
+	if err != nil {
+		return []string{}, err
+	}
+
+	response := []string{}
+	for _, r := range results {
+		response = append(response, r[0].(string))
+	}
+
+	return response, nil
+}
+
+// StoragePoolVolumeSnapshotGet returns the snapshot with the given name of the
+// storage volume with the given ID.
+func (n *Node) StoragePoolVolumeSnapshotGet(volumeID int64, name string) (StorageVolumeSnapshotArgs, error) {
+	args := StorageVolumeSnapshotArgs{}
+	args.VolumeID = volumeID
+	args.Name = name
+
+	description := sql.NullString{}
+	var create *time.Time
+
+	q := "SELECT id, description, creation_date FROM storage_volumes_snapshots WHERE storage_volume_id=? AND name=?"
+	arg1 := []interface{}{volumeID, name}
+	arg2 := []interface{}{&args.ID, &description, &create}
+	err := dbQueryRowScan(n.db, q, arg1, arg2)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return args, NoSuchObjectError
+		}
+
+		return args, err
+	}
+
+	args.Description = description.String
+	if create != nil {
+		args.CreationDate = *create
+	}
+
+	return args, nil
+}
+
+// StoragePoolVolumeSnapshotCreate adds a new storage volume snapshot to the
+// database.
+func (n *Node) StoragePoolVolumeSnapshotCreate(args StorageVolumeSnapshotArgs) (int64, error) {
+	_, err := n.StoragePoolVolumeSnapshotGet(args.VolumeID, args.Name)
+	if err == nil {
+		return -1, DbErrAlreadyDefined
+	}
+
+	result, err := exec(n.db, `INSERT INTO storage_volumes_snapshots
+            (storage_volume_id, name, description, creation_date)
+            VALUES (?, ?, ?, ?)`,
+		args.VolumeID, args.Name, args.Description, args.CreationDate)
+	if err != nil {
+		return -1, err
+	}
+
+	return result.LastInsertId()
+}
+
+// StoragePoolVolumeSnapshotRename renames the storage volume snapshot with
+// the given ID.
+func (n *Node) StoragePoolVolumeSnapshotRename(id int64, newName string) error {
+	_, err := exec(n.db, "UPDATE storage_volumes_snapshots SET name=? WHERE id=?", newName, id)
+	return err
+}
+
+// StoragePoolVolumeSnapshotDelete removes the storage volume snapshot with
+// the given ID.
+func (n *Node) StoragePoolVolumeSnapshotDelete(id int64) error {
+	_, err := exec(n.db, "DELETE FROM storage_volumes_snapshots WHERE id=?", id)
+	return err
+}
+
+// StoragePoolVolumeNextSnapshot returns the index to use for the next
+// automatically named snapshot of the storage volume with the given ID.
+func (n *Node) StoragePoolVolumeNextSnapshot(volumeID int64) int {
+	names, err := n.StoragePoolVolumeSnapshots(volumeID)
+	if err != nil {
+		return 0
+	}
+
+	max := 0
+	for _, name := range names {
+		var num int
+		count, err := fmt.Sscanf(name, "snap%d", &num)
+		if err != nil || count != 1 {
+			continue
+		}
+
+		if num >= max {
+			max = num + 1
+		}
+	}
+
+	return max
+}
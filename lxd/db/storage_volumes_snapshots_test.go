@@ -0,0 +1,78 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Storage volume snapshots can be created, fetched, renamed and removed.
+func TestStoragePoolVolumeSnapshot_Lifecycle(t *testing.T) {
+	node, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	poolID, err := node.StoragePoolCreate("default", "", "dir", nil)
+	require.NoError(t, err)
+
+	volumeID, err := node.StoragePoolVolumeCreate(poolID, "data", "", db.StoragePoolVolumeTypeCustom, nil)
+	require.NoError(t, err)
+
+	for _, name := range []string{"snap0", "before-upgrade"} {
+		_, err = node.StoragePoolVolumeSnapshotCreate(db.StorageVolumeSnapshotArgs{
+			VolumeID:     volumeID,
+			Name:         name,
+			CreationDate: time.Now().UTC(),
+		})
+		require.NoError(t, err)
+	}
+
+	_, err = node.StoragePoolVolumeSnapshotCreate(db.StorageVolumeSnapshotArgs{VolumeID: volumeID, Name: "snap0"})
+	assert.Equal(t, db.DbErrAlreadyDefined, err)
+
+	names, err := node.StoragePoolVolumeSnapshots(volumeID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"snap0", "before-upgrade"}, names)
+	assert.Equal(t, 1, node.StoragePoolVolumeNextSnapshot(volumeID))
+
+	snapshot, err := node.StoragePoolVolumeSnapshotGet(volumeID, "snap0")
+	require.NoError(t, err)
+	assert.False(t, snapshot.CreationDate.IsZero())
+
+	err = node.StoragePoolVolumeSnapshotRename(snapshot.ID, "other")
+	require.NoError(t, err)
+
+	_, err = node.StoragePoolVolumeSnapshotGet(volumeID, "snap0")
+	assert.Equal(t, db.NoSuchObjectError, err)
+
+	err = node.StoragePoolVolumeSnapshotDelete(snapshot.ID)
+	require.NoError(t, err)
+
+	names, err = node.StoragePoolVolumeSnapshots(volumeID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"before-upgrade"}, names)
+}
+
+// Deleting a storage volume deletes its snapshots.
+func TestStoragePoolVolumeDelete_Snapshots(t *testing.T) {
+	node, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	poolID, err := node.StoragePoolCreate("default", "", "dir", nil)
+	require.NoError(t, err)
+
+	volumeID, err := node.StoragePoolVolumeCreate(poolID, "data", "", db.StoragePoolVolumeTypeCustom, nil)
+	require.NoError(t, err)
+
+	_, err = node.StoragePoolVolumeSnapshotCreate(db.StorageVolumeSnapshotArgs{VolumeID: volumeID, Name: "snap0"})
+	require.NoError(t, err)
+
+	err = node.StoragePoolVolumeDelete(volumeID)
+	require.NoError(t, err)
+
+	names, err := node.StoragePoolVolumeSnapshots(volumeID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, names)
+}
@@ -0,0 +1,65 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Custom storage volumes can be created, fetched, renamed and deleted.
+func TestStoragePoolVolume_Lifecycle(t *testing.T) {
+	node, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	poolID, err := node.StoragePoolCreate("default", "", "dir", nil)
+	require.NoError(t, err)
+
+	config := map[string]string{"size": "10GB"}
+	id, err := node.StoragePoolVolumeCreate(poolID, "data", "Data", db.StoragePoolVolumeTypeCustom, config)
+	require.NoError(t, err)
+
+	names, err := node.StoragePoolVolumes(poolID, db.StoragePoolVolumeTypeCustom)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"data"}, names)
+
+	volumeID, volume, err := node.StoragePoolVolumeGet(poolID, "data", db.StoragePoolVolumeTypeCustom)
+	require.NoError(t, err)
+	assert.Equal(t, id, volumeID)
+	assert.Equal(t, "custom", volume.Type)
+	assert.Equal(t, "Data", volume.Description)
+	assert.Equal(t, config, volume.Config)
+
+	err = node.StoragePoolVolumeRename(id, "other")
+	require.NoError(t, err)
+
+	_, _, err = node.StoragePoolVolumeGet(poolID, "data", db.StoragePoolVolumeTypeCustom)
+	assert.Equal(t, db.NoSuchObjectError, err)
+
+	err = node.StoragePoolVolumeDelete(id)
+	require.NoError(t, err)
+
+	names, err = node.StoragePoolVolumes(poolID, db.StoragePoolVolumeTypeCustom)
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, names)
+}
+
+// Deleting a storage pool deletes its volumes.
+func TestStoragePoolDelete_Volumes(t *testing.T) {
+	node, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	poolID, err := node.StoragePoolCreate("default", "", "dir", nil)
+	require.NoError(t, err)
+
+	_, err = node.StoragePoolVolumeCreate(poolID, "data", "", db.StoragePoolVolumeTypeCustom, nil)
+	require.NoError(t, err)
+
+	err = node.StoragePoolDelete("default")
+	require.NoError(t, err)
+
+	names, err := node.StoragePoolVolumes(poolID, db.StoragePoolVolumeTypeCustom)
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, names)
+}
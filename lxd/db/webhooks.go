@@ -0,0 +1,45 @@
+package db
+
+import (
+	"database/sql"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// WebhookFailures returns the number of events which couldn't be delivered
+// to the webhook with the given name.
+func (n *Node) WebhookFailures(name string) (int64, error) {
+	failures := int64(0)
+
+	q := "SELECT failures FROM webhooks WHERE name=?"
+	arg1 := []interface{}{name}
+	arg2 := []interface{}{&failures}
+	err := dbQueryRowScan(n.db, q, arg1, arg2)
+	if err != nil && err != sql.ErrNoRows {
+		return -1, err
+	}
+
+	return failures, nil
+}
+
+// WebhookFailureAdd records a failed delivery for the webhook with the given
+// name and returns its updated number of failures.
+func (n *Node) WebhookFailureAdd(name string) (int64, error) {
+	_, err := exec(n.db, "INSERT OR IGNORE INTO webhooks (name) VALUES (?)", name)
+	if err != nil {
+		return -1, err
+	}
+
+	_, err = exec(n.db, "UPDATE webhooks SET failures=failures+1 WHERE name=?", name)
+	if err != nil {
+		return -1, err
+	}
+
+	return n.WebhookFailures(name)
+}
+
+// WebhookDelete forgets about the failures of the webhook with the given name.
+func (n *Node) WebhookDelete(name string) error {
+	_, err := exec(n.db, "DELETE FROM webhooks WHERE name=?", name)
+	return err
+}
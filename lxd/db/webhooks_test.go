@@ -0,0 +1,36 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Webhook delivery failures are counted until the webhook is deleted.
+func TestWebhookFailures(t *testing.T) {
+	node, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	failures, err := node.WebhookFailures("chatops")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), failures)
+
+	for i := 1; i <= 3; i++ {
+		failures, err = node.WebhookFailureAdd("chatops")
+		require.NoError(t, err)
+		assert.Equal(t, int64(i), failures)
+	}
+
+	failures, err = node.WebhookFailures("cmdb")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), failures)
+
+	err = node.WebhookDelete("chatops")
+	require.NoError(t, err)
+
+	failures, err = node.WebhookFailures("chatops")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), failures)
+}
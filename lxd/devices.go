@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math/big"
+	"net"
 	"os"
 	"path"
 	"path/filepath"
@@ -20,6 +21,7 @@ import (
 
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/types"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
@@ -543,6 +545,322 @@ func deviceRemoveInterface(nic string) error {
 	return err
 }
 
+func deviceNetworkFilteringEnabled(m types.Device) bool {
+	for _, key := range []string{"security.mac_filtering", "security.ipv4_filtering", "security.ipv6_filtering"} {
+		if shared.IsTrue(m[key]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deviceNetworkFilterRules returns the ebtables rules which only let the
+// traffic coming from the host side veth of a bridged nic through if it uses
+// the nic's own MAC and IP addresses.
+func deviceNetworkFilterRules(veth string, m types.Device) ([][]string, error) {
+	rules := [][]string{}
+
+	if shared.IsTrue(m["security.mac_filtering"]) {
+		if m["hwaddr"] == "" {
+			return nil, fmt.Errorf("Failed to find the MAC address of the nic")
+		}
+
+		rules = append(rules,
+			[]string{"-p", "ARP", "-i", veth, "--arp-mac-src", "!", m["hwaddr"], "-j", "DROP"},
+			[]string{"-i", veth, "-s", "!", m["hwaddr"], "-j", "DROP"})
+	}
+
+	if shared.IsTrue(m["security.ipv4_filtering"]) {
+		if m["ipv4.address"] == "" {
+			return nil, fmt.Errorf("IPv4 filtering requires ipv4.address to be set on the nic")
+		}
+
+		// Let the DHCP and ARP probes without an address through
+		rules = append(rules,
+			[]string{"-p", "ARP", "-i", veth, "--arp-ip-src", "0.0.0.0", "-j", "ACCEPT"},
+			[]string{"-p", "ARP", "-i", veth, "--arp-ip-src", "!", m["ipv4.address"], "-j", "DROP"},
+			[]string{"-p", "IPv4", "-i", veth, "--ip-src", "0.0.0.0", "--ip-dst", "255.255.255.255", "--ip-proto", "udp", "--ip-dport", "67", "-j", "ACCEPT"},
+			[]string{"-p", "IPv4", "-i", veth, "--ip-src", "!", m["ipv4.address"], "-j", "DROP"})
+	}
+
+	if shared.IsTrue(m["security.ipv6_filtering"]) {
+		if m["ipv6.address"] == "" {
+			return nil, fmt.Errorf("IPv6 filtering requires ipv6.address to be set on the nic")
+		}
+
+		linkLocal, err := deviceEUI64LinkLocal(m["hwaddr"])
+		if err != nil {
+			return nil, err
+		}
+
+		// Containers mustn't act as routers, but need their own
+		// link-local address and duplicate address detection
+		rules = append(rules,
+			[]string{"-p", "IPv6", "-i", veth, "--ip6-proto", "ipv6-icmp", "--ip6-icmp-type", "router-advertisement", "-j", "DROP"},
+			[]string{"-p", "IPv6", "-i", veth, "--ip6-src", linkLocal.String(), "-j", "ACCEPT"},
+			[]string{"-p", "IPv6", "-i", veth, "--ip6-src", "::/128", "--ip6-proto", "ipv6-icmp", "-j", "ACCEPT"},
+			[]string{"-p", "IPv6", "-i", veth, "--ip6-src", "!", m["ipv6.address"], "-j", "DROP"})
+	}
+
+	return rules, nil
+}
+
+// deviceNetworkFilterNDRules returns the ip6tables rules which drop the
+// neighbour advertisements coming from the nic for addresses other than its
+// own, as ebtables can't match on the advertised target address.
+func deviceNetworkFilterNDRules(veth string, m types.Device) ([][]string, error) {
+	if !shared.IsTrue(m["security.ipv6_filtering"]) {
+		return nil, nil
+	}
+
+	rule, err := deviceNetworkFilterNDRule([]string{"-m", "physdev", "--physdev-in", veth}, m)
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]string{rule}, nil
+}
+
+// deviceNetworkFilterNDRule returns the ip6tables rule dropping the neighbour
+// advertisements matched by the given arguments which target neither the
+// nic's link-local nor its static IPv6 address.
+func deviceNetworkFilterNDRule(match []string, m types.Device) ([]string, error) {
+	linkLocal, err := deviceEUI64LinkLocal(m["hwaddr"])
+	if err != nil {
+		return nil, err
+	}
+
+	rule := append([]string{"-p", "ipv6-icmp"}, match...)
+	rule = append(rule, "-m", "icmp6", "--icmpv6-type", "neighbour-advertisement")
+
+	// The target address follows the 40 bytes IPv6 header and the first
+	// 8 bytes of the advertisement
+	for _, address := range []string{linkLocal.String(), m["ipv6.address"]} {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return nil, fmt.Errorf("Invalid IPv6 address '%s'", address)
+		}
+
+		rule = append(rule, "-m", "string", "!", "--hex-string", fmt.Sprintf("|%s|", hex.EncodeToString(ip.To16())), "--algo", "bm", "--from", "48", "--to", "64")
+	}
+
+	return append(rule, "-j", "DROP"), nil
+}
+
+// deviceNetworkFilterRoutedRules returns the iptables and ip6tables rules
+// which only let the traffic coming from the host side veth of a p2p nic
+// through if it uses the nic's own MAC and IP addresses. That traffic is
+// routed by the host rather than bridged, so it's matched on its input
+// interface and ARP isn't filtered.
+func deviceNetworkFilterRoutedRules(veth string, m types.Device) ([][]string, [][]string, error) {
+	ipv4Rules := [][]string{}
+	ipv6Rules := [][]string{}
+
+	if shared.IsTrue(m["security.mac_filtering"]) {
+		if m["hwaddr"] == "" {
+			return nil, nil, fmt.Errorf("Failed to find the MAC address of the nic")
+		}
+
+		rule := []string{"-i", veth, "-m", "mac", "!", "--mac-source", m["hwaddr"], "-j", "DROP"}
+		ipv4Rules = append(ipv4Rules, rule)
+		ipv6Rules = append(ipv6Rules, rule)
+	}
+
+	if shared.IsTrue(m["security.ipv4_filtering"]) {
+		if m["ipv4.address"] == "" {
+			return nil, nil, fmt.Errorf("IPv4 filtering requires ipv4.address to be set on the nic")
+		}
+
+		// Let the DHCP requests without an address through
+		ipv4Rules = append(ipv4Rules,
+			[]string{"-i", veth, "-s", "0.0.0.0", "-d", "255.255.255.255", "-p", "udp", "--dport", "67", "-j", "ACCEPT"},
+			[]string{"-i", veth, "!", "-s", m["ipv4.address"], "-j", "DROP"})
+	}
+
+	if shared.IsTrue(m["security.ipv6_filtering"]) {
+		if m["ipv6.address"] == "" {
+			return nil, nil, fmt.Errorf("IPv6 filtering requires ipv6.address to be set on the nic")
+		}
+
+		linkLocal, err := deviceEUI64LinkLocal(m["hwaddr"])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// The neighbour advertisements are checked ahead of the
+		// link-local address being let through
+		ndRule, err := deviceNetworkFilterNDRule([]string{"-i", veth}, m)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ipv6Rules = append(ipv6Rules,
+			ndRule,
+			[]string{"-i", veth, "-p", "ipv6-icmp", "-m", "icmp6", "--icmpv6-type", "router-advertisement", "-j", "DROP"},
+			[]string{"-i", veth, "-s", linkLocal.String(), "-j", "ACCEPT"},
+			[]string{"-i", veth, "-s", "::/128", "-p", "ipv6-icmp", "-j", "ACCEPT"},
+			[]string{"-i", veth, "!", "-s", m["ipv6.address"], "-j", "DROP"})
+	}
+
+	return ipv4Rules, ipv6Rules, nil
+}
+
+// deviceEUI64LinkLocal returns the link-local address a nic derives from its
+// MAC address.
+func deviceEUI64LinkLocal(hwaddr string) (net.IP, error) {
+	mac, err := net.ParseMAC(hwaddr)
+	if err != nil || len(mac) != 6 {
+		return nil, fmt.Errorf("Failed to find the MAC address of the nic")
+	}
+
+	ip := net.ParseIP("fe80::")
+	ip[8] = mac[0] ^ 0x02
+	ip[9] = mac[1]
+	ip[10] = mac[2]
+	ip[11] = 0xff
+	ip[12] = 0xfe
+	ip[13] = mac[3]
+	ip[14] = mac[4]
+	ip[15] = mac[5]
+
+	return ip, nil
+}
+
+// deviceNetworkFilterNDCheck makes sure that bridged IPv6 traffic goes
+// through ip6tables, which the neighbour advertisement rules rely on.
+func deviceNetworkFilterNDCheck() error {
+	_ = util.LoadModule("br_netfilter")
+
+	content, err := ioutil.ReadFile("/proc/sys/net/bridge/bridge-nf-call-ip6tables")
+	if err != nil || strings.TrimSpace(string(content)) != "1" {
+		return fmt.Errorf("IPv6 filtering requires the br_netfilter module with net.bridge.bridge-nf-call-ip6tables enabled")
+	}
+
+	return nil
+}
+
+// deviceNetworkFiltersApply installs the spoofing protection rules of the nic.
+func deviceNetworkFiltersApply(veth string, m types.Device) error {
+	if m["nictype"] == "p2p" {
+		return deviceNetworkFiltersApplyRouted(veth, m)
+	}
+
+	rules, err := deviceNetworkFilterRules(veth, m)
+	if err != nil {
+		return err
+	}
+
+	ndRules, err := deviceNetworkFilterNDRules(veth, m)
+	if err != nil {
+		return err
+	}
+
+	if len(ndRules) > 0 {
+		err = deviceNetworkFilterNDCheck()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Clear any rule left behind by an unclean shutdown
+	deviceNetworkFiltersRemove(veth, m)
+
+	for _, chain := range []string{"INPUT", "FORWARD"} {
+		for _, rule := range rules {
+			args := append([]string{"-t", "filter", "-A", chain}, rule...)
+			_, err := shared.RunCommand("ebtables", args...)
+			if err != nil {
+				deviceNetworkFiltersRemove(veth, m)
+				return err
+			}
+		}
+
+		for _, rule := range ndRules {
+			args := append([]string{"-w", "-t", "filter", "-I", chain}, rule...)
+			_, err := shared.RunCommand("ip6tables", args...)
+			if err != nil {
+				deviceNetworkFiltersRemove(veth, m)
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// deviceNetworkFiltersRemove removes the spoofing protection rules of the nic.
+func deviceNetworkFiltersRemove(veth string, m types.Device) {
+	if m["nictype"] == "p2p" {
+		deviceNetworkFiltersRemoveRouted(veth, m)
+		return
+	}
+
+	rules, err := deviceNetworkFilterRules(veth, m)
+	if err != nil {
+		return
+	}
+
+	ndRules, _ := deviceNetworkFilterNDRules(veth, m)
+
+	for _, chain := range []string{"INPUT", "FORWARD"} {
+		for _, rule := range rules {
+			args := append([]string{"-t", "filter", "-D", chain}, rule...)
+			shared.RunCommand("ebtables", args...)
+		}
+
+		for _, rule := range ndRules {
+			args := append([]string{"-w", "-t", "filter", "-D", chain}, rule...)
+			shared.RunCommand("ip6tables", args...)
+		}
+	}
+}
+
+// deviceNetworkFiltersApplyRouted installs the spoofing protection rules of
+// a p2p nic, at the top of the chains and in order.
+func deviceNetworkFiltersApplyRouted(veth string, m types.Device) error {
+	ipv4Rules, ipv6Rules, err := deviceNetworkFilterRoutedRules(veth, m)
+	if err != nil {
+		return err
+	}
+
+	// Clear any rule left behind by an unclean shutdown
+	deviceNetworkFiltersRemoveRouted(veth, m)
+
+	for _, chain := range []string{"INPUT", "FORWARD"} {
+		for command, rules := range map[string][][]string{"iptables": ipv4Rules, "ip6tables": ipv6Rules} {
+			for i, rule := range rules {
+				args := append([]string{"-w", "-t", "filter", "-I", chain, fmt.Sprintf("%d", i+1)}, rule...)
+				_, err := shared.RunCommand(command, args...)
+				if err != nil {
+					deviceNetworkFiltersRemoveRouted(veth, m)
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// deviceNetworkFiltersRemoveRouted removes the spoofing protection rules of
+// a p2p nic.
+func deviceNetworkFiltersRemoveRouted(veth string, m types.Device) {
+	ipv4Rules, ipv6Rules, err := deviceNetworkFilterRoutedRules(veth, m)
+	if err != nil {
+		return
+	}
+
+	for _, chain := range []string{"INPUT", "FORWARD"} {
+		for command, rules := range map[string][][]string{"iptables": ipv4Rules, "ip6tables": ipv6Rules} {
+			for _, rule := range rules {
+				args := append([]string{"-w", "-t", "filter", "-D", chain}, rule...)
+				shared.RunCommand(command, args...)
+			}
+		}
+	}
+}
+
 func deviceMountDisk(srcPath string, dstPath string, readonly bool, recursive bool) error {
 	var err error
 
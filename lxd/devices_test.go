@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/types"
+)
+
+// The spoofing protection rules only let the nic's own addresses through.
+func TestDeviceNetworkFilterRules(t *testing.T) {
+	m := types.Device{
+		"nictype":                "bridged",
+		"hwaddr":                 "00:16:3e:c4:ed:25",
+		"ipv4.address":           "10.0.3.50",
+		"security.mac_filtering": "true",
+	}
+
+	rules, err := deviceNetworkFilterRules("veth1234", m)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"-p", "ARP", "-i", "veth1234", "--arp-mac-src", "!", "00:16:3e:c4:ed:25", "-j", "DROP"},
+		{"-i", "veth1234", "-s", "!", "00:16:3e:c4:ed:25", "-j", "DROP"},
+	}, rules)
+
+	m["security.ipv4_filtering"] = "true"
+	rules, err = deviceNetworkFilterRules("veth1234", m)
+	require.NoError(t, err)
+	assert.Len(t, rules, 6)
+	assert.Equal(t, []string{"-p", "IPv4", "-i", "veth1234", "--ip-src", "!", "10.0.3.50", "-j", "DROP"}, rules[5])
+
+	// IPv6 filtering needs a static address
+	m["security.ipv6_filtering"] = "true"
+	_, err = deviceNetworkFilterRules("veth1234", m)
+	assert.Error(t, err)
+
+	// Only the nic's own link-local address is let through
+	m["ipv6.address"] = "fd42::50"
+	rules, err = deviceNetworkFilterRules("veth1234", m)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-p", "IPv6", "-i", "veth1234", "--ip6-src", "fe80::216:3eff:fec4:ed25", "-j", "ACCEPT"}, rules[7])
+}
+
+// Neighbour advertisements must target the nic's link-local or static IPv6
+// address.
+func TestDeviceNetworkFilterNDRules(t *testing.T) {
+	m := types.Device{
+		"nictype":      "bridged",
+		"hwaddr":       "00:16:3e:c4:ed:25",
+		"ipv6.address": "fd42::50",
+	}
+
+	rules, err := deviceNetworkFilterNDRules("veth1234", m)
+	require.NoError(t, err)
+	assert.Len(t, rules, 0)
+
+	m["security.ipv6_filtering"] = "true"
+	rules, err = deviceNetworkFilterNDRules("veth1234", m)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{
+		"-p", "ipv6-icmp", "-m", "physdev", "--physdev-in", "veth1234", "-m", "icmp6", "--icmpv6-type", "neighbour-advertisement",
+		"-m", "string", "!", "--hex-string", "|fe8000000000000002163efffec4ed25|", "--algo", "bm", "--from", "48", "--to", "64",
+		"-m", "string", "!", "--hex-string", "|fd420000000000000000000000000050|", "--algo", "bm", "--from", "48", "--to", "64",
+		"-j", "DROP",
+	}}, rules)
+}
+
+// The spoofing protection rules of p2p nics match on the host side veth, with
+// the neighbour advertisements checked first.
+func TestDeviceNetworkFilterRoutedRules(t *testing.T) {
+	m := types.Device{
+		"nictype":                "p2p",
+		"hwaddr":                 "00:16:3e:c4:ed:25",
+		"security.mac_filtering": "true",
+	}
+
+	ipv4Rules, ipv6Rules, err := deviceNetworkFilterRoutedRules("veth1234", m)
+	require.NoError(t, err)
+	macRule := []string{"-i", "veth1234", "-m", "mac", "!", "--mac-source", "00:16:3e:c4:ed:25", "-j", "DROP"}
+	assert.Equal(t, [][]string{macRule}, ipv4Rules)
+	assert.Equal(t, [][]string{macRule}, ipv6Rules)
+
+	// IPv4 filtering needs a static address
+	m["security.ipv4_filtering"] = "true"
+	_, _, err = deviceNetworkFilterRoutedRules("veth1234", m)
+	assert.Error(t, err)
+
+	m["ipv4.address"] = "192.0.2.50"
+	ipv4Rules, _, err = deviceNetworkFilterRoutedRules("veth1234", m)
+	require.NoError(t, err)
+	assert.Len(t, ipv4Rules, 3)
+	assert.Equal(t, []string{"-i", "veth1234", "!", "-s", "192.0.2.50", "-j", "DROP"}, ipv4Rules[2])
+
+	m["security.ipv6_filtering"] = "true"
+	m["ipv6.address"] = "2001:db8::50"
+	ipv4Rules, ipv6Rules, err = deviceNetworkFilterRoutedRules("veth1234", m)
+	require.NoError(t, err)
+	assert.Len(t, ipv4Rules, 3)
+	assert.Len(t, ipv6Rules, 6)
+	assert.Equal(t, []string{"-p", "ipv6-icmp", "-i", "veth1234", "-m", "icmp6", "--icmpv6-type", "neighbour-advertisement"}, ipv6Rules[1][:8])
+	assert.Equal(t, []string{"-i", "veth1234", "-s", "fe80::216:3eff:fec4:ed25", "-j", "ACCEPT"}, ipv6Rules[3])
+	assert.Equal(t, []string{"-i", "veth1234", "!", "-s", "2001:db8::50", "-j", "DROP"}, ipv6Rules[5])
+}
@@ -43,6 +43,16 @@ type Config struct {
 	//
 	// It can be updated after the endpoints are up using UpdateNetworkAddress().
 	NetworkAddress string
+
+	// HTTP server exposing the metrics without authentication. If not set,
+	// the metrics endpoint won't be started.
+	MetricsServer *http.Server
+
+	// MetricsAddress sets the address for the metrics endpoint. If not set,
+	// the metrics endpoint won't be started.
+	//
+	// It can be updated after the endpoints are up using MetricsUpdateAddress().
+	MetricsAddress string
 }
 
 // Up brings up all applicable LXD endpoints and starts accepting HTTP
@@ -83,6 +93,12 @@ type Config struct {
 //
 // The network endpoint socket will use TLS encryption, using the certificate
 // keypair and CA passed via config.Cert.
+//
+// metrics endpoint (TCP socket)
+// -----------------------------
+//
+// If a metrics address was set via config.MetricsAddress, create a plain HTTP
+// network socket bound to the given address, serving config.MetricsServer.
 func Up(config *Config) (*Endpoints, error) {
 	if config.Dir == "" {
 		return nil, fmt.Errorf("no directory configured")
@@ -134,6 +150,7 @@ func (e *Endpoints) up(config *Config) error {
 		devlxd:  config.DevLxdServer,
 		local:   config.RestServer,
 		network: config.RestServer,
+		metrics: config.MetricsServer,
 	}
 	e.cert = config.Cert
 
@@ -171,6 +188,11 @@ func (e *Endpoints) up(config *Config) error {
 		e.listeners[network] = networkCreateListener(config.NetworkAddress, e.cert)
 	}
 
+	if config.MetricsAddress != "" && config.MetricsServer != nil {
+		// Errors here are not fatal and are just logged.
+		e.listeners[metrics] = metricsCreateListener(config.MetricsAddress)
+	}
+
 	logger.Infof("Starting /dev/lxd handler:")
 	e.serveHTTP(devlxd)
 
@@ -178,6 +200,9 @@ func (e *Endpoints) up(config *Config) error {
 	e.serveHTTP(local)
 	e.serveHTTP(network)
 
+	logger.Infof("Metrics:")
+	e.serveHTTP(metrics)
+
 	return nil
 }
 
@@ -196,6 +221,12 @@ func (e *Endpoints) Down() error {
 		return err
 	}
 
+	logger.Infof("Stopping metrics handler")
+	err = e.closeListener(metrics)
+	if err != nil {
+		return err
+	}
+
 	logger.Infof("Stopping /dev/lxd handler")
 	err = e.closeListener(devlxd)
 	if err != nil {
@@ -277,6 +308,7 @@ const (
 	local kind = iota
 	devlxd
 	network
+	metrics
 )
 
 // Human-readable descriptions of the various kinds of endpoints.
@@ -284,4 +316,5 @@ var descriptions = map[kind]string{
 	local:   "Unix socket",
 	devlxd:  "devlxd socket",
 	network: "TCP socket",
+	metrics: "metrics TCP socket",
 }
@@ -1,6 +1,8 @@
 package endpoints
 
 import (
+	"net"
+
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 )
@@ -24,6 +26,13 @@ func (e *Endpoints) DevLxdSocketPath() string {
 	return listener.Addr().String()
 }
 
+// Return the listener of the metrics endpoint.
+func (e *Endpoints) MetricsListener() net.Listener {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.listeners[metrics]
+}
+
 func (e *Endpoints) LocalSocketPath() string {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
@@ -0,0 +1,108 @@
+package endpoints
+
+import (
+	"fmt"
+	"net"
+
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// MetricsAddress returns the network address of the metrics endpoint, or an
+// empty string if there's no metrics endpoint.
+func (e *Endpoints) MetricsAddress() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	listener := e.listeners[metrics]
+	if listener == nil {
+		return ""
+	}
+	return listener.Addr().String()
+}
+
+// MetricsUpdateAddress updates the address for the metrics endpoint, shutting
+// it down and restarting it.
+func (e *Endpoints) MetricsUpdateAddress(address string) error {
+	e.mu.RLock()
+	listener := e.listeners[metrics]
+	e.mu.RUnlock()
+
+	if listener == nil && address == "" {
+		return nil
+	}
+
+	if listener != nil && address != "" && metricsAddressMatches(listener, address) {
+		return nil
+	}
+
+	oldAddress := e.MetricsAddress()
+
+	logger.Infof("Update metrics address")
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.servers[metrics] == nil {
+		return fmt.Errorf("no metrics server configured")
+	}
+
+	// Close the previous socket
+	e.closeListener(metrics)
+
+	// If turning off listening, we're done
+	if address == "" {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		// Attempt to revert to the previous address
+		if oldAddress != "" {
+			listener, err1 := net.Listen("tcp", oldAddress)
+			if err1 == nil {
+				e.listeners[metrics] = listener
+				e.serveHTTP(metrics)
+			}
+		}
+
+		return fmt.Errorf("cannot listen on metrics socket: %v", err)
+	}
+
+	e.listeners[metrics] = listener
+	e.serveHTTP(metrics)
+
+	return nil
+}
+
+// metricsAddressMatches checks whether the listener is bound to the given
+// address. Both are resolved first, as the address a listener reports can
+// be spelled differently from the configured one.
+func metricsAddressMatches(listener net.Listener, address string) bool {
+	addr, err := net.ResolveTCPAddr("tcp", address)
+	if err != nil {
+		return false
+	}
+
+	current, ok := listener.Addr().(*net.TCPAddr)
+	if !ok || addr.Port != current.Port {
+		return false
+	}
+
+	// Wildcard addresses are reported as [::] whichever was configured
+	if addr.IP == nil || addr.IP.IsUnspecified() {
+		return current.IP == nil || current.IP.IsUnspecified()
+	}
+
+	return addr.IP.Equal(current.IP)
+}
+
+// Create a new net.Listener bound to the tcp socket of the metrics endpoint.
+func metricsCreateListener(address string) net.Listener {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		logger.Error("cannot listen on metrics socket, skipping...", log.Ctx{"err": err})
+		return nil
+	}
+	return listener
+}
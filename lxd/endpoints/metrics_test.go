@@ -0,0 +1,64 @@
+package endpoints_test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// If a metrics address is set, a plain HTTP socket is created for the metrics
+// server.
+func TestEndpoints_MetricsCreateTCPSocket(t *testing.T) {
+	endpoints, config, cleanup := newEndpoints(t)
+	defer cleanup()
+
+	config.MetricsServer = newServer()
+	config.MetricsAddress = "127.0.0.1:0"
+	require.NoError(t, endpoints.Up(config))
+
+	_, err := http.Get(fmt.Sprintf("http://%s/", endpoints.MetricsAddress()))
+	assert.NoError(t, err)
+}
+
+// When the metrics address is updated, any previous metrics socket gets
+// closed.
+func TestEndpoints_MetricsUpdateAddress(t *testing.T) {
+	endpoints, config, cleanup := newEndpoints(t)
+	defer cleanup()
+
+	config.MetricsServer = newServer()
+	require.NoError(t, endpoints.Up(config))
+	assert.Equal(t, "", endpoints.MetricsAddress())
+
+	require.NoError(t, endpoints.MetricsUpdateAddress("127.0.0.1:0"))
+	address := endpoints.MetricsAddress()
+	assert.NotEqual(t, "", address)
+
+	require.NoError(t, endpoints.MetricsUpdateAddress(""))
+	assert.Equal(t, "", endpoints.MetricsAddress())
+
+	_, err := http.Get(fmt.Sprintf("http://%s/", address))
+	assert.Error(t, err)
+}
+
+// The metrics socket is left alone if the new address resolves to the one
+// it's already bound to.
+func TestEndpoints_MetricsUpdateSameAddress(t *testing.T) {
+	endpoints, config, cleanup := newEndpoints(t)
+	defer cleanup()
+
+	config.MetricsServer = newServer()
+	config.MetricsAddress = "0.0.0.0:0"
+	require.NoError(t, endpoints.Up(config))
+
+	listener := endpoints.MetricsListener()
+	_, port, err := net.SplitHostPort(endpoints.MetricsAddress())
+	require.NoError(t, err)
+
+	require.NoError(t, endpoints.MetricsUpdateAddress(fmt.Sprintf("0.0.0.0:%s", port)))
+	assert.True(t, listener == endpoints.MetricsListener())
+}
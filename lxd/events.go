@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -15,6 +16,7 @@ import (
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
 )
 
 type eventsHandler struct {
@@ -47,6 +49,22 @@ func (h eventsHandler) Log(r *log.Record) error {
 var eventsLock sync.Mutex
 var eventListeners map[string]*eventListener = make(map[string]*eventListener)
 
+// The last events are kept around so that clients can catch up on what they
+// missed while disconnected. Each event gets a monotonically increasing ID,
+// which only makes sense within a given run of the daemon, hence the boot ID.
+const eventsHistorySize = 1024
+
+var eventsHistory []*eventEntry
+var eventsNextID int64 = 1
+var eventsBootID = uuid.NewRandom().String()
+
+type eventEntry struct {
+	id          int64
+	messageType string
+	metadata    interface{}
+	body        []byte
+}
+
 type eventListener struct {
 	connection   *websocket.Conn
 	messageTypes []string
@@ -54,6 +72,61 @@ type eventListener struct {
 	id           string
 	lock         sync.Mutex
 	done         bool
+
+	// Filters
+	container        string
+	operationClasses []string
+	logLevel         log.Lvl
+}
+
+// matches checks whether the given event should be sent to the listener.
+func (l *eventListener) matches(entry *eventEntry) bool {
+	if !shared.StringInSlice(entry.messageType, l.messageTypes) {
+		return false
+	}
+
+	switch metadata := entry.metadata.(type) {
+	case api.EventLogging:
+		level, err := log.LvlFromString(metadata.Level)
+		if err == nil && level > l.logLevel {
+			return false
+		}
+
+		if l.container != "" && metadata.Context["container"] != l.container && metadata.Context["name"] != l.container {
+			return false
+		}
+	case api.EventLifecycle:
+		if l.container != "" && !eventContainerURLMatches(metadata.Source, l.container) {
+			return false
+		}
+	case *api.Operation:
+		if len(l.operationClasses) > 0 && !shared.StringInSlice(metadata.Class, l.operationClasses) {
+			return false
+		}
+
+		if l.container != "" {
+			found := false
+			for _, url := range metadata.Resources["containers"] {
+				if eventContainerURLMatches(url, l.container) {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// eventContainerURLMatches checks whether the URL points to the given
+// container or to one of its sub-resources (snapshots, ...).
+func eventContainerURLMatches(url string, name string) bool {
+	prefix := fmt.Sprintf("/%s/containers/%s", version.APIVersion, name)
+	return url == prefix || strings.HasPrefix(url, prefix+"/")
 }
 
 type eventsServe struct {
@@ -71,25 +144,87 @@ func (r *eventsServe) String() string {
 func eventsSocket(r *http.Request, w http.ResponseWriter) error {
 	typeStr := r.FormValue("type")
 	if typeStr == "" {
-		typeStr = "logging,operation"
-	}
-
-	c, err := shared.WebsocketUpgrader.Upgrade(w, r, nil)
-	if err != nil {
-		return err
+		typeStr = "logging,operation,lifecycle"
 	}
 
 	listener := eventListener{
 		active:       make(chan bool, 1),
-		connection:   c,
 		id:           uuid.NewRandom().String(),
 		messageTypes: strings.Split(typeStr, ","),
+		container:    r.FormValue("container"),
+		logLevel:     log.LvlDebug,
+	}
+
+	classStr := r.FormValue("class")
+	if classStr != "" {
+		listener.operationClasses = strings.Split(classStr, ",")
+	}
+
+	levelStr := r.FormValue("level")
+	if levelStr != "" {
+		level, err := log.LvlFromString(levelStr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+
+		listener.logLevel = level
+	}
+
+	since := int64(-1)
+	sinceStr := r.FormValue("since")
+	if sinceStr != "" {
+		id, err := strconv.ParseInt(sinceStr, 10, 64)
+		if err != nil || id < 0 {
+			http.Error(w, fmt.Sprintf("Invalid event ID: %s", sinceStr), http.StatusBadRequest)
+			return nil
+		}
+
+		since = id
+	}
+
+	// IDs from a previous run of the daemon don't mean anything anymore,
+	// replay everything since this one started instead
+	bootID := r.FormValue("boot_id")
+	if since >= 0 && bootID != "" && bootID != eventsBootID {
+		since = 0
 	}
 
+	if since >= 0 && !eventsAvailableSince(since) {
+		http.Error(w, fmt.Sprintf("Events since %d are no longer available", since), http.StatusGone)
+		return nil
+	}
+
+	c, err := shared.WebsocketUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return err
+	}
+	listener.connection = c
+
+	// Hold the listener lock until any missed events have been replayed,
+	// so that new events only go out after them.
+	listener.lock.Lock()
+
 	eventsLock.Lock()
 	eventListeners[listener.id] = &listener
+	missed := []*eventEntry{}
+	if since >= 0 {
+		for _, entry := range eventsHistory {
+			if entry.id > since && listener.matches(entry) {
+				missed = append(missed, entry)
+			}
+		}
+	}
 	eventsLock.Unlock()
 
+	for _, entry := range missed {
+		err := listener.connection.WriteMessage(websocket.TextMessage, entry.body)
+		if err != nil {
+			break
+		}
+	}
+	listener.lock.Unlock()
+
 	logger.Debugf("New event listener: %s", listener.id)
 
 	<-listener.active
@@ -97,6 +232,19 @@ func eventsSocket(r *http.Request, w http.ResponseWriter) error {
 	return nil
 }
 
+// eventsAvailableSince checks that none of the events following the given ID
+// were dropped from the history yet.
+func eventsAvailableSince(since int64) bool {
+	eventsLock.Lock()
+	defer eventsLock.Unlock()
+
+	if len(eventsHistory) == 0 {
+		return true
+	}
+
+	return eventsHistory[0].id <= since+1
+}
+
 func eventsGet(d *Daemon, r *http.Request) Response {
 	return &eventsServe{r}
 }
@@ -104,7 +252,12 @@ func eventsGet(d *Daemon, r *http.Request) Response {
 var eventsCmd = Command{name: "events", get: eventsGet}
 
 func eventSend(eventType string, eventMessage interface{}) error {
+	eventsLock.Lock()
+	defer eventsLock.Unlock()
+
 	event := shared.Jmap{}
+	event["id"] = eventsNextID
+	event["boot_id"] = eventsBootID
 	event["type"] = eventType
 	event["timestamp"] = time.Now()
 	event["metadata"] = eventMessage
@@ -114,10 +267,24 @@ func eventSend(eventType string, eventMessage interface{}) error {
 		return err
 	}
 
-	eventsLock.Lock()
-	listeners := eventListeners
-	for _, listener := range listeners {
-		if !shared.StringInSlice(eventType, listener.messageTypes) {
+	entry := &eventEntry{
+		id:          eventsNextID,
+		messageType: eventType,
+		metadata:    eventMessage,
+		body:        body,
+	}
+	eventsNextID++
+
+	// Record the event, dropping the oldest one if the history is full
+	if len(eventsHistory) >= eventsHistorySize {
+		copy(eventsHistory, eventsHistory[1:])
+		eventsHistory[len(eventsHistory)-1] = entry
+	} else {
+		eventsHistory = append(eventsHistory, entry)
+	}
+
+	for _, listener := range eventListeners {
+		if !listener.matches(entry) {
 			continue
 		}
 
@@ -136,7 +303,7 @@ func eventSend(eventType string, eventMessage interface{}) error {
 				return
 			}
 
-			err = listener.connection.WriteMessage(websocket.TextMessage, body)
+			err := listener.connection.WriteMessage(websocket.TextMessage, body)
 			if err != nil {
 				// Remove the listener from the list
 				eventsLock.Lock()
@@ -151,7 +318,38 @@ func eventSend(eventType string, eventMessage interface{}) error {
 			}
 		}(listener, body)
 	}
-	eventsLock.Unlock()
+
+	webhooksSend(entry)
 
 	return nil
 }
+
+// eventSendLifecycle sends a lifecycle event for the given action on the
+// resource at the given URL. The requestor is nil for actions which weren't
+// triggered through the API.
+func eventSendLifecycle(action string, source string, context map[string]interface{}, requestor *api.EventLifecycleRequestor) error {
+	return eventSend("lifecycle", api.EventLifecycle{
+		Action:    action,
+		Source:    source,
+		Context:   context,
+		Requestor: requestor,
+	})
+}
+
+// eventRequestor returns the identity of the client behind the given request.
+func eventRequestor(r *http.Request) *api.EventLifecycleRequestor {
+	if r.RemoteAddr == "@" {
+		return &api.EventLifecycleRequestor{Protocol: "unix", Address: r.RemoteAddr}
+	}
+
+	if r.TLS == nil {
+		return &api.EventLifecycleRequestor{Protocol: "http", Address: r.RemoteAddr}
+	}
+
+	requestor := &api.EventLifecycleRequestor{Protocol: "tls", Address: r.RemoteAddr}
+	if len(r.TLS.PeerCertificates) > 0 {
+		requestor.Username = shared.CertFingerprint(r.TLS.PeerCertificates[0])
+	}
+
+	return requestor
+}
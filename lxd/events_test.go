@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Event listeners only get the events matching their filters.
+func TestEventListener_Filters(t *testing.T) {
+	listener := &eventListener{
+		messageTypes:     []string{"logging", "operation", "lifecycle"},
+		container:        "c1",
+		operationClasses: []string{"task"},
+		logLevel:         log.LvlWarn,
+	}
+
+	cases := []struct {
+		entry   *eventEntry
+		matches bool
+	}{
+		{&eventEntry{messageType: "logging", metadata: api.EventLogging{Level: "eror", Context: map[string]string{"container": "c1"}}}, true},
+		{&eventEntry{messageType: "logging", metadata: api.EventLogging{Level: "info", Context: map[string]string{"container": "c1"}}}, false},
+		{&eventEntry{messageType: "logging", metadata: api.EventLogging{Level: "crit", Context: map[string]string{"container": "c2"}}}, false},
+		{&eventEntry{messageType: "lifecycle", metadata: api.EventLifecycle{Source: "/1.0/containers/c1/snapshots/snap0"}}, true},
+		{&eventEntry{messageType: "lifecycle", metadata: api.EventLifecycle{Source: "/1.0/containers/c10"}}, false},
+		{&eventEntry{messageType: "operation", metadata: &api.Operation{Class: "task", Resources: map[string][]string{"containers": {"/1.0/containers/c1"}}}}, true},
+		{&eventEntry{messageType: "operation", metadata: &api.Operation{Class: "websocket", Resources: map[string][]string{"containers": {"/1.0/containers/c1"}}}}, false},
+		{&eventEntry{messageType: "operation", metadata: &api.Operation{Class: "task"}}, false},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.matches, listener.matches(c.entry), "%+v", c.entry.metadata)
+	}
+
+	listener.messageTypes = []string{"operation"}
+	assert.False(t, listener.matches(cases[0].entry))
+}
+
+// Resuming is refused once events following the given ID were dropped.
+func TestEventsAvailableSince(t *testing.T) {
+	eventsHistory = []*eventEntry{{id: 5}, {id: 6}}
+	defer func() { eventsHistory = nil }()
+
+	assert.True(t, eventsAvailableSince(4))
+	assert.True(t, eventsAvailableSince(6))
+	assert.False(t, eventsAvailableSince(3))
+}
+
+// The requestor's protocol reflects how the request reached the daemon.
+func TestEventRequestor(t *testing.T) {
+	r := &http.Request{RemoteAddr: "@"}
+	assert.Equal(t, "unix", eventRequestor(r).Protocol)
+
+	r = &http.Request{RemoteAddr: "10.0.0.1:1234"}
+	assert.Equal(t, "http", eventRequestor(r).Protocol)
+
+	r.TLS = &tls.ConnectionState{}
+	assert.Equal(t, "tls", eventRequestor(r).Protocol)
+}
@@ -628,6 +628,8 @@ func imagesPost(d *Daemon, r *http.Request) Response {
 		metadata["fingerprint"] = info.Fingerprint
 		metadata["size"] = strconv.FormatInt(info.Size, 10)
 		op.UpdateMetadata(metadata)
+
+		eventSendLifecycle("image-created", fmt.Sprintf("/%s/images/%s", version.APIVersion, info.Fingerprint), nil, eventRequestor(r))
 		return nil
 	}
 
@@ -729,11 +731,33 @@ func autoUpdateImagesTask(d *Daemon) (task.Func, task.Schedule) {
 	f := func(ctx context.Context) {
 		autoUpdateImages(ctx, d)
 	}
-	schedule := func() (time.Duration, error) {
-		interval := daemonConfig["images.auto_update_interval"].GetInt64()
-		return time.Duration(interval) * time.Hour, nil
+
+	// The schedule gets re-created whenever images.auto_update_interval
+	// changes, since a cron schedule keeps track of its next firing time.
+	var current string
+	var schedule task.Schedule
+
+	return f, func() (time.Duration, error) {
+		value := daemonConfig["images.auto_update_interval"].Get()
+		if schedule == nil || value != current {
+			current = value
+			schedule = autoUpdateImagesSchedule(value)
+		}
+
+		return schedule()
 	}
-	return f, schedule
+}
+
+// autoUpdateImagesSchedule returns the schedule of the image refreshes, given
+// either as a number of hours or as a cron expression. A random delay is added
+// so that many hosts don't all hit the image servers at the same time.
+func autoUpdateImagesSchedule(value string) task.Schedule {
+	interval, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		return task.Jitter(task.Every(time.Duration(interval)*time.Hour), 5*time.Minute)
+	}
+
+	return task.Jitter(task.Cron(value), 5*time.Minute)
 }
 
 func autoUpdateImages(ctx context.Context, d *Daemon) {
@@ -954,7 +978,13 @@ func imageDelete(d *Daemon, r *http.Request) Response {
 	fingerprint := mux.Vars(r)["fingerprint"]
 
 	rmimg := func(op *operation) error {
-		return doDeleteImage(d, fingerprint)
+		err := doDeleteImage(d, fingerprint)
+		if err != nil {
+			return err
+		}
+
+		eventSendLifecycle("image-deleted", fmt.Sprintf("/%s/images/%s", version.APIVersion, fingerprint), nil, eventRequestor(r))
+		return nil
 	}
 
 	resources := map[string][]string{}
@@ -1042,6 +1072,8 @@ func imagePut(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	eventSendLifecycle("image-updated", fmt.Sprintf("/%s/images/%s", version.APIVersion, info.Fingerprint), nil, eventRequestor(r))
+
 	return EmptySyncResponse
 }
 
@@ -73,7 +73,7 @@ func run() error {
 	// Process sub-commands
 	if args.Subcommand != "" {
 		// "forkputfile", "forkgetfile", "forkmount" and "forkumount" are handled specially in main_nsexec.go
-		// "forkgetnet" is partially handled in main_nsexec.go (setns)
+		// "forkgetnet" and "forkproxy" are partially handled in main_nsexec.go (setns)
 		switch args.Subcommand {
 		// Main commands
 		case "activateifneeded":
@@ -91,10 +91,14 @@ func run() error {
 		case "waitready":
 			return cmdWaitReady(args)
 		// Internal commands
+		case "forkconsole":
+			return cmdForkConsole(args)
 		case "forkgetnet":
 			return cmdForkGetNet()
 		case "forkmigrate":
 			return cmdForkMigrate(args)
+		case "forkproxy":
+			return cmdForkProxy(args)
 		case "forkstart":
 			return cmdForkStart(args)
 		case "forkexec":
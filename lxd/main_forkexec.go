@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -16,7 +17,7 @@ import (
  * This is called by lxd when called as "lxd forkexec <container>"
  */
 func cmdForkExec(args *Args) (int, error) {
-	if len(args.Params) < 3 {
+	if len(args.Params) < 6 {
 		return -1, fmt.Errorf("Bad params: %q", args.Params)
 	}
 	if len(args.Extra) < 1 {
@@ -26,6 +27,17 @@ func cmdForkExec(args *Args) (int, error) {
 	name := args.Params[0]
 	lxcpath := args.Params[1]
 	configPath := args.Params[2]
+	cwd := args.Params[3]
+
+	uid, err := strconv.ParseUint(args.Params[4], 10, 32)
+	if err != nil {
+		return -1, fmt.Errorf("Bad uid: %q", args.Params[4])
+	}
+
+	gid, err := strconv.ParseUint(args.Params[5], 10, 32)
+	if err != nil {
+		return -1, fmt.Errorf("Bad gid: %q", args.Params[5])
+	}
 
 	c, err := lxc.NewContainer(name, lxcpath)
 	if err != nil {
@@ -92,6 +104,13 @@ func cmdForkExec(args *Args) (int, error) {
 	}
 
 	opts.Env = env
+	opts.UID = int(uid)
+	opts.GID = int(gid)
+
+	// An explicit working directory takes precedence over $HOME
+	if cwd != "" {
+		opts.Cwd = cwd
+	}
 
 	status, err := c.RunCommandNoWait(cmd, opts)
 	if err != nil {
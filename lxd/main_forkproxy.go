@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// How long a UDP client may stay silent before its relay is torn down
+const proxyUDPTimeout = 60 * time.Second
+
+/*
+ * This is called by lxd when called as "lxd forkproxy <pid> <connect> <listen>"
+ *
+ * The listening socket is inherited as fd 3 and the process has already
+ * been moved to the container's network namespace by main_nsexec.go.
+ */
+func cmdForkProxy(args *Args) error {
+	if len(args.Params) != 3 {
+		return fmt.Errorf("Bad arguments: %q", args.Params)
+	}
+
+	connect, err := proxyParseAddr(args.Params[1])
+	if err != nil {
+		return err
+	}
+
+	listen, err := proxyParseAddr(args.Params[2])
+	if err != nil {
+		return err
+	}
+
+	file := os.NewFile(3, "listener")
+	defer file.Close()
+
+	if listen.protocol == "udp" {
+		conn, err := net.FilePacketConn(file)
+		if err != nil {
+			return fmt.Errorf("Failed to use the listening socket: %v", err)
+		}
+
+		return proxyRelayPackets(conn, connect)
+	}
+
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return fmt.Errorf("Failed to use the listening socket: %v", err)
+	}
+
+	for {
+		src, err := listener.Accept()
+		if err != nil {
+			return fmt.Errorf("Failed to accept connection: %v", err)
+		}
+
+		go proxyRelayStream(src, connect)
+	}
+}
+
+// proxyRelayStream copies a stream connection back and forth with a new
+// connection to the target.
+func proxyRelayStream(src net.Conn, connect *proxyAddress) {
+	defer src.Close()
+
+	dst, err := net.Dial(connect.protocol, connect.address)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to %s: %v\n", connect, err)
+		return
+	}
+	defer dst.Close()
+
+	done := make(chan bool, 2)
+	relay := func(to net.Conn, from net.Conn) {
+		io.Copy(to, from)
+
+		// Let the other side know we're done writing
+		switch conn := to.(type) {
+		case *net.TCPConn:
+			conn.CloseWrite()
+		case *net.UnixConn:
+			conn.CloseWrite()
+		}
+
+		done <- true
+	}
+
+	go relay(dst, src)
+	go relay(src, dst)
+
+	<-done
+	<-done
+}
+
+// proxyUDPClient is the connection relaying the datagrams of one client.
+type proxyUDPClient struct {
+	conn     net.Conn
+	lastSeen time.Time
+}
+
+// proxyRelayPackets relays datagrams to the target, using one connection per
+// client so that the replies can be sent back to it.
+func proxyRelayPackets(conn net.PacketConn, connect *proxyAddress) error {
+	clients := map[string]*proxyUDPClient{}
+	clientsLock := sync.Mutex{}
+
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return fmt.Errorf("Failed to read datagram: %v", err)
+		}
+
+		clientsLock.Lock()
+		client, ok := clients[addr.String()]
+		if !ok {
+			dst, err := net.Dial(connect.protocol, connect.address)
+			if err != nil {
+				clientsLock.Unlock()
+				fmt.Fprintf(os.Stderr, "Failed to connect to %s: %v\n", connect, err)
+				continue
+			}
+
+			client = &proxyUDPClient{conn: dst}
+			clients[addr.String()] = client
+
+			// Send the replies back until the client goes quiet
+			go func(client *proxyUDPClient, addr net.Addr) {
+				reply := make([]byte, 65536)
+				for {
+					client.conn.SetReadDeadline(time.Now().Add(proxyUDPTimeout))
+					n, err := client.conn.Read(reply)
+					if err != nil {
+						// Keep the relay up while the client is still
+						// sending, even if the target doesn't reply
+						netErr, ok := err.(net.Error)
+
+						clientsLock.Lock()
+						active := ok && netErr.Timeout() && time.Since(client.lastSeen) < proxyUDPTimeout
+						if !active {
+							delete(clients, addr.String())
+						}
+						clientsLock.Unlock()
+
+						if active {
+							continue
+						}
+
+						break
+					}
+
+					_, err = conn.WriteTo(reply[:n], addr)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Failed to send reply to %s: %v\n", addr, err)
+					}
+				}
+
+				client.conn.Close()
+			}(client, addr)
+		}
+		client.lastSeen = time.Now()
+		clientsLock.Unlock()
+
+		_, err = client.conn.Write(buf[:n])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to relay datagram from %s: %v\n", addr, err)
+		}
+	}
+}
@@ -30,6 +30,7 @@ package main
 #include <sys/mman.h>
 #include <sys/stat.h>
 #include <sys/types.h>
+#include <dirent.h>
 #include <fcntl.h>
 #include <stdbool.h>
 #include <unistd.h>
@@ -40,10 +41,11 @@ package main
 #include <grp.h>
 
 // This expects:
-//  ./lxd forkputfile /source/path <pid> /target/path
+//  ./lxd forkputfile <rootfs> <pid> /source/path /target/path <type> <write> <uid> <gid> <mode> <default uid> <default gid> <default mode>
 // or
-//  ./lxd forkgetfile /target/path <pid> /soruce/path <uid> <gid> <mode>
-// i.e. 8 arguments, each which have a max length of PATH_MAX.
+//  ./lxd forkgetfile <rootfs> <pid> /target/path /source/path
+// i.e. at most 13 arguments, only four of which are paths with a max length
+// of PATH_MAX.
 // Unfortunately, lseek() and fstat() both fail (EINVAL and 0 size) for
 // procfs. Also, we can't mmap, because procfs doesn't support that, either.
 //
@@ -86,12 +88,12 @@ int mkdir_p(const char *dir, mode_t mode)
 	return 0;
 }
 
-int copy(int target, int source)
+int copy(int target, int source, bool append)
 {
 	ssize_t n;
 	char buf[1024];
 
-	if (ftruncate(target, 0) < 0) {
+	if (!append && ftruncate(target, 0) < 0) {
 		error("error: truncate");
 		return -1;
 	}
@@ -174,12 +176,18 @@ void attach_userns(int pid) {
 	}
 }
 
-int manip_file_in_ns(char *rootfs, int pid, char *host, char *container, bool is_put, uid_t uid, gid_t gid, mode_t mode, uid_t defaultUid, gid_t defaultGid, mode_t defaultMode) {
+int manip_file_in_ns(char *rootfs, int pid, char *host, char *container, bool is_put, bool follow, char *type, char *write_mode, uid_t uid, gid_t gid, mode_t mode, uid_t defaultUid, gid_t defaultGid, mode_t defaultMode) {
 	int host_fd, container_fd;
 	int ret = -1;
-	int container_open_flags;
 	struct stat st;
 	int exists = 1;
+	bool is_dir_manip = type != NULL && !strcmp(type, "directory");
+	bool is_symlink_manip = type != NULL && !strcmp(type, "symlink");
+	bool is_append = write_mode != NULL && !strcmp(write_mode, "append");
+	bool is_atomic = write_mode != NULL && !strcmp(write_mode, "atomic");
+	char *container_tmp = NULL;
+	char link_target[PATH_MAX];
+	ssize_t link_length;
 
 	host_fd = open(host, O_RDWR);
 	if (host_fd < 0) {
@@ -187,10 +195,6 @@ int manip_file_in_ns(char *rootfs, int pid, char *host, char *container, bool is
 		return -1;
 	}
 
-	container_open_flags = O_RDWR;
-	if (is_put)
-		container_open_flags |= O_CREAT;
-
 	if (pid > 0) {
 		attach_userns(pid);
 
@@ -210,67 +214,232 @@ int manip_file_in_ns(char *rootfs, int pid, char *host, char *container, bool is
 		}
 	}
 
-	if (is_put && stat(container, &st) < 0)
-		exists = 0;
+	umask(0);
 
-	if (is_put && exists && S_ISDIR(st.st_mode)) {
-		error("error: Path already exists as a directory");
-		goto close_host;
-	}
+	if (!is_put) {
+		// Symlinks are only reported as such when asked to
+		if ((follow ? stat(container, &st) : lstat(container, &st)) < 0) {
+			error("error: stat");
+			goto close_host;
+		}
 
-	umask(0);
-	container_fd = open(container, container_open_flags, 0);
-	if (container_fd < 0) {
-		error("error: open");
-		goto close_host;
-	}
+		fprintf(stderr, "uid: %ld\n", (long)st.st_uid);
+		fprintf(stderr, "gid: %ld\n", (long)st.st_gid);
+		fprintf(stderr, "mode: %ld\n", (unsigned long)st.st_mode & (S_IRWXU | S_IRWXG | S_IRWXO));
 
-	if (is_put) {
-		if (!exists) {
-			if (mode == -1) {
-				mode = defaultMode;
+		if (S_ISLNK(st.st_mode)) {
+			// The content of a symlink is its target
+			link_length = readlink(container, link_target, PATH_MAX);
+			if (link_length < 0) {
+				error("error: readlink");
+				goto close_host;
+			}
+
+			if (ftruncate(host_fd, 0) < 0 || write(host_fd, link_target, link_length) != link_length) {
+				error("error: write");
+				goto close_host;
 			}
 
-			if (uid == -1) {
-				uid = defaultUid;
+			fprintf(stderr, "type: symlink\n");
+			ret = 0;
+			goto close_host;
+		}
+
+		if (S_ISDIR(st.st_mode)) {
+			DIR *fdir;
+			struct dirent *de;
+			char *c;
+
+			fdir = opendir(container);
+			if (!fdir) {
+				error("error: opendir");
+				goto close_host;
 			}
 
-			if (gid == -1) {
-				gid = defaultGid;
+			fprintf(stderr, "type: directory\n");
+			while ((de = readdir(fdir))) {
+				if (!strcmp(de->d_name, ".") || !strcmp(de->d_name, ".."))
+					continue;
+
+				// The output is split by line, so swap any \n for \0
+				fprintf(stderr, "entry: ");
+				for (c = de->d_name; *c; c++)
+					putc(*c == '\n' ? 0 : *c, stderr);
+				fprintf(stderr, "\n");
 			}
+
+			closedir(fdir);
+			ret = 0;
+			goto close_host;
 		}
 
-		if (copy(container_fd, host_fd) < 0) {
-			error("error: copy");
-			goto close_container;
+		container_fd = open(container, O_RDONLY);
+		if (container_fd < 0) {
+			error("error: open");
+			goto close_host;
 		}
 
-		if (mode != -1 && fchmod(container_fd, mode) < 0) {
+		fprintf(stderr, "type: file\n");
+		ret = copy(host_fd, container_fd, false);
+		goto close_container;
+	}
+
+	if (lstat(container, &st) < 0)
+		exists = 0;
+
+	if (!exists) {
+		if (mode == -1) {
+			mode = defaultMode;
+		}
+
+		if (uid == -1) {
+			uid = defaultUid;
+		}
+
+		if (gid == -1) {
+			gid = defaultGid;
+		}
+	}
+
+	if (is_dir_manip) {
+		if (exists && !S_ISDIR(st.st_mode)) {
+			errno = EEXIST;
+			error("error: Path already exists and isn't a directory");
+			goto close_host;
+		}
+
+		if (!exists && mkdir(container, mode) < 0) {
+			error("error: mkdir");
+			goto close_host;
+		}
+
+		if (mode != -1 && chmod(container, mode) < 0) {
 			error("error: chmod");
-			goto close_container;
+			goto close_host;
 		}
 
-		if (fchown(container_fd, uid, gid) < 0) {
+		if (chown(container, uid, gid) < 0) {
 			error("error: chown");
-			goto close_container;
+			goto close_host;
+		}
+
+		ret = 0;
+		goto close_host;
+	}
+
+	if (is_symlink_manip) {
+		// The content of the uploaded file is the target of the symlink
+		link_length = read(host_fd, link_target, PATH_MAX - 1);
+		if (link_length < 0) {
+			error("error: read");
+			goto close_host;
+		}
+		link_target[link_length] = '\0';
+
+		if (exists) {
+			if (!S_ISLNK(st.st_mode)) {
+				errno = EEXIST;
+				error("error: Path already exists and isn't a symlink");
+				goto close_host;
+			}
+
+			if (unlink(container) < 0) {
+				error("error: unlink");
+				goto close_host;
+			}
+		}
+
+		if (symlink(link_target, container) < 0) {
+			error("error: symlink");
+			goto close_host;
+		}
+
+		if (lchown(container, uid, gid) < 0) {
+			error("error: chown");
+			goto close_host;
 		}
 
 		ret = 0;
+		goto close_host;
+	}
+
+	if (exists && S_ISDIR(st.st_mode)) {
+		errno = EISDIR;
+		error("error: Path already exists as a directory");
+		goto close_host;
+	}
+
+	if (is_atomic) {
+		// Write to a temporary file next to the target and rename it
+		// over the target once complete, so that readers never see a
+		// partially written file.
+		char *container_copy = strdupa(container);
+		char *container_dir = dirname(container_copy);
+
+		container_tmp = alloca(strlen(container_dir) + sizeof("/.lxd_put_XXXXXX"));
+		sprintf(container_tmp, "%s/.lxd_put_XXXXXX", container_dir);
+
+		container_fd = mkstemp(container_tmp);
+		if (container_fd < 0) {
+			container_tmp = NULL;
+			error("error: mkstemp");
+			goto close_host;
+		}
+
+		// Keep the ownership and permissions of the file being replaced
+		if (exists) {
+			if (mode == -1)
+				mode = st.st_mode & (S_IRWXU | S_IRWXG | S_IRWXO);
+
+			if (uid == -1)
+				uid = st.st_uid;
+
+			if (gid == -1)
+				gid = st.st_gid;
+		}
 	} else {
-		ret = copy(host_fd, container_fd);
+		container_fd = open(container, O_RDWR | O_CREAT | (is_append ? O_APPEND : 0), 0);
+		if (container_fd < 0) {
+			error("error: open");
+			goto close_host;
+		}
+	}
 
-		if (fstat(container_fd, &st) < 0) {
-			error("error: stat");
+	if (copy(container_fd, host_fd, is_append) < 0) {
+		error("error: copy");
+		goto close_container;
+	}
+
+	if (mode != -1 && fchmod(container_fd, mode) < 0) {
+		error("error: chmod");
+		goto close_container;
+	}
+
+	if (fchown(container_fd, uid, gid) < 0) {
+		error("error: chown");
+		goto close_container;
+	}
+
+	if (container_tmp) {
+		if (fsync(container_fd) < 0) {
+			error("error: fsync");
 			goto close_container;
 		}
 
-		fprintf(stderr, "uid: %ld\n", (long)st.st_uid);
-		fprintf(stderr, "gid: %ld\n", (long)st.st_gid);
-		fprintf(stderr, "mode: %ld\n", (unsigned long)st.st_mode & (S_IRWXU | S_IRWXG | S_IRWXO));
+		if (rename(container_tmp, container) < 0) {
+			error("error: rename");
+			goto close_container;
+		}
+
+		container_tmp = NULL;
 	}
 
+	ret = 0;
+
 close_container:
 	close(container_fd);
+	if (container_tmp)
+		unlink(container_tmp);
 close_host:
 	close(host_fd);
 	return ret;
@@ -428,7 +597,8 @@ void forkdofile(char *buf, char *cur, bool is_put, ssize_t size) {
 	uid_t defaultUid = 0;
 	gid_t defaultGid = 0;
 	mode_t defaultMode = 0;
-	char *command = cur, *rootfs = NULL, *source = NULL, *target = NULL;
+	char *command = cur, *rootfs = NULL, *source = NULL, *target = NULL, *type = NULL, *write_mode = NULL;
+	bool follow = true;
 	pid_t pid;
 
 	ADVANCE_ARG_REQUIRED();
@@ -444,6 +614,12 @@ void forkdofile(char *buf, char *cur, bool is_put, ssize_t size) {
 	target = cur;
 
 	if (is_put) {
+		ADVANCE_ARG_REQUIRED();
+		type = cur;
+
+		ADVANCE_ARG_REQUIRED();
+		write_mode = cur;
+
 		ADVANCE_ARG_REQUIRED();
 		uid = atoi(cur);
 
@@ -461,9 +637,12 @@ void forkdofile(char *buf, char *cur, bool is_put, ssize_t size) {
 
 		ADVANCE_ARG_REQUIRED();
 		defaultMode = atoi(cur);
+	} else {
+		ADVANCE_ARG_REQUIRED();
+		follow = strcmp(cur, "nofollow") != 0;
 	}
 
-	_exit(manip_file_in_ns(rootfs, pid, source, target, is_put, uid, gid, mode, defaultUid, defaultGid, defaultMode));
+	_exit(manip_file_in_ns(rootfs, pid, source, target, is_put, follow, type, write_mode, uid, gid, mode, defaultUid, defaultGid, defaultMode));
 }
 
 void forkcheckfile(char *buf, char *cur, bool is_put, ssize_t size) {
@@ -539,7 +718,7 @@ void forkremovefile(char *buf, char *cur, bool is_put, ssize_t size) {
 		}
 	}
 
-	if (stat(path, &sb) < 0) {
+	if (lstat(path, &sb) < 0) {
 		error("error: stat");
 		_exit(1);
 	}
@@ -571,6 +750,25 @@ void forkgetnet(char *buf, char *cur, ssize_t size) {
 	// The rest happens in Go
 }
 
+void forkproxy(char *buf, char *cur, ssize_t size) {
+	ADVANCE_ARG_REQUIRED();
+	int pid = atoi(cur);
+
+	if (dosetns(pid, "net") < 0) {
+		fprintf(stderr, "Failed setns to container network namespace: %s\n", strerror(errno));
+		_exit(1);
+	}
+
+	// Unix sockets are looked up in the container's filesystem
+	ADVANCE_ARG_REQUIRED();
+	if (strncmp(cur, "unix:", 5) == 0 && dosetns(pid, "mnt") < 0) {
+		fprintf(stderr, "Failed setns to container mount namespace: %s\n", strerror(errno));
+		_exit(1);
+	}
+
+	// The rest happens in Go
+}
+
 __attribute__((constructor)) void init(void) {
 	int cmdline;
 	char buf[CMDLINE_SIZE];
@@ -613,6 +811,8 @@ __attribute__((constructor)) void init(void) {
 		forkumount(buf, cur, size);
 	} else if (strcmp(cur, "forkgetnet") == 0) {
 		forkgetnet(buf, cur, size);
+	} else if (strcmp(cur, "forkproxy") == 0) {
+		forkproxy(buf, cur, size);
 	}
 }
 */
@@ -30,6 +30,9 @@ func cmdShutdown(args *Args) error {
 			return
 		}
 
+		// The connection going away is how we know LXD stopped
+		monitor.DisableReconnect()
+
 		monitor.Wait()
 		close(chMonitor)
 	}()
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The metrics are rendered in the OpenMetrics text format, which is what
+// Prometheus scrapes.
+const metricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+type metricsSample struct {
+	suffix string
+	labels map[string]string
+	value  float64
+}
+
+type metricsFamily struct {
+	name    string
+	kind    string
+	help    string
+	samples []metricsSample
+}
+
+// metricsSet holds metric families in the order they were first added.
+type metricsSet struct {
+	families []*metricsFamily
+	index    map[string]*metricsFamily
+}
+
+func (s *metricsSet) add(name string, kind string, help string, suffix string, labels map[string]string, value float64) {
+	if s.index == nil {
+		s.index = map[string]*metricsFamily{}
+	}
+
+	family, ok := s.index[name]
+	if !ok {
+		family = &metricsFamily{name: name, kind: kind, help: help}
+		s.families = append(s.families, family)
+		s.index[name] = family
+	}
+
+	family.samples = append(family.samples, metricsSample{suffix: suffix, labels: labels, value: value})
+}
+
+func (s *metricsSet) gauge(name string, help string, labels map[string]string, value float64) {
+	s.add(name, "gauge", help, "", labels, value)
+}
+
+func (s *metricsSet) counter(name string, help string, labels map[string]string, value float64) {
+	s.add(name, "counter", help, "_total", labels, value)
+}
+
+func (s *metricsSet) summary(name string, help string, labels map[string]string, sum float64, count float64) {
+	s.add(name, "summary", help, "_sum", labels, sum)
+	s.add(name, "summary", help, "_count", labels, count)
+}
+
+// merge adds all the samples of the other set.
+func (s *metricsSet) merge(other *metricsSet) {
+	for _, family := range other.families {
+		for _, sample := range family.samples {
+			s.add(family.name, family.kind, family.help, sample.suffix, sample.labels, sample.value)
+		}
+	}
+}
+
+func (s *metricsSet) String() string {
+	buf := bytes.Buffer{}
+
+	for _, family := range s.families {
+		fmt.Fprintf(&buf, "# HELP %s %s\n", family.name, family.help)
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", family.name, family.kind)
+
+		for _, sample := range family.samples {
+			buf.WriteString(family.name + sample.suffix)
+
+			if len(sample.labels) > 0 {
+				keys := []string{}
+				for key := range sample.labels {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+
+				labels := []string{}
+				for _, key := range keys {
+					labels = append(labels, fmt.Sprintf("%s=\"%s\"", key, metricsEscapeLabel(sample.labels[key])))
+				}
+
+				fmt.Fprintf(&buf, "{%s}", strings.Join(labels, ","))
+			}
+
+			fmt.Fprintf(&buf, " %s\n", strconv.FormatFloat(sample.value, 'f', -1, 64))
+		}
+	}
+
+	buf.WriteString("# EOF\n")
+
+	return buf.String()
+}
+
+func metricsEscapeLabel(value string) string {
+	value = strings.Replace(value, "\\", "\\\\", -1)
+	value = strings.Replace(value, "\"", "\\\"", -1)
+	value = strings.Replace(value, "\n", "\\n", -1)
+	return value
+}
+
+// Collecting the container metrics means loading and querying each running
+// container, so they're only refreshed every so often however frequently the
+// endpoint gets scraped.
+const metricsContainersExpiry = 10 * time.Second
+
+var metricsContainersLock sync.Mutex
+var metricsContainersCache *metricsSet
+var metricsContainersTime time.Time
+
+// API request statistics, keyed by route and method
+type metricsRequestKey struct {
+	route  string
+	method string
+}
+
+type metricsRequestStats struct {
+	count    int64
+	duration time.Duration
+}
+
+var metricsRequestsLock sync.Mutex
+var metricsRequests = map[metricsRequestKey]*metricsRequestStats{}
+
+// metricsRequestRecord records an API request against the given route.
+func metricsRequestRecord(route string, method string, duration time.Duration) {
+	metricsRequestsLock.Lock()
+	defer metricsRequestsLock.Unlock()
+
+	key := metricsRequestKey{route: route, method: method}
+	stats, ok := metricsRequests[key]
+	if !ok {
+		stats = &metricsRequestStats{}
+		metricsRequests[key] = stats
+	}
+
+	stats.count++
+	stats.duration += duration
+}
+
+type metricsResponse struct {
+	metrics *metricsSet
+}
+
+func (r *metricsResponse) Render(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", metricsContentType)
+	_, err := w.Write([]byte(r.metrics.String()))
+	return err
+}
+
+func (r *metricsResponse) String() string {
+	return "metrics"
+}
+
+func metricsGet(d *Daemon, r *http.Request) Response {
+	metrics := &metricsSet{}
+
+	err := metricsContainersCached(d, metrics)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = metricsImages(d, metrics)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	metricsDaemon(metrics)
+
+	return &metricsResponse{metrics}
+}
+
+var metricsCmd = Command{name: "metrics", get: metricsGet}
+
+// metricsContainersCached adds the container metrics, collecting them again
+// only if the last ones are too old.
+func metricsContainersCached(d *Daemon, metrics *metricsSet) error {
+	metricsContainersLock.Lock()
+	defer metricsContainersLock.Unlock()
+
+	if metricsContainersCache == nil || time.Since(metricsContainersTime) > metricsContainersExpiry {
+		containers := &metricsSet{}
+		err := metricsContainers(d, containers)
+		if err != nil {
+			return err
+		}
+
+		metricsContainersCache = containers
+		metricsContainersTime = time.Now()
+	}
+
+	metrics.merge(metricsContainersCache)
+	return nil
+}
+
+// metricsContainers adds the resource usage of all the containers.
+func metricsContainers(d *Daemon, metrics *metricsSet) error {
+	states, err := d.db.ContainersPowerState()
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range states {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		labels := map[string]string{"name": name}
+
+		// Only the containers which were last seen running need loading
+		if states[name] != "RUNNING" {
+			metrics.gauge("lxd_container_running", "Whether the container is running.", labels, 0)
+			continue
+		}
+
+		c, err := containerLoadByName(d.State(), d.Storage, name)
+		if err != nil {
+			continue
+		}
+
+		if !c.IsRunning() {
+			metrics.gauge("lxd_container_running", "Whether the container is running.", labels, 0)
+			continue
+		}
+
+		state, err := c.RenderState()
+		if err != nil {
+			continue
+		}
+
+		metrics.gauge("lxd_container_running", "Whether the container is running.", labels, 1)
+		metrics.counter("lxd_container_cpu_seconds", "CPU time used by the container.", labels, float64(state.CPU.Usage)/float64(time.Second))
+		metrics.gauge("lxd_container_memory_usage_bytes", "Memory used by the container.", labels, float64(state.Memory.Usage))
+		metrics.gauge("lxd_container_swap_usage_bytes", "Swap used by the container.", labels, float64(state.Memory.SwapUsage))
+		metrics.gauge("lxd_container_processes", "Number of processes in the container.", labels, float64(state.Processes))
+
+		devices := []string{}
+		for device := range state.Disk {
+			devices = append(devices, device)
+		}
+		sort.Strings(devices)
+
+		for _, device := range devices {
+			diskLabels := map[string]string{"name": name, "device": device}
+			metrics.gauge("lxd_container_disk_usage_bytes", "Disk space used by the container.", diskLabels, float64(state.Disk[device].Usage))
+		}
+
+		interfaces := []string{}
+		for iface := range state.Network {
+			interfaces = append(interfaces, iface)
+		}
+		sort.Strings(interfaces)
+
+		for _, iface := range interfaces {
+			counters := state.Network[iface].Counters
+			netLabels := map[string]string{"name": name, "interface": iface}
+			metrics.counter("lxd_container_network_receive_bytes", "Bytes received on the container network interface.", netLabels, float64(counters.BytesReceived))
+			metrics.counter("lxd_container_network_transmit_bytes", "Bytes sent on the container network interface.", netLabels, float64(counters.BytesSent))
+			metrics.counter("lxd_container_network_receive_packets", "Packets received on the container network interface.", netLabels, float64(counters.PacketsReceived))
+			metrics.counter("lxd_container_network_transmit_packets", "Packets sent on the container network interface.", netLabels, float64(counters.PacketsSent))
+		}
+	}
+
+	return nil
+}
+
+// metricsImages adds the number and size of the images in the store, split
+// between cached and other images.
+func metricsImages(d *Daemon, metrics *metricsSet) error {
+	fingerprints, err := d.db.ImagesGet(false)
+	if err != nil {
+		return err
+	}
+
+	count := map[bool]int64{false: 0, true: 0}
+	size := map[bool]int64{false: 0, true: 0}
+	for _, fingerprint := range fingerprints {
+		_, image, err := d.db.ImageGet(fingerprint, false, true)
+		if err != nil {
+			continue
+		}
+
+		count[image.Cached]++
+		size[image.Cached] += image.Size
+	}
+
+	for _, cached := range []bool{false, true} {
+		labels := map[string]string{"cached": strconv.FormatBool(cached)}
+		metrics.gauge("lxd_images", "Number of images in the image store.", labels, float64(count[cached]))
+		metrics.gauge("lxd_images_size_bytes", "Size of the images in the image store.", labels, float64(size[cached]))
+	}
+
+	return nil
+}
+
+// metricsDaemon adds the daemon's own metrics.
+func metricsDaemon(metrics *metricsSet) {
+	metrics.gauge("lxd_goroutines", "Number of goroutines in the daemon.", nil, float64(runtime.NumGoroutine()))
+
+	// Operations by class and status
+	type operationKey struct {
+		class  string
+		status string
+	}
+
+	ops := map[operationKey]int64{}
+	operationsLock.Lock()
+	for _, op := range operations {
+		key := operationKey{class: op.class.String(), status: strings.ToLower(op.status.String())}
+		ops[key]++
+	}
+	operationsLock.Unlock()
+
+	opKeys := []operationKey{}
+	for key := range ops {
+		opKeys = append(opKeys, key)
+	}
+	sort.Slice(opKeys, func(i, j int) bool {
+		if opKeys[i].class != opKeys[j].class {
+			return opKeys[i].class < opKeys[j].class
+		}
+
+		return opKeys[i].status < opKeys[j].status
+	})
+
+	for _, key := range opKeys {
+		labels := map[string]string{"class": key.class, "status": key.status}
+		metrics.gauge("lxd_operations", "Number of operations.", labels, float64(ops[key]))
+	}
+
+	// API requests by route and method
+	metricsRequestsLock.Lock()
+	requestKeys := []metricsRequestKey{}
+	for key := range metricsRequests {
+		requestKeys = append(requestKeys, key)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		if requestKeys[i].route != requestKeys[j].route {
+			return requestKeys[i].route < requestKeys[j].route
+		}
+
+		return requestKeys[i].method < requestKeys[j].method
+	})
+
+	for _, key := range requestKeys {
+		stats := metricsRequests[key]
+		labels := map[string]string{"route": key.route, "method": key.method}
+		metrics.counter("lxd_api_requests", "Number of API requests.", labels, float64(stats.count))
+		metrics.summary("lxd_api_request_duration_seconds", "Time spent handling API requests.", labels, stats.duration.Seconds(), float64(stats.count))
+	}
+	metricsRequestsLock.Unlock()
+}
+
+// MetricsServer creates an http.Server exposing the metrics without any
+// authentication, for the core.metrics_address listener.
+func MetricsServer(d *Daemon) *http.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/1.0/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.Header().Set("Content-Type", "application/json")
+			NotImplemented.Render(w)
+			return
+		}
+
+		err := metricsGet(d, r).Render(w)
+		if err != nil {
+			InternalError(err).Render(w)
+		}
+	})
+
+	return &http.Server{Handler: mux}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Metrics are grouped by family and rendered in the OpenMetrics text format.
+func TestMetricsSet_String(t *testing.T) {
+	metrics := &metricsSet{}
+	metrics.gauge("lxd_goroutines", "Number of goroutines.", nil, 12)
+	metrics.counter("lxd_api_requests", "Number of API requests.", map[string]string{"route": "/1.0", "method": "GET"}, 3)
+	metrics.summary("lxd_api_request_duration_seconds", "Time spent.", map[string]string{"route": "/1.0"}, 0.25, 3)
+	metrics.counter("lxd_api_requests", "Number of API requests.", map[string]string{"route": "/1.0/\"x\"", "method": "GET"}, 1)
+
+	expected := `# HELP lxd_goroutines Number of goroutines.
+# TYPE lxd_goroutines gauge
+lxd_goroutines 12
+# HELP lxd_api_requests Number of API requests.
+# TYPE lxd_api_requests counter
+lxd_api_requests_total{method="GET",route="/1.0"} 3
+lxd_api_requests_total{method="GET",route="/1.0/\"x\""} 1
+# HELP lxd_api_request_duration_seconds Time spent.
+# TYPE lxd_api_request_duration_seconds summary
+lxd_api_request_duration_seconds_sum{route="/1.0"} 0.25
+lxd_api_request_duration_seconds_count{route="/1.0"} 3
+# EOF
+`
+
+	assert.Equal(t, expected, metrics.String())
+}
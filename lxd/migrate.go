@@ -352,6 +352,12 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 		driver, _ = rsyncMigrationSource(s.container)
 	}
 
+	// When refreshing an existing container, the target tells us which
+	// snapshots it's missing.
+	if header.GetRefresh() {
+		driver.FilterSnapshots(header.SnapshotNames)
+	}
+
 	// All failure paths need to do a few things to correctly handle errors before returning.
 	// Unfortunately, handling errors is not well-suited to defer as the code depends on the
 	// status of driver and the error value.  The error value is especially tricky due to the
@@ -547,6 +553,9 @@ type migrationSink struct {
 
 	url    string
 	dialer websocket.Dialer
+
+	// We are refreshing an existing container.
+	refresh bool
 }
 
 type MigrationSinkArgs struct {
@@ -554,13 +563,15 @@ type MigrationSinkArgs struct {
 	Dialer    websocket.Dialer
 	Container container
 	Secrets   map[string]string
+	Refresh   bool
 }
 
 func NewMigrationSink(args *MigrationSinkArgs) (*migrationSink, error) {
 	sink := migrationSink{
-		src:    migrationFields{container: args.Container},
-		url:    args.Url,
-		dialer: args.Dialer,
+		src:     migrationFields{container: args.Container},
+		url:     args.Url,
+		dialer:  args.Dialer,
+		refresh: args.Refresh,
 	}
 
 	var ok bool
@@ -602,16 +613,30 @@ func (c *migrationSink) connectWithSecret(secret string) (*websocket.Conn, error
 func (c *migrationSink) Do(migrateOp *operation) error {
 	var err error
 
+	// The snapshots being transferred when refreshing the container.
+	refreshSnapshots := []*migration.Snapshot{}
+
+	// On failure, a new container gets deleted while a refreshed one only
+	// loses the snapshots which were being transferred.
+	abort := func() {
+		if !c.refresh {
+			c.src.container.Delete()
+			return
+		}
+
+		migrationRefreshAbort(c.src.container, refreshSnapshots)
+	}
+
 	c.src.controlConn, err = c.connectWithSecret(c.src.controlSecret)
 	if err != nil {
-		c.src.container.Delete()
+		abort()
 		return err
 	}
 	defer c.src.disconnect()
 
 	c.src.fsConn, err = c.connectWithSecret(c.src.fsSecret)
 	if err != nil {
-		c.src.container.Delete()
+		abort()
 		c.src.sendControl(err)
 		return err
 	}
@@ -619,7 +644,7 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 	if c.src.live {
 		c.src.criuConn, err = c.connectWithSecret(c.src.criuSecret)
 		if err != nil {
-			c.src.container.Delete()
+			abort()
 			c.src.sendControl(err)
 			return err
 		}
@@ -627,7 +652,7 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 
 	header := migration.MigrationHeader{}
 	if err := c.src.recv(&header); err != nil {
-		c.src.container.Delete()
+		abort()
 		c.src.sendControl(err)
 		return err
 	}
@@ -652,9 +677,48 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 		resp.Fs = &myType
 	}
 
+	/* Legacy: we only sent the snapshot names, so we just copy the
+	 * container's config over, same as we used to do.
+	 */
+	snapshots := []*migration.Snapshot{}
+	if len(header.SnapshotNames) != len(header.Snapshots) {
+		for _, name := range header.SnapshotNames {
+			base := snapshotToProtobuf(c.src.container)
+			base.Name = &name
+			snapshots = append(snapshots, base)
+		}
+	} else {
+		snapshots = header.Snapshots
+	}
+
+	// When refreshing the container, ask the source for the snapshots
+	// we're missing only.
+	if c.refresh {
+		var optimized bool
+		snapshots, optimized, err = migrationRefreshSnapshots(c.src.container, snapshots)
+		if err != nil {
+			c.src.sendControl(err)
+			return err
+		}
+
+		// Incremental streams need a snapshot to start from
+		if !optimized {
+			mySink = rsyncMigrationSink
+			myType = migration.MigrationFSType_RSYNC
+			resp.Fs = &myType
+		}
+
+		refreshSnapshots = snapshots
+		resp.Refresh = proto.Bool(true)
+		resp.Snapshots = snapshots
+		for _, snap := range snapshots {
+			resp.SnapshotNames = append(resp.SnapshotNames, snap.GetName())
+		}
+	}
+
 	err = c.src.send(&resp)
 	if err != nil {
-		c.src.container.Delete()
+		abort()
 		c.src.sendControl(err)
 		return err
 	}
@@ -682,22 +746,6 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 		 */
 		fsTransfer := make(chan error)
 		go func() {
-			snapshots := []*migration.Snapshot{}
-
-			/* Legacy: we only sent the snapshot names, so we just
-			 * copy the container's config over, same as we used to
-			 * do.
-			 */
-			if len(header.SnapshotNames) != len(header.Snapshots) {
-				for _, name := range header.SnapshotNames {
-					base := snapshotToProtobuf(c.src.container)
-					base.Name = &name
-					snapshots = append(snapshots, base)
-				}
-			} else {
-				snapshots = header.Snapshots
-			}
-
 			err := mySink(c.src.live, c.src.container, snapshots, c.src.fsConn, srcIdmap)
 			if err != nil {
 				fsTransfer <- err
@@ -763,19 +811,19 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 		case err = <-restore:
 			c.src.sendControl(err)
 			if err != nil {
-				c.src.container.Delete()
+				abort()
 				return err
 			}
 			return nil
 		case msg, ok := <-source:
 			if !ok {
 				c.src.disconnect()
-				c.src.container.Delete()
+				abort()
 				return fmt.Errorf("Got error reading source")
 			}
 			if !*msg.Success {
 				c.src.disconnect()
-				c.src.container.Delete()
+				abort()
 				return fmt.Errorf(*msg.Message)
 			} else {
 				// The source can only tell us it failed (e.g. if
@@ -784,10 +832,81 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 				logger.Debugf("Unknown message %v from source", msg)
 				err = c.src.container.TemplateApply("copy")
 				if err != nil {
-					c.src.container.Delete()
+					abort()
 					return err
 				}
 			}
 		}
 	}
 }
+
+// migrationRefreshSnapshots prepares the refresh of an existing container
+// from the snapshots of the source. The snapshots of the container which
+// don't exist on the source anymore are deleted, and the ones it's missing
+// are returned.
+//
+// Incremental zfs or btrfs streams can only be used if the container has at
+// least one snapshot and the snapshots it has are the oldest ones of the
+// source, otherwise the transfer needs to fall back to rsync.
+func migrationRefreshSnapshots(c container, snapshots []*migration.Snapshot) ([]*migration.Snapshot, bool, error) {
+	sourceNames := []string{}
+	for _, snap := range snapshots {
+		sourceNames = append(sourceNames, snap.GetName())
+	}
+
+	existing, err := c.Snapshots()
+	if err != nil {
+		return nil, false, err
+	}
+
+	targetNames := []string{}
+	for _, snap := range existing {
+		name := shared.ExtractSnapshotName(snap.Name())
+		if shared.StringInSlice(name, sourceNames) {
+			targetNames = append(targetNames, name)
+			continue
+		}
+
+		err := snap.Delete()
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	missing := []*migration.Snapshot{}
+	optimized := len(targetNames) > 0
+	for _, snap := range snapshots {
+		if !shared.StringInSlice(snap.GetName(), targetNames) {
+			missing = append(missing, snap)
+			continue
+		}
+
+		if len(missing) > 0 {
+			optimized = false
+		}
+	}
+
+	return missing, optimized, nil
+}
+
+// migrationRefreshAbort deletes the snapshots of a container which were
+// being transferred when its refresh failed.
+func migrationRefreshAbort(c container, snapshots []*migration.Snapshot) {
+	names := []string{}
+	for _, snap := range snapshots {
+		names = append(names, snap.GetName())
+	}
+
+	existing, err := c.Snapshots()
+	if err != nil {
+		logger.Errorf("Failed to list the snapshots of %s: %s", c.Name(), err)
+		return
+	}
+
+	for _, snap := range migrationFilterSnapshots(existing, names) {
+		err := snap.Delete()
+		if err != nil {
+			logger.Errorf("Failed to delete snapshot %s: %s", snap.Name(), err)
+		}
+	}
+}
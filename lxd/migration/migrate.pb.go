@@ -276,6 +276,7 @@ type MigrationHeader struct {
 	Idmap            []*IDMapType     `protobuf:"bytes,3,rep,name=idmap" json:"idmap,omitempty"`
 	SnapshotNames    []string         `protobuf:"bytes,4,rep,name=snapshotNames" json:"snapshotNames,omitempty"`
 	Snapshots        []*Snapshot      `protobuf:"bytes,5,rep,name=snapshots" json:"snapshots,omitempty"`
+	Refresh          *bool            `protobuf:"varint,6,opt,name=refresh" json:"refresh,omitempty"`
 	XXX_unrecognized []byte           `json:"-"`
 }
 
@@ -319,6 +320,13 @@ func (m *MigrationHeader) GetSnapshots() []*Snapshot {
 	return nil
 }
 
+func (m *MigrationHeader) GetRefresh() bool {
+	if m != nil && m.Refresh != nil {
+		return *m.Refresh
+	}
+	return false
+}
+
 type MigrationControl struct {
 	Success *bool `protobuf:"varint,1,req,name=success" json:"success,omitempty"`
 	// optional failure message if sending a failure
@@ -359,38 +367,39 @@ func init() {
 func init() { proto.RegisterFile("lxd/migration/migrate.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 523 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x52, 0xcd, 0x6e, 0xd3, 0x4c,
-	0x14, 0xfd, 0x62, 0xc7, 0xa9, 0x7d, 0xdb, 0x2f, 0x98, 0x4b, 0x85, 0x46, 0x85, 0x45, 0x64, 0x21,
-	0x11, 0xb2, 0x48, 0x4b, 0x10, 0x0f, 0x00, 0x29, 0xa1, 0x95, 0x68, 0x40, 0x93, 0x76, 0x01, 0x1b,
-	0x34, 0xb2, 0x6f, 0x9c, 0x11, 0xfe, 0x93, 0xc7, 0xae, 0xe8, 0x8a, 0x0d, 0x6f, 0xcb, 0x4b, 0x20,
-	0x8f, 0x7f, 0xea, 0x08, 0x24, 0x76, 0xe7, 0x9c, 0x39, 0xbe, 0xc7, 0xf7, 0xcc, 0xc0, 0x93, 0xe8,
-	0x7b, 0x70, 0x1a, 0xcb, 0x30, 0x17, 0x85, 0x4c, 0x93, 0x06, 0xd1, 0x3c, 0xcb, 0xd3, 0x22, 0x45,
-	0xa7, 0x3b, 0xf0, 0x7e, 0x80, 0x73, 0x79, 0x7e, 0x25, 0xb2, 0xeb, 0xbb, 0x8c, 0xf0, 0x18, 0x2c,
-	0xa9, 0x4a, 0x19, 0xb0, 0xc1, 0xc4, 0x98, 0xda, 0xbc, 0x26, 0xb5, 0x1a, 0xca, 0x80, 0x19, 0xad,
-	0x1a, 0xca, 0x00, 0x1f, 0xc3, 0x68, 0x97, 0xaa, 0x42, 0x06, 0xcc, 0x9c, 0x18, 0x53, 0x8b, 0x37,
-	0x0c, 0x11, 0x86, 0x89, 0x92, 0x01, 0x1b, 0x6a, 0x55, 0x63, 0x3c, 0x01, 0x3b, 0x16, 0x59, 0x2e,
-	0x92, 0x90, 0x98, 0xa5, 0xf5, 0x8e, 0x7b, 0x67, 0x30, 0x5a, 0xa6, 0xc9, 0x56, 0x86, 0xe8, 0x82,
-	0xf9, 0x8d, 0xee, 0x74, 0xb6, 0xc3, 0x2b, 0x58, 0x25, 0xdf, 0x8a, 0xa8, 0x24, 0x9d, 0xec, 0xf0,
-	0x9a, 0x78, 0xef, 0x61, 0x74, 0x4e, 0xb7, 0xd2, 0x27, 0x9d, 0x25, 0x62, 0x6a, 0x3e, 0xd1, 0x18,
-	0x5f, 0xc0, 0xc8, 0xd7, 0xf3, 0x98, 0x31, 0x31, 0xa7, 0x87, 0x8b, 0x87, 0xf3, 0x6e, 0xd9, 0x79,
-	0x1d, 0xc4, 0x1b, 0x83, 0xf7, 0xd3, 0x00, 0x7b, 0x93, 0x88, 0x4c, 0xed, 0xd2, 0xe2, 0xaf, 0xb3,
-	0x5e, 0xc1, 0x61, 0x94, 0xfa, 0x22, 0x5a, 0xfe, 0x63, 0x60, 0xdf, 0x55, 0x2d, 0x9b, 0xe5, 0xe9,
-	0x56, 0x46, 0xa4, 0x98, 0x39, 0x31, 0xa7, 0x0e, 0xef, 0x38, 0x3e, 0x05, 0x87, 0xb2, 0x1d, 0xc5,
-	0x94, 0x8b, 0x48, 0x37, 0x64, 0xf3, 0x7b, 0x01, 0x5f, 0xc3, 0x91, 0x1e, 0x54, 0x6f, 0xa7, 0x98,
-	0xf5, 0x47, 0x5e, 0x7d, 0xc2, 0xf7, 0x6c, 0xe8, 0xc1, 0x91, 0xc8, 0xfd, 0x9d, 0x2c, 0xc8, 0x2f,
-	0xca, 0x9c, 0xd8, 0x48, 0x37, 0xbc, 0xa7, 0x55, 0x3f, 0xa5, 0x0a, 0x51, 0xd0, 0xb6, 0x8c, 0xd8,
-	0x81, 0xce, 0xed, 0xb8, 0xf7, 0x6b, 0x00, 0x0f, 0xae, 0xda, 0x88, 0x0b, 0x12, 0x01, 0xe5, 0x38,
-	0x03, 0x63, 0xab, 0x74, 0x17, 0xe3, 0xc5, 0x49, 0xef, 0x07, 0x3a, 0xdf, 0x6a, 0x53, 0xbd, 0x18,
-	0x6e, 0x6c, 0x15, 0x3e, 0x87, 0xa1, 0x9f, 0xcb, 0x92, 0x19, 0x93, 0xc1, 0x74, 0xbc, 0x78, 0xd4,
-	0xaf, 0x87, 0x5f, 0xde, 0x68, 0x9b, 0x36, 0xe0, 0x0c, 0x2c, 0x19, 0xc4, 0x22, 0xd3, 0xb5, 0x1c,
-	0x2e, 0x8e, 0x7b, 0xce, 0xee, 0x0d, 0xf2, 0xda, 0x82, 0xcf, 0xe0, 0x7f, 0xd5, 0x5c, 0xcd, 0x5a,
-	0xc4, 0xa4, 0xd8, 0x50, 0x57, 0xb9, 0x2f, 0xe2, 0x4b, 0x70, 0x5a, 0xa1, 0xad, 0xab, 0x9f, 0xdf,
-	0x5e, 0x2e, 0xbf, 0x77, 0x79, 0x2b, 0x70, 0xbb, 0x25, 0x96, 0x69, 0x52, 0xe4, 0x69, 0x84, 0x0c,
-	0x0e, 0x54, 0xe9, 0xfb, 0xa4, 0x54, 0xf3, 0xf2, 0x5b, 0x5a, 0x9d, 0xc4, 0xa4, 0x94, 0x08, 0x49,
-	0xaf, 0xe7, 0xf0, 0x96, 0xce, 0xce, 0x7a, 0xa5, 0xd5, 0x65, 0xa0, 0x03, 0x16, 0xdf, 0x7c, 0x5e,
-	0x2f, 0xdd, 0xff, 0x2a, 0xf8, 0xf6, 0x9a, 0xaf, 0x36, 0xee, 0x00, 0x0f, 0xc0, 0xfc, 0xb2, 0xda,
-	0xb8, 0xc6, 0xec, 0x14, 0xec, 0xb6, 0x10, 0x1c, 0x03, 0x54, 0xf8, 0x6b, 0xcf, 0xff, 0xe9, 0xe2,
-	0xcd, 0xcd, 0x07, 0x77, 0x80, 0x36, 0x0c, 0xd7, 0x1f, 0xd7, 0xef, 0x5c, 0xe3, 0x77, 0x00, 0x00,
-	0x00, 0xff, 0xff, 0x1b, 0x27, 0xb6, 0xaa, 0xc4, 0x03, 0x00, 0x00,
+	// 530 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x53, 0x5d, 0x6f, 0xd3, 0x30,
+	0x14, 0xa5, 0x49, 0x93, 0x26, 0xb7, 0xa3, 0x0b, 0x66, 0x42, 0xd6, 0xe0, 0x21, 0x8a, 0x90, 0x08,
+	0x7d, 0xe8, 0x46, 0x11, 0x3f, 0x00, 0x3a, 0xca, 0x26, 0xb1, 0x82, 0xdc, 0xed, 0x01, 0x5e, 0x90,
+	0x95, 0x38, 0xa9, 0x45, 0xbe, 0x64, 0x27, 0x13, 0x7b, 0xe2, 0x05, 0xfe, 0x37, 0x8a, 0xf3, 0xb1,
+	0x14, 0x90, 0xf6, 0x76, 0xcf, 0xb9, 0x27, 0xf7, 0xf8, 0x1e, 0x3b, 0xf0, 0x34, 0xf9, 0x11, 0x9e,
+	0xa4, 0x3c, 0x16, 0xb4, 0xe4, 0x79, 0xd6, 0x56, 0x6c, 0x51, 0x88, 0xbc, 0xcc, 0x91, 0xdd, 0x37,
+	0xbc, 0x9f, 0x60, 0x5f, 0x9c, 0x5d, 0xd2, 0xe2, 0xea, 0xb6, 0x60, 0xe8, 0x08, 0x0c, 0x2e, 0x2b,
+	0x1e, 0xe2, 0x91, 0xab, 0xf9, 0x16, 0x69, 0x40, 0xc3, 0xc6, 0x3c, 0xc4, 0x5a, 0xc7, 0xc6, 0x3c,
+	0x44, 0x4f, 0xc0, 0xdc, 0xe5, 0xb2, 0xe4, 0x21, 0xd6, 0x5d, 0xcd, 0x37, 0x48, 0x8b, 0x10, 0x82,
+	0x71, 0x26, 0x79, 0x88, 0xc7, 0x8a, 0x55, 0x35, 0x3a, 0x06, 0x2b, 0xa5, 0x85, 0xa0, 0x59, 0xcc,
+	0xb0, 0xa1, 0xf8, 0x1e, 0x7b, 0xa7, 0x60, 0xae, 0xf2, 0x2c, 0xe2, 0x31, 0x72, 0x40, 0xff, 0xce,
+	0x6e, 0x95, 0xb7, 0x4d, 0xea, 0xb2, 0x76, 0xbe, 0xa1, 0x49, 0xc5, 0x94, 0xb3, 0x4d, 0x1a, 0xe0,
+	0x7d, 0x00, 0xf3, 0x8c, 0xdd, 0xf0, 0x80, 0x29, 0x2f, 0x9a, 0xb2, 0xf6, 0x13, 0x55, 0xa3, 0x97,
+	0x60, 0x06, 0x6a, 0x1e, 0xd6, 0x5c, 0xdd, 0x9f, 0x2e, 0x1f, 0x2d, 0xfa, 0x65, 0x17, 0x8d, 0x11,
+	0x69, 0x05, 0xde, 0x2f, 0x0d, 0xac, 0x6d, 0x46, 0x0b, 0xb9, 0xcb, 0xcb, 0xff, 0xce, 0x7a, 0x0d,
+	0xd3, 0x24, 0x0f, 0x68, 0xb2, 0xba, 0x67, 0xe0, 0x50, 0x55, 0x2f, 0x5b, 0x88, 0x3c, 0xe2, 0x09,
+	0x93, 0x58, 0x77, 0x75, 0xdf, 0x26, 0x3d, 0x46, 0xcf, 0xc0, 0x66, 0xc5, 0x8e, 0xa5, 0x4c, 0xd0,
+	0x44, 0x25, 0x64, 0x91, 0x3b, 0x02, 0xbd, 0x81, 0x03, 0x35, 0xa8, 0xd9, 0x4e, 0x62, 0xe3, 0x1f,
+	0xbf, 0xa6, 0x43, 0xf6, 0x64, 0xc8, 0x83, 0x03, 0x2a, 0x82, 0x1d, 0x2f, 0x59, 0x50, 0x56, 0x82,
+	0x61, 0x53, 0x25, 0xbc, 0xc7, 0xd5, 0x87, 0x92, 0x25, 0x2d, 0x59, 0x54, 0x25, 0x78, 0xa2, 0x7c,
+	0x7b, 0xec, 0xfd, 0xd6, 0xe0, 0xf0, 0xb2, 0xb3, 0x38, 0x67, 0x34, 0x64, 0x02, 0xcd, 0x41, 0x8b,
+	0xa4, 0xca, 0x62, 0xb6, 0x3c, 0x1e, 0x1c, 0xa0, 0xd7, 0xad, 0xb7, 0xf5, 0x8b, 0x21, 0x5a, 0x24,
+	0xd1, 0x0b, 0x18, 0x07, 0x82, 0x57, 0x58, 0x73, 0x47, 0xfe, 0x6c, 0xf9, 0x78, 0x18, 0x0f, 0xb9,
+	0xb8, 0x56, 0x32, 0x25, 0x40, 0x73, 0x30, 0x78, 0x98, 0xd2, 0x42, 0xc5, 0x32, 0x5d, 0x1e, 0x0d,
+	0x94, 0xfd, 0x1b, 0x24, 0x8d, 0x04, 0x3d, 0x87, 0x87, 0xb2, 0xbd, 0x9a, 0x0d, 0x4d, 0x99, 0xc4,
+	0x63, 0x15, 0xe5, 0x3e, 0x89, 0x5e, 0x81, 0xdd, 0x11, 0x5d, 0x5c, 0x43, 0xff, 0xee, 0x72, 0xc9,
+	0x9d, 0x0a, 0x61, 0x98, 0x08, 0x16, 0x09, 0x26, 0x77, 0xd8, 0x74, 0x47, 0xbe, 0x45, 0x3a, 0xe8,
+	0xad, 0xc1, 0xe9, 0xd7, 0x5b, 0xe5, 0x59, 0x29, 0xf2, 0xa4, 0x56, 0xcb, 0x2a, 0x08, 0x98, 0x94,
+	0xed, 0x3f, 0xd1, 0xc1, 0xba, 0x93, 0x32, 0x29, 0x69, 0xcc, 0xd4, 0xe2, 0x36, 0xe9, 0xe0, 0xfc,
+	0x14, 0x0e, 0xff, 0x8a, 0x09, 0xd9, 0x60, 0x90, 0xed, 0x97, 0xcd, 0xca, 0x79, 0x50, 0x97, 0xef,
+	0xae, 0xc8, 0x7a, 0xeb, 0x8c, 0xd0, 0x04, 0xf4, 0xaf, 0xeb, 0xad, 0xa3, 0xcd, 0x4f, 0xc0, 0xea,
+	0xa2, 0x42, 0x33, 0x80, 0xba, 0xfe, 0x36, 0xd0, 0x7f, 0x3e, 0x7f, 0x7b, 0xfd, 0xd1, 0x19, 0x21,
+	0x0b, 0xc6, 0x9b, 0x4f, 0x9b, 0xf7, 0x8e, 0xf6, 0x67, 0x00, 0x1e, 0x70, 0x9d, 0x84, 0xde, 0x03,
+	0x00, 0x00,
 }
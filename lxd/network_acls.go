@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// /1.0/network-acls
+func networkACLsGet(d *Daemon, r *http.Request) Response {
+	names, err := d.db.NetworkACLs()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	recursion := util.IsRecursionRequest(r)
+
+	resultString := []string{}
+	resultMap := []*api.NetworkACL{}
+	for _, name := range names {
+		if !recursion {
+			resultString = append(resultString, fmt.Sprintf("/%s/network-acls/%s", version.APIVersion, name))
+		} else {
+			acl, err := doNetworkACLGet(d, name)
+			if err != nil {
+				continue
+			}
+			resultMap = append(resultMap, acl)
+		}
+	}
+
+	if !recursion {
+		return SyncResponse(true, resultString)
+	}
+
+	return SyncResponse(true, resultMap)
+}
+
+func networkACLsPost(d *Daemon, r *http.Request) Response {
+	req := api.NetworkACLsPost{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	// Sanity checks
+	err := networkACLValidName(req.Name)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = networkACLValidate(req.NetworkACLPut)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	_, _, err = d.db.NetworkACLGet(req.Name)
+	if err == nil {
+		return Conflict
+	}
+
+	// Create the database entry
+	_, err = d.db.NetworkACLCreate(req.Name, req.NetworkACLPut)
+	if err != nil {
+		return SmartError(
+			fmt.Errorf("Error inserting %s into database: %s", req.Name, err))
+	}
+
+	eventSendLifecycle("network-acl-created", fmt.Sprintf("/%s/network-acls/%s", version.APIVersion, req.Name), nil, eventRequestor(r))
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/network-acls/%s", version.APIVersion, req.Name))
+}
+
+var networkACLsCmd = Command{name: "network-acls", get: networkACLsGet, post: networkACLsPost}
+
+func doNetworkACLGet(d *Daemon, name string) (*api.NetworkACL, error) {
+	_, acl, err := d.db.NetworkACLGet(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look for containers using the ACL
+	cts, err := d.db.ContainersList(db.CTypeRegular)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ct := range cts {
+		c, err := containerLoadByName(d.State(), d.Storage, ct)
+		if err != nil {
+			logger.Warn("Failed to load container", log.Ctx{"name": ct, "err": err})
+			continue
+		}
+
+		for _, m := range c.ExpandedDevices() {
+			if networkACLNicUses(m, name) {
+				acl.UsedBy = append(acl.UsedBy, fmt.Sprintf("/%s/containers/%s", version.APIVersion, ct))
+				break
+			}
+		}
+	}
+
+	return acl, nil
+}
+
+// /1.0/network-acls/{name}
+func networkACLGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	acl, err := doNetworkACLGet(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, acl)
+}
+
+func networkACLPut(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	_, _, err := d.db.NetworkACLGet(name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	req := api.NetworkACLPut{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	err = networkACLValidate(req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = d.db.NetworkACLUpdate(name, req)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Apply the new rules to the running containers
+	if !d.os.MockMode {
+		err = networkACLUpdateContainers(d.State(), d.Storage, name)
+		if err != nil {
+			return SmartError(err)
+		}
+	}
+
+	eventSendLifecycle("network-acl-updated", fmt.Sprintf("/%s/network-acls/%s", version.APIVersion, name), nil, eventRequestor(r))
+
+	return EmptySyncResponse
+}
+
+func networkACLPost(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	req := api.NetworkACLPost{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	err := networkACLValidName(req.Name)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	acl, err := doNetworkACLGet(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Nics refer to the ACL by name
+	if len(acl.UsedBy) != 0 {
+		return BadRequest(fmt.Errorf("The network ACL is currently in use"))
+	}
+
+	_, _, err = d.db.NetworkACLGet(req.Name)
+	if err == nil {
+		return Conflict
+	}
+
+	err = d.db.NetworkACLRename(name, req.Name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	eventSendLifecycle("network-acl-renamed", fmt.Sprintf("/%s/network-acls/%s", version.APIVersion, name),
+		map[string]interface{}{"new_name": req.Name}, eventRequestor(r))
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/network-acls/%s", version.APIVersion, req.Name))
+}
+
+func networkACLDelete(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	acl, err := doNetworkACLGet(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if len(acl.UsedBy) != 0 {
+		return BadRequest(fmt.Errorf("The network ACL is currently in use"))
+	}
+
+	err = d.db.NetworkACLDelete(name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	eventSendLifecycle("network-acl-deleted", fmt.Sprintf("/%s/network-acls/%s", version.APIVersion, name), nil, eventRequestor(r))
+
+	return EmptySyncResponse
+}
+
+var networkACLCmd = Command{name: "network-acls/{name}", get: networkACLGet, put: networkACLPut, post: networkACLPost, delete: networkACLDelete}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Rules need a known action and protocol, valid addresses, and ports only
+// with tcp or udp.
+func TestNetworkACLValidateRule(t *testing.T) {
+	cases := []struct {
+		rule  api.NetworkACLRule
+		valid bool
+	}{
+		{api.NetworkACLRule{Action: "allow"}, true},
+		{api.NetworkACLRule{Action: "accept"}, false},
+		{api.NetworkACLRule{Action: "drop", Protocol: "sctp"}, false},
+		{api.NetworkACLRule{Action: "allow", Source: "10.0.0.0/8, 192.0.2.1"}, true},
+		{api.NetworkACLRule{Action: "allow", Destination: "fd42::/64"}, true},
+		{api.NetworkACLRule{Action: "allow", Source: "10.0.0.0/33"}, false},
+		{api.NetworkACLRule{Action: "allow", Source: "foo"}, false},
+		{api.NetworkACLRule{Action: "allow", Protocol: "tcp", DestinationPort: "22,80-90"}, true},
+		{api.NetworkACLRule{Action: "allow", Protocol: "udp", SourcePort: "0"}, false},
+		{api.NetworkACLRule{Action: "allow", Protocol: "tcp", DestinationPort: "65536"}, false},
+		{api.NetworkACLRule{Action: "allow", Protocol: "icmp4", DestinationPort: "22"}, false},
+		{api.NetworkACLRule{Action: "reject", DestinationPort: "22"}, false},
+	}
+
+	for _, c := range cases {
+		err := networkACLValidateRule(c.rule)
+		assert.Equal(t, c.valid, err == nil, "%+v: %v", c.rule, err)
+	}
+}
+
+// Bridge connection tracking appeared in Linux 5.3.
+func TestNetworkACLKernelSupported(t *testing.T) {
+	cases := map[string]bool{
+		"4.19.0-6-amd64":    false,
+		"5.2.21":            false,
+		"5.3.0-51-generic":  true,
+		"5.15.0-91-generic": true,
+		"6.1.0":             true,
+		"garbage":           false,
+	}
+
+	for release, supported := range cases {
+		assert.Equal(t, supported, networkACLKernelSupported(release), release)
+	}
+}
+
+// Rules are rendered once per address family they apply to.
+func TestNetworkACLRenderRule(t *testing.T) {
+	cases := []struct {
+		rule     api.NetworkACLRule
+		expected []string
+	}{
+		{
+			api.NetworkACLRule{Action: "drop"},
+			[]string{"drop"},
+		},
+		{
+			api.NetworkACLRule{Action: "allow", Protocol: "tcp", DestinationPort: "80, 443"},
+			[]string{"meta l4proto tcp tcp dport { 80, 443 } accept"},
+		},
+		{
+			api.NetworkACLRule{Action: "reject", Source: "10.0.0.0/8,fd42::1"},
+			[]string{
+				"ether type ip ip saddr { 10.0.0.0/8 } reject",
+				"ether type ip6 ip6 saddr { fd42::1/128 } reject",
+			},
+		},
+		{
+			api.NetworkACLRule{Action: "allow", Protocol: "udp", Destination: "192.0.2.1", SourcePort: "1000-2000"},
+			[]string{"ether type ip ip daddr { 192.0.2.1/32 } meta l4proto udp udp sport { 1000-2000 } accept"},
+		},
+		{
+			api.NetworkACLRule{Action: "allow", Protocol: "icmp6"},
+			[]string{"ether type ip6 meta l4proto ipv6-icmp accept"},
+		},
+		{
+			api.NetworkACLRule{Action: "allow", Protocol: "icmp4", Source: "fd42::/64"},
+			[]string{},
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, networkACLRenderRule(c.rule), "%+v", c.rule)
+	}
+}
+
+// Directions with rules end with a drop, the others let everything through.
+func TestNetworkACLRender(t *testing.T) {
+	acls := []*api.NetworkACL{
+		{
+			Name: "web",
+			NetworkACLPut: api.NetworkACLPut{
+				Ingress: []api.NetworkACLRule{{Action: "allow", Protocol: "tcp", DestinationPort: "80"}},
+			},
+		},
+	}
+
+	script := networkACLRender("veth1a2b-3", acls)
+	assert.Contains(t, script, "add chain bridge lxd acl_veth1a2b_3_forward { type filter hook forward priority 0; policy accept; }\n")
+	assert.Contains(t, script, "add rule bridge lxd acl_veth1a2b_3_forward oifname \"veth1a2b-3\" jump acl_veth1a2b_3_ingress\n")
+	assert.Contains(t, script, "add rule bridge lxd acl_veth1a2b_3_ingress meta l4proto tcp tcp dport { 80 } accept\n")
+	assert.Contains(t, script, "add rule bridge lxd acl_veth1a2b_3_ingress drop\n")
+	assert.NotContains(t, script, "add rule bridge lxd acl_veth1a2b_3_egress drop\n")
+
+	// The rules come after the boilerplate and before the final drop
+	accept := strings.Index(script, "dport { 80 } accept")
+	assert.True(t, strings.Index(script, "acl_veth1a2b_3_ingress ct state established,related accept") < accept)
+	assert.True(t, accept < strings.Index(script, "acl_veth1a2b_3_ingress drop"))
+}
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Validation
+func networkACLValidName(name string) error {
+	if name == "" {
+		return fmt.Errorf("No name provided")
+	}
+
+	if name == "." || name == ".." || strings.ContainsAny(name, "/, \t\n") {
+		return fmt.Errorf("Invalid network ACL name '%s'", name)
+	}
+
+	return nil
+}
+
+func networkACLValidate(acl api.NetworkACLPut) error {
+	for _, rule := range acl.Ingress {
+		err := networkACLValidateRule(rule)
+		if err != nil {
+			return fmt.Errorf("Invalid ingress rule: %v", err)
+		}
+	}
+
+	for _, rule := range acl.Egress {
+		err := networkACLValidateRule(rule)
+		if err != nil {
+			return fmt.Errorf("Invalid egress rule: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func networkACLValidateRule(rule api.NetworkACLRule) error {
+	if !shared.StringInSlice(rule.Action, []string{"allow", "drop", "reject"}) {
+		return fmt.Errorf("Invalid action '%s'", rule.Action)
+	}
+
+	if !shared.StringInSlice(rule.Protocol, []string{"", "tcp", "udp", "icmp4", "icmp6"}) {
+		return fmt.Errorf("Invalid protocol '%s'", rule.Protocol)
+	}
+
+	for _, value := range []string{rule.Source, rule.Destination} {
+		_, err := networkACLSubnets(value)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, value := range []string{rule.SourcePort, rule.DestinationPort} {
+		if value == "" {
+			continue
+		}
+
+		if rule.Protocol != "tcp" && rule.Protocol != "udp" {
+			return fmt.Errorf("Ports can only be used with the tcp and udp protocols")
+		}
+
+		_, err := networkACLPorts(value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// networkACLSubnets parses a comma separated list of addresses or subnets.
+func networkACLSubnets(value string) ([]*net.IPNet, error) {
+	subnets := []*net.IPNet{}
+	if value == "" {
+		return subnets, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid address '%s'", entry)
+			}
+
+			if ip.To4() != nil {
+				entry = fmt.Sprintf("%s/32", entry)
+			} else {
+				entry = fmt.Sprintf("%s/128", entry)
+			}
+		}
+
+		_, subnet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid subnet '%s'", entry)
+		}
+
+		subnets = append(subnets, subnet)
+	}
+
+	return subnets, nil
+}
+
+// networkACLPorts parses a comma separated list of ports or port ranges.
+func networkACLPorts(value string) ([]string, error) {
+	ports := []string{}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+
+		fields := strings.SplitN(entry, "-", 2)
+		for _, field := range fields {
+			port, err := strconv.ParseUint(field, 10, 16)
+			if err != nil || port == 0 {
+				return nil, fmt.Errorf("Invalid port '%s'", entry)
+			}
+		}
+
+		ports = append(ports, entry)
+	}
+
+	return ports, nil
+}
+
+// Rendering
+var networkACLChainUnsafe = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+// networkACLChainPrefix returns the prefix of the nftables chains of the given
+// host side veth.
+func networkACLChainPrefix(veth string) string {
+	return fmt.Sprintf("acl_%s", networkACLChainUnsafe.ReplaceAllString(veth, "_"))
+}
+
+// networkACLRenderRule renders an ACL rule into nftables rules, one per
+// address family it applies to. The source and destination are those of the
+// packets, whichever the direction of the rule.
+func networkACLRenderRule(rule api.NetworkACLRule) []string {
+	sources, _ := networkACLSubnets(rule.Source)
+	destinations, _ := networkACLSubnets(rule.Destination)
+
+	families := []string{""}
+	if len(sources) > 0 || len(destinations) > 0 {
+		families = []string{"ip", "ip6"}
+	}
+
+	// ICMP is specific to an address family
+	if rule.Protocol == "icmp4" {
+		families = []string{"ip"}
+	} else if rule.Protocol == "icmp6" {
+		families = []string{"ip6"}
+	}
+
+	verdict := map[string]string{"allow": "accept", "drop": "drop", "reject": "reject"}[rule.Action]
+
+	filter := func(family string, subnets []*net.IPNet) []string {
+		result := []string{}
+		for _, subnet := range subnets {
+			if (subnet.IP.To4() != nil) == (family == "ip") {
+				result = append(result, subnet.String())
+			}
+		}
+
+		return result
+	}
+
+	rules := []string{}
+	for _, family := range families {
+		matches := []string{}
+
+		if family != "" {
+			familySources := filter(family, sources)
+			familyDestinations := filter(family, destinations)
+
+			// Skip the family if none of the addresses belong to it
+			if (len(sources) > 0 && len(familySources) == 0) || (len(destinations) > 0 && len(familyDestinations) == 0) {
+				continue
+			}
+
+			matches = append(matches, fmt.Sprintf("ether type %s", family))
+
+			if len(familySources) > 0 {
+				matches = append(matches, fmt.Sprintf("%s saddr { %s }", family, strings.Join(familySources, ", ")))
+			}
+
+			if len(familyDestinations) > 0 {
+				matches = append(matches, fmt.Sprintf("%s daddr { %s }", family, strings.Join(familyDestinations, ", ")))
+			}
+		}
+
+		switch rule.Protocol {
+		case "tcp", "udp":
+			matches = append(matches, fmt.Sprintf("meta l4proto %s", rule.Protocol))
+
+			if rule.SourcePort != "" {
+				ports, _ := networkACLPorts(rule.SourcePort)
+				matches = append(matches, fmt.Sprintf("%s sport { %s }", rule.Protocol, strings.Join(ports, ", ")))
+			}
+
+			if rule.DestinationPort != "" {
+				ports, _ := networkACLPorts(rule.DestinationPort)
+				matches = append(matches, fmt.Sprintf("%s dport { %s }", rule.Protocol, strings.Join(ports, ", ")))
+			}
+		case "icmp4":
+			matches = append(matches, "meta l4proto icmp")
+		case "icmp6":
+			matches = append(matches, "meta l4proto ipv6-icmp")
+		}
+
+		rules = append(rules, strings.TrimSpace(fmt.Sprintf("%s %s", strings.Join(matches, " "), verdict)))
+	}
+
+	return rules
+}
+
+// networkACLRender returns the nftables script setting up the chains which
+// apply the given ACLs, in order, to the traffic of the host side veth.
+//
+// Ingress rules apply to the traffic going to the container and egress ones
+// to the traffic coming from it. Traffic not matching any of the rules of a
+// direction is dropped, unless none of the ACLs has rules for it.
+func networkACLRender(veth string, acls []*api.NetworkACL) string {
+	prefix := networkACLChainPrefix(veth)
+	script := &bytes.Buffer{}
+
+	fmt.Fprintf(script, "add table bridge lxd\n")
+	for _, hook := range []string{"forward", "input", "output"} {
+		fmt.Fprintf(script, "add chain bridge lxd %s_%s { type filter hook %s priority 0; policy accept; }\n", prefix, hook, hook)
+	}
+
+	for _, direction := range []string{"ingress", "egress"} {
+		fmt.Fprintf(script, "add chain bridge lxd %s_%s\n", prefix, direction)
+	}
+
+	for _, chain := range []string{"forward", "input", "output", "ingress", "egress"} {
+		fmt.Fprintf(script, "flush chain bridge lxd %s_%s\n", prefix, chain)
+	}
+
+	fmt.Fprintf(script, "add rule bridge lxd %s_forward iifname \"%s\" jump %s_egress\n", prefix, veth, prefix)
+	fmt.Fprintf(script, "add rule bridge lxd %s_forward oifname \"%s\" jump %s_ingress\n", prefix, veth, prefix)
+	fmt.Fprintf(script, "add rule bridge lxd %s_input iifname \"%s\" jump %s_egress\n", prefix, veth, prefix)
+	fmt.Fprintf(script, "add rule bridge lxd %s_output oifname \"%s\" jump %s_ingress\n", prefix, veth, prefix)
+
+	for _, direction := range []string{"ingress", "egress"} {
+		chain := fmt.Sprintf("%s_%s", prefix, direction)
+
+		// Replies, address resolution and DHCP always go through
+		fmt.Fprintf(script, "add rule bridge lxd %s ct state established,related accept\n", chain)
+		fmt.Fprintf(script, "add rule bridge lxd %s ether type arp accept\n", chain)
+		fmt.Fprintf(script, "add rule bridge lxd %s icmpv6 type { nd-router-solicit, nd-router-advert, nd-neighbor-solicit, nd-neighbor-advert } accept\n", chain)
+		if direction == "egress" {
+			fmt.Fprintf(script, "add rule bridge lxd %s udp dport { 67, 547 } accept\n", chain)
+		} else {
+			fmt.Fprintf(script, "add rule bridge lxd %s udp dport { 68, 546 } accept\n", chain)
+		}
+
+		hasRules := false
+		for _, acl := range acls {
+			rules := acl.Ingress
+			if direction == "egress" {
+				rules = acl.Egress
+			}
+
+			for _, rule := range rules {
+				hasRules = true
+
+				for _, line := range networkACLRenderRule(rule) {
+					fmt.Fprintf(script, "add rule bridge lxd %s %s\n", chain, line)
+				}
+			}
+		}
+
+		if hasRules {
+			fmt.Fprintf(script, "add rule bridge lxd %s drop\n", chain)
+		}
+	}
+
+	return script.String()
+}
+
+func networkACLRun(script string) error {
+	cmd := exec.Command("nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(script)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Failed to run nft: %s", strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// Applying
+
+// networkACLCheckKernel makes sure that the kernel tracks the connections of
+// bridged traffic, without which the replies to allowed traffic are dropped.
+func networkACLCheckKernel() error {
+	// Bridge connection tracking may also be built into the kernel
+	if util.LoadModule("nf_conntrack_bridge") == nil {
+		return nil
+	}
+
+	uname, err := shared.Uname()
+	if err != nil {
+		return err
+	}
+
+	if !networkACLKernelSupported(uname.Release) {
+		return fmt.Errorf("Network ACLs require bridge connection tracking, available from Linux 5.3")
+	}
+
+	return nil
+}
+
+// networkACLKernelSupported returns whether the given kernel release has
+// bridge connection tracking (nf_conntrack_bridge).
+func networkACLKernelSupported(release string) bool {
+	var major, minor int
+	_, err := fmt.Sscanf(release, "%d.%d", &major, &minor)
+	if err != nil {
+		return false
+	}
+
+	return major > 5 || (major == 5 && minor >= 3)
+}
+
+func networkACLLoad(s *state.State, names string) ([]*api.NetworkACL, error) {
+	acls := []*api.NetworkACL{}
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		_, acl, err := s.DB.NetworkACLGet(name)
+		if err != nil {
+			if err == db.NoSuchObjectError {
+				return nil, fmt.Errorf("Network ACL '%s' doesn't exist", name)
+			}
+
+			return nil, err
+		}
+
+		acls = append(acls, acl)
+	}
+
+	return acls, nil
+}
+
+// networkACLApply sets up the chains applying the nic's ACLs to the given
+// host side veth, replacing any previous ones.
+func networkACLApply(s *state.State, veth string, m types.Device) error {
+	err := networkACLCheckKernel()
+	if err != nil {
+		return err
+	}
+
+	acls, err := networkACLLoad(s, m["security.acls"])
+	if err != nil {
+		return err
+	}
+
+	return networkACLRun(networkACLRender(veth, acls))
+}
+
+// networkACLRemove removes the chains of the given host side veth.
+func networkACLRemove(veth string) error {
+	prefix := networkACLChainPrefix(veth)
+
+	_, err := shared.RunCommand("nft", "list", "chain", "bridge", "lxd", fmt.Sprintf("%s_forward", prefix))
+	if err != nil {
+		// Nothing to remove
+		return nil
+	}
+
+	script := &bytes.Buffer{}
+	for _, chain := range []string{"forward", "input", "output", "ingress", "egress"} {
+		fmt.Fprintf(script, "flush chain bridge lxd %s_%s\n", prefix, chain)
+	}
+
+	for _, chain := range []string{"forward", "input", "output", "ingress", "egress"} {
+		fmt.Fprintf(script, "delete chain bridge lxd %s_%s\n", prefix, chain)
+	}
+
+	return networkACLRun(script.String())
+}
+
+// networkACLNicUses returns whether the nic has the given ACL attached.
+func networkACLNicUses(m types.Device, name string) bool {
+	if m["type"] != "nic" || m["security.acls"] == "" {
+		return false
+	}
+
+	for _, entry := range strings.Split(m["security.acls"], ",") {
+		if strings.TrimSpace(entry) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// networkACLUpdateContainers re-applies the given ACL to the running
+// containers it's attached to. A nic failing to be updated doesn't stop the
+// others from being updated, all the failures are reported at the end.
+func networkACLUpdateContainers(s *state.State, st storage, name string) error {
+	cts, err := s.DB.ContainersList(db.CTypeRegular)
+	if err != nil {
+		return err
+	}
+
+	failures := []string{}
+
+	for _, ct := range cts {
+		c, err := containerLoadByName(s, st, ct)
+		if err != nil {
+			logger.Warn("Failed to load container", log.Ctx{"name": ct, "err": err})
+			continue
+		}
+
+		if !c.IsRunning() {
+			continue
+		}
+
+		devices := c.ExpandedDevices()
+		for _, k := range devices.DeviceNames() {
+			m := devices[k]
+			if !networkACLNicUses(m, name) {
+				continue
+			}
+
+			veth := m["host_name"]
+			if veth == "" {
+				veth = c.ExpandedConfig()[fmt.Sprintf("volatile.%s.host_name", k)]
+			}
+
+			err = networkACLApply(s, veth, m)
+			if err != nil {
+				logger.Error("Failed to update network ACL", log.Ctx{"container": ct, "device": k, "acl": name, "err": err})
+				failures = append(failures, fmt.Sprintf("%s/%s: %v", ct, k, err))
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("Failed to update the network ACL of some nics: %s", strings.Join(failures, "; "))
+	}
+
+	return nil
+}
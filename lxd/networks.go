@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
@@ -50,13 +51,25 @@ func networksGet(d *Daemon, r *http.Request) Response {
 		return InternalError(err)
 	}
 
+	// Managed networks first, then any other host interface
+	names, err := d.db.Networks()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	for _, iface := range ifs {
+		if !shared.StringInSlice(iface.Name, names) {
+			names = append(names, iface.Name)
+		}
+	}
+
 	resultString := []string{}
 	resultMap := []api.Network{}
-	for _, iface := range ifs {
+	for _, name := range names {
 		if recursion == 0 {
-			resultString = append(resultString, fmt.Sprintf("/%s/networks/%s", version.APIVersion, iface.Name))
+			resultString = append(resultString, fmt.Sprintf("/%s/networks/%s", version.APIVersion, name))
 		} else {
-			net, err := doNetworkGet(d, iface.Name)
+			net, err := doNetworkGet(d, name)
 			if err != nil {
 				continue
 			}
@@ -72,29 +85,100 @@ func networksGet(d *Daemon, r *http.Request) Response {
 	return SyncResponse(true, resultMap)
 }
 
-var networksCmd = Command{name: "networks", get: networksGet}
+func networksPost(d *Daemon, r *http.Request) Response {
+	req := api.NetworksPost{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	// Sanity checks
+	err := networkValidName(req.Name)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Type != "" && req.Type != "bridge" {
+		return BadRequest(fmt.Errorf("Only 'bridge' type networks can be created"))
+	}
+
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	err = networkValidateConfig(req.Name, req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	_, _, err = d.db.NetworkGet(req.Name)
+	if err == nil {
+		return Conflict
+	}
+
+	if shared.PathExists(fmt.Sprintf("/sys/class/net/%s", req.Name)) {
+		return BadRequest(fmt.Errorf("The network already exists"))
+	}
+
+	// Create the database entry
+	_, err = d.db.NetworkCreate(req.Name, req.Description, req.Config)
+	if err != nil {
+		return SmartError(
+			fmt.Errorf("Error inserting %s into database: %s", req.Name, err))
+	}
+
+	// Bring the network up
+	n, err := networkLoadByName(d.State(), req.Name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = n.Start()
+	if err != nil {
+		n.Delete()
+		return SmartError(err)
+	}
+
+	err = networkUpdateStatic(d.State(), req.Name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	eventSendLifecycle("network-created", fmt.Sprintf("/%s/networks/%s", version.APIVersion, req.Name), nil, eventRequestor(r))
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/networks/%s", version.APIVersion, req.Name))
+}
+
+var networksCmd = Command{name: "networks", get: networksGet, post: networksPost}
 
 func networkGet(d *Daemon, r *http.Request) Response {
 	name := mux.Vars(r)["name"]
 
 	n, err := doNetworkGet(d, name)
 	if err != nil {
-		return InternalError(err)
+		return SmartError(err)
 	}
 
 	return SyncResponse(true, &n)
 }
 
 func doNetworkGet(d *Daemon, name string) (api.Network, error) {
-	iface, err := net.InterfaceByName(name)
-	if err != nil {
-		return api.Network{}, err
-	}
-
 	// Prepare the response
 	n := api.Network{}
-	n.Name = iface.Name
+	n.Name = name
 	n.UsedBy = []string{}
+	n.Config = map[string]string{}
+
+	_, dbInfo, err := d.db.NetworkGet(name)
+	if err == nil {
+		n = *dbInfo
+	} else if err != db.NoSuchObjectError {
+		return api.Network{}, err
+	}
+
+	iface, err := net.InterfaceByName(name)
+	if err != nil && !n.Managed {
+		return api.Network{}, db.NoSuchObjectError
+	}
 
 	// Look for containers using the interface
 	cts, err := d.db.ContainersList(db.CTypeRegular)
@@ -114,7 +198,9 @@ func doNetworkGet(d *Daemon, name string) (api.Network, error) {
 	}
 
 	// Set the device type as needed
-	if shared.IsLoopback(iface) {
+	if n.Managed {
+		n.Type = "bridge"
+	} else if shared.IsLoopback(iface) {
 		n.Type = "loopback"
 	} else if shared.PathExists(fmt.Sprintf("/sys/class/net/%s/bridge", n.Name)) {
 		n.Type = "bridge"
@@ -136,4 +222,194 @@ func doNetworkGet(d *Daemon, name string) (api.Network, error) {
 	return n, nil
 }
 
-var networkCmd = Command{name: "networks/{name}", get: networkGet}
+func networkPut(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	n, err := networkLoadByName(d.State(), name)
+	if err != nil {
+		if err == db.NoSuchObjectError {
+			return BadRequest(fmt.Errorf("Only managed networks can be modified"))
+		}
+
+		return SmartError(err)
+	}
+
+	req := api.NetworkPut{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	err = networkValidateConfig(name, req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = n.Update(req)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	eventSendLifecycle("network-updated", fmt.Sprintf("/%s/networks/%s", version.APIVersion, name), nil, eventRequestor(r))
+
+	return EmptySyncResponse
+}
+
+func networkPost(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	req := api.NetworkPost{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	err := networkValidName(req.Name)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	n, err := networkLoadByName(d.State(), name)
+	if err != nil {
+		if err == db.NoSuchObjectError {
+			return BadRequest(fmt.Errorf("Only managed networks can be renamed"))
+		}
+
+		return SmartError(err)
+	}
+
+	info, err := doNetworkGet(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if len(info.UsedBy) != 0 {
+		return BadRequest(fmt.Errorf("The network is currently in use"))
+	}
+
+	_, _, err = d.db.NetworkGet(req.Name)
+	if err == nil {
+		return Conflict
+	}
+
+	if shared.PathExists(fmt.Sprintf("/sys/class/net/%s", req.Name)) {
+		return BadRequest(fmt.Errorf("The network already exists"))
+	}
+
+	err = n.Rename(req.Name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	eventSendLifecycle("network-renamed", fmt.Sprintf("/%s/networks/%s", version.APIVersion, name),
+		map[string]interface{}{"new_name": req.Name}, eventRequestor(r))
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/networks/%s", version.APIVersion, req.Name))
+}
+
+func networkDelete(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	n, err := networkLoadByName(d.State(), name)
+	if err != nil {
+		if err == db.NoSuchObjectError {
+			return BadRequest(fmt.Errorf("Only managed networks can be removed"))
+		}
+
+		return SmartError(err)
+	}
+
+	info, err := doNetworkGet(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if len(info.UsedBy) != 0 {
+		return BadRequest(fmt.Errorf("The network is currently in use"))
+	}
+
+	err = n.Delete()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	eventSendLifecycle("network-deleted", fmt.Sprintf("/%s/networks/%s", version.APIVersion, name), nil, eventRequestor(r))
+
+	return EmptySyncResponse
+}
+
+var networkCmd = Command{name: "networks/{name}", get: networkGet, put: networkPut, post: networkPost, delete: networkDelete}
+
+// /1.0/networks/{name}/leases
+func networkLeasesGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	_, _, err := d.db.NetworkGet(name)
+	if err != nil {
+		if err == db.NoSuchObjectError {
+			return BadRequest(fmt.Errorf("Leases are only available for managed networks"))
+		}
+
+		return SmartError(err)
+	}
+
+	leases := []api.NetworkLease{}
+	hostnames := map[string]string{}
+
+	// Static reservations of the containers attached to the network
+	hosts, err := networkStaticHosts(d.State(), name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	for _, host := range hosts {
+		if host.hwaddr != "" {
+			hostnames[host.hwaddr] = host.container
+		}
+
+		for _, key := range []string{"ipv4.address", "ipv6.address"} {
+			if host.device[key] == "" {
+				continue
+			}
+
+			leases = append(leases, api.NetworkLease{
+				Hostname: host.container,
+				Hwaddr:   host.hwaddr,
+				Address:  host.device[key],
+				Type:     "static",
+			})
+		}
+	}
+
+	// Dynamic leases handed out by dnsmasq
+	dynamic, err := networkLeasesRead(name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	for _, lease := range dynamic {
+		static := false
+		for _, existing := range leases {
+			if existing.Address == lease.Address {
+				static = true
+				break
+			}
+		}
+
+		if static {
+			continue
+		}
+
+		if hostnames[lease.Hwaddr] != "" {
+			lease.Hostname = hostnames[lease.Hwaddr]
+		}
+
+		leases = append(leases, lease)
+	}
+
+	return SyncResponse(true, leases)
+}
+
+var networkLeasesCmd = Command{name: "networks/{name}/leases", get: networkLeasesGet}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+)
+
+var networkConfigKeys = map[string]func(value string) error{
+	"ipv4.address": func(value string) error {
+		return networkValidAddressCIDR(value, 4)
+	},
+	"ipv4.nat": networkValidBool,
+	"ipv4.dhcp.ranges": func(value string) error {
+		return networkValidRanges(value, 4)
+	},
+
+	"ipv6.address": func(value string) error {
+		return networkValidAddressCIDR(value, 6)
+	},
+	"ipv6.nat": networkValidBool,
+
+	"dns.domain": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		if strings.ContainsAny(value, " /,") {
+			return fmt.Errorf("Invalid domain: %s", value)
+		}
+
+		return nil
+	},
+}
+
+// networkValidateConfig checks that the given configuration is valid for a
+// managed bridge.
+func networkValidateConfig(name string, config map[string]string) error {
+	for key, value := range config {
+		if strings.HasPrefix(key, "user.") {
+			continue
+		}
+
+		validator, ok := networkConfigKeys[key]
+		if !ok {
+			return fmt.Errorf("Invalid network configuration key: %s", key)
+		}
+
+		err := validator(value)
+		if err != nil {
+			return fmt.Errorf("Invalid value for %s: %v", key, err)
+		}
+	}
+
+	// The DHCP ranges must be within the bridge subnet, without the bridge
+	// address
+	if config["ipv4.dhcp.ranges"] != "" {
+		bridge, subnet, err := net.ParseCIDR(config["ipv4.address"])
+		if err != nil {
+			return fmt.Errorf("ipv4.dhcp.ranges requires ipv4.address to be set")
+		}
+
+		for _, r := range strings.Split(config["ipv4.dhcp.ranges"], ",") {
+			fields := strings.SplitN(strings.TrimSpace(r), "-", 2)
+			for _, ip := range fields {
+				if !subnet.Contains(net.ParseIP(ip)) {
+					return fmt.Errorf("The DHCP range %s isn't within %s", r, subnet.String())
+				}
+			}
+
+			if networkRangeContains(fields[0], fields[1], bridge) {
+				return fmt.Errorf("The DHCP range %s includes the bridge address %s", r, bridge.String())
+			}
+		}
+	}
+
+	return nil
+}
+
+// networkValidName checks that the name can be used for a bridge interface.
+func networkValidName(name string) error {
+	if name == "" {
+		return fmt.Errorf("No name provided")
+	}
+
+	// Linux limits interface names to 15 characters
+	if len(name) > 15 {
+		return fmt.Errorf("Network name must be 15 characters or less")
+	}
+
+	if name == "." || name == ".." || strings.ContainsAny(name, "/: \t\n") {
+		return fmt.Errorf("Invalid network name '%s'", name)
+	}
+
+	return nil
+}
+
+func networkValidBool(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if !shared.StringInSlice(strings.ToLower(value), []string{"true", "false", "1", "0", "yes", "no", "on", "off"}) {
+		return fmt.Errorf("Invalid value for a boolean: %s", value)
+	}
+
+	return nil
+}
+
+// networkValidAddressCIDR checks for a host address with its prefix length,
+// like 10.0.3.1/24, or "none".
+func networkValidAddressCIDR(value string, family int) error {
+	if value == "" || value == "none" {
+		return nil
+	}
+
+	ip, subnet, err := net.ParseCIDR(value)
+	if err != nil {
+		return err
+	}
+
+	if (family == 4) != (ip.To4() != nil) {
+		return fmt.Errorf("Not an IPv%d address: %s", family, value)
+	}
+
+	if ip.Equal(subnet.IP) {
+		return fmt.Errorf("Not a usable IP address: %s", value)
+	}
+
+	// Leave room for at least one DHCP client next to the bridge
+	ones, _ := subnet.Mask.Size()
+	if family == 4 && ones > 30 {
+		return fmt.Errorf("Subnet too small, the prefix length must be 30 or less: %s", value)
+	}
+
+	if family == 4 && ip.To4().Equal(networkBroadcast(subnet)) {
+		return fmt.Errorf("Not a usable IP address: %s", value)
+	}
+
+	return nil
+}
+
+// networkBroadcast returns the broadcast address of the given IPv4 subnet.
+func networkBroadcast(subnet *net.IPNet) net.IP {
+	broadcast := make(net.IP, 4)
+	for i, b := range subnet.IP.To4() {
+		broadcast[i] = b | ^subnet.Mask[len(subnet.Mask)-4+i]
+	}
+
+	return broadcast
+}
+
+// networkRangeContains checks whether the IP is within the start-end range.
+func networkRangeContains(start string, end string, ip net.IP) bool {
+	first := net.ParseIP(start).To16()
+	last := net.ParseIP(end).To16()
+	addr := ip.To16()
+
+	return bytes.Compare(addr, first) >= 0 && bytes.Compare(addr, last) <= 0
+}
+
+// networkValidRanges checks a comma separated list of "<start>-<end>" IP
+// ranges.
+func networkValidRanges(value string, family int) error {
+	if value == "" {
+		return nil
+	}
+
+	for _, r := range strings.Split(value, ",") {
+		fields := strings.SplitN(strings.TrimSpace(r), "-", 2)
+		if len(fields) != 2 {
+			return fmt.Errorf("Invalid IP range: %s", r)
+		}
+
+		for _, field := range fields {
+			ip := net.ParseIP(field)
+			if ip == nil || (family == 4) != (ip.To4() != nil) {
+				return fmt.Errorf("Invalid IP range: %s", r)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Bridge configuration is validated key by key, and the DHCP ranges must fit
+// in the bridge subnet.
+func TestNetworkValidateConfig(t *testing.T) {
+	cases := []struct {
+		config map[string]string
+		valid  bool
+	}{
+		{map[string]string{"ipv4.address": "10.0.3.1/24", "ipv4.nat": "true"}, true},
+		{map[string]string{"ipv4.address": "none", "ipv6.address": "fd42::1/64"}, true},
+		{map[string]string{"ipv4.address": "10.0.3.1"}, false},
+		{map[string]string{"ipv4.address": "10.0.3.0/24"}, false},
+		{map[string]string{"ipv4.address": "fd42::1/64"}, false},
+		{map[string]string{"ipv6.nat": "maybe"}, false},
+		{map[string]string{"ipv4.address": "10.0.3.1/24", "ipv4.dhcp.ranges": "10.0.3.10-10.0.3.20"}, true},
+		{map[string]string{"ipv4.address": "10.0.3.1/24", "ipv4.dhcp.ranges": "10.0.4.10-10.0.4.20"}, false},
+		{map[string]string{"ipv4.address": "10.0.3.15/24", "ipv4.dhcp.ranges": "10.0.3.10-10.0.3.20"}, false},
+		{map[string]string{"ipv4.address": "10.0.3.1/31"}, false},
+		{map[string]string{"ipv4.address": "10.0.3.1/32"}, false},
+		{map[string]string{"ipv4.address": "10.0.3.255/24"}, false},
+		{map[string]string{"ipv4.address": "10.0.3.1/30"}, true},
+		{map[string]string{"ipv4.dhcp.ranges": "10.0.3.10-10.0.3.20"}, false},
+		{map[string]string{"dns.domain": "lxd"}, true},
+		{map[string]string{"user.foo": "bar"}, true},
+		{map[string]string{"foo": "bar"}, false},
+	}
+
+	for _, c := range cases {
+		err := networkValidateConfig("lxdbr0", c.config)
+		assert.Equal(t, c.valid, err == nil, "%v: %v", c.config, err)
+	}
+}
+
+// The default DHCP range leaves out the network, bridge and broadcast
+// addresses.
+func TestNetworkDHCPRange(t *testing.T) {
+	cases := map[string]string{
+		"10.0.3.1/24":   "10.0.3.2-10.0.3.254",
+		"10.0.3.254/24": "10.0.3.1-10.0.3.253",
+		"10.0.3.100/24": "10.0.3.1-10.0.3.99,10.0.3.101-10.0.3.254",
+		"10.0.3.1/30":   "10.0.3.2-10.0.3.2",
+	}
+
+	for address, expected := range cases {
+		ip, subnet, _ := net.ParseCIDR(address)
+		assert.Equal(t, expected, networkDHCPRange(ip, subnet), address)
+	}
+}
+
+// Rules printed by iptables are split into arguments, keeping quoted
+// comments whole.
+func TestNetworkIptablesSplit(t *testing.T) {
+	line := `-A INPUT -i lxdbr0 -p tcp -m tcp --dport 53 -m comment --comment "generated for LXD network lxdbr0" -j ACCEPT`
+	expected := []string{"-A", "INPUT", "-i", "lxdbr0", "-p", "tcp", "-m", "tcp", "--dport", "53",
+		"-m", "comment", "--comment", "generated for LXD network lxdbr0", "-j", "ACCEPT"}
+	assert.Equal(t, expected, networkIptablesSplit(line))
+}
+
+// The dnsmasq lease file is parsed into dynamic leases, IPv6 ones being
+// keyed on their IAID rather than a MAC address.
+func TestNetworkLeasesRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-network-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	os.Setenv("LXD_DIR", dir)
+	defer os.Unsetenv("LXD_DIR")
+
+	leases, err := networkLeasesRead("lxdbr0")
+	require.NoError(t, err)
+	assert.Equal(t, []api.NetworkLease{}, leases)
+
+	content := `1520000000 00:16:3e:3a:fa:8b 10.0.3.187 c2 01:00:16:3e:3a:fa:8b
+1520000000 00:16:3e:c4:ed:25 10.0.3.12 * *
+duid 00:01:00:01:22:2a:d1:2c:00:16:3e:00:00:01
+1520000000 1065016091 fd42::6b c2 00:01:00:01:22:2a:d1:2c:00:16:3e:3a:fa:8b
+`
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "networks", "lxdbr0"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "networks", "lxdbr0", "dnsmasq.leases"), []byte(content), 0644))
+
+	leases, err = networkLeasesRead("lxdbr0")
+	require.NoError(t, err)
+	assert.Equal(t, []api.NetworkLease{
+		{Hostname: "c2", Hwaddr: "00:16:3e:3a:fa:8b", Address: "10.0.3.187", Type: "dynamic"},
+		{Hostname: "", Hwaddr: "00:16:3e:c4:ed:25", Address: "10.0.3.12", Type: "dynamic"},
+		{Hostname: "c2", Hwaddr: "", Address: "fd42::6b", Type: "dynamic"},
+	}, leases)
+}
+
+// Static addresses must be hosts of the bridge subnet, other than the bridge
+// itself, and IPv4 ones must be within the DHCP ranges.
+func TestNetworkStaticAddressCheck(t *testing.T) {
+	config := map[string]string{
+		"ipv4.address":     "10.0.3.1/24",
+		"ipv4.dhcp.ranges": "10.0.3.100-10.0.3.200",
+		"ipv6.address":     "fd42::1/64",
+	}
+
+	cases := map[string]bool{
+		"10.0.3.150": true,
+		"10.0.3.50":  false,
+		"10.0.4.150": false,
+		"10.0.3.1":   false,
+		"fd42::10":   true,
+		"fd42::1":    false,
+		"fd43::10":   false,
+		"garbage":    false,
+	}
+
+	for address, valid := range cases {
+		err := networkStaticAddressCheck(config, address)
+		if valid {
+			assert.NoError(t, err, address)
+		} else {
+			assert.Error(t, err, address)
+		}
+	}
+
+	// Without explicit ranges, the whole subnet but the bridge is available
+	delete(config, "ipv4.dhcp.ranges")
+	assert.NoError(t, networkStaticAddressCheck(config, "10.0.3.50"))
+	assert.Error(t, networkStaticAddressCheck(map[string]string{}, "10.0.3.50"))
+}
+
+// A static address belongs to the first nic which has it, whatever its
+// notation.
+func TestNetworkStaticAddressUser(t *testing.T) {
+	hosts := []networkStaticHost{
+		{container: "c1", device: types.Device{"ipv4.address": "10.0.3.100"}},
+		{container: "c2", device: types.Device{"ipv6.address": "fd42::10"}},
+	}
+
+	assert.Equal(t, "c1", networkStaticAddressUser(hosts, "ipv4.address", "10.0.3.100"))
+	assert.Equal(t, "c2", networkStaticAddressUser(hosts, "ipv6.address", "fd42:0::10"))
+	assert.Equal(t, "", networkStaticAddressUser(hosts, "ipv4.address", "10.0.3.101"))
+	assert.Equal(t, "", networkStaticAddressUser(hosts, "ipv6.address", "10.0.3.100"))
+	assert.Equal(t, "", networkStaticAddressUser(hosts, "ipv4.address", "garbage"))
+}
@@ -0,0 +1,924 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// A managed bridge network, as stored in the database.
+type network struct {
+	state       *state.State
+	id          int64
+	name        string
+	description string
+	config      map[string]string
+}
+
+func networkLoadByName(s *state.State, name string) (*network, error) {
+	id, dbInfo, err := s.DB.NetworkGet(name)
+	if err != nil {
+		return nil, err
+	}
+
+	n := network{
+		state:       s,
+		id:          id,
+		name:        name,
+		description: dbInfo.Description,
+		config:      dbInfo.Config,
+	}
+
+	return &n, nil
+}
+
+// networkStartup brings up all the managed networks, it's called when the
+// daemon starts.
+func networkStartup(s *state.State) {
+	networks, err := s.DB.Networks()
+	if err != nil {
+		logger.Error("Failed to list networks", log.Ctx{"err": err})
+		return
+	}
+
+	for _, name := range networks {
+		n, err := networkLoadByName(s, name)
+		if err != nil {
+			logger.Error("Failed to load network", log.Ctx{"network": name, "err": err})
+			continue
+		}
+
+		err = n.Start()
+		if err != nil {
+			logger.Error("Failed to bring network up", log.Ctx{"network": name, "err": err})
+		}
+	}
+}
+
+// IsRunning returns whether the bridge interface currently exists.
+func (n *network) IsRunning() bool {
+	return shared.PathExists(fmt.Sprintf("/sys/class/net/%s", n.name))
+}
+
+// Start creates the bridge if needed and (re-)applies its addresses,
+// firewall rules and DHCP/DNS server.
+func (n *network) Start() error {
+	if n.state.OS.MockMode {
+		return nil
+	}
+
+	err := os.MkdirAll(shared.VarPath("networks", n.name), 0711)
+	if err != nil {
+		return err
+	}
+
+	if !n.IsRunning() {
+		_, err := shared.RunCommand("ip", "link", "add", "dev", n.name, "type", "bridge")
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = shared.RunCommand("ip", "link", "set", "dev", n.name, "up")
+	if err != nil {
+		return err
+	}
+
+	// Start from a clean state
+	networkDnsmasqStop(n.name)
+
+	for _, protocol := range []string{"ipv4", "ipv6"} {
+		err = networkIptablesClear(protocol, n.name)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = shared.RunCommand("ip", "-4", "addr", "flush", "dev", n.name)
+	if err != nil {
+		return err
+	}
+
+	_, err = shared.RunCommand("ip", "-6", "addr", "flush", "dev", n.name, "scope", "global")
+	if err != nil {
+		return err
+	}
+
+	dnsmasqArgs := []string{
+		"--strict-order",
+		"--bind-interfaces",
+		"--except-interface=lo",
+		fmt.Sprintf("--interface=%s", n.name),
+		"--dhcp-no-override",
+		"--dhcp-authoritative",
+		fmt.Sprintf("--dhcp-leasefile=%s", shared.VarPath("networks", n.name, "dnsmasq.leases")),
+		fmt.Sprintf("--dhcp-hostsdir=%s", shared.VarPath("networks", n.name, "dnsmasq.hosts")),
+	}
+
+	err = os.MkdirAll(shared.VarPath("networks", n.name, "dnsmasq.hosts"), 0755)
+	if err != nil {
+		return err
+	}
+
+	hasAddress := false
+
+	// IPv4
+	if n.config["ipv4.address"] != "" && n.config["ipv4.address"] != "none" {
+		ip, subnet, err := net.ParseCIDR(n.config["ipv4.address"])
+		if err != nil {
+			return err
+		}
+
+		_, err = shared.RunCommand("ip", "-4", "addr", "add", "dev", n.name, n.config["ipv4.address"])
+		if err != nil {
+			return err
+		}
+
+		err = networkSysctlSet("ipv4/ip_forward", "1")
+		if err != nil {
+			return err
+		}
+
+		err = networkSetupFirewall("ipv4", n.name, subnet, "67", shared.IsTrue(n.config["ipv4.nat"]))
+		if err != nil {
+			return err
+		}
+
+		dnsmasqArgs = append(dnsmasqArgs, fmt.Sprintf("--listen-address=%s", ip.String()))
+
+		ranges := n.config["ipv4.dhcp.ranges"]
+		if ranges == "" {
+			ranges = networkDHCPRange(ip, subnet)
+		}
+
+		for _, r := range strings.Split(ranges, ",") {
+			r = strings.Replace(strings.TrimSpace(r), "-", ",", 1)
+			dnsmasqArgs = append(dnsmasqArgs, "--dhcp-range", r)
+		}
+
+		hasAddress = true
+	}
+
+	// IPv6
+	if n.config["ipv6.address"] != "" && n.config["ipv6.address"] != "none" {
+		ip, subnet, err := net.ParseCIDR(n.config["ipv6.address"])
+		if err != nil {
+			return err
+		}
+
+		_, err = shared.RunCommand("ip", "-6", "addr", "add", "dev", n.name, n.config["ipv6.address"])
+		if err != nil {
+			return err
+		}
+
+		err = networkSysctlSet("ipv6/conf/all/forwarding", "1")
+		if err != nil {
+			return err
+		}
+
+		err = networkSetupFirewall("ipv6", n.name, subnet, "547", shared.IsTrue(n.config["ipv6.nat"]))
+		if err != nil {
+			return err
+		}
+
+		dnsmasqArgs = append(dnsmasqArgs,
+			fmt.Sprintf("--listen-address=%s", ip.String()),
+			"--enable-ra",
+			"--dhcp-range", fmt.Sprintf("::,constructor:%s,ra-stateless,ra-names", n.name),
+			"--dhcp-range", fmt.Sprintf("::,constructor:%s,static", n.name))
+
+		hasAddress = true
+	}
+
+	if !hasAddress {
+		return nil
+	}
+
+	if n.config["dns.domain"] != "" {
+		dnsmasqArgs = append(dnsmasqArgs,
+			"-s", n.config["dns.domain"],
+			"-S", fmt.Sprintf("/%s/", n.config["dns.domain"]))
+	}
+
+	return networkDnsmasqStart(n.name, dnsmasqArgs)
+}
+
+// Stop tears down the bridge along with its firewall rules and DHCP/DNS
+// server.
+func (n *network) Stop() error {
+	if n.state.OS.MockMode {
+		return nil
+	}
+
+	networkDnsmasqStop(n.name)
+
+	for _, protocol := range []string{"ipv4", "ipv6"} {
+		err := networkIptablesClear(protocol, n.name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if n.IsRunning() {
+		_, err := shared.RunCommand("ip", "link", "del", "dev", n.name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Update stores the new configuration and re-applies it to the bridge.
+func (n *network) Update(newNetwork api.NetworkPut) error {
+	err := n.state.DB.NetworkUpdate(n.name, newNetwork.Description, newNetwork.Config)
+	if err != nil {
+		return err
+	}
+
+	n.description = newNetwork.Description
+	n.config = newNetwork.Config
+
+	return n.Start()
+}
+
+// Rename tears the bridge down and brings it back up under its new name.
+func (n *network) Rename(newName string) error {
+	err := n.Stop()
+	if err != nil {
+		return err
+	}
+
+	if shared.PathExists(shared.VarPath("networks", newName)) {
+		os.RemoveAll(shared.VarPath("networks", newName))
+	}
+
+	if shared.PathExists(shared.VarPath("networks", n.name)) {
+		err = os.Rename(shared.VarPath("networks", n.name), shared.VarPath("networks", newName))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = n.state.DB.NetworkRename(n.name, newName)
+	if err != nil {
+		return err
+	}
+
+	n.name = newName
+
+	return n.Start()
+}
+
+// Delete tears the bridge down and removes its on-disk state.
+func (n *network) Delete() error {
+	err := n.Stop()
+	if err != nil {
+		return err
+	}
+
+	os.RemoveAll(shared.VarPath("networks", n.name))
+
+	return n.state.DB.NetworkDelete(n.name)
+}
+
+// networkDHCPRange returns the default DHCP ranges for the given IPv4
+// subnet, covering all its host addresses but the bridge's own one.
+func networkDHCPRange(bridge net.IP, subnet *net.IPNet) string {
+	start := binary.BigEndian.Uint32(subnet.IP.To4())
+	size := ^binary.BigEndian.Uint32(net.IP(subnet.Mask).To4())
+	own := binary.BigEndian.Uint32(bridge.To4())
+
+	ip := func(value uint32) string {
+		addr := make(net.IP, 4)
+		binary.BigEndian.PutUint32(addr, value)
+		return addr.String()
+	}
+
+	ranges := []string{}
+	if own > start+1 {
+		ranges = append(ranges, fmt.Sprintf("%s-%s", ip(start+1), ip(own-1)))
+	}
+
+	if own < start+size-1 {
+		ranges = append(ranges, fmt.Sprintf("%s-%s", ip(own+1), ip(start+size-1)))
+	}
+
+	return strings.Join(ranges, ",")
+}
+
+func networkSysctlSet(path string, value string) error {
+	content, err := ioutil.ReadFile(fmt.Sprintf("/proc/sys/net/%s", path))
+	if err == nil && strings.TrimSpace(string(content)) == value {
+		return nil
+	}
+
+	return ioutil.WriteFile(fmt.Sprintf("/proc/sys/net/%s", path), []byte(value), 0)
+}
+
+// Firewall handling
+func networkSetupFirewall(protocol string, name string, subnet *net.IPNet, dhcpPort string, nat bool) error {
+	rules := [][]string{
+		{"filter", "INPUT", "-i", name, "-p", "udp", "--dport", dhcpPort, "-j", "ACCEPT"},
+		{"filter", "INPUT", "-i", name, "-p", "udp", "--dport", "53", "-j", "ACCEPT"},
+		{"filter", "INPUT", "-i", name, "-p", "tcp", "--dport", "53", "-j", "ACCEPT"},
+		{"filter", "FORWARD", "-i", name, "-j", "ACCEPT"},
+		{"filter", "FORWARD", "-o", name, "-j", "ACCEPT"},
+	}
+
+	if protocol == "ipv4" {
+		rules = append(rules, []string{"mangle", "POSTROUTING", "-o", name, "-p", "udp", "--dport", "68", "-j", "CHECKSUM", "--checksum-fill"})
+	}
+
+	if nat {
+		rules = append(rules, []string{"nat", "POSTROUTING", "-s", subnet.String(), "!", "-d", subnet.String(), "-j", "MASQUERADE"})
+	}
+
+	for _, rule := range rules {
+		err := networkIptablesPrepend(protocol, name, rule[0], rule[1], rule[2:]...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func networkIptablesCommand(protocol string) string {
+	if protocol == "ipv6" {
+		return "ip6tables"
+	}
+
+	return "iptables"
+}
+
+func networkIptablesComment(name string) string {
+	return fmt.Sprintf("generated for LXD network %s", name)
+}
+
+// networkIptablesPrepend inserts a rule tagged with the network's comment so
+// that it can be found again by networkIptablesClear.
+func networkIptablesPrepend(protocol string, name string, table string, chain string, rule ...string) error {
+	args := []string{"-w", "-t", table, "-I", chain}
+	args = append(args, rule...)
+	args = append(args, "-m", "comment", "--comment", networkIptablesComment(name))
+
+	_, err := shared.RunCommand(networkIptablesCommand(protocol), args...)
+	return err
+}
+
+// networkIptablesClear removes all the rules previously added for the
+// network.
+func networkIptablesClear(protocol string, name string) error {
+	command := networkIptablesCommand(protocol)
+
+	// Nothing to clear if the tool isn't available
+	_, err := exec.LookPath(command)
+	if err != nil {
+		return nil
+	}
+
+	comment := fmt.Sprintf("\"%s\"", networkIptablesComment(name))
+	for _, table := range []string{"filter", "mangle", "nat"} {
+		output, err := shared.RunCommand(command, "-w", "-t", table, "-S")
+		if err != nil {
+			// The table may not be supported by the kernel
+			continue
+		}
+
+		for _, line := range strings.Split(output, "\n") {
+			if !strings.HasPrefix(line, "-A ") || !strings.Contains(line, comment) {
+				continue
+			}
+
+			args := []string{"-w", "-t", table, "-D"}
+			args = append(args, networkIptablesSplit(line)[1:]...)
+
+			_, err = shared.RunCommand(command, args...)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// networkIptablesSplit splits a rule as printed by "iptables -S" into its
+// arguments, honoring double quotes.
+func networkIptablesSplit(line string) []string {
+	fields := []string{}
+	field := ""
+	quoted := false
+	inField := false
+
+	for _, c := range strings.TrimSpace(line) {
+		switch {
+		case c == '"':
+			quoted = !quoted
+			inField = true
+		case c == ' ' && !quoted:
+			if inField {
+				fields = append(fields, field)
+				field = ""
+				inField = false
+			}
+		default:
+			field += string(c)
+			inField = true
+		}
+	}
+
+	if inField {
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// Static DHCP host entries
+var networkStaticLock sync.Mutex
+
+// networkStaticHost is a bridged nic attached to a managed network, as
+// recorded in its container's database entry.
+type networkStaticHost struct {
+	container string
+	hwaddr    string
+	device    types.Device
+}
+
+// networkStaticHosts returns the bridged nics attached to the given network.
+// Containers whose database entry can't be read are skipped.
+func networkStaticHosts(s *state.State, networkName string) ([]networkStaticHost, error) {
+	cts, err := s.DB.ContainersList(db.CTypeRegular)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := []networkStaticHost{}
+	for _, ct := range cts {
+		args, err := s.DB.ContainerGet(ct)
+		if err != nil {
+			logger.Warn("Failed to load container", log.Ctx{"name": ct, "err": err})
+			continue
+		}
+
+		devices, err := networkExpandDevices(s, args)
+		if err != nil {
+			logger.Warn("Failed to load container devices", log.Ctx{"name": ct, "err": err})
+			continue
+		}
+
+		for _, name := range devices.DeviceNames() {
+			m := devices[name]
+			if m["type"] != "nic" || m["nictype"] != "bridged" || m["parent"] != networkName {
+				continue
+			}
+
+			hosts = append(hosts, networkStaticHost{
+				container: ct,
+				hwaddr:    networkDeviceHwaddr(args.Config, name, m),
+				device:    m,
+			})
+		}
+	}
+
+	return hosts, nil
+}
+
+// networkExpandDevices applies the profiles of the container to its local
+// devices, the same way the container does when loaded.
+func networkExpandDevices(s *state.State, args db.ContainerArgs) (types.Devices, error) {
+	devices := types.Devices{}
+
+	for _, p := range args.Profiles {
+		profileDevices, err := s.DB.Devices(p, true)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range profileDevices {
+			devices[k] = v
+		}
+	}
+
+	for k, v := range args.Devices {
+		devices[k] = v
+	}
+
+	return devices, nil
+}
+
+// networkDeviceHwaddr returns the MAC address of the container's nic, either
+// set on the device or generated by LXD.
+func networkDeviceHwaddr(config map[string]string, name string, m types.Device) string {
+	if m["hwaddr"] != "" {
+		return m["hwaddr"]
+	}
+
+	return config[fmt.Sprintf("volatile.%s.hwaddr", name)]
+}
+
+// networkUpdateStatic regenerates the static DHCP host entries of the given
+// network from the containers attached to it. Unmanaged networks are skipped.
+func networkUpdateStatic(s *state.State, networkName string) error {
+	networkStaticLock.Lock()
+	defer networkStaticLock.Unlock()
+
+	_, _, err := s.DB.NetworkGet(networkName)
+	if err == db.NoSuchObjectError {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	hosts, err := networkStaticHosts(s, networkName)
+	if err != nil {
+		return err
+	}
+
+	// One dnsmasq host entry per nic, grouped by container
+	entries := map[string][]string{}
+	for _, host := range hosts {
+		// The MAC address is only generated on first start
+		if host.hwaddr == "" {
+			continue
+		}
+
+		fields := []string{host.hwaddr}
+		if host.device["ipv4.address"] != "" {
+			fields = append(fields, host.device["ipv4.address"])
+		}
+
+		if host.device["ipv6.address"] != "" {
+			fields = append(fields, fmt.Sprintf("[%s]", host.device["ipv6.address"]))
+		}
+
+		fields = append(fields, host.container)
+		entries[host.container] = append(entries[host.container], strings.Join(fields, ","))
+	}
+
+	path := shared.VarPath("networks", networkName, "dnsmasq.hosts")
+	err = os.MkdirAll(path, 0755)
+	if err != nil {
+		return err
+	}
+
+	for name, lines := range entries {
+		content := strings.Join(lines, "\n") + "\n"
+		current, err := ioutil.ReadFile(filepath.Join(path, name))
+		if err != nil || string(current) != content {
+			err = ioutil.WriteFile(filepath.Join(path, name), []byte(content), 0644)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Remove the entries of containers which went away
+	existing, err := ioutil.ReadDir(path)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range existing {
+		if entries[f.Name()] == nil {
+			os.Remove(filepath.Join(path, f.Name()))
+		}
+	}
+
+	networkDnsmasqReload(networkName)
+
+	return nil
+}
+
+// networkUpdateStaticDevices regenerates the static DHCP host entries of the
+// networks the bridged nics in the given devices are attached to.
+func networkUpdateStaticDevices(s *state.State, devices ...types.Devices) error {
+	networks := []string{}
+	for _, d := range devices {
+		for _, m := range d {
+			if m["type"] != "nic" || m["nictype"] != "bridged" || shared.StringInSlice(m["parent"], networks) {
+				continue
+			}
+
+			networks = append(networks, m["parent"])
+		}
+	}
+
+	for _, name := range networks {
+		err := networkUpdateStatic(s, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// networkDevicesCheck makes sure that the static addresses of the bridged nics
+// in the given devices fit the managed networks they're attached to, and
+// aren't used by another nic on the same network.
+func networkDevicesCheck(s *state.State, container string, devices types.Devices) error {
+	// Static addresses in use, by network
+	inUse := map[string][]networkStaticHost{}
+
+	for _, name := range devices.DeviceNames() {
+		m := devices[name]
+		if m["type"] != "nic" || m["nictype"] != "bridged" {
+			continue
+		}
+
+		if m["ipv4.address"] == "" && m["ipv6.address"] == "" {
+			continue
+		}
+
+		// Unmanaged bridges don't hand out addresses
+		_, network, err := s.DB.NetworkGet(m["parent"])
+		if err == db.NoSuchObjectError {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		for _, key := range []string{"ipv4.address", "ipv6.address"} {
+			if m[key] == "" {
+				continue
+			}
+
+			err := networkStaticAddressCheck(network.Config, m[key])
+			if err != nil {
+				return fmt.Errorf("Invalid %s for device '%s': %v", key, name, err)
+			}
+		}
+
+		hosts, ok := inUse[m["parent"]]
+		if !ok {
+			all, err := networkStaticHosts(s, m["parent"])
+			if err != nil {
+				return err
+			}
+
+			// The container's current nics are replaced by the given ones
+			hosts = []networkStaticHost{}
+			for _, host := range all {
+				if host.container != container {
+					hosts = append(hosts, host)
+				}
+			}
+		}
+
+		for _, key := range []string{"ipv4.address", "ipv6.address"} {
+			if m[key] == "" {
+				continue
+			}
+
+			user := networkStaticAddressUser(hosts, key, m[key])
+			if user != "" {
+				return fmt.Errorf("Invalid %s for device '%s': %s is already used by container '%s'", key, name, m[key], user)
+			}
+		}
+
+		inUse[m["parent"]] = append(hosts, networkStaticHost{container: container, device: m})
+	}
+
+	return nil
+}
+
+// networkStaticAddressUser returns the name of the container whose nic has
+// the given static address, if any.
+func networkStaticAddressUser(hosts []networkStaticHost, key string, address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return ""
+	}
+
+	for _, host := range hosts {
+		if ip.Equal(net.ParseIP(host.device[key])) {
+			return host.container
+		}
+	}
+
+	return ""
+}
+
+// networkStaticAddressCheck checks that a static address is within the
+// network's subnet, isn't the bridge's own address and, for IPv4, is within
+// the DHCP ranges.
+func networkStaticAddressCheck(config map[string]string, address string) error {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return fmt.Errorf("Invalid IP address '%s'", address)
+	}
+
+	key := "ipv6.address"
+	if ip.To4() != nil {
+		key = "ipv4.address"
+	}
+
+	bridge, subnet, err := net.ParseCIDR(config[key])
+	if err != nil {
+		return fmt.Errorf("The network doesn't have an %s", key)
+	}
+
+	if !subnet.Contains(ip) {
+		return fmt.Errorf("%s isn't within %s", address, subnet.String())
+	}
+
+	if ip.Equal(bridge) {
+		return fmt.Errorf("%s is the bridge address", address)
+	}
+
+	if ip.To4() == nil {
+		return nil
+	}
+
+	ranges := config["ipv4.dhcp.ranges"]
+	if ranges == "" {
+		ranges = networkDHCPRange(bridge, subnet)
+	}
+
+	for _, r := range strings.Split(ranges, ",") {
+		fields := strings.SplitN(strings.TrimSpace(r), "-", 2)
+		if len(fields) == 2 && networkRangeContains(fields[0], fields[1], ip) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s isn't within the DHCP ranges %s", address, ranges)
+}
+
+// networkLeasesRead parses the dnsmasq lease file of the given network.
+func networkLeasesRead(name string) ([]api.NetworkLease, error) {
+	leases := []api.NetworkLease{}
+
+	content, err := ioutil.ReadFile(shared.VarPath("networks", name, "dnsmasq.leases"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return leases, nil
+		}
+
+		return nil, err
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		// <expiry> <MAC address or IAID> <IP address> <hostname> <client ID>
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] == "duid" {
+			continue
+		}
+
+		lease := api.NetworkLease{
+			Address: fields[2],
+			Type:    "dynamic",
+		}
+
+		if fields[3] != "*" {
+			lease.Hostname = fields[3]
+		}
+
+		// IPv6 leases are keyed on the IAID rather than the MAC address
+		if strings.Contains(fields[1], ":") {
+			lease.Hwaddr = fields[1]
+		}
+
+		leases = append(leases, lease)
+	}
+
+	return leases, nil
+}
+
+// DHCP/DNS server handling
+var networkDnsmasqLock sync.Mutex
+var networkDnsmasqProcesses = map[string]*exec.Cmd{}
+
+func networkDnsmasqPidPath(name string) string {
+	return shared.VarPath("networks", name, "dnsmasq.pid")
+}
+
+func networkDnsmasqUser() string {
+	for _, name := range []string{"lxd", "dnsmasq"} {
+		_, err := shared.UserId(name)
+		if err == nil {
+			return name
+		}
+	}
+
+	return "nobody"
+}
+
+// networkDnsmasqStart spawns dnsmasq in the foreground and restarts it
+// whenever it dies until networkDnsmasqStop is called.
+func networkDnsmasqStart(name string, args []string) error {
+	networkDnsmasqLock.Lock()
+	defer networkDnsmasqLock.Unlock()
+
+	cmdArgs := []string{"--keep-in-foreground", "--conf-file=/dev/null", "--no-ping",
+		fmt.Sprintf("--user=%s", networkDnsmasqUser())}
+	cmdArgs = append(cmdArgs, args...)
+
+	cmd := exec.Command("dnsmasq", cmdArgs...)
+	err := cmd.Start()
+	if err != nil {
+		return fmt.Errorf("Failed to start dnsmasq: %v", err)
+	}
+
+	err = ioutil.WriteFile(networkDnsmasqPidPath(name), []byte(fmt.Sprintf("%d\n", cmd.Process.Pid)), 0644)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	networkDnsmasqProcesses[name] = cmd
+
+	go func() {
+		err := cmd.Wait()
+
+		networkDnsmasqLock.Lock()
+		current := networkDnsmasqProcesses[name]
+		if current == cmd {
+			delete(networkDnsmasqProcesses, name)
+		}
+		networkDnsmasqLock.Unlock()
+
+		// Stopped on purpose
+		if current != cmd {
+			return
+		}
+
+		logger.Warn("dnsmasq exited unexpectedly, restarting", log.Ctx{"network": name, "err": err})
+		time.Sleep(5 * time.Second)
+
+		// Only restart if nobody started or stopped it in the meantime
+		networkDnsmasqLock.Lock()
+		_, ok := networkDnsmasqProcesses[name]
+		networkDnsmasqLock.Unlock()
+		if ok || !shared.PathExists(networkDnsmasqPidPath(name)) {
+			return
+		}
+
+		err = networkDnsmasqStart(name, args)
+		if err != nil {
+			logger.Error("Failed to restart dnsmasq", log.Ctx{"network": name, "err": err})
+		}
+	}()
+
+	return nil
+}
+
+// networkDnsmasqReload has the network's dnsmasq re-read its static host
+// entries.
+func networkDnsmasqReload(name string) {
+	networkDnsmasqLock.Lock()
+	defer networkDnsmasqLock.Unlock()
+
+	cmd, ok := networkDnsmasqProcesses[name]
+	if ok {
+		cmd.Process.Signal(syscall.SIGHUP)
+	}
+}
+
+// networkDnsmasqStop kills the network's dnsmasq, including one left behind
+// by a previous run of the daemon.
+func networkDnsmasqStop(name string) {
+	networkDnsmasqLock.Lock()
+	defer networkDnsmasqLock.Unlock()
+
+	cmd, ok := networkDnsmasqProcesses[name]
+	if ok {
+		delete(networkDnsmasqProcesses, name)
+		cmd.Process.Kill()
+	} else {
+		content, err := ioutil.ReadFile(networkDnsmasqPidPath(name))
+		if err == nil {
+			pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+			if err == nil && shared.PathExists(fmt.Sprintf("/proc/%d", pid)) {
+				cmdline, _ := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+				if strings.HasPrefix(string(cmdline), "dnsmasq") {
+					syscall.Kill(pid, syscall.SIGKILL)
+				}
+			}
+		}
+	}
+
+	os.Remove(networkDnsmasqPidPath(name))
+}
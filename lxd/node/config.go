@@ -82,6 +82,7 @@ var ConfigSchema = config.Schema{
 	"core.https_allowed_headers":   {},
 	"core.https_allowed_methods":   {},
 	"core.https_allowed_origin":    {},
+	"core.metrics_address":         {},
 	"core.proxy_http":              {},
 	"core.proxy_https":             {},
 	"core.proxy_ignore_hosts":      {},
@@ -87,6 +87,11 @@ func profilesPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(err)
 	}
 
+	err = storagePoolDevicesCheck(d.State(), req.Devices)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	// Update DB entry
 	_, err = d.db.ProfileCreate(req.Name, req.Description, req.Config, req.Devices)
 	if err != nil {
@@ -94,6 +99,8 @@ func profilesPost(d *Daemon, r *http.Request) Response {
 			fmt.Errorf("Error inserting %s into database: %s", req.Name, err))
 	}
 
+	eventSendLifecycle("profile-created", fmt.Sprintf("/%s/profiles/%s", version.APIVersion, req.Name), nil, eventRequestor(r))
+
 	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/profiles/%s", version.APIVersion, req.Name))
 }
 
@@ -151,7 +158,12 @@ func profilePut(d *Daemon, r *http.Request) Response {
 		return BadRequest(err)
 	}
 
-	return doProfileUpdate(d, name, id, profile, req)
+	resp := doProfileUpdate(d, name, id, profile, req)
+	if resp == EmptySyncResponse {
+		eventSendLifecycle("profile-updated", fmt.Sprintf("/%s/profiles/%s", version.APIVersion, name), nil, eventRequestor(r))
+	}
+
+	return resp
 }
 
 // The handler for the post operation.
@@ -187,6 +199,9 @@ func profilePost(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	eventSendLifecycle("profile-renamed", fmt.Sprintf("/%s/profiles/%s", version.APIVersion, name),
+		map[string]interface{}{"new_name": req.Name}, eventRequestor(r))
+
 	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/profiles/%s", version.APIVersion, req.Name))
 }
 
@@ -209,6 +224,8 @@ func profileDelete(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	eventSendLifecycle("profile-deleted", fmt.Sprintf("/%s/profiles/%s", version.APIVersion, name), nil, eventRequestor(r))
+
 	return EmptySyncResponse
 }
 
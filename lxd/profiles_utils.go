@@ -20,6 +20,11 @@ func doProfileUpdate(d *Daemon, name string, id int64, profile *api.Profile, req
 		return BadRequest(err)
 	}
 
+	err = storagePoolDevicesCheck(d.State(), req.Devices)
+	if err != nil {
+		return BadRequest(err)
+	}
+
 	containers := getContainersWithProfile(d.State(), d.Storage, name)
 
 	// Update the database
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/types"
+)
+
+// proxyAddress is a parsed "<protocol>:<address>" proxy endpoint, like
+// tcp:127.0.0.1:80 or unix:/run/app.socket.
+type proxyAddress struct {
+	protocol string
+	address  string
+}
+
+func (a *proxyAddress) String() string {
+	return fmt.Sprintf("%s:%s", a.protocol, a.address)
+}
+
+// proxyParseAddr parses a proxy device listen or connect address.
+func proxyParseAddr(value string) (*proxyAddress, error) {
+	fields := strings.SplitN(value, ":", 2)
+	if len(fields) != 2 || fields[1] == "" {
+		return nil, fmt.Errorf("Invalid proxy address '%s'", value)
+	}
+
+	addr := &proxyAddress{protocol: fields[0], address: fields[1]}
+
+	switch addr.protocol {
+	case "tcp", "udp":
+		host, port, err := net.SplitHostPort(addr.address)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid proxy address '%s': %v", value, err)
+		}
+
+		if host != "" && net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("Invalid IP address '%s'", host)
+		}
+
+		portNum, err := strconv.ParseUint(port, 10, 16)
+		if err != nil || portNum == 0 {
+			return nil, fmt.Errorf("Invalid port '%s'", port)
+		}
+	case "unix":
+		if !strings.HasPrefix(addr.address, "/") && !strings.HasPrefix(addr.address, "@") {
+			return nil, fmt.Errorf("Unix socket paths must be absolute: %s", addr.address)
+		}
+	default:
+		return nil, fmt.Errorf("Invalid proxy protocol '%s'", addr.protocol)
+	}
+
+	return addr, nil
+}
+
+// proxyValidate checks the listen and connect addresses of a proxy device.
+func proxyValidate(m types.Device) error {
+	if m["listen"] == "" || m["connect"] == "" {
+		return fmt.Errorf("Proxy devices require both the \"listen\" and \"connect\" properties.")
+	}
+
+	listen, err := proxyParseAddr(m["listen"])
+	if err != nil {
+		return err
+	}
+
+	connect, err := proxyParseAddr(m["connect"])
+	if err != nil {
+		return err
+	}
+
+	// Datagrams can't be relayed to or from a stream
+	if (listen.protocol == "udp") != (connect.protocol == "udp") {
+		return fmt.Errorf("UDP can only be proxied to UDP.")
+	}
+
+	return nil
+}
+
+// proxyListen sets up the host side of a proxy device and returns its
+// socket, to be handed over to forkproxy.
+func proxyListen(addr *proxyAddress) (*os.File, error) {
+	if addr.protocol == "udp" {
+		conn, err := net.ListenPacket(addr.protocol, addr.address)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+
+		return conn.(*net.UDPConn).File()
+	}
+
+	// Clear any socket left behind by a previous proxy
+	proxyRemoveSocket(addr)
+
+	listener, err := net.Listen(addr.protocol, addr.address)
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	switch l := listener.(type) {
+	case *net.TCPListener:
+		return l.File()
+	case *net.UnixListener:
+		// The socket must outlive our copy of the listener
+		l.SetUnlinkOnClose(false)
+		return l.File()
+	}
+
+	return nil, fmt.Errorf("Unsupported listener type")
+}
+
+// proxyRemoveSocket removes the unix socket of a proxy address, leaving any
+// other kind of file at that path alone.
+func proxyRemoveSocket(addr *proxyAddress) {
+	if addr.protocol != "unix" || !strings.HasPrefix(addr.address, "/") {
+		return
+	}
+
+	fi, err := os.Lstat(addr.address)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return
+	}
+
+	os.Remove(addr.address)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/types"
+)
+
+// Proxy addresses are made of a protocol and an address for it.
+func TestProxyParseAddr(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected *proxyAddress
+	}{
+		{"tcp:0.0.0.0:8080", &proxyAddress{"tcp", "0.0.0.0:8080"}},
+		{"tcp::8080", &proxyAddress{"tcp", ":8080"}},
+		{"udp:[::1]:53", &proxyAddress{"udp", "[::1]:53"}},
+		{"unix:/run/app.socket", &proxyAddress{"unix", "/run/app.socket"}},
+		{"unix:@app", &proxyAddress{"unix", "@app"}},
+		{"tcp:127.0.0.1", nil},
+		{"tcp:127.0.0.1:0", nil},
+		{"tcp:127.0.0.1:65536", nil},
+		{"tcp:localhost:80", nil},
+		{"unix:app.socket", nil},
+		{"sctp:127.0.0.1:80", nil},
+		{"tcp:", nil},
+		{"127.0.0.1:80", nil},
+	}
+
+	for _, c := range cases {
+		addr, err := proxyParseAddr(c.value)
+		assert.Equal(t, c.expected, addr, "%s: %v", c.value, err)
+	}
+}
+
+// Both ends are required and UDP can't be mixed with streams.
+func TestProxyValidate(t *testing.T) {
+	cases := []struct {
+		device types.Device
+		valid  bool
+	}{
+		{types.Device{"listen": "tcp:0.0.0.0:8080", "connect": "tcp:127.0.0.1:80"}, true},
+		{types.Device{"listen": "unix:/run/app.socket", "connect": "tcp:127.0.0.1:80"}, true},
+		{types.Device{"listen": "udp:0.0.0.0:53", "connect": "udp:127.0.0.1:53"}, true},
+		{types.Device{"listen": "udp:0.0.0.0:53", "connect": "tcp:127.0.0.1:53"}, false},
+		{types.Device{"listen": "unix:/run/app.socket", "connect": "udp:127.0.0.1:53"}, false},
+		{types.Device{"listen": "tcp:0.0.0.0:8080"}, false},
+		{types.Device{"connect": "tcp:127.0.0.1:80"}, false},
+	}
+
+	for _, c := range cases {
+		err := proxyValidate(c.device)
+		assert.Equal(t, c.valid, err == nil, "%v: %v", c.device, err)
+	}
+}
+
+// Only unix sockets are cleared from the listen path, never regular files.
+func TestProxyRemoveSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-proxy-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "file")
+	require.NoError(t, ioutil.WriteFile(path, []byte("data"), 0644))
+	proxyRemoveSocket(&proxyAddress{protocol: "unix", address: path})
+	_, err = os.Lstat(path)
+	assert.NoError(t, err)
+
+	path = filepath.Join(dir, "socket")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+
+	proxyRemoveSocket(&proxyAddress{protocol: "unix", address: path})
+	_, err = os.Lstat(path)
+	assert.True(t, os.IsNotExist(err))
+}
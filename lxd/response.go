@@ -29,6 +29,7 @@ type syncResponse struct {
 	success  bool
 	metadata interface{}
 	location string
+	headers  map[string]string
 }
 
 func (r *syncResponse) Render(w http.ResponseWriter) error {
@@ -37,6 +38,12 @@ func (r *syncResponse) Render(w http.ResponseWriter) error {
 		status = api.Failure
 	}
 
+	if r.headers != nil {
+		for h, v := range r.headers {
+			w.Header().Set(h, v)
+		}
+	}
+
 	if r.location != "" {
 		w.Header().Set("Location", r.location)
 		w.WriteHeader(201)
@@ -68,6 +75,10 @@ func SyncResponseLocation(success bool, metadata interface{}, location string) R
 	return &syncResponse{success: success, metadata: metadata, location: location}
 }
 
+func SyncResponseHeaders(success bool, metadata interface{}, headers map[string]string) Response {
+	return &syncResponse{success: success, metadata: metadata, headers: headers}
+}
+
 var EmptySyncResponse = &syncResponse{success: true, metadata: make(map[string]interface{})}
 
 // File transfer response
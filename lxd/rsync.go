@@ -60,6 +60,7 @@ func rsyncSendSetup(name string, path string, execPath string) (*exec.Cmd, net.C
 		"--numeric-ids",
 		"--partial",
 		"--sparse",
+		"--delete",
 		path,
 		"localhost:/tmp/foo",
 		"-e",
@@ -128,6 +129,7 @@ func RsyncRecv(path string, conn *websocket.Conn) error {
 		"--devices",
 		"--partial",
 		"--sparse",
+		"--delete",
 		".",
 		path)
 
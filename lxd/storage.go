@@ -92,6 +92,23 @@ func storageTypeToString(sType storageType) string {
 	return "dir"
 }
 
+func storageStringToType(sName string) (storageType, error) {
+	switch sName {
+	case "btrfs":
+		return storageTypeBtrfs, nil
+	case "zfs":
+		return storageTypeZfs, nil
+	case "lvm":
+		return storageTypeLvm, nil
+	case "dir":
+		return storageTypeDir, nil
+	case "mock":
+		return storageTypeMock, nil
+	}
+
+	return -1, fmt.Errorf("Invalid storage type name: %s", sName)
+}
+
 type MigrationStorageSourceDriver interface {
 	/* snapshots for this container, if any */
 	Snapshots() []container
@@ -108,6 +125,13 @@ type MigrationStorageSourceDriver interface {
 	 */
 	SendAfterCheckpoint(conn *websocket.Conn) error
 
+	/* only send the snapshots with the given names, the target already
+	 * having the other ones. This is used when refreshing an existing
+	 * container, in which case the last snapshot before the first one sent
+	 * is used as the base of incremental transfers.
+	 */
+	FilterSnapshots(names []string)
+
 	/* Called after either success or failure of a migration, can be used
 	 * to clean up any temporary snapshots, etc.
 	 */
@@ -121,6 +145,30 @@ type storage interface {
 	GetStorageTypeName() string
 	GetStorageTypeVersion() string
 
+	// StoragePoolResources returns the space and inodes used and
+	// available on the backing storage.
+	StoragePoolResources() (*api.ResourcesStoragePool, error)
+
+	// Custom storage volumes, only available on drivers bound to a pool.
+	StoragePoolVolumeCreate(name string) error
+	StoragePoolVolumeDelete(name string) error
+	StoragePoolVolumeRename(oldName string, newName string) error
+	// StoragePoolVolumeSetQuota sets the size of the volume, a size of 0
+	// removing any limit set so far when the driver supports it.
+	StoragePoolVolumeSetQuota(name string, size int64) error
+
+	// StoragePoolVolumeMount makes the volume available on the host and
+	// returns the path it's mounted on.
+	StoragePoolVolumeMount(name string) (string, error)
+	StoragePoolVolumeUmount(name string) error
+
+	// Custom storage volume snapshots. Restoring a volume replaces its
+	// content with the one of the given snapshot.
+	StoragePoolVolumeSnapshotCreate(name string, snapshotName string) error
+	StoragePoolVolumeSnapshotDelete(name string, snapshotName string) error
+	StoragePoolVolumeSnapshotRename(name string, oldSnapshotName string, newSnapshotName string) error
+	StoragePoolVolumeRestore(name string, snapshotName string) error
+
 	// ContainerCreate creates an empty container (no rootfs/metadata.yaml)
 	ContainerCreate(container container) error
 
@@ -147,6 +195,16 @@ type storage interface {
 	/* for use in migrating snapshots */
 	ContainerSnapshotCreateEmpty(snapshotContainer container) error
 
+	// ContainerBackupCreate writes the content of the container and,
+	// unless the backup is container only, of its snapshots to the
+	// backup directory at path.
+	ContainerBackupCreate(path string, backup backup, sourceContainer container) error
+
+	// ContainerBackupLoad restores the content of a freshly created
+	// container and creates its snapshots from the backup directory at
+	// path.
+	ContainerBackupLoad(path string, info backupInfo, container container) error
+
 	ImageCreate(fingerprint string) error
 	ImageDelete(fingerprint string) error
 
@@ -188,6 +246,10 @@ func newStorageWithConfig(s *state.State, st storage, sType storageType, config
 	}
 
 	shared := storageShared{s: s}
+	if config["poolName"] != nil {
+		shared.poolName = config["poolName"].(string)
+	}
+
 	var w storage
 
 	switch sType {
@@ -275,6 +337,9 @@ type storageShared struct {
 	sTypeName    string
 	sTypeVersion string
 
+	// Name of the storage pool this driver instance is bound to, if any.
+	poolName string
+
 	s *state.State
 
 	storage storage
@@ -302,6 +367,75 @@ func (ss *storageShared) GetStorageTypeVersion() string {
 	return ss.sTypeVersion
 }
 
+// storageResourcesStatfs returns the usage of the filesystem at the given path.
+func storageResourcesStatfs(path string) (*api.ResourcesStoragePool, error) {
+	st := syscall.Statfs_t{}
+	err := syscall.Statfs(path, &st)
+	if err != nil {
+		return nil, err
+	}
+
+	res := api.ResourcesStoragePool{}
+	res.Space.Total = st.Blocks * uint64(st.Bsize)
+	res.Space.Used = (st.Blocks - st.Bfree) * uint64(st.Bsize)
+
+	// Some filesystems don't report inodes since they allocate them
+	// dynamically (e.g. btrfs).
+	if st.Files > 0 {
+		res.Inodes.Total = st.Files
+		res.Inodes.Used = st.Files - st.Ffree
+	}
+
+	return &res, nil
+}
+
+// volumeMountPoint returns the path a custom volume of this pool is
+// available at on the host.
+func (ss *storageShared) volumeMountPoint(name string) string {
+	return storagePoolVolumeMountPoint(ss.poolName, name)
+}
+
+// volumeSnapshotsPath returns the path the snapshots of a custom volume of
+// this pool are stored in on drivers keeping them as directories.
+func (ss *storageShared) volumeSnapshotsPath(name string) string {
+	return storagePoolVolumeSnapshotsPath(ss.poolName, name)
+}
+
+// volumeSnapshotPath returns the path a snapshot of a custom volume of this
+// pool is stored at on drivers keeping them as directories.
+func (ss *storageShared) volumeSnapshotPath(name string, snapshotName string) string {
+	return filepath.Join(ss.volumeSnapshotsPath(name), snapshotName)
+}
+
+// volumeGet returns the ID and details of a custom volume of this pool.
+func (ss *storageShared) volumeGet(name string) (int64, *api.StorageVolume, error) {
+	poolID, _, err := ss.s.DB.StoragePoolGet(ss.poolName)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	return ss.s.DB.StoragePoolVolumeGet(poolID, name, db.StoragePoolVolumeTypeCustom)
+}
+
+// volumeSnapshots returns the names of the snapshots of a custom volume of
+// this pool, from the oldest to the most recent one.
+func (ss *storageShared) volumeSnapshots(name string) ([]string, error) {
+	volumeID, _, err := ss.volumeGet(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return ss.s.DB.StoragePoolVolumeSnapshots(volumeID)
+}
+
+func (ss *storageShared) volumeCheckPool() error {
+	if ss.poolName == "" {
+		return fmt.Errorf("Custom storage volumes require a storage pool")
+	}
+
+	return nil
+}
+
 func (ss *storageShared) shiftRootfs(c container) error {
 	dpath := c.Path()
 	rpath := c.RootfsPath()
@@ -391,6 +525,86 @@ func (lw *storageLogWrapper) GetStorageTypeVersion() string {
 	return lw.w.GetStorageTypeVersion()
 }
 
+func (lw *storageLogWrapper) StoragePoolResources() (*api.ResourcesStoragePool, error) {
+	lw.log.Debug("StoragePoolResources")
+	return lw.w.StoragePoolResources()
+}
+
+func (lw *storageLogWrapper) StoragePoolVolumeCreate(name string) error {
+	lw.log.Debug("StoragePoolVolumeCreate", log.Ctx{"volume": name})
+	return lw.w.StoragePoolVolumeCreate(name)
+}
+
+func (lw *storageLogWrapper) StoragePoolVolumeDelete(name string) error {
+	lw.log.Debug("StoragePoolVolumeDelete", log.Ctx{"volume": name})
+	return lw.w.StoragePoolVolumeDelete(name)
+}
+
+func (lw *storageLogWrapper) StoragePoolVolumeRename(oldName string, newName string) error {
+	lw.log.Debug(
+		"StoragePoolVolumeRename",
+		log.Ctx{
+			"oldName": oldName,
+			"newName": newName})
+	return lw.w.StoragePoolVolumeRename(oldName, newName)
+}
+
+func (lw *storageLogWrapper) StoragePoolVolumeSetQuota(name string, size int64) error {
+	lw.log.Debug(
+		"StoragePoolVolumeSetQuota",
+		log.Ctx{
+			"volume": name,
+			"size":   size})
+	return lw.w.StoragePoolVolumeSetQuota(name, size)
+}
+
+func (lw *storageLogWrapper) StoragePoolVolumeMount(name string) (string, error) {
+	lw.log.Debug("StoragePoolVolumeMount", log.Ctx{"volume": name})
+	return lw.w.StoragePoolVolumeMount(name)
+}
+
+func (lw *storageLogWrapper) StoragePoolVolumeUmount(name string) error {
+	lw.log.Debug("StoragePoolVolumeUmount", log.Ctx{"volume": name})
+	return lw.w.StoragePoolVolumeUmount(name)
+}
+
+func (lw *storageLogWrapper) StoragePoolVolumeSnapshotCreate(name string, snapshotName string) error {
+	lw.log.Debug(
+		"StoragePoolVolumeSnapshotCreate",
+		log.Ctx{
+			"volume":   name,
+			"snapshot": snapshotName})
+	return lw.w.StoragePoolVolumeSnapshotCreate(name, snapshotName)
+}
+
+func (lw *storageLogWrapper) StoragePoolVolumeSnapshotDelete(name string, snapshotName string) error {
+	lw.log.Debug(
+		"StoragePoolVolumeSnapshotDelete",
+		log.Ctx{
+			"volume":   name,
+			"snapshot": snapshotName})
+	return lw.w.StoragePoolVolumeSnapshotDelete(name, snapshotName)
+}
+
+func (lw *storageLogWrapper) StoragePoolVolumeSnapshotRename(name string, oldSnapshotName string, newSnapshotName string) error {
+	lw.log.Debug(
+		"StoragePoolVolumeSnapshotRename",
+		log.Ctx{
+			"volume":  name,
+			"oldName": oldSnapshotName,
+			"newName": newSnapshotName})
+	return lw.w.StoragePoolVolumeSnapshotRename(name, oldSnapshotName, newSnapshotName)
+}
+
+func (lw *storageLogWrapper) StoragePoolVolumeRestore(name string, snapshotName string) error {
+	lw.log.Debug(
+		"StoragePoolVolumeRestore",
+		log.Ctx{
+			"volume":   name,
+			"snapshot": snapshotName})
+	return lw.w.StoragePoolVolumeRestore(name, snapshotName)
+}
+
 func (lw *storageLogWrapper) ContainerCreate(container container) error {
 	lw.log.Debug(
 		"ContainerCreate",
@@ -559,6 +773,25 @@ func (lw *storageLogWrapper) MigrationSource(container container) (MigrationStor
 	return lw.w.MigrationSource(container)
 }
 
+func (lw *storageLogWrapper) ContainerBackupCreate(path string, backup backup, sourceContainer container) error {
+	lw.log.Debug("ContainerBackupCreate", log.Ctx{
+		"path":             path,
+		"backup":           backup.Name(),
+		"sourceContainer":  sourceContainer.Name(),
+		"containerOnly":    backup.containerOnly,
+		"optimizedStorage": backup.optimizedStorage})
+	return lw.w.ContainerBackupCreate(path, backup, sourceContainer)
+}
+
+func (lw *storageLogWrapper) ContainerBackupLoad(path string, info backupInfo, container container) error {
+	lw.log.Debug("ContainerBackupLoad", log.Ctx{
+		"path":      path,
+		"container": container.Name(),
+		"backend":   info.Backend,
+		"optimized": info.Optimized})
+	return lw.w.ContainerBackupLoad(path, info, container)
+}
+
 func (lw *storageLogWrapper) MigrationSink(live bool, container container, objects []*migration.Snapshot, conn *websocket.Conn, srcIdmap *idmap.IdmapSet) error {
 	objNames := []string{}
 	for _, obj := range objects {
@@ -642,6 +875,10 @@ func (s rsyncStorageSourceDriver) SendAfterCheckpoint(conn *websocket.Conn) erro
 	return RsyncSend(ctName, shared.AddSlash(s.container.Path()), conn, state.OS.ExecPath)
 }
 
+func (s *rsyncStorageSourceDriver) FilterSnapshots(names []string) {
+	s.snapshots = migrationFilterSnapshots(s.snapshots, names)
+}
+
 func (s rsyncStorageSourceDriver) Cleanup() {
 	/* no-op */
 }
@@ -652,7 +889,19 @@ func rsyncMigrationSource(container container) (MigrationStorageSourceDriver, er
 		return nil, err
 	}
 
-	return rsyncStorageSourceDriver{container, snapshots}, nil
+	return &rsyncStorageSourceDriver{container, snapshots}, nil
+}
+
+// migrationFilterSnapshots returns the snapshots whose name is in names.
+func migrationFilterSnapshots(snapshots []container, names []string) []container {
+	filtered := []container{}
+	for _, snap := range snapshots {
+		if shared.StringInSlice(shared.ExtractSnapshotName(snap.Name()), names) {
+			filtered = append(filtered, snap)
+		}
+	}
+
+	return filtered
 }
 
 func snapshotProtobufToContainerArgs(containerName string, snap *migration.Snapshot) db.ContainerArgs {
@@ -755,6 +1004,108 @@ func rsyncMigrationSink(live bool, container container, snapshots []*migration.S
 	return nil
 }
 
+// rsyncBackupCreate copies the container and, unless the backup is container
+// only, its snapshots into the backup directory at path.
+func rsyncBackupCreate(path string, b backup, sourceContainer container) error {
+	if !b.containerOnly {
+		snapshots, err := sourceContainer.Snapshots()
+		if err != nil {
+			return err
+		}
+
+		for _, snap := range snapshots {
+			_, snapName, _ := containerGetParentAndSnapshotName(snap.Name())
+
+			if err := snap.StorageStart(); err != nil {
+				return err
+			}
+
+			_, err := storageRsyncCopy(snap.Path(), filepath.Join(path, "snapshots", snapName))
+			snap.StorageStop()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !sourceContainer.IsRunning() {
+		if err := sourceContainer.StorageStart(); err != nil {
+			return err
+		}
+		defer sourceContainer.StorageStop()
+	}
+
+	_, err := storageRsyncCopy(sourceContainer.Path(), filepath.Join(path, "container"))
+	return err
+}
+
+// rsyncBackupLoad fills a freshly created container and its snapshots from
+// the backup directory at path.
+func rsyncBackupLoad(path string, info backupInfo, container container) error {
+	if info.Optimized {
+		return fmt.Errorf("The backup was created with optimized storage on %s and can't be restored on %s",
+			info.Backend, container.Storage().GetStorageTypeName())
+	}
+
+	isDirBackend := container.Storage().GetStorageType() == storageTypeDir
+
+	if isDirBackend {
+		if len(info.Snapshots) > 0 {
+			err := os.MkdirAll(shared.VarPath(fmt.Sprintf("snapshots/%s", container.Name())), 0700)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, snap := range info.Snapshots {
+			args, err := backupSnapshotToContainerArgs(container.Name(), snap)
+			if err != nil {
+				return err
+			}
+
+			s, err := containerCreateEmptySnapshot(container.DaemonState(), container.Storage(), args)
+			if err != nil {
+				return err
+			}
+
+			_, snapName, _ := containerGetParentAndSnapshotName(snap.Name)
+			_, err = storageRsyncCopy(filepath.Join(path, "snapshots", snapName), s.Path())
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := storageRsyncCopy(filepath.Join(path, "container"), container.Path())
+		return err
+	}
+
+	if err := container.StorageStart(); err != nil {
+		return err
+	}
+	defer container.StorageStop()
+
+	for _, snap := range info.Snapshots {
+		_, snapName, _ := containerGetParentAndSnapshotName(snap.Name)
+		_, err := storageRsyncCopy(filepath.Join(path, "snapshots", snapName), container.Path())
+		if err != nil {
+			return err
+		}
+
+		args, err := backupSnapshotToContainerArgs(container.Name(), snap)
+		if err != nil {
+			return err
+		}
+
+		_, err = containerCreateAsSnapshot(container.DaemonState(), container.Storage(), args, container)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := storageRsyncCopy(filepath.Join(path, "container"), container.Path())
+	return err
+}
+
 func SetupStorageDriver(d *Daemon) error {
 	var err error
 
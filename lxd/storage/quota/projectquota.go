@@ -0,0 +1,259 @@
+package quota
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Quota commands and types, from linux/quota.h
+const (
+	qGetInfo  = 0x800005
+	qGetQuota = 0x800007
+	qSetQuota = 0x800008
+
+	prjQuota = 2
+
+	qifBLimits = 1
+)
+
+// Extended attributes ioctls and flags, from linux/fs.h
+const (
+	fsIocFsGetXAttr = 0x801c581f
+	fsIocFsSetXAttr = 0x401c5820
+
+	fsXFlagProjInherit = 0x200
+)
+
+// The if_dqblk structure from linux/quota.h
+type dqblk struct {
+	bHardLimit uint64
+	bSoftLimit uint64
+	curSpace   uint64
+	iHardLimit uint64
+	iSoftLimit uint64
+	curInodes  uint64
+	bTime      uint64
+	iTime      uint64
+	valid      uint32
+}
+
+// The if_dqinfo structure from linux/quota.h
+type dqinfo struct {
+	bGrace uint64
+	iGrace uint64
+	flags  uint32
+	valid  uint32
+}
+
+// The fsxattr structure from linux/fs.h
+type fsxattr struct {
+	xflags     uint32
+	extsize    uint32
+	nextents   uint32
+	projid     uint32
+	cowextsize uint32
+	pad        [8]byte
+}
+
+// Supported returns whether project quotas are enabled on the filesystem
+// backing the given path.
+func Supported(path string) (bool, error) {
+	dev, err := devForPath(path)
+	if err != nil {
+		return false, err
+	}
+
+	info := dqinfo{}
+	err = quotactl(qGetInfo, dev, 0, unsafe.Pointer(&info))
+	if err != nil {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// GetProject returns the project ID of the given path, 0 meaning that none
+// is set.
+func GetProject(path string) (uint32, error) {
+	attr, err := getXAttr(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return attr.projid, nil
+}
+
+// SetProject recursively sets the project ID of the given path and of all
+// the files and directories under it. Directories also get flagged so that
+// the files created in them later on inherit the project ID.
+func SetProject(path string, id uint32) error {
+	return filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Only regular files and directories can carry a project ID
+		if !info.Mode().IsRegular() && !info.Mode().IsDir() {
+			return nil
+		}
+
+		attr, err := getXAttr(filePath)
+		if err != nil {
+			return err
+		}
+
+		attr.projid = id
+		if info.IsDir() {
+			attr.xflags |= fsXFlagProjInherit
+		}
+
+		return setXAttr(filePath, attr)
+	})
+}
+
+// SetProjectQuota sets the hard limit, in bytes, of the given project on the
+// filesystem backing path. A size of 0 removes the limit.
+func SetProjectQuota(path string, id uint32, size int64) error {
+	dev, err := devForPath(path)
+	if err != nil {
+		return err
+	}
+
+	quota := dqblk{
+		// Limits are expressed in 1KiB blocks
+		bHardLimit: uint64(size) / 1024,
+		bSoftLimit: uint64(size) / 1024,
+		valid:      qifBLimits,
+	}
+
+	err = quotactl(qSetQuota, dev, id, unsafe.Pointer(&quota))
+	if err != nil {
+		return fmt.Errorf("Failed to set the quota of project %d on %s: %v", id, dev, err)
+	}
+
+	return nil
+}
+
+// GetProjectUsage returns the disk space, in bytes, used by the given project
+// on the filesystem backing path.
+func GetProjectUsage(path string, id uint32) (int64, error) {
+	dev, err := devForPath(path)
+	if err != nil {
+		return -1, err
+	}
+
+	quota := dqblk{}
+	err = quotactl(qGetQuota, dev, id, unsafe.Pointer(&quota))
+	if err != nil {
+		return -1, fmt.Errorf("Failed to get the usage of project %d on %s: %v", id, dev, err)
+	}
+
+	return int64(quota.curSpace), nil
+}
+
+func quotactl(cmd int, dev string, id uint32, addr unsafe.Pointer) error {
+	devPtr, err := syscall.BytePtrFromString(dev)
+	if err != nil {
+		return err
+	}
+
+	_, _, errno := syscall.Syscall6(
+		syscall.SYS_QUOTACTL,
+		uintptr(cmd<<8|prjQuota),
+		uintptr(unsafe.Pointer(devPtr)),
+		uintptr(id),
+		uintptr(addr), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+func getXAttr(path string) (*fsxattr, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	attr := fsxattr{}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), fsIocFsGetXAttr, uintptr(unsafe.Pointer(&attr)))
+	if errno != 0 {
+		return nil, fmt.Errorf("Failed to get the attributes of %s: %v", path, errno)
+	}
+
+	return &attr, nil
+}
+
+func setXAttr(path string, attr *fsxattr) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), fsIocFsSetXAttr, uintptr(unsafe.Pointer(attr)))
+	if errno != 0 {
+		return fmt.Errorf("Failed to set the attributes of %s: %v", path, errno)
+	}
+
+	return nil
+}
+
+// Returns the block device backing the filesystem the given path is on.
+func devForPath(path string) (string, error) {
+	stat := syscall.Stat_t{}
+	err := syscall.Stat(path, &stat)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	major := (stat.Dev >> 8) & 0xfff
+	minor := (stat.Dev & 0xff) | ((stat.Dev >> 12) & 0xfff00)
+
+	dev, err := parseMountInfo(f, fmt.Sprintf("%d:%d", major, minor))
+	if err != nil {
+		return "", err
+	}
+
+	if dev == "" {
+		return "", fmt.Errorf("Couldn't find the device backing %s", path)
+	}
+
+	return dev, nil
+}
+
+// Returns the source of the first mount with the given device number in a
+// mountinfo file.
+func parseMountInfo(r io.Reader, devNumber string) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[2] != devNumber {
+			continue
+		}
+
+		// The mount source follows the "-" separator and the
+		// filesystem type.
+		for i, field := range fields {
+			if field == "-" && i+2 < len(fields) {
+				return fields[i+2], nil
+			}
+		}
+	}
+
+	return "", scanner.Err()
+}
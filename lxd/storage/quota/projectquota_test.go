@@ -0,0 +1,30 @@
+package quota
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mountInfo = `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw,data=ordered
+23 22 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw
+45 22 7:0 / /var/lib/lxd rw,relatime shared:30 - xfs /dev/loop0 rw,prjquota
+`
+
+// The mount source is looked up by device number.
+func TestParseMountInfo(t *testing.T) {
+	cases := map[string]string{
+		"8:1":  "/dev/sda1",
+		"7:0":  "/dev/loop0",
+		"0:21": "proc",
+		"9:9":  "",
+	}
+
+	for devNumber, expected := range cases {
+		dev, err := parseMountInfo(strings.NewReader(mountInfo), devNumber)
+		require.NoError(t, err)
+		assert.Equal(t, expected, dev, devNumber)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/idmap"
 	"github.com/lxc/lxd/shared/logger"
 
@@ -267,11 +269,16 @@ func (s *storageBtrfs) ContainerSetQuota(container container, size int64) error
 		return err
 	}
 
+	limit := "none"
+	if size > 0 {
+		limit = fmt.Sprintf("%d", size)
+	}
+
 	output, err := shared.RunCommand(
 		"btrfs",
 		"qgroup",
 		"limit",
-		"-e", fmt.Sprintf("%d", size),
+		"-e", limit,
 		subvol)
 
 	if err != nil {
@@ -285,6 +292,260 @@ func (s *storageBtrfs) ContainerGetUsage(container container) (int64, error) {
 	return s.subvolQGroupUsage(container.Path())
 }
 
+func (s *storageBtrfs) StoragePoolResources() (*api.ResourcesStoragePool, error) {
+	return storageResourcesStatfs(shared.VarPath())
+}
+
+func (s *storageBtrfs) StoragePoolVolumeCreate(name string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	volPath := s.volumeMountPoint(name)
+	err = os.MkdirAll(filepath.Dir(volPath), 0711)
+	if err != nil {
+		return err
+	}
+
+	return s.subvolCreate(volPath)
+}
+
+func (s *storageBtrfs) StoragePoolVolumeDelete(name string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	// Delete the snapshots
+	snapshotsPath := s.volumeSnapshotsPath(name)
+	if shared.PathExists(snapshotsPath) {
+		entries, err := ioutil.ReadDir(snapshotsPath)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			err := s.StoragePoolVolumeSnapshotDelete(name, entry.Name())
+			if err != nil {
+				return err
+			}
+		}
+
+		err = os.RemoveAll(snapshotsPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	volPath := s.volumeMountPoint(name)
+	if s.isSubvolume(volPath) {
+		return s.subvolsDelete(volPath)
+	}
+
+	return os.RemoveAll(volPath)
+}
+
+func (s *storageBtrfs) StoragePoolVolumeRename(oldName string, newName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	// The snapshots are kept in a plain directory, which can be renamed
+	// along with the read-only subvolumes it contains.
+	if shared.PathExists(s.volumeSnapshotsPath(oldName)) {
+		err := os.Rename(s.volumeSnapshotsPath(oldName), s.volumeSnapshotsPath(newName))
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(s.volumeMountPoint(oldName), s.volumeMountPoint(newName))
+}
+
+func (s *storageBtrfs) StoragePoolVolumeSetQuota(name string, size int64) error {
+	subvol := s.volumeMountPoint(name)
+
+	_, err := s.subvolQGroup(subvol)
+	if err != nil {
+		return err
+	}
+
+	output, err := shared.RunCommand(
+		"btrfs",
+		"qgroup",
+		"limit",
+		"-e", fmt.Sprintf("%d", size),
+		subvol)
+
+	if err != nil {
+		return fmt.Errorf("Failed to set btrfs quota: %s", output)
+	}
+
+	return nil
+}
+
+func (s *storageBtrfs) StoragePoolVolumeMount(name string) (string, error) {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return "", err
+	}
+
+	return s.volumeMountPoint(name), nil
+}
+
+func (s *storageBtrfs) StoragePoolVolumeUmount(name string) error {
+	return nil
+}
+
+func (s *storageBtrfs) StoragePoolVolumeSnapshotCreate(name string, snapshotName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	volPath := s.volumeMountPoint(name)
+	snapshotPath := s.volumeSnapshotPath(name, snapshotName)
+
+	if s.isSubvolume(volPath) {
+		// Create a readonly snapshot of the volume
+		err := s.subvolsSnapshot(volPath, snapshotPath, true)
+		if err != nil {
+			s.StoragePoolVolumeSnapshotDelete(name, snapshotName)
+			return err
+		}
+
+		return nil
+	}
+
+	// Copy by using rsync
+	output, err := storageRsyncCopy(volPath, snapshotPath)
+	if err != nil {
+		s.StoragePoolVolumeSnapshotDelete(name, snapshotName)
+		s.log.Error("StoragePoolVolumeSnapshotCreate: rsync failed", log.Ctx{"output": string(output)})
+		return fmt.Errorf("rsync failed: %s", string(output))
+	}
+
+	return nil
+}
+
+func (s *storageBtrfs) StoragePoolVolumeSnapshotDelete(name string, snapshotName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	snapshotPath := s.volumeSnapshotPath(name, snapshotName)
+	if s.isSubvolume(snapshotPath) {
+		err = s.subvolsDelete(snapshotPath)
+	} else {
+		err = os.RemoveAll(snapshotPath)
+	}
+	if err != nil {
+		return fmt.Errorf("Error deleting snapshot %s of volume %s: %s", snapshotName, name, err)
+	}
+
+	if ok, _ := shared.PathIsEmpty(s.volumeSnapshotsPath(name)); ok {
+		os.Remove(s.volumeSnapshotsPath(name))
+	}
+
+	return nil
+}
+
+func (s *storageBtrfs) StoragePoolVolumeSnapshotRename(name string, oldSnapshotName string, newSnapshotName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	oldPath := s.volumeSnapshotPath(name, oldSnapshotName)
+	newPath := s.volumeSnapshotPath(name, newSnapshotName)
+
+	if !s.isSubvolume(oldPath) {
+		return os.Rename(oldPath, newPath)
+	}
+
+	err = s.subvolsSnapshot(oldPath, newPath, true)
+	if err != nil {
+		return err
+	}
+
+	return s.subvolsDelete(oldPath)
+}
+
+func (s *storageBtrfs) StoragePoolVolumeRestore(name string, snapshotName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	volPath := s.volumeMountPoint(name)
+	snapshotPath := s.volumeSnapshotPath(name, snapshotName)
+	backupPath := volPath + ".back"
+
+	// Create a backup of the volume
+	err = os.Rename(volPath, backupPath)
+	if err != nil {
+		return err
+	}
+
+	var failure error
+	if s.isSubvolume(snapshotPath) {
+		// Restore using btrfs snapshots
+		failure = s.subvolsSnapshot(snapshotPath, volPath, false)
+	} else {
+		// Restore using rsync but create a btrfs subvol
+		failure = s.subvolCreate(volPath)
+		if failure == nil {
+			output, err := storageRsyncCopy(snapshotPath, volPath)
+			if err != nil {
+				s.log.Error("StoragePoolVolumeRestore: rsync failed", log.Ctx{"output": string(output)})
+				failure = err
+			}
+		}
+	}
+
+	if failure != nil {
+		// Restore the original volume
+		if s.isSubvolume(volPath) {
+			s.subvolsDelete(volPath)
+		} else {
+			os.RemoveAll(volPath)
+		}
+		os.Rename(backupPath, volPath)
+
+		return failure
+	}
+
+	// Remove the backup we made
+	if s.isSubvolume(backupPath) {
+		err = s.subvolsDelete(backupPath)
+	} else {
+		err = os.RemoveAll(backupPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The quota is attached to the replaced subvolume, set it again
+	_, volume, err := s.volumeGet(name)
+	if err != nil {
+		return err
+	}
+
+	if volume.Config["size"] != "" {
+		size, err := shared.ParseByteSizeString(volume.Config["size"])
+		if err != nil {
+			return err
+		}
+
+		return s.StoragePoolVolumeSetQuota(name, size)
+	}
+
+	return nil
+}
+
 func (s *storageBtrfs) ContainerSnapshotCreate(
 	snapshotContainer container, sourceContainer container) error {
 
@@ -407,6 +668,117 @@ func (s *storageBtrfs) ContainerSnapshotCreateEmpty(snapshotContainer container)
 	return s.subvolCreate(dpath)
 }
 
+func (s *storageBtrfs) ContainerBackupCreate(path string, backup backup, sourceContainer container) error {
+	if !backup.optimizedStorage {
+		return rsyncBackupCreate(path, backup, sourceContainer)
+	}
+
+	if s.s.OS.RunningInUserNS {
+		return fmt.Errorf("Optimized backups aren't supported on btrfs when running inside a user namespace")
+	}
+
+	parent := ""
+	if !backup.containerOnly {
+		snapshots, err := sourceContainer.Snapshots()
+		if err != nil {
+			return err
+		}
+
+		err = os.MkdirAll(filepath.Join(path, "snapshots"), 0700)
+		if err != nil {
+			return err
+		}
+
+		for _, snap := range snapshots {
+			_, snapName, _ := containerGetParentAndSnapshotName(snap.Name())
+			err := s.btrfsBackupSend(snap.Path(), parent, filepath.Join(path, "snapshots", snapName+".bin"))
+			if err != nil {
+				return err
+			}
+
+			parent = snap.Path()
+		}
+	}
+
+	/* btrfs can only send read-only subvolumes, so send a temporary
+	 * snapshot of the container.
+	 */
+	tmpPath := containerPath(fmt.Sprintf("%s/.backup-%s", sourceContainer.Name(), uuid.NewRandom().String()), true)
+	err := os.MkdirAll(tmpPath, 0700)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpPath)
+
+	btrfsPath := fmt.Sprintf("%s/.root", tmpPath)
+	err = s.subvolsSnapshot(sourceContainer.Path(), btrfsPath, true)
+	if err != nil {
+		return err
+	}
+	defer s.subvolsDelete(btrfsPath)
+
+	return s.btrfsBackupSend(btrfsPath, parent, filepath.Join(path, "container.bin"))
+}
+
+func (s *storageBtrfs) ContainerBackupLoad(path string, info backupInfo, container container) error {
+	if !info.Optimized || info.Backend != "btrfs" {
+		return rsyncBackupLoad(path, info, container)
+	}
+
+	if s.s.OS.RunningInUserNS {
+		return fmt.Errorf("Optimized backups aren't supported on btrfs when running inside a user namespace")
+	}
+
+	cName := container.Name()
+	snapshotsPath := containerPath(cName, true)
+	err := os.MkdirAll(snapshotsPath, 0700)
+	if err != nil {
+		return err
+	}
+
+	for _, snap := range info.Snapshots {
+		args, err := backupSnapshotToContainerArgs(cName, snap)
+		if err != nil {
+			return err
+		}
+
+		sc, err := containerCreateEmptySnapshot(s.s, s.storage, args)
+		if err != nil {
+			return err
+		}
+
+		// Remove the pre-created subvolume, receive creates it
+		err = s.subvolsDelete(sc.Path())
+		if err != nil {
+			return err
+		}
+
+		_, snapName, _ := containerGetParentAndSnapshotName(snap.Name)
+		err = s.btrfsBackupRecv(filepath.Join(path, "snapshots", snapName+".bin"), snapshotsPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	/* The container was sent as a read-only ".root" subvolume, replace
+	 * the pre-created subvolume with a writable snapshot of it.
+	 */
+	err = s.btrfsBackupRecv(filepath.Join(path, "container.bin"), snapshotsPath)
+	if err != nil {
+		return err
+	}
+
+	rootPath := containerPath(fmt.Sprintf("%s/.root", cName), true)
+	defer s.subvolsDelete(rootPath)
+
+	err = s.subvolsDelete(container.Path())
+	if err != nil {
+		return err
+	}
+
+	return s.subvolsSnapshot(rootPath, container.Path(), false)
+}
+
 func (s *storageBtrfs) ImageCreate(fingerprint string) error {
 	imagePath := shared.VarPath("images", fingerprint)
 	subvol := fmt.Sprintf("%s.btrfs", imagePath)
@@ -744,6 +1116,56 @@ func (s *storageBtrfs) getSubVolumes(path string) ([]string, error) {
 	return result, nil
 }
 
+// btrfsBackupSend writes the send stream of the given read-only subvolume,
+// incremental from parent if set, to the target file.
+func (s *storageBtrfs) btrfsBackupSend(subvol string, parent string, target string) error {
+	args := []string{"send"}
+	if parent != "" {
+		args = append(args, "-p", parent)
+	}
+	args = append(args, subvol)
+
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("btrfs", args...)
+	cmd.Stdout = f
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		s.log.Error("btrfs send failed", log.Ctx{"output": stderr.String()})
+		return fmt.Errorf("Failed to send btrfs subvolume: %s", stderr.String())
+	}
+
+	return nil
+}
+
+// btrfsBackupRecv receives the send stream stored in the source file into the
+// target directory.
+func (s *storageBtrfs) btrfsBackupRecv(source string, target string) error {
+	f, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cmd := exec.Command("btrfs", "receive", "-e", target)
+	cmd.Stdin = f
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		s.log.Error("btrfs receive failed", log.Ctx{"output": string(output)})
+		return fmt.Errorf("Failed to receive btrfs subvolume: %s", output)
+	}
+
+	return nil
+}
+
 type btrfsMigrationSourceDriver struct {
 	container          container
 	snapshots          []container
@@ -751,6 +1173,10 @@ type btrfsMigrationSourceDriver struct {
 	btrfs              *storageBtrfs
 	runningSnapName    string
 	stoppedSnapName    string
+
+	// Snapshot already present on the target, used as the parent of the
+	// first snapshot being sent when refreshing a container.
+	baseSnapName string
 }
 
 func (s *btrfsMigrationSourceDriver) Snapshots() []container {
@@ -815,15 +1241,13 @@ func (s *btrfsMigrationSourceDriver) SendWhileRunning(conn *websocket.Conn) erro
 		return s.send(conn, btrfsPath, "")
 	}
 
-	for i, snap := range s.snapshots {
-		prev := ""
-		if i > 0 {
-			prev = s.snapshots[i-1].Path()
-		}
-
+	prev := s.baseSnapName
+	for _, snap := range s.snapshots {
 		if err := s.send(conn, snap.Path(), prev); err != nil {
 			return err
 		}
+
+		prev = snap.Path()
 	}
 
 	/* We can't send running fses, so let's snapshot the fs and send
@@ -842,7 +1266,7 @@ func (s *btrfsMigrationSourceDriver) SendWhileRunning(conn *websocket.Conn) erro
 	}
 	defer s.btrfs.subvolsDelete(s.runningSnapName)
 
-	btrfsParent := ""
+	btrfsParent := s.baseSnapName
 	if len(s.btrfsSnapshotNames) > 0 {
 		btrfsParent = s.btrfsSnapshotNames[len(s.btrfsSnapshotNames)-1]
 	}
@@ -867,6 +1291,27 @@ func (s *btrfsMigrationSourceDriver) SendAfterCheckpoint(conn *websocket.Conn) e
 	return s.send(conn, s.stoppedSnapName, s.runningSnapName)
 }
 
+func (s *btrfsMigrationSourceDriver) FilterSnapshots(names []string) {
+	snapshots := []container{}
+	btrfsSnapshotNames := []string{}
+
+	for i, snap := range s.snapshots {
+		if !shared.StringInSlice(shared.ExtractSnapshotName(snap.Name()), names) {
+			if len(snapshots) == 0 {
+				s.baseSnapName = s.btrfsSnapshotNames[i]
+			}
+
+			continue
+		}
+
+		snapshots = append(snapshots, snap)
+		btrfsSnapshotNames = append(btrfsSnapshotNames, s.btrfsSnapshotNames[i])
+	}
+
+	s.snapshots = snapshots
+	s.btrfsSnapshotNames = btrfsSnapshotNames
+}
+
 func (s *btrfsMigrationSourceDriver) Cleanup() {
 	if s.stoppedSnapName != "" {
 		s.btrfs.subvolsDelete(s.stoppedSnapName)
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -9,7 +10,9 @@ import (
 	"github.com/gorilla/websocket"
 
 	"github.com/lxc/lxd/lxd/migration"
+	"github.com/lxc/lxd/lxd/storage/quota"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/idmap"
 
 	log "github.com/lxc/lxd/shared/log15"
@@ -17,6 +20,9 @@ import (
 
 type storageDir struct {
 	storageShared
+
+	// Whether the backing filesystem can clone files (reflink)
+	reflink bool
 }
 
 func (s *storageDir) Init(config map[string]interface{}) (storage, error) {
@@ -26,9 +32,27 @@ func (s *storageDir) Init(config map[string]interface{}) (storage, error) {
 		return s, err
 	}
 
+	s.reflink = storageReflinkSupported(shared.VarPath())
+
 	return s, nil
 }
 
+// copy copies the source directory into the destination one, cloning the
+// files if the backing filesystem supports it and falling back to rsync
+// otherwise.
+func (s *storageDir) copy(source string, dest string) (string, error) {
+	if s.reflink {
+		output, err := storageReflinkCopy(source, dest)
+		if err == nil {
+			return output, nil
+		}
+
+		s.log.Debug("Reflink copy failed, falling back to rsync", log.Ctx{"source": source, "err": err})
+	}
+
+	return storageRsyncCopy(source, dest)
+}
+
 func (s *storageDir) ContainerCreate(container container) error {
 	cPath := container.Path()
 	if err := os.MkdirAll(cPath, 0755); err != nil {
@@ -57,6 +81,26 @@ func (s *storageDir) ContainerCreateFromImage(
 		return fmt.Errorf("Error creating rootfs directory")
 	}
 
+	// Clone the unpacked image if the filesystem supports it, otherwise
+	// unpack the image into the container.
+	cloned := false
+	if s.reflink {
+		err := s.imageClone(imageFingerprint, container.Path())
+		if err == nil {
+			cloned = true
+		} else {
+			s.log.Debug("Failed to clone the image, unpacking it instead", log.Ctx{"fingerprint": imageFingerprint, "err": err})
+		}
+	}
+
+	if !cloned {
+		imagePath := shared.VarPath("images", imageFingerprint)
+		if err := unpackImage(imagePath, container.Path(), s.storage.GetStorageType(), s.s.OS.RunningInUserNS); err != nil {
+			s.ContainerDelete(container)
+			return err
+		}
+	}
+
 	var mode os.FileMode
 	if container.IsPrivileged() {
 		mode = 0700
@@ -68,12 +112,6 @@ func (s *storageDir) ContainerCreateFromImage(
 		return err
 	}
 
-	imagePath := shared.VarPath("images", imageFingerprint)
-	if err := unpackImage(imagePath, container.Path(), s.storage.GetStorageType(), s.s.OS.RunningInUserNS); err != nil {
-		s.ContainerDelete(container)
-		return err
-	}
-
 	if !container.IsPrivileged() {
 		if err := s.shiftRootfs(container); err != nil {
 			s.ContainerDelete(container)
@@ -95,7 +133,16 @@ func (s *storageDir) ContainerDelete(container container) error {
 		return nil
 	}
 
-	err := os.RemoveAll(cPath)
+	// Clear the quota of the container, if any
+	projectID, err := quota.GetProject(cPath)
+	if err == nil && projectID != 0 {
+		err := quota.SetProjectQuota(cPath, projectID, 0)
+		if err != nil {
+			s.log.Warn("ContainerDelete: failed to clear the quota", log.Ctx{"cPath": cPath, "err": err})
+		}
+	}
+
+	err = os.RemoveAll(cPath)
 	if err != nil {
 		// RemovaAll fails on very long paths, so attempt an rm -Rf
 		output, err := shared.RunCommand("rm", "-Rf", cPath)
@@ -115,9 +162,9 @@ func (s *storageDir) ContainerCopy(
 	newPath := container.Path()
 
 	/*
-	 * Copy by using rsync
+	 * Clone the files if possible, copy by using rsync otherwise
 	 */
-	output, err := storageRsyncCopy(oldPath, newPath)
+	output, err := s.copy(oldPath, newPath)
 	if err != nil {
 		s.ContainerDelete(container)
 		s.log.Error("ContainerCopy: rsync failed", log.Ctx{"output": string(output)})
@@ -187,12 +234,182 @@ func (s *storageDir) ContainerRestore(
 	return nil
 }
 
+// quotaProjectID returns the filesystem project ID used to enforce the quota
+// of the container.
+func (s *storageDir) quotaProjectID(container container) uint32 {
+	return uint32(10000 + container.Id())
+}
+
+// ContainerSetQuota limits the disk space used by the container through
+// filesystem project quotas, which need to be enabled on the filesystem
+// backing LXD's directory (e.g. ext4 or XFS mounted with prjquota).
 func (s *storageDir) ContainerSetQuota(container container, size int64) error {
-	return fmt.Errorf("The directory container backend doesn't support quotas.")
+	cPath := container.Path()
+
+	supported, err := quota.Supported(cPath)
+	if err != nil || !supported {
+		return fmt.Errorf("The directory container backend requires project quotas to be enabled to support quotas.")
+	}
+
+	projectID := s.quotaProjectID(container)
+
+	currentID, err := quota.GetProject(cPath)
+	if err != nil {
+		return err
+	}
+
+	if currentID != projectID {
+		err = quota.SetProject(cPath, projectID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return quota.SetProjectQuota(cPath, projectID, size)
 }
 
 func (s *storageDir) ContainerGetUsage(container container) (int64, error) {
-	return -1, fmt.Errorf("The directory container backend doesn't support quotas.")
+	cPath := container.Path()
+
+	projectID, err := quota.GetProject(cPath)
+	if err != nil {
+		return -1, err
+	}
+
+	if projectID == 0 {
+		return -1, fmt.Errorf("The container doesn't have a quota set.")
+	}
+
+	return quota.GetProjectUsage(cPath, projectID)
+}
+
+func (s *storageDir) StoragePoolResources() (*api.ResourcesStoragePool, error) {
+	return storageResourcesStatfs(shared.VarPath())
+}
+
+func (s *storageDir) StoragePoolVolumeCreate(name string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	return os.MkdirAll(s.volumeMountPoint(name), 0711)
+}
+
+func (s *storageDir) StoragePoolVolumeDelete(name string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	err = os.RemoveAll(s.volumeSnapshotsPath(name))
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(s.volumeMountPoint(name))
+}
+
+func (s *storageDir) StoragePoolVolumeRename(oldName string, newName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	if shared.PathExists(s.volumeSnapshotsPath(oldName)) {
+		err := os.Rename(s.volumeSnapshotsPath(oldName), s.volumeSnapshotsPath(newName))
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(s.volumeMountPoint(oldName), s.volumeMountPoint(newName))
+}
+
+func (s *storageDir) StoragePoolVolumeSetQuota(name string, size int64) error {
+	// There's no limit to remove
+	if size == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("The directory container backend doesn't support quotas.")
+}
+
+func (s *storageDir) StoragePoolVolumeMount(name string) (string, error) {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return "", err
+	}
+
+	return s.volumeMountPoint(name), nil
+}
+
+func (s *storageDir) StoragePoolVolumeUmount(name string) error {
+	return nil
+}
+
+func (s *storageDir) StoragePoolVolumeSnapshotCreate(name string, snapshotName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	snapshotPath := s.volumeSnapshotPath(name, snapshotName)
+	err = os.MkdirAll(filepath.Dir(snapshotPath), 0700)
+	if err != nil {
+		return err
+	}
+
+	output, err := s.copy(s.volumeMountPoint(name), snapshotPath)
+	if err != nil {
+		os.RemoveAll(snapshotPath)
+		s.log.Error("StoragePoolVolumeSnapshotCreate: copy failed", log.Ctx{"output": string(output)})
+		return fmt.Errorf("copy failed: %s", string(output))
+	}
+
+	return nil
+}
+
+func (s *storageDir) StoragePoolVolumeSnapshotDelete(name string, snapshotName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	err = os.RemoveAll(s.volumeSnapshotPath(name, snapshotName))
+	if err != nil {
+		return err
+	}
+
+	if ok, _ := shared.PathIsEmpty(s.volumeSnapshotsPath(name)); ok {
+		os.Remove(s.volumeSnapshotsPath(name))
+	}
+
+	return nil
+}
+
+func (s *storageDir) StoragePoolVolumeSnapshotRename(name string, oldSnapshotName string, newSnapshotName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(s.volumeSnapshotPath(name, oldSnapshotName), s.volumeSnapshotPath(name, newSnapshotName))
+}
+
+func (s *storageDir) StoragePoolVolumeRestore(name string, snapshotName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	output, err := storageRsyncCopy(s.volumeSnapshotPath(name, snapshotName), s.volumeMountPoint(name))
+	if err != nil {
+		s.log.Error("StoragePoolVolumeRestore: rsync failed", log.Ctx{"output": string(output)})
+		return fmt.Errorf("rsync failed: %s", string(output))
+	}
+
+	return nil
 }
 
 func (s *storageDir) ContainerSnapshotCreate(
@@ -216,8 +433,17 @@ func (s *storageDir) ContainerSnapshotCreate(
 		return nil
 	}
 
-	if err := rsync(snapshotContainer, oldPath, newPath); err != nil {
-		return err
+	/*
+	 * Clone the files if possible for the initial copy, the consistency
+	 * pass below only transfers what changed in the meantime.
+	 */
+	output, err := s.copy(oldPath, newPath)
+	if err != nil {
+		s.ContainerDelete(snapshotContainer)
+		s.log.Error("ContainerSnapshotCreate: copy failed",
+			log.Ctx{"output": string(output)})
+
+		return fmt.Errorf("copy failed: %s", string(output))
 	}
 
 	if sourceContainer.IsRunning() {
@@ -243,6 +469,14 @@ func (s *storageDir) ContainerSnapshotCreateEmpty(snapshotContainer container) e
 	return os.MkdirAll(snapshotContainer.Path(), 0700)
 }
 
+func (s *storageDir) ContainerBackupCreate(path string, backup backup, sourceContainer container) error {
+	return rsyncBackupCreate(path, backup, sourceContainer)
+}
+
+func (s *storageDir) ContainerBackupLoad(path string, info backupInfo, container container) error {
+	return rsyncBackupLoad(path, info, container)
+}
+
 func (s *storageDir) ContainerSnapshotDelete(
 	snapshotContainer container) error {
 	err := s.ContainerDelete(snapshotContainer)
@@ -294,12 +528,57 @@ func (s *storageDir) ContainerSnapshotStop(container container) error {
 	return nil
 }
 
+// ImageCreate keeps an unpacked copy of the image when the filesystem
+// supports reflinks, so that containers can be cloned from it.
 func (s *storageDir) ImageCreate(fingerprint string) error {
+	if !s.reflink {
+		return nil
+	}
+
+	imagePath := shared.VarPath("images", fingerprint)
+	imageDir := imagePath + ".dir"
+	if shared.PathExists(imageDir) {
+		return nil
+	}
+
+	// Unpack in a temporary directory so that concurrent creations don't
+	// see a partially unpacked image.
+	tmpDir, err := ioutil.TempDir(shared.VarPath("images"), "lxd_image_")
+	if err != nil {
+		return err
+	}
+
+	err = unpackImage(imagePath, tmpDir, s.storage.GetStorageType(), s.s.OS.RunningInUserNS)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+
+	err = os.Rename(tmpDir, imageDir)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		if !shared.PathExists(imageDir) {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (s *storageDir) ImageDelete(fingerprint string) error {
-	return nil
+	return os.RemoveAll(shared.VarPath("images", fingerprint+".dir"))
+}
+
+// imageClone clones the unpacked image into dest, unpacking it first if
+// needed.
+func (s *storageDir) imageClone(fingerprint string, dest string) error {
+	err := s.ImageCreate(fingerprint)
+	if err != nil {
+		return err
+	}
+
+	_, err = storageReflinkCopy(shared.VarPath("images", fingerprint+".dir"), dest)
+	return err
 }
 
 func (s *storageDir) MigrationType() migration.MigrationFSType {
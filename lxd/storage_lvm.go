@@ -15,6 +15,7 @@ import (
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/idmap"
 	"github.com/lxc/lxd/shared/logger"
 
@@ -163,7 +164,10 @@ func containerNameToLVName(containerName string) string {
 }
 
 type storageLvm struct {
-	vgName string
+	vgName       string
+	thinPoolName string
+	fsType       string
+	volumeSize   string
 
 	storageShared
 }
@@ -207,6 +211,21 @@ func (s *storageLvm) Init(config map[string]interface{}) (storage, error) {
 		s.vgName = config["vgName"].(string)
 	}
 
+	s.thinPoolName = daemonConfig["storage.lvm_thinpool_name"].Get()
+	if config["thinPoolName"] != nil {
+		s.thinPoolName = config["thinPoolName"].(string)
+	}
+
+	s.fsType = daemonConfig["storage.lvm_fstype"].Get()
+	if config["fsType"] != nil {
+		s.fsType = config["fsType"].(string)
+	}
+
+	s.volumeSize = daemonConfig["storage.lvm_volume_size"].Get()
+	if config["volumeSize"] != nil {
+		s.volumeSize = config["volumeSize"].(string)
+	}
+
 	return s, nil
 }
 
@@ -320,7 +339,7 @@ func (s *storageLvm) ContainerCreateFromImage(
 	}
 
 	// Generate a new xfs's UUID
-	fstype := daemonConfig["storage.lvm_fstype"].Get()
+	fstype := s.fsType
 	if fstype == "xfs" {
 		err := xfsGenerateNewUUID(lvpath)
 		if err != nil {
@@ -435,7 +454,7 @@ func (s *storageLvm) ContainerCopy(container container, sourceContainer containe
 func (s *storageLvm) ContainerStart(name string, path string) error {
 	lvName := containerNameToLVName(name)
 	lvpath := fmt.Sprintf("/dev/%s/%s", s.vgName, lvName)
-	fstype := daemonConfig["storage.lvm_fstype"].Get()
+	fstype := s.fsType
 
 	err := tryMount(lvpath, path, fstype, 0, "discard")
 	if err != nil {
@@ -554,6 +573,253 @@ func (s *storageLvm) ContainerGetUsage(container container) (int64, error) {
 	return -1, fmt.Errorf("The LVM container backend doesn't support quotas.")
 }
 
+func (s *storageLvm) StoragePoolResources() (*api.ResourcesStoragePool, error) {
+	output, err := shared.RunCommand(
+		"vgs",
+		"--noheadings",
+		"--nosuffix",
+		"--units", "b",
+		"-o", "vg_size,vg_free",
+		s.vgName)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get LVM usage: %s", output)
+	}
+
+	fields := strings.Fields(output)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("Unexpected LVM usage output: %s", output)
+	}
+
+	size, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	free, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	res := api.ResourcesStoragePool{}
+	res.Space.Total = size
+	res.Space.Used = size - free
+
+	return &res, nil
+}
+
+// customVolumeLVName returns the name of the LV backing a custom volume.
+func customVolumeLVName(name string) string {
+	return fmt.Sprintf("custom_%s", containerNameToLVName(name))
+}
+
+func (s *storageLvm) StoragePoolVolumeCreate(name string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.createThinLV(customVolumeLVName(name))
+	if err != nil {
+		return err
+	}
+
+	return os.MkdirAll(s.volumeMountPoint(name), 0711)
+}
+
+func (s *storageLvm) StoragePoolVolumeDelete(name string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	err = s.StoragePoolVolumeUmount(name)
+	if err != nil {
+		return err
+	}
+
+	err = s.removeLV(customVolumeLVName(name))
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(s.volumeMountPoint(name))
+}
+
+func (s *storageLvm) StoragePoolVolumeRename(oldName string, newName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	if shared.IsMountPoint(s.volumeMountPoint(oldName)) {
+		return fmt.Errorf("Can't rename a volume that is in use")
+	}
+
+	output, err := s.renameLV(customVolumeLVName(oldName), customVolumeLVName(newName))
+	if err != nil {
+		s.log.Error("Failed to rename a custom volume LV", log.Ctx{"oldName": oldName, "newName": newName, "err": err, "output": string(output)})
+		return fmt.Errorf("Failed to rename a custom volume LV, oldName='%s', newName='%s', err='%s'", oldName, newName, err)
+	}
+
+	// Rename the snapshots
+	snapshots, err := s.volumeSnapshots(oldName)
+	if err != nil {
+		return err
+	}
+
+	for _, snapshotName := range snapshots {
+		oldLVName := customVolumeSnapshotLVName(oldName, snapshotName)
+		newLVName := customVolumeSnapshotLVName(newName, snapshotName)
+
+		output, err := s.renameLV(oldLVName, newLVName)
+		if err != nil {
+			s.log.Error("Failed to rename a custom volume snapshot LV", log.Ctx{"oldName": oldLVName, "newName": newLVName, "err": err, "output": string(output)})
+			return fmt.Errorf("Failed to rename a custom volume snapshot LV, oldName='%s', newName='%s', err='%s'", oldLVName, newLVName, err)
+		}
+	}
+
+	return os.Rename(s.volumeMountPoint(oldName), s.volumeMountPoint(newName))
+}
+
+func (s *storageLvm) StoragePoolVolumeSetQuota(name string, size int64) error {
+	// Logical volumes always have a size, keep the current one
+	if size == 0 {
+		return nil
+	}
+
+	lvpath := fmt.Sprintf("/dev/%s/%s", s.vgName, customVolumeLVName(name))
+
+	output, err := shared.TryRunCommand("lvextend", "-L", fmt.Sprintf("%db", size), lvpath)
+	if err != nil {
+		return fmt.Errorf("Could not extend LV '%s' (only growing volumes is supported): %s", lvpath, output)
+	}
+
+	// The filesystem is grown through the mounted volume
+	volPath, err := s.StoragePoolVolumeMount(name)
+	if err != nil {
+		return err
+	}
+
+	switch s.fsType {
+	case "xfs":
+		output, err = shared.TryRunCommand("xfs_growfs", volPath)
+	default:
+		output, err = shared.TryRunCommand("resize2fs", lvpath)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Could not grow the filesystem of '%s': %s", lvpath, output)
+	}
+
+	return nil
+}
+
+func (s *storageLvm) StoragePoolVolumeMount(name string) (string, error) {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return "", err
+	}
+
+	volPath := s.volumeMountPoint(name)
+	if shared.IsMountPoint(volPath) {
+		return volPath, nil
+	}
+
+	lvpath := fmt.Sprintf("/dev/%s/%s", s.vgName, customVolumeLVName(name))
+	err = tryMount(lvpath, volPath, s.fsType, 0, "discard")
+	if err != nil {
+		return "", fmt.Errorf("Error mounting custom volume LV path='%s': %v", volPath, err)
+	}
+
+	return volPath, nil
+}
+
+func (s *storageLvm) StoragePoolVolumeUmount(name string) error {
+	volPath := s.volumeMountPoint(name)
+	if !shared.IsMountPoint(volPath) {
+		return nil
+	}
+
+	err := tryUnmount(volPath, 0)
+	if err != nil {
+		return fmt.Errorf("Failed to unmount custom volume path '%s': %v", volPath, err)
+	}
+
+	return nil
+}
+
+// customVolumeSnapshotLVName returns the name of the LV backing a snapshot of
+// a custom volume.
+func customVolumeSnapshotLVName(name string, snapshotName string) string {
+	return customVolumeLVName(name + shared.SnapshotDelimiter + snapshotName)
+}
+
+func (s *storageLvm) StoragePoolVolumeSnapshotCreate(name string, snapshotName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.createSnapshotLV(customVolumeSnapshotLVName(name, snapshotName), customVolumeLVName(name), true)
+	if err != nil {
+		return fmt.Errorf("Error creating snapshot LV: %v", err)
+	}
+
+	return nil
+}
+
+func (s *storageLvm) StoragePoolVolumeSnapshotDelete(name string, snapshotName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	return s.removeLV(customVolumeSnapshotLVName(name, snapshotName))
+}
+
+func (s *storageLvm) StoragePoolVolumeSnapshotRename(name string, oldSnapshotName string, newSnapshotName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	oldLVName := customVolumeSnapshotLVName(name, oldSnapshotName)
+	newLVName := customVolumeSnapshotLVName(name, newSnapshotName)
+
+	output, err := s.renameLV(oldLVName, newLVName)
+	if err != nil {
+		s.log.Error("Failed to rename a custom volume snapshot LV", log.Ctx{"oldName": oldLVName, "newName": newLVName, "err": err, "output": string(output)})
+		return fmt.Errorf("Failed to rename a custom volume snapshot LV, oldName='%s', newName='%s', err='%s'", oldLVName, newLVName, err)
+	}
+
+	return nil
+}
+
+func (s *storageLvm) StoragePoolVolumeRestore(name string, snapshotName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	// The LV is replaced, so it can't be in use
+	err = s.StoragePoolVolumeUmount(name)
+	if err != nil {
+		return err
+	}
+
+	err = s.removeLV(customVolumeLVName(name))
+	if err != nil {
+		return fmt.Errorf("Error removing LV about to be restored over: %v", err)
+	}
+
+	_, err = s.createSnapshotLV(customVolumeLVName(name), customVolumeSnapshotLVName(name, snapshotName), false)
+	if err != nil {
+		return fmt.Errorf("Error creating snapshot LV: %v", err)
+	}
+
+	return nil
+}
+
 func (s *storageLvm) ContainerSnapshotCreate(
 	snapshotContainer container, sourceContainer container) error {
 	return s.createSnapshotContainer(snapshotContainer, sourceContainer, true)
@@ -673,7 +939,7 @@ func (s *storageLvm) ContainerSnapshotStart(container container) error {
 	}
 
 	// Generate a new xfs's UUID
-	fstype := daemonConfig["storage.lvm_fstype"].Get()
+	fstype := s.fsType
 	if fstype == "xfs" {
 		err := xfsGenerateNewUUID(lvpath)
 		if err != nil {
@@ -711,6 +977,14 @@ func (s *storageLvm) ContainerSnapshotCreateEmpty(snapshotContainer container) e
 	return s.ContainerCreate(snapshotContainer)
 }
 
+func (s *storageLvm) ContainerBackupCreate(path string, backup backup, sourceContainer container) error {
+	return rsyncBackupCreate(path, backup, sourceContainer)
+}
+
+func (s *storageLvm) ContainerBackupLoad(path string, info backupInfo, container container) error {
+	return rsyncBackupLoad(path, info, container)
+}
+
 func (s *storageLvm) ImageCreate(fingerprint string) error {
 	finalName := shared.VarPath("images", fingerprint)
 
@@ -736,7 +1010,7 @@ func (s *storageLvm) ImageCreate(fingerprint string) error {
 		}
 	}()
 
-	fstype := daemonConfig["storage.lvm_fstype"].Get()
+	fstype := s.fsType
 	err = tryMount(lvpath, tempLVMountPoint, fstype, 0, "discard")
 	if err != nil {
 		logger.Infof("Error mounting image LV for unpacking: %v", err)
@@ -784,7 +1058,7 @@ func (s *storageLvm) ImageDelete(fingerprint string) error {
 }
 
 func (s *storageLvm) createDefaultThinPool() (string, error) {
-	thinPoolName := daemonConfig["storage.lvm_thinpool_name"].Get()
+	thinPoolName := s.thinPoolName
 	isRecent, err := s.lvmVersionIsAtLeast("2.02.99")
 	if err != nil {
 		return "", fmt.Errorf("Error checking LVM version: %v", err)
@@ -847,9 +1121,8 @@ func (s *storageLvm) createDefaultThinPool() (string, error) {
 func (s *storageLvm) createThinLV(lvname string) (string, error) {
 	var err error
 
-	vgname := daemonConfig["storage.lvm_vg_name"].Get()
-	poolname := daemonConfig["storage.lvm_thinpool_name"].Get()
-	exists, err := storageLVMThinpoolExists(vgname, poolname)
+	poolname := s.thinPoolName
+	exists, err := storageLVMThinpoolExists(s.vgName, poolname)
 	if err != nil {
 		return "", err
 	}
@@ -860,14 +1133,17 @@ func (s *storageLvm) createThinLV(lvname string) (string, error) {
 			return "", fmt.Errorf("Error creating LVM thin pool: %v", err)
 		}
 
-		err = doStorageLVMValidateThinPoolName(s.s.DB, "", poolname)
-		if err != nil {
-			s.log.Error("Setting thin pool name", log.Ctx{"err": err})
-			return "", fmt.Errorf("Error setting LVM thin pool config: %v", err)
+		// Storage pools record their thin pool in their own config
+		if s.poolName == "" {
+			err = doStorageLVMValidateThinPoolName(s.s.DB, "", poolname)
+			if err != nil {
+				s.log.Error("Setting thin pool name", log.Ctx{"err": err})
+				return "", fmt.Errorf("Error setting LVM thin pool config: %v", err)
+			}
 		}
 	}
 
-	lvSize := daemonConfig["storage.lvm_volume_size"].Get()
+	lvSize := s.volumeSize
 
 	output, err := shared.TryRunCommand(
 		"lvcreate",
@@ -882,7 +1158,7 @@ func (s *storageLvm) createThinLV(lvname string) (string, error) {
 
 	lvpath := fmt.Sprintf("/dev/%s/%s", s.vgName, lvname)
 
-	fstype := daemonConfig["storage.lvm_fstype"].Get()
+	fstype := s.fsType
 	switch fstype {
 	case "xfs":
 		output, err = shared.TryRunCommand(
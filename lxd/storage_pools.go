@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// /1.0/storage-pools
+func storagePoolsGet(d *Daemon, r *http.Request) Response {
+	pools, err := d.db.StoragePools()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	recursion := util.IsRecursionRequest(r)
+
+	resultString := []string{}
+	resultMap := []*api.StoragePool{}
+	for _, name := range pools {
+		if !recursion {
+			resultString = append(resultString, fmt.Sprintf("/%s/storage-pools/%s", version.APIVersion, name))
+		} else {
+			pool, err := doStoragePoolGet(d.State(), name)
+			if err != nil {
+				logger.Error("Failed to get storage pool", log.Ctx{"pool": name})
+				continue
+			}
+			resultMap = append(resultMap, pool)
+		}
+	}
+
+	if !recursion {
+		return SyncResponse(true, resultString)
+	}
+
+	return SyncResponse(true, resultMap)
+}
+
+func storagePoolsPost(d *Daemon, r *http.Request) Response {
+	req := api.StoragePoolsPost{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	// Sanity checks
+	if req.Name == "" {
+		return BadRequest(fmt.Errorf("No name provided"))
+	}
+
+	if req.Driver == "" {
+		return BadRequest(fmt.Errorf("No driver provided"))
+	}
+
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	_, pool, _ := d.db.StoragePoolGet(req.Name)
+	if pool != nil {
+		return BadRequest(fmt.Errorf("The storage pool already exists"))
+	}
+
+	err := storagePoolValidateConfig(req.Name, req.Driver, req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// Make sure the backing storage is usable
+	if !d.os.MockMode {
+		err = storagePoolCheck(req.Driver, req.Config)
+		if err != nil {
+			return BadRequest(err)
+		}
+
+		_, err = storagePoolNew(d.State(), d.Storage, req.Name, req.Driver, req.Config)
+		if err != nil {
+			return BadRequest(err)
+		}
+	}
+
+	// Create the database entry
+	_, err = d.db.StoragePoolCreate(req.Name, req.Description, req.Driver, req.Config)
+	if err != nil {
+		return SmartError(
+			fmt.Errorf("Error inserting %s into database: %s", req.Name, err))
+	}
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/storage-pools/%s", version.APIVersion, req.Name))
+}
+
+var storagePoolsCmd = Command{name: "storage-pools", get: storagePoolsGet, post: storagePoolsPost}
+
+func doStoragePoolGet(s *state.State, name string) (*api.StoragePool, error) {
+	_, pool, err := s.DB.StoragePoolGet(name)
+	if err != nil {
+		return nil, err
+	}
+
+	pool.UsedBy, err = storagePoolUsedByGet(s, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// /1.0/storage-pools/{name}
+func storagePoolGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	pool, err := doStoragePoolGet(d.State(), name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, pool)
+}
+
+func storagePoolPut(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	_, pool, err := d.db.StoragePoolGet(name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	req := api.StoragePoolPut{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	err = storagePoolValidateConfig(name, pool.Driver, req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// The backing storage can't be moved once the pool exists
+	for _, key := range []string{"zfs.pool_name", "lvm.vg_name", "lvm.thinpool_name"} {
+		if req.Config[key] != pool.Config[key] {
+			return BadRequest(fmt.Errorf("The key %s cannot be changed", key))
+		}
+	}
+
+	err = d.db.StoragePoolUpdate(name, req.Description, req.Config)
+	if err != nil {
+		return SmartError(err)
+	}
+	storagePoolForget(name)
+
+	return EmptySyncResponse
+}
+
+func storagePoolDelete(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	pool, err := doStoragePoolGet(d.State(), name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if len(pool.UsedBy) != 0 {
+		return BadRequest(fmt.Errorf("The storage pool is currently in use"))
+	}
+
+	err = d.db.StoragePoolDelete(name)
+	if err != nil {
+		return SmartError(err)
+	}
+	storagePoolForget(name)
+
+	return EmptySyncResponse
+}
+
+var storagePoolCmd = Command{name: "storage-pools/{name}", get: storagePoolGet, put: storagePoolPut, delete: storagePoolDelete}
+
+// /1.0/storage-pools/{name}/resources
+func storagePoolResourcesGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	st, err := storagePoolInit(d.State(), d.Storage, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	res, err := st.StoragePoolResources()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return SyncResponse(true, res)
+}
+
+var storagePoolResourcesCmd = Command{name: "storage-pools/{name}/resources", get: storagePoolResourcesGet}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+)
+
+var storagePoolConfigKeys = map[string]func(value string) error{
+	// Driver specific keys
+	"lvm.thinpool_name": func(value string) error {
+		return nil
+	},
+	"lvm.vg_name": func(value string) error {
+		return nil
+	},
+	"zfs.pool_name": func(value string) error {
+		return nil
+	},
+
+	// Volume defaults
+	"volume.block.filesystem": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		if !shared.StringInSlice(value, []string{"ext4", "xfs"}) {
+			return fmt.Errorf("Invalid value: %s (not one of %s)", value, []string{"ext4", "xfs"})
+		}
+
+		return nil
+	},
+	"volume.size": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		_, err := shared.ParseByteSizeString(value)
+		return err
+	},
+}
+
+// storagePoolValidateConfig checks that the given configuration is valid for
+// a pool using the given driver.
+func storagePoolValidateConfig(name string, driver string, config map[string]string) error {
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("Storage pool names may not contain slashes")
+	}
+
+	if shared.StringInSlice(name, []string{".", ".."}) {
+		return fmt.Errorf("Invalid storage pool name '%s'", name)
+	}
+
+	for key, value := range config {
+		validator, ok := storagePoolConfigKeys[key]
+		if !ok {
+			return fmt.Errorf("Invalid storage pool configuration key: %s", key)
+		}
+
+		prefix := strings.SplitN(key, ".", 2)[0]
+		if prefix != "volume" && prefix != driver {
+			return fmt.Errorf("The key %s cannot be used with %s storage pools", key, driver)
+		}
+
+		if strings.HasPrefix(key, "volume.") && driver != "lvm" {
+			return fmt.Errorf("The key %s cannot be used with %s storage pools", key, driver)
+		}
+
+		err := validator(value)
+		if err != nil {
+			return fmt.Errorf("Invalid value for %s: %v", key, err)
+		}
+	}
+
+	switch driver {
+	case "zfs":
+		if config["zfs.pool_name"] == "" {
+			return fmt.Errorf("ZFS storage pools require zfs.pool_name")
+		}
+	case "lvm":
+		if config["lvm.vg_name"] == "" {
+			return fmt.Errorf("LVM storage pools require lvm.vg_name")
+		}
+	case "btrfs", "dir":
+	default:
+		return fmt.Errorf("Invalid storage pool driver: %s", driver)
+	}
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/version"
+)
+
+// storagePoolDriverConfig translates the user facing configuration of a
+// storage pool into the configuration map understood by the storage drivers.
+func storagePoolDriverConfig(name string, config map[string]string) map[string]interface{} {
+	driverConfig := map[string]interface{}{
+		"poolName": name,
+	}
+
+	if config["zfs.pool_name"] != "" {
+		driverConfig["zfsPool"] = config["zfs.pool_name"]
+	}
+
+	if config["lvm.vg_name"] != "" {
+		driverConfig["vgName"] = config["lvm.vg_name"]
+	}
+
+	if config["lvm.thinpool_name"] != "" {
+		driverConfig["thinPoolName"] = config["lvm.thinpool_name"]
+	}
+
+	if config["volume.block.filesystem"] != "" {
+		driverConfig["fsType"] = config["volume.block.filesystem"]
+	}
+
+	if config["volume.size"] != "" {
+		driverConfig["volumeSize"] = config["volume.size"]
+	}
+
+	return driverConfig
+}
+
+// storagePoolCheck makes sure the backing storage of a pool is usable before
+// it gets recorded in the database.
+func storagePoolCheck(driver string, config map[string]string) error {
+	switch driver {
+	case "btrfs":
+		fs, err := util.FilesystemDetect(shared.VarPath())
+		if err != nil {
+			return err
+		}
+
+		if fs != "btrfs" {
+			return fmt.Errorf("The btrfs driver requires %s to be on a btrfs filesystem", shared.VarPath())
+		}
+	case "lvm":
+		return storageLVMCheckVolumeGroup(config["lvm.vg_name"])
+	}
+
+	return nil
+}
+
+// storagePoolNew returns a storage driver for a pool that isn't in the
+// database yet.
+func storagePoolNew(s *state.State, st storage, name string, driver string, config map[string]string) (storage, error) {
+	if s.OS.MockMode {
+		return st, nil
+	}
+
+	sType, err := storageStringToType(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStorageWithConfig(s, nil, sType, storagePoolDriverConfig(name, config))
+}
+
+// Initialized storage drivers, by pool name. Setting up a driver can be
+// expensive (tool checks, mounts), so it's only done once per pool.
+var storagePoolDrivers = map[string]storage{}
+var storagePoolDriversLock sync.Mutex
+
+// storagePoolInit returns a storage driver for the pool with the given name.
+func storagePoolInit(s *state.State, st storage, name string) (storage, error) {
+	storagePoolDriversLock.Lock()
+	defer storagePoolDriversLock.Unlock()
+
+	driver, ok := storagePoolDrivers[name]
+	if ok {
+		return driver, nil
+	}
+
+	_, pool, err := s.DB.StoragePoolGet(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.OS.MockMode {
+		return st, nil
+	}
+
+	driver, err = storagePoolNew(s, st, name, pool.Driver, pool.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	storagePoolDrivers[name] = driver
+
+	return driver, nil
+}
+
+// storagePoolForget drops the cached driver of the given pool, so that the
+// next storagePoolInit call picks up its new configuration.
+func storagePoolForget(name string) {
+	storagePoolDriversLock.Lock()
+	defer storagePoolDriversLock.Unlock()
+
+	delete(storagePoolDrivers, name)
+}
+
+// storagePoolUsedByGet returns the URLs of the custom volumes on the given
+// storage pool and of the containers and profiles whose root disk is on it.
+func storagePoolUsedByGet(s *state.State, poolName string) ([]string, error) {
+	usedBy := []string{}
+
+	poolID, _, err := s.DB.StoragePoolGet(poolName)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes, err := s.DB.StoragePoolVolumes(poolID, db.StoragePoolVolumeTypeCustom)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range volumes {
+		usedBy = append(usedBy, fmt.Sprintf("/%s/storage-pools/%s/volumes/%s/%s", version.APIVersion, poolName, db.StoragePoolVolumeTypeNameCustom, name))
+	}
+
+	profiles, err := s.DB.Profiles()
+	if err != nil {
+		return nil, err
+	}
+
+	profileDevices := map[string]types.Devices{}
+	for _, name := range profiles {
+		_, profile, err := s.DB.ProfileGet(name)
+		if err != nil {
+			return nil, err
+		}
+		profileDevices[name] = profile.Devices
+
+		_, rootDiskDevice, err := containerGetRootDiskDevice(profile.Devices)
+		if err == nil && rootDiskDevice["pool"] == poolName {
+			usedBy = append(usedBy, fmt.Sprintf("/%s/profiles/%s", version.APIVersion, name))
+		}
+	}
+
+	containers, err := s.DB.ContainersList(db.CTypeRegular)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range containers {
+		args, err := s.DB.ContainerGet(name)
+		if err != nil {
+			return nil, err
+		}
+
+		// Expand the devices the same way containerLXC does
+		devices := types.Devices{}
+		for _, profile := range args.Profiles {
+			for k, v := range profileDevices[profile] {
+				devices[k] = v
+			}
+		}
+
+		for k, v := range args.Devices {
+			devices[k] = v
+		}
+
+		_, rootDiskDevice, err := containerGetRootDiskDevice(devices)
+		if err == nil && rootDiskDevice["pool"] == poolName {
+			usedBy = append(usedBy, fmt.Sprintf("/%s/containers/%s", version.APIVersion, name))
+		}
+	}
+
+	return usedBy, nil
+}
+
+// storagePoolDevicesCheck makes sure that the storage pools and custom
+// volumes referenced by disks in the given devices exist.
+func storagePoolDevicesCheck(s *state.State, devices types.Devices) error {
+	for _, device := range devices {
+		if device["type"] != "disk" || device["pool"] == "" {
+			continue
+		}
+
+		poolID, _, err := s.DB.StoragePoolGet(device["pool"])
+		if err == db.NoSuchObjectError {
+			return fmt.Errorf("Storage pool '%s' doesn't exist", device["pool"])
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !isCustomVolumeDevice(device) {
+			continue
+		}
+
+		_, _, err = s.DB.StoragePoolVolumeGet(poolID, device["source"], db.StoragePoolVolumeTypeCustom)
+		if err == db.NoSuchObjectError {
+			return fmt.Errorf("Storage volume '%s' doesn't exist on pool '%s'", device["source"], device["pool"])
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/gorilla/websocket"
 
 	"github.com/lxc/lxd/lxd/migration"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/idmap"
 
 	log "github.com/lxc/lxd/shared/log15"
@@ -38,6 +39,50 @@ func (s *storageMock) GetStorageTypeName() string {
 	return s.sTypeName
 }
 
+func (s *storageMock) StoragePoolResources() (*api.ResourcesStoragePool, error) {
+	return &api.ResourcesStoragePool{}, nil
+}
+
+func (s *storageMock) StoragePoolVolumeCreate(name string) error {
+	return nil
+}
+
+func (s *storageMock) StoragePoolVolumeDelete(name string) error {
+	return nil
+}
+
+func (s *storageMock) StoragePoolVolumeRename(oldName string, newName string) error {
+	return nil
+}
+
+func (s *storageMock) StoragePoolVolumeSetQuota(name string, size int64) error {
+	return nil
+}
+
+func (s *storageMock) StoragePoolVolumeMount(name string) (string, error) {
+	return "", nil
+}
+
+func (s *storageMock) StoragePoolVolumeUmount(name string) error {
+	return nil
+}
+
+func (s *storageMock) StoragePoolVolumeSnapshotCreate(name string, snapshotName string) error {
+	return nil
+}
+
+func (s *storageMock) StoragePoolVolumeSnapshotDelete(name string, snapshotName string) error {
+	return nil
+}
+
+func (s *storageMock) StoragePoolVolumeSnapshotRename(name string, oldSnapshotName string, newSnapshotName string) error {
+	return nil
+}
+
+func (s *storageMock) StoragePoolVolumeRestore(name string, snapshotName string) error {
+	return nil
+}
+
 func (s *storageMock) ContainerCreate(container container) error {
 	return nil
 }
@@ -122,6 +167,14 @@ func (s *storageMock) ContainerSnapshotCreateEmpty(snapshotContainer container)
 	return nil
 }
 
+func (s *storageMock) ContainerBackupCreate(path string, backup backup, sourceContainer container) error {
+	return nil
+}
+
+func (s *storageMock) ContainerBackupLoad(path string, info backupInfo, container container) error {
+	return nil
+}
+
 func (s *storageMock) ImageCreate(fingerprint string) error {
 	return nil
 }
@@ -1,10 +1,20 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
 	"syscall"
 	"time"
+
+	"github.com/lxc/lxd/shared"
 )
 
+// The FICLONE ioctl, which makes a file share the extents of another one
+// (reflink) on filesystems supporting it, like btrfs or XFS.
+const ficlone = 0x40049409
+
 // Useful functions for unreliable backends
 func tryMount(src string, dst string, fs string, flags uintptr, options string) error {
 	var err error
@@ -43,3 +53,52 @@ func tryUnmount(path string, flags int) error {
 
 	return nil
 }
+
+// storageReflinkSupported checks whether the filesystem backing path supports
+// cloning files through the FICLONE ioctl.
+func storageReflinkSupported(path string) bool {
+	src, err := ioutil.TempFile(path, ".lxd_reflink_")
+	if err != nil {
+		return false
+	}
+	defer os.Remove(src.Name())
+	defer src.Close()
+
+	_, err = src.Write([]byte("reflink"))
+	if err != nil {
+		return false
+	}
+
+	dst, err := ioutil.TempFile(path, ".lxd_reflink_")
+	if err != nil {
+		return false
+	}
+	defer os.Remove(dst.Name())
+	defer dst.Close()
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), ficlone, src.Fd())
+	return errno == 0
+}
+
+// storageReflinkCopy copies the content of the source directory into dest,
+// cloning the files rather than duplicating their data. It fails if the
+// filesystem doesn't support reflinks, or if source and dest are on different
+// filesystems.
+func storageReflinkCopy(source string, dest string) (string, error) {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return "", err
+	}
+
+	output, err := shared.RunCommand(
+		"cp",
+		"-a",
+		"--reflink=always",
+		"--no-target-directory",
+		source,
+		dest)
+	if err != nil {
+		return output, fmt.Errorf("Failed to clone %s: %s", source, strings.TrimSpace(output))
+	}
+
+	return output, nil
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// storagePoolVolumeTypeFromRequest parses the volume type from the request
+// and makes sure it's one that can be managed through the API.
+func storagePoolVolumeTypeFromRequest(r *http.Request) (int, error) {
+	typeName := mux.Vars(r)["type"]
+
+	volumeType, err := db.StoragePoolVolumeTypeFromName(typeName)
+	if err != nil {
+		return -1, err
+	}
+
+	if volumeType != db.StoragePoolVolumeTypeCustom {
+		return -1, fmt.Errorf("Only custom storage volumes can be managed through the API")
+	}
+
+	return volumeType, nil
+}
+
+func doStoragePoolVolumeGet(s *state.State, poolName string, poolID int64, name string) (int64, *api.StorageVolume, error) {
+	id, volume, err := s.DB.StoragePoolVolumeGet(poolID, name, db.StoragePoolVolumeTypeCustom)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	volume.UsedBy, err = storagePoolVolumeUsedByGet(s, poolName, name)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	return id, volume, nil
+}
+
+// /1.0/storage-pools/{pool}/volumes
+// /1.0/storage-pools/{pool}/volumes/{type}
+func storagePoolVolumesGet(d *Daemon, r *http.Request) Response {
+	poolName := mux.Vars(r)["pool"]
+
+	poolID, _, err := d.db.StoragePoolGet(poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if mux.Vars(r)["type"] != "" {
+		_, err = storagePoolVolumeTypeFromRequest(r)
+		if err != nil {
+			return BadRequest(err)
+		}
+	}
+
+	volumes, err := d.db.StoragePoolVolumes(poolID, db.StoragePoolVolumeTypeCustom)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	recursion := util.IsRecursionRequest(r)
+
+	resultString := []string{}
+	resultMap := []*api.StorageVolume{}
+	for _, name := range volumes {
+		if !recursion {
+			resultString = append(resultString, fmt.Sprintf("/%s/storage-pools/%s/volumes/%s/%s", version.APIVersion, poolName, db.StoragePoolVolumeTypeNameCustom, name))
+		} else {
+			_, volume, err := doStoragePoolVolumeGet(d.State(), poolName, poolID, name)
+			if err != nil {
+				logger.Error("Failed to get storage volume", log.Ctx{"pool": poolName, "volume": name})
+				continue
+			}
+			resultMap = append(resultMap, volume)
+		}
+	}
+
+	if !recursion {
+		return SyncResponse(true, resultString)
+	}
+
+	return SyncResponse(true, resultMap)
+}
+
+func storagePoolVolumesTypePost(d *Daemon, r *http.Request) Response {
+	poolName := mux.Vars(r)["pool"]
+
+	volumeType, err := storagePoolVolumeTypeFromRequest(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	req := api.StorageVolumesPost{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Type != "" && req.Type != db.StoragePoolVolumeTypeNameCustom {
+		return BadRequest(fmt.Errorf("Only custom storage volumes can be created"))
+	}
+
+	if req.Source.Name != "" {
+		return BadRequest(fmt.Errorf("Copying storage volumes isn't supported"))
+	}
+
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	poolID, pool, err := d.db.StoragePoolGet(poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = storagePoolVolumeValidateConfig(req.Name, pool.Driver, req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	_, volume, _ := d.db.StoragePoolVolumeGet(poolID, req.Name, volumeType)
+	if volume != nil {
+		return BadRequest(fmt.Errorf("The storage volume already exists"))
+	}
+
+	st, err := storagePoolInit(d.State(), d.Storage, poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = st.StoragePoolVolumeCreate(req.Name)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	if req.Config["size"] != "" {
+		size, _ := shared.ParseByteSizeString(req.Config["size"])
+		err = st.StoragePoolVolumeSetQuota(req.Name, size)
+		if err != nil {
+			st.StoragePoolVolumeDelete(req.Name)
+			return InternalError(err)
+		}
+	}
+
+	_, err = d.db.StoragePoolVolumeCreate(poolID, req.Name, req.Description, volumeType, req.Config)
+	if err != nil {
+		st.StoragePoolVolumeDelete(req.Name)
+		return SmartError(
+			fmt.Errorf("Error inserting %s into database: %s", req.Name, err))
+	}
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/storage-pools/%s/volumes/%s/%s", version.APIVersion, poolName, db.StoragePoolVolumeTypeNameCustom, req.Name))
+}
+
+var storagePoolVolumesCmd = Command{name: "storage-pools/{pool}/volumes", get: storagePoolVolumesGet}
+var storagePoolVolumesTypeCmd = Command{name: "storage-pools/{pool}/volumes/{type}", get: storagePoolVolumesGet, post: storagePoolVolumesTypePost}
+
+// /1.0/storage-pools/{pool}/volumes/{type}/{name}
+func storagePoolVolumeTypeGet(d *Daemon, r *http.Request) Response {
+	poolName := mux.Vars(r)["pool"]
+	name := mux.Vars(r)["name"]
+
+	_, err := storagePoolVolumeTypeFromRequest(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	poolID, _, err := d.db.StoragePoolGet(poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	_, volume, err := doStoragePoolVolumeGet(d.State(), poolName, poolID, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, volume)
+}
+
+func storagePoolVolumeTypePut(d *Daemon, r *http.Request) Response {
+	poolName := mux.Vars(r)["pool"]
+	name := mux.Vars(r)["name"]
+
+	_, err := storagePoolVolumeTypeFromRequest(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	poolID, pool, err := d.db.StoragePoolGet(poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	id, volume, err := d.db.StoragePoolVolumeGet(poolID, name, db.StoragePoolVolumeTypeCustom)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	req := api.StorageVolumePut{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	// Restoring a snapshot leaves the configuration untouched
+	if req.Restore != "" {
+		return storagePoolVolumeSnapshotRestore(d, poolName, name, id, req.Restore)
+	}
+
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	// Check that volatile wasn't modified
+	for k, v := range req.Config {
+		if strings.HasPrefix(k, "volatile.") {
+			if volume.Config[k] != v {
+				return BadRequest(fmt.Errorf("Volatile keys are read-only"))
+			}
+
+			delete(req.Config, k)
+		}
+	}
+
+	err = storagePoolVolumeValidateConfig(name, pool.Driver, req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// Resize the volume
+	if req.Config["size"] != volume.Config["size"] {
+		st, err := storagePoolInit(d.State(), d.Storage, poolName)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		size, _ := shared.ParseByteSizeString(req.Config["size"])
+		err = st.StoragePoolVolumeSetQuota(name, size)
+		if err != nil {
+			return InternalError(err)
+		}
+	}
+
+	// Keep the internal keys
+	for k, v := range volume.Config {
+		if strings.HasPrefix(k, "volatile.") {
+			req.Config[k] = v
+		}
+	}
+
+	err = d.db.StoragePoolVolumeUpdate(id, req.Description, req.Config)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+func storagePoolVolumeTypePost(d *Daemon, r *http.Request) Response {
+	poolName := mux.Vars(r)["pool"]
+	name := mux.Vars(r)["name"]
+
+	volumeType, err := storagePoolVolumeTypeFromRequest(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	req := api.StorageVolumePost{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Pool != "" && req.Pool != poolName {
+		return BadRequest(fmt.Errorf("Moving storage volumes between pools isn't supported"))
+	}
+
+	poolID, pool, err := d.db.StoragePoolGet(poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = storagePoolVolumeValidateConfig(req.Name, pool.Driver, nil)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	id, volume, err := doStoragePoolVolumeGet(d.State(), poolName, poolID, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Check that the name isn't already in use
+	_, existing, _ := d.db.StoragePoolVolumeGet(poolID, req.Name, volumeType)
+	if existing != nil {
+		return Conflict
+	}
+
+	if len(volume.UsedBy) != 0 {
+		return BadRequest(fmt.Errorf("The storage volume is currently in use"))
+	}
+
+	st, err := storagePoolInit(d.State(), d.Storage, poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = st.StoragePoolVolumeRename(name, req.Name)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	err = d.db.StoragePoolVolumeRename(id, req.Name)
+	if err != nil {
+		st.StoragePoolVolumeRename(req.Name, name)
+		return SmartError(err)
+	}
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/storage-pools/%s/volumes/%s/%s", version.APIVersion, poolName, db.StoragePoolVolumeTypeNameCustom, req.Name))
+}
+
+func storagePoolVolumeTypeDelete(d *Daemon, r *http.Request) Response {
+	poolName := mux.Vars(r)["pool"]
+	name := mux.Vars(r)["name"]
+
+	_, err := storagePoolVolumeTypeFromRequest(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	poolID, _, err := d.db.StoragePoolGet(poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	id, volume, err := doStoragePoolVolumeGet(d.State(), poolName, poolID, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if len(volume.UsedBy) != 0 {
+		return BadRequest(fmt.Errorf("The storage volume is currently in use"))
+	}
+
+	st, err := storagePoolInit(d.State(), d.Storage, poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Delete the snapshots first
+	snapshots, err := d.db.StoragePoolVolumeSnapshots(id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	for _, snapshotName := range snapshots {
+		args, err := d.db.StoragePoolVolumeSnapshotGet(id, snapshotName)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		err = st.StoragePoolVolumeSnapshotDelete(name, snapshotName)
+		if err != nil {
+			return InternalError(err)
+		}
+
+		err = d.db.StoragePoolVolumeSnapshotDelete(args.ID)
+		if err != nil {
+			return SmartError(err)
+		}
+	}
+
+	err = st.StoragePoolVolumeDelete(name)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	err = d.db.StoragePoolVolumeDelete(id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+var storagePoolVolumeTypeCmd = Command{name: "storage-pools/{pool}/volumes/{type}/{name}", get: storagePoolVolumeTypeGet, put: storagePoolVolumeTypePut, post: storagePoolVolumeTypePost, delete: storagePoolVolumeTypeDelete}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+// storagePoolVolumeSnapshotValidateName checks the name of a new custom
+// volume snapshot.
+func storagePoolVolumeSnapshotValidateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("No name provided")
+	}
+
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("Snapshot names may not contain slashes")
+	}
+
+	if name == "." || name == ".." {
+		return fmt.Errorf("Invalid snapshot name '%s'", name)
+	}
+
+	return nil
+}
+
+// storagePoolVolumeID returns the ID of the given custom volume.
+func storagePoolVolumeID(d *Daemon, poolName string, name string) (int64, error) {
+	poolID, _, err := d.db.StoragePoolGet(poolName)
+	if err != nil {
+		return -1, err
+	}
+
+	volumeID, _, err := d.db.StoragePoolVolumeGet(poolID, name, db.StoragePoolVolumeTypeCustom)
+	if err != nil {
+		return -1, err
+	}
+
+	return volumeID, nil
+}
+
+func storagePoolVolumeSnapshotRender(args db.StorageVolumeSnapshotArgs) *api.StorageVolumeSnapshot {
+	return &api.StorageVolumeSnapshot{
+		Name:         args.Name,
+		Description:  args.Description,
+		CreationDate: args.CreationDate,
+	}
+}
+
+// /1.0/storage-pools/{pool}/volumes/{type}/{name}/snapshots
+func storagePoolVolumeSnapshotsTypeGet(d *Daemon, r *http.Request) Response {
+	poolName := mux.Vars(r)["pool"]
+	name := mux.Vars(r)["name"]
+
+	_, err := storagePoolVolumeTypeFromRequest(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	volumeID, err := storagePoolVolumeID(d, poolName, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	snapshots, err := d.db.StoragePoolVolumeSnapshots(volumeID)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	recursion := util.IsRecursionRequest(r)
+
+	resultString := []string{}
+	resultMap := []*api.StorageVolumeSnapshot{}
+	for _, snapshotName := range snapshots {
+		if !recursion {
+			url := fmt.Sprintf("/%s/storage-pools/%s/volumes/%s/%s/snapshots/%s", version.APIVersion, poolName, db.StoragePoolVolumeTypeNameCustom, name, snapshotName)
+			resultString = append(resultString, url)
+		} else {
+			args, err := d.db.StoragePoolVolumeSnapshotGet(volumeID, snapshotName)
+			if err != nil {
+				continue
+			}
+
+			resultMap = append(resultMap, storagePoolVolumeSnapshotRender(args))
+		}
+	}
+
+	if !recursion {
+		return SyncResponse(true, resultString)
+	}
+
+	return SyncResponse(true, resultMap)
+}
+
+func storagePoolVolumeSnapshotsTypePost(d *Daemon, r *http.Request) Response {
+	poolName := mux.Vars(r)["pool"]
+	name := mux.Vars(r)["name"]
+
+	_, err := storagePoolVolumeTypeFromRequest(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	volumeID, err := storagePoolVolumeID(d, poolName, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	req := api.StorageVolumeSnapshotsPost{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Name == "" {
+		// come up with a name
+		i := d.db.StoragePoolVolumeNextSnapshot(volumeID)
+		req.Name = fmt.Sprintf("snap%d", i)
+	}
+
+	err = storagePoolVolumeSnapshotValidateName(req.Name)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	_, err = d.db.StoragePoolVolumeSnapshotGet(volumeID, req.Name)
+	if err == nil {
+		return Conflict
+	}
+
+	st, err := storagePoolInit(d.State(), d.Storage, poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	snapshot := func(op *operation) error {
+		err := st.StoragePoolVolumeSnapshotCreate(name, req.Name)
+		if err != nil {
+			return err
+		}
+
+		_, err = d.db.StoragePoolVolumeSnapshotCreate(db.StorageVolumeSnapshotArgs{
+			VolumeID:     volumeID,
+			Name:         req.Name,
+			Description:  req.Description,
+			CreationDate: time.Now().UTC(),
+		})
+		if err != nil {
+			st.StoragePoolVolumeSnapshotDelete(name, req.Name)
+			return err
+		}
+
+		return nil
+	}
+
+	resources := map[string][]string{}
+	resources["storage-pools"] = []string{fmt.Sprintf("%s/volumes/%s/%s", poolName, db.StoragePoolVolumeTypeNameCustom, name)}
+
+	op, err := operationCreate(operationClassTask, resources, nil, snapshot, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+var storagePoolVolumeSnapshotsTypeCmd = Command{name: "storage-pools/{pool}/volumes/{type}/{name}/snapshots", get: storagePoolVolumeSnapshotsTypeGet, post: storagePoolVolumeSnapshotsTypePost}
+
+// /1.0/storage-pools/{pool}/volumes/{type}/{name}/snapshots/{snapshotName}
+func storagePoolVolumeSnapshotTypeGet(d *Daemon, r *http.Request) Response {
+	poolName := mux.Vars(r)["pool"]
+	name := mux.Vars(r)["name"]
+	snapshotName := mux.Vars(r)["snapshotName"]
+
+	_, err := storagePoolVolumeTypeFromRequest(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	volumeID, err := storagePoolVolumeID(d, poolName, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	args, err := d.db.StoragePoolVolumeSnapshotGet(volumeID, snapshotName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, storagePoolVolumeSnapshotRender(args))
+}
+
+func storagePoolVolumeSnapshotTypePost(d *Daemon, r *http.Request) Response {
+	poolName := mux.Vars(r)["pool"]
+	name := mux.Vars(r)["name"]
+	snapshotName := mux.Vars(r)["snapshotName"]
+
+	_, err := storagePoolVolumeTypeFromRequest(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	volumeID, err := storagePoolVolumeID(d, poolName, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	req := api.StorageVolumeSnapshotPost{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return BadRequest(err)
+	}
+
+	err = storagePoolVolumeSnapshotValidateName(req.Name)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	args, err := d.db.StoragePoolVolumeSnapshotGet(volumeID, snapshotName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Check that the name isn't already in use
+	_, err = d.db.StoragePoolVolumeSnapshotGet(volumeID, req.Name)
+	if err == nil {
+		return Conflict
+	}
+
+	st, err := storagePoolInit(d.State(), d.Storage, poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = st.StoragePoolVolumeSnapshotRename(name, snapshotName, req.Name)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	err = d.db.StoragePoolVolumeSnapshotRename(args.ID, req.Name)
+	if err != nil {
+		st.StoragePoolVolumeSnapshotRename(name, req.Name, snapshotName)
+		return SmartError(err)
+	}
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/storage-pools/%s/volumes/%s/%s/snapshots/%s", version.APIVersion, poolName, db.StoragePoolVolumeTypeNameCustom, name, req.Name))
+}
+
+func storagePoolVolumeSnapshotTypeDelete(d *Daemon, r *http.Request) Response {
+	poolName := mux.Vars(r)["pool"]
+	name := mux.Vars(r)["name"]
+	snapshotName := mux.Vars(r)["snapshotName"]
+
+	_, err := storagePoolVolumeTypeFromRequest(r)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	volumeID, err := storagePoolVolumeID(d, poolName, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	args, err := d.db.StoragePoolVolumeSnapshotGet(volumeID, snapshotName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	st, err := storagePoolInit(d.State(), d.Storage, poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = st.StoragePoolVolumeSnapshotDelete(name, snapshotName)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	err = d.db.StoragePoolVolumeSnapshotDelete(args.ID)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+var storagePoolVolumeSnapshotTypeCmd = Command{name: "storage-pools/{pool}/volumes/{type}/{name}/snapshots/{snapshotName}", get: storagePoolVolumeSnapshotTypeGet, post: storagePoolVolumeSnapshotTypePost, delete: storagePoolVolumeSnapshotTypeDelete}
+
+// storagePoolVolumeSnapshotRestore replaces the content of a custom volume
+// with the one of the given snapshot. The volume can't be in use by a running
+// container.
+func storagePoolVolumeSnapshotRestore(d *Daemon, poolName string, name string, volumeID int64, snapshotName string) Response {
+	_, err := d.db.StoragePoolVolumeSnapshotGet(volumeID, snapshotName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	running, err := storagePoolVolumeUsedByRunning(d.State(), d.Storage, poolName, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if len(running) != 0 {
+		return BadRequest(fmt.Errorf("The storage volume is in use by running containers: %s", strings.Join(running, ", ")))
+	}
+
+	st, err := storagePoolInit(d.State(), d.Storage, poolName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = st.StoragePoolVolumeRestore(name, snapshotName)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return EmptySyncResponse
+}
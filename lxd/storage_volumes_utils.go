@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/types"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/idmap"
+	"github.com/lxc/lxd/shared/version"
+)
+
+// storagePoolVolumeMountPoint returns the path a custom volume is available
+// at on the host.
+func storagePoolVolumeMountPoint(poolName string, volumeName string) string {
+	return shared.VarPath("storage-pools", poolName, "custom", volumeName)
+}
+
+// storagePoolVolumeSnapshotsPath returns the path the snapshots of a custom
+// volume are stored in on drivers keeping them as directories.
+func storagePoolVolumeSnapshotsPath(poolName string, volumeName string) string {
+	return shared.VarPath("storage-pools", poolName, "custom-snapshots", volumeName)
+}
+
+// storagePoolVolumeValidateConfig checks the user provided configuration of a
+// custom storage volume on a pool using the given driver.
+func storagePoolVolumeValidateConfig(name string, driver string, config map[string]string) error {
+	if name == "" {
+		return fmt.Errorf("No name provided")
+	}
+
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("Storage volume names may not contain slashes")
+	}
+
+	if shared.StringInSlice(name, []string{".", ".."}) {
+		return fmt.Errorf("Invalid storage volume name '%s'", name)
+	}
+
+	for key, value := range config {
+		switch key {
+		case "size":
+			if value == "" {
+				continue
+			}
+
+			if driver == "dir" {
+				return fmt.Errorf("The %s driver doesn't support volume quotas", driver)
+			}
+
+			_, err := shared.ParseByteSizeString(value)
+			if err != nil {
+				return fmt.Errorf("Invalid value for size: %v", err)
+			}
+		default:
+			if strings.HasPrefix(key, "volatile.") {
+				return fmt.Errorf("Volatile keys are read-only")
+			}
+
+			return fmt.Errorf("Invalid storage volume configuration key: %s", key)
+		}
+	}
+
+	return nil
+}
+
+// storagePoolVolumeUsedByGet returns the URLs of the containers and profiles
+// with a disk device referencing the given custom volume.
+func storagePoolVolumeUsedByGet(s *state.State, poolName string, volumeName string) ([]string, error) {
+	usedBy := []string{}
+
+	isVolume := func(devices types.Devices) bool {
+		for _, device := range devices {
+			if isCustomVolumeDevice(device) && device["pool"] == poolName && device["source"] == volumeName {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	containers, err := s.DB.ContainersList(db.CTypeRegular)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range containers {
+		args, err := s.DB.ContainerGet(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if isVolume(args.Devices) {
+			usedBy = append(usedBy, fmt.Sprintf("/%s/containers/%s", version.APIVersion, name))
+		}
+	}
+
+	profiles, err := s.DB.Profiles()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range profiles {
+		_, profile, err := s.DB.ProfileGet(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if isVolume(profile.Devices) {
+			usedBy = append(usedBy, fmt.Sprintf("/%s/profiles/%s", version.APIVersion, name))
+		}
+	}
+
+	return usedBy, nil
+}
+
+// storagePoolVolumeUsedByRunning returns the names of the running containers
+// with a disk device referencing the given custom volume.
+func storagePoolVolumeUsedByRunning(s *state.State, st storage, poolName string, volumeName string) ([]string, error) {
+	running := []string{}
+
+	containers, err := s.DB.ContainersList(db.CTypeRegular)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range containers {
+		c, err := containerLoadByName(s, st, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if !c.IsRunning() {
+			continue
+		}
+
+		for _, device := range c.ExpandedDevices() {
+			if isCustomVolumeDevice(device) && device["pool"] == poolName && device["source"] == volumeName {
+				running = append(running, name)
+				break
+			}
+		}
+	}
+
+	return running, nil
+}
+
+// storagePoolVolumeAttach makes a custom volume available on the host for the
+// given container and returns its path. The ownership of the volume's content
+// is shifted to match the container's idmap when they differ from the last
+// one it was shifted to.
+func storagePoolVolumeAttach(s *state.State, st storage, c container, poolName string, volumeName string) (string, error) {
+	poolID, _, err := s.DB.StoragePoolGet(poolName)
+	if err != nil {
+		return "", err
+	}
+
+	volumeID, volume, err := s.DB.StoragePoolVolumeGet(poolID, volumeName, db.StoragePoolVolumeTypeCustom)
+	if err != nil {
+		return "", err
+	}
+
+	st, err = storagePoolInit(s, st, poolName)
+	if err != nil {
+		return "", err
+	}
+
+	volumePath, err := st.StoragePoolVolumeMount(volumeName)
+	if err != nil {
+		return "", err
+	}
+
+	if s.OS.MockMode {
+		return volumePath, nil
+	}
+
+	nextIdmap, err := c.IdmapSet()
+	if err != nil {
+		return "", err
+	}
+
+	var lastIdmap *idmap.IdmapSet
+	lastJSONIdmap := volume.Config["volatile.idmap.last"]
+	if lastJSONIdmap != "" {
+		lastIdmap = new(idmap.IdmapSet)
+		err := json.Unmarshal([]byte(lastJSONIdmap), &lastIdmap.Idmap)
+		if err != nil {
+			return "", err
+		}
+
+		if len(lastIdmap.Idmap) == 0 {
+			lastIdmap = nil
+		}
+	}
+
+	if reflect.DeepEqual(nextIdmap, lastIdmap) {
+		return volumePath, nil
+	}
+
+	// Shifting the volume would break its ownership for the other running
+	// containers using it with a different idmap
+	running, err := storagePoolVolumeUsedByRunning(s, st, poolName, volumeName)
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range running {
+		if name == c.Name() {
+			continue
+		}
+
+		other, err := containerLoadByName(s, st, name)
+		if err != nil {
+			return "", err
+		}
+
+		otherIdmap, err := other.IdmapSet()
+		if err != nil {
+			return "", err
+		}
+
+		if !reflect.DeepEqual(nextIdmap, otherIdmap) {
+			return "", fmt.Errorf("Idmaps of container %s and running container %s are not identical", c.Name(), name)
+		}
+	}
+
+	if lastIdmap != nil {
+		err := lastIdmap.UnshiftRootfs(volumePath)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	nextJSONIdmap := ""
+	if nextIdmap != nil {
+		err := nextIdmap.ShiftRootfs(volumePath)
+		if err != nil {
+			return "", err
+		}
+
+		idmapBytes, err := json.Marshal(nextIdmap.Idmap)
+		if err != nil {
+			return "", err
+		}
+		nextJSONIdmap = string(idmapBytes)
+	}
+
+	volume.Config["volatile.idmap.last"] = nextJSONIdmap
+	err = s.DB.StoragePoolVolumeUpdate(volumeID, volume.Description, volume.Config)
+	if err != nil {
+		return "", err
+	}
+
+	return volumePath, nil
+}
+
+// storagePoolVolumeDetach releases a custom volume attached to the given
+// container, unmounting it from the host unless another running container
+// still uses it.
+func storagePoolVolumeDetach(s *state.State, st storage, c container, poolName string, volumeName string) error {
+	running, err := storagePoolVolumeUsedByRunning(s, st, poolName, volumeName)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range running {
+		if name != c.Name() {
+			return nil
+		}
+	}
+
+	st, err = storagePoolInit(s, st, poolName)
+	if err != nil {
+		return err
+	}
+
+	return st.StoragePoolVolumeUmount(volumeName)
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
@@ -15,6 +17,7 @@ import (
 	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/idmap"
 	"github.com/lxc/lxd/shared/logger"
 
@@ -452,6 +455,187 @@ func (s *storageZfs) ContainerGetUsage(container container) (int64, error) {
 	return valueInt, nil
 }
 
+func (s *storageZfs) StoragePoolResources() (*api.ResourcesStoragePool, error) {
+	output, err := shared.RunCommand(
+		"zfs",
+		"get",
+		"-H",
+		"-p",
+		"-o", "value",
+		"used,available",
+		s.zfsPool)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get ZFS usage: %s", output)
+	}
+
+	fields := strings.Fields(output)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("Unexpected ZFS usage output: %s", output)
+	}
+
+	used, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	available, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	res := api.ResourcesStoragePool{}
+	res.Space.Used = used
+	res.Space.Total = used + available
+
+	return &res, nil
+}
+
+func (s *storageZfs) StoragePoolVolumeCreate(name string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	fs := fmt.Sprintf("custom/%s", name)
+	output, err := shared.RunCommand(
+		"zfs",
+		"create",
+		"-p",
+		"-o", fmt.Sprintf("mountpoint=%s", s.volumeMountPoint(name)),
+		fmt.Sprintf("%s/%s", s.zfsPool, fs))
+	if err != nil {
+		s.log.Error("zfs create failed", log.Ctx{"output": string(output)})
+		return fmt.Errorf("Failed to create ZFS filesystem: %s", output)
+	}
+
+	return nil
+}
+
+func (s *storageZfs) StoragePoolVolumeDelete(name string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	fs := fmt.Sprintf("custom/%s", name)
+	if s.zfsExists(fs) {
+		err := s.zfsDestroy(fs)
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.RemoveAll(s.volumeMountPoint(name))
+}
+
+func (s *storageZfs) StoragePoolVolumeRename(oldName string, newName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	oldFs := fmt.Sprintf("custom/%s", oldName)
+	newFs := fmt.Sprintf("custom/%s", newName)
+
+	err = s.zfsRename(oldFs, newFs)
+	if err != nil {
+		return err
+	}
+
+	err = s.zfsSet(newFs, "mountpoint", s.volumeMountPoint(newName))
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(s.volumeMountPoint(oldName))
+}
+
+func (s *storageZfs) StoragePoolVolumeSetQuota(name string, size int64) error {
+	fs := fmt.Sprintf("custom/%s", name)
+
+	if size > 0 {
+		return s.zfsSet(fs, "quota", fmt.Sprintf("%d", size))
+	}
+
+	return s.zfsSet(fs, "quota", "none")
+}
+
+func (s *storageZfs) StoragePoolVolumeMount(name string) (string, error) {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return "", err
+	}
+
+	volPath := s.volumeMountPoint(name)
+	if !shared.IsMountPoint(volPath) {
+		err := s.zfsMount(fmt.Sprintf("custom/%s", name))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return volPath, nil
+}
+
+func (s *storageZfs) StoragePoolVolumeUmount(name string) error {
+	if !shared.IsMountPoint(s.volumeMountPoint(name)) {
+		return nil
+	}
+
+	return s.zfsUnmount(fmt.Sprintf("custom/%s", name))
+}
+
+func (s *storageZfs) StoragePoolVolumeSnapshotCreate(name string, snapshotName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	return s.zfsSnapshotCreate(fmt.Sprintf("custom/%s", name), fmt.Sprintf("snapshot-%s", snapshotName))
+}
+
+func (s *storageZfs) StoragePoolVolumeSnapshotDelete(name string, snapshotName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	return s.zfsSnapshotDestroy(fmt.Sprintf("custom/%s", name), fmt.Sprintf("snapshot-%s", snapshotName))
+}
+
+func (s *storageZfs) StoragePoolVolumeSnapshotRename(name string, oldSnapshotName string, newSnapshotName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	return s.zfsSnapshotRename(
+		fmt.Sprintf("custom/%s", name),
+		fmt.Sprintf("snapshot-%s", oldSnapshotName),
+		fmt.Sprintf("snapshot-%s", newSnapshotName))
+}
+
+func (s *storageZfs) StoragePoolVolumeRestore(name string, snapshotName string) error {
+	err := s.volumeCheckPool()
+	if err != nil {
+		return err
+	}
+
+	fs := fmt.Sprintf("custom/%s", name)
+	snapName := fmt.Sprintf("snapshot-%s", snapshotName)
+
+	snaps, err := s.zfsListSnapshots(fs)
+	if err != nil {
+		return err
+	}
+
+	if len(snaps) == 0 || snaps[len(snaps)-1] != snapName {
+		return fmt.Errorf("ZFS can only restore from the latest snapshot. Delete newer snapshots first.")
+	}
+
+	return s.zfsSnapshotRestore(fs, snapName)
+}
+
 func (s *storageZfs) ContainerSnapshotCreate(snapshotContainer container, sourceContainer container) error {
 	fields := strings.SplitN(snapshotContainer.Name(), shared.SnapshotDelimiter, 2)
 	cName := fields[0]
@@ -608,6 +792,117 @@ func (s *storageZfs) ContainerSnapshotCreateEmpty(snapshotContainer container) e
 	return nil
 }
 
+func (s *storageZfs) ContainerBackupCreate(path string, backup backup, sourceContainer container) error {
+	if !backup.optimizedStorage {
+		return rsyncBackupCreate(path, backup, sourceContainer)
+	}
+
+	fs := fmt.Sprintf("containers/%s", sourceContainer.Name())
+
+	parent := ""
+	if !backup.containerOnly {
+		snapshots, err := sourceContainer.Snapshots()
+		if err != nil {
+			return err
+		}
+
+		err = os.MkdirAll(filepath.Join(path, "snapshots"), 0700)
+		if err != nil {
+			return err
+		}
+
+		for _, snap := range snapshots {
+			_, snapName, _ := containerGetParentAndSnapshotName(snap.Name())
+			zfsSnapName := fmt.Sprintf("snapshot-%s", snapName)
+
+			err := s.zfsBackupSend(fs, zfsSnapName, parent, filepath.Join(path, "snapshots", snapName+".bin"))
+			if err != nil {
+				return err
+			}
+
+			parent = zfsSnapName
+		}
+	}
+
+	// Send the current state of the container through a temporary snapshot
+	backupSnapName := fmt.Sprintf("backup-%s", uuid.NewRandom().String())
+	err := s.zfsSnapshotCreate(fs, backupSnapName)
+	if err != nil {
+		return err
+	}
+	defer s.zfsSnapshotDestroy(fs, backupSnapName)
+
+	return s.zfsBackupSend(fs, backupSnapName, parent, filepath.Join(path, "container.bin"))
+}
+
+func (s *storageZfs) ContainerBackupLoad(path string, info backupInfo, container container) error {
+	if !info.Optimized || info.Backend != "zfs" {
+		return rsyncBackupLoad(path, info, container)
+	}
+
+	cName := container.Name()
+	fs := fmt.Sprintf("containers/%s", cName)
+
+	/* zfs receive can't always write to mounted filesystems, so unmount
+	 * the freshly created (and empty) one first.
+	 */
+	err := s.zfsUnmount(fs)
+	if err != nil {
+		return err
+	}
+
+	for _, snap := range info.Snapshots {
+		args, err := backupSnapshotToContainerArgs(cName, snap)
+		if err != nil {
+			return err
+		}
+
+		_, err = containerCreateEmptySnapshot(s.s, s.storage, args)
+		if err != nil {
+			return err
+		}
+
+		_, snapName, _ := containerGetParentAndSnapshotName(snap.Name)
+		err = s.zfsBackupRecv(fmt.Sprintf("%s@snapshot-%s", fs, snapName), filepath.Join(path, "snapshots", snapName+".bin"))
+		if err != nil {
+			return err
+		}
+
+		err = os.MkdirAll(shared.VarPath(fmt.Sprintf("snapshots/%s", cName)), 0700)
+		if err != nil {
+			return err
+		}
+
+		err = os.Symlink("on-zfs", shared.VarPath(fmt.Sprintf("snapshots/%s/%s.zfs", cName, snapName)))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.zfsBackupRecv(fs, filepath.Join(path, "container.bin"))
+	if err != nil {
+		return err
+	}
+
+	// Remove the temporary snapshot the container was sent from
+	zfsSnapshots, err := s.zfsListSnapshots(fs)
+	if err != nil {
+		return err
+	}
+
+	for _, snap := range zfsSnapshots {
+		if strings.HasPrefix(snap, "backup-") {
+			s.zfsSnapshotDestroy(fs, snap)
+		}
+	}
+
+	/* As with migration, zfs receive may or may not have mounted the
+	 * filesystem, so try to mount it without complaining on failure.
+	 */
+	s.zfsMount(fs)
+	return nil
+}
+
 func (s *storageZfs) ImageCreate(fingerprint string) error {
 	imagePath := shared.VarPath("images", fingerprint)
 	subvol := fmt.Sprintf("%s.zfs", imagePath)
@@ -979,6 +1274,55 @@ func (s *storageZfs) zfsSnapshotDestroy(path string, name string) error {
 	return nil
 }
 
+// zfsBackupSend writes the send stream of the given snapshot, incremental
+// from parent if set, to the target file.
+func (s *storageZfs) zfsBackupSend(path string, name string, parent string, target string) error {
+	args := []string{"send", fmt.Sprintf("%s/%s@%s", s.zfsPool, path, name)}
+	if parent != "" {
+		args = append(args, "-i", fmt.Sprintf("%s/%s@%s", s.zfsPool, path, parent))
+	}
+
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("zfs", args...)
+	cmd.Stdout = f
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		s.log.Error("zfs send failed", log.Ctx{"output": stderr.String()})
+		return fmt.Errorf("Failed to send ZFS snapshot: %s", stderr.String())
+	}
+
+	return nil
+}
+
+// zfsBackupRecv receives the send stream stored in the source file into the
+// given dataset or snapshot.
+func (s *storageZfs) zfsBackupRecv(path string, source string) error {
+	f, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cmd := exec.Command("zfs", "receive", "-F", "-u", fmt.Sprintf("%s/%s", s.zfsPool, path))
+	cmd.Stdin = f
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		s.log.Error("zfs receive failed", log.Ctx{"output": string(output)})
+		return fmt.Errorf("Failed to receive ZFS snapshot: %s", output)
+	}
+
+	return nil
+}
+
 func (s *storageZfs) zfsSnapshotRestore(path string, name string) error {
 	output, err := shared.TryRunCommand(
 		"zfs",
@@ -1238,6 +1582,10 @@ type zfsMigrationSourceDriver struct {
 	zfs              *storageZfs
 	runningSnapName  string
 	stoppedSnapName  string
+
+	// Snapshot already present on the target, used as the parent of the
+	// first snapshot being sent when refreshing a container.
+	baseSnapName string
 }
 
 func (s *zfsMigrationSourceDriver) Snapshots() []container {
@@ -1289,14 +1637,10 @@ func (s *zfsMigrationSourceDriver) SendWhileRunning(conn *websocket.Conn) error
 		return s.send(conn, snapshotName, "")
 	}
 
-	lastSnap := ""
-
-	for i, snap := range s.zfsSnapshotNames {
-		prev := ""
-		if i > 0 {
-			prev = s.zfsSnapshotNames[i-1]
-		}
+	lastSnap := s.baseSnapName
 
+	for _, snap := range s.zfsSnapshotNames {
+		prev := lastSnap
 		lastSnap = snap
 
 		if err := s.send(conn, snap, prev); err != nil {
@@ -1329,6 +1673,27 @@ func (s *zfsMigrationSourceDriver) SendAfterCheckpoint(conn *websocket.Conn) err
 	return nil
 }
 
+func (s *zfsMigrationSourceDriver) FilterSnapshots(names []string) {
+	snapshots := []container{}
+	zfsSnapshotNames := []string{}
+
+	for i, snap := range s.snapshots {
+		if !shared.StringInSlice(shared.ExtractSnapshotName(snap.Name()), names) {
+			if len(snapshots) == 0 {
+				s.baseSnapName = s.zfsSnapshotNames[i]
+			}
+
+			continue
+		}
+
+		snapshots = append(snapshots, snap)
+		zfsSnapshotNames = append(zfsSnapshotNames, s.zfsSnapshotNames[i])
+	}
+
+	s.snapshots = snapshots
+	s.zfsSnapshotNames = zfsSnapshotNames
+}
+
 func (s *zfsMigrationSourceDriver) Cleanup() {
 	if s.stoppedSnapName != "" {
 		s.zfs.zfsSnapshotDestroy(fmt.Sprintf("containers/%s", s.container.Name()), s.stoppedSnapName)
@@ -1471,9 +1836,17 @@ func (s *storageZfs) MigrationSink(live bool, container container, snapshots []*
 			return
 		}
 
+		// When refreshing a container, it may have snapshots even if
+		// we didn't receive any.
+		existing, err := container.Snapshots()
+		if err != nil {
+			logger.Error("failed listing snapshots post migration", log.Ctx{"err": err})
+			return
+		}
+
 		for _, snap := range zfsSnapshots {
-			// If we received a bunch of snapshots, remove the migration-send-* ones, if not, wipe any snapshot we got
-			if snapshots != nil && len(snapshots) > 0 && !strings.HasPrefix(snap, "migration-send") {
+			// If the container has snapshots, remove the migration-send-* ones, if not, wipe any snapshot we got
+			if len(existing) > 0 && !strings.HasPrefix(snap, "migration-send") {
 				continue
 			}
 
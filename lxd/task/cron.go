@@ -0,0 +1,237 @@
+package task
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed cron expression, able to compute the times at
+// which it fires.
+type CronSchedule struct {
+	minute uint64 // Bit set of the matching minutes (0-59)
+	hour   uint64 // Bit set of the matching hours (0-23)
+	dom    uint64 // Bit set of the matching days of the month (1-31)
+	month  uint64 // Bit set of the matching months (1-12)
+	dow    uint64 // Bit set of the matching days of the week (0-6)
+
+	// Whether the day of the month or day of the week fields are a
+	// wildcard, see Next().
+	domStar bool
+	dowStar bool
+}
+
+// Descriptors which can be used in place of a cron expression.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// A field of a cron expression along with its bounds.
+type cronField struct {
+	name string
+	min  uint
+	max  uint
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 7},
+}
+
+// ParseCron parses a standard 5 fields cron expression ("<minute> <hour>
+// <day of month> <month> <day of week>"), or one of the @yearly, @monthly,
+// @weekly, @daily and @hourly descriptors.
+//
+// Each field can be a wildcard, a value, a range ("1-5") or a comma
+// separated list of those, optionally followed by a step ("*/15").
+func ParseCron(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@") {
+		value, ok := cronDescriptors[expr]
+		if !ok {
+			return nil, fmt.Errorf("Unknown cron descriptor: %s", expr)
+		}
+
+		expr = value
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("Expected %d fields in cron expression, got %d", len(cronFields), len(fields))
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		var err error
+		bits[i], err = parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	schedule := &CronSchedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+
+	// Sunday can be either 0 or 7
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+		schedule.dow &^= 1 << 7
+	}
+
+	return schedule, nil
+}
+
+// Parse a single field of a cron expression into a bit set.
+func parseCronField(value string, field cronField) (uint64, error) {
+	var bits uint64
+
+	for _, entry := range strings.Split(value, ",") {
+		// Extract the step
+		step := uint(1)
+		fields := strings.SplitN(entry, "/", 2)
+		if len(fields) == 2 {
+			n, err := strconv.ParseUint(fields[1], 10, 8)
+			if err != nil || n == 0 {
+				return 0, fmt.Errorf("Invalid step in %s field: %s", field.name, entry)
+			}
+			step = uint(n)
+		}
+
+		// Extract the range
+		start, end := field.min, field.max
+		if fields[0] != "*" {
+			bounds := strings.SplitN(fields[0], "-", 2)
+
+			n, err := strconv.ParseUint(bounds[0], 10, 8)
+			if err != nil {
+				return 0, fmt.Errorf("Invalid value in %s field: %s", field.name, entry)
+			}
+			start = uint(n)
+			end = start
+
+			if len(bounds) == 2 {
+				n, err := strconv.ParseUint(bounds[1], 10, 8)
+				if err != nil {
+					return 0, fmt.Errorf("Invalid value in %s field: %s", field.name, entry)
+				}
+				end = uint(n)
+			} else if len(fields) == 2 {
+				// A step after a single value means "from that
+				// value to the end of the range".
+				end = field.max
+			}
+		}
+
+		if start < field.min || end > field.max || start > end {
+			return 0, fmt.Errorf("Out of range value in %s field: %s", field.name, entry)
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << i
+		}
+	}
+
+	return bits, nil
+}
+
+// Next returns the first time after t at which the schedule fires, or the
+// zero time if it never does.
+//
+// Like with cron, when both the day of the month and the day of the week are
+// restricted, a day matches if either of them does.
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	// Start from the next whole minute
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+
+	// Give up if there's no match within 5 years (e.g. February 30th)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *CronSchedule) matchDay(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+// Cron returns a Schedule that triggers the task function whenever the given
+// cron expression fires, see ParseCron(). Unlike Every, the task function
+// doesn't get run immediately, nor when the task is reset.
+//
+// If the expression is invalid, the task is aborted.
+func Cron(expr string) Schedule {
+	schedule, err := ParseCron(expr)
+
+	// The time at which the task function is next due
+	var due time.Time
+
+	return func() (time.Duration, error) {
+		if err != nil {
+			return 0, err
+		}
+
+		now := time.Now()
+
+		// If we reached the due time, run the task function now and
+		// wait until the following one.
+		run := !due.IsZero() && !now.Before(due)
+
+		due = schedule.Next(now)
+		if due.IsZero() {
+			return 0, nil
+		}
+
+		if run {
+			return due.Sub(now), nil
+		}
+
+		return due.Sub(now), ErrSkip
+	}
+}
@@ -0,0 +1,94 @@
+package task_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The next firing time of a cron expression is computed from the given time.
+func TestCronSchedule_Next(t *testing.T) {
+	// A Wednesday
+	now := time.Date(2018, time.January, 31, 12, 34, 56, 0, time.UTC)
+
+	cases := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2018, time.January, 31, 12, 35, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2018, time.January, 31, 12, 45, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2018, time.February, 1, 3, 0, 0, 0, time.UTC)},
+		{"30 2-4 * * *", time.Date(2018, time.February, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2018, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2018, time.February, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2018, time.February, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1,5", time.Date(2018, time.February, 2, 0, 0, 0, 0, time.UTC)},
+		{"0 0 15 * 6", time.Date(2018, time.February, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2018, time.January, 31, 13, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			schedule, err := task.ParseCron(c.expr)
+			require.NoError(t, err)
+			assert.Equal(t, c.next, schedule.Next(now))
+		})
+	}
+}
+
+// A cron expression which never fires has no next firing time.
+func TestCronSchedule_NextNever(t *testing.T) {
+	schedule, err := task.ParseCron("0 0 30 2 *")
+	require.NoError(t, err)
+	assert.True(t, schedule.Next(time.Now()).IsZero())
+}
+
+// Invalid cron expressions are rejected.
+func TestParseCron_Invalid(t *testing.T) {
+	cases := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@fortnightly",
+	}
+
+	for _, expr := range cases {
+		t.Run(expr, func(t *testing.T) {
+			_, err := task.ParseCron(expr)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// The Cron schedule waits for the next firing time before running the task
+// function for the first time.
+func TestCron(t *testing.T) {
+	schedule := task.Cron("* * * * *")
+
+	interval, err := schedule()
+	assert.Equal(t, task.ErrSkip, err)
+	assert.True(t, interval > 0)
+	assert.True(t, interval <= time.Minute)
+}
+
+// An invalid cron expression aborts the task.
+func TestCron_Invalid(t *testing.T) {
+	schedule := task.Cron("boom")
+
+	interval, err := schedule()
+	assert.Error(t, err)
+	assert.Equal(t, time.Duration(0), interval)
+}
@@ -2,6 +2,7 @@ package task
 
 import (
 	"fmt"
+	"math/rand"
 	"time"
 )
 
@@ -51,6 +52,20 @@ func Daily(options ...EveryOption) Schedule {
 	return Every(24*time.Hour, options...)
 }
 
+// Jitter wraps the given schedule, adding a random delay of up to max to the
+// interval it returns. This is handy to spread over time tasks that many
+// nodes would otherwise execute at the same moment.
+func Jitter(schedule Schedule, max time.Duration) Schedule {
+	return func() (time.Duration, error) {
+		interval, err := schedule()
+		if interval <= 0 || max <= 0 {
+			return interval, err
+		}
+
+		return interval + time.Duration(rand.Int63n(int64(max))), err
+	}
+}
+
 // SkipFirst is an option for the Every schedule that will make the schedule
 // skip the very first invokation of the task function.
 var SkipFirst = func(every *every) { every.skipFirst = true }
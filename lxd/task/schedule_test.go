@@ -0,0 +1,30 @@
+package task_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/stretchr/testify/assert"
+)
+
+// The Jitter schedule adds a random delay to the wrapped schedule.
+func TestJitter(t *testing.T) {
+	schedule := task.Jitter(task.Every(time.Second), 500*time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		interval, err := schedule()
+		assert.NoError(t, err)
+		assert.True(t, interval >= time.Second)
+		assert.True(t, interval < 1500*time.Millisecond)
+	}
+}
+
+// A zero interval is left untouched, so the task still never runs.
+func TestJitter_ZeroInterval(t *testing.T) {
+	schedule := task.Jitter(task.Every(0), time.Second)
+
+	interval, err := schedule()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), interval)
+}
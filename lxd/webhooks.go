@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/lxc/lxd/shared/log15"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+)
+
+// Number of events which can be waiting for delivery to a single webhook,
+// any further event is dropped until the receiver catches up.
+const webhookQueueSize = 128
+
+// Number of delivery attempts for a single event.
+const webhookAttempts = 5
+
+// Event types sent to a webhook when core.webhooks.<name>.types isn't set.
+const webhookDefaultTypes = "lifecycle,operation"
+
+var webhooksLock sync.Mutex
+var webhooks = map[string]*webhook{}
+
+// webhook delivers the daemon events of the configured types to an HTTP
+// endpoint, one at a time and in order.
+type webhook struct {
+	name string
+
+	// Settings, which may change while events are queued
+	lock   sync.Mutex
+	url    string
+	types  string
+	secret string
+
+	db         *db.Node
+	client     *http.Client
+	retryDelay time.Duration
+
+	queue chan []byte
+	stop  chan struct{}
+
+	// Number of events dropped since the last time failures were recorded
+	dropped int64
+}
+
+// webhooksUpdate starts, reconfigures or stops the webhooks according to the
+// current core.webhooks.* configuration keys.
+func webhooksUpdate(d *Daemon) {
+	config := map[string]map[string]string{}
+
+	daemonConfigLock.Lock()
+	daemonConfigWebhooksLock.Lock()
+	for key, value := range daemonConfigWebhooks {
+		name, field, ok := daemonConfigWebhookKey(key)
+		if !ok {
+			continue
+		}
+
+		if config[name] == nil {
+			config[name] = map[string]string{}
+		}
+
+		config[name][field] = value.Get()
+	}
+	daemonConfigWebhooksLock.Unlock()
+	daemonConfigLock.Unlock()
+
+	for _, c := range config {
+		if c["types"] == "" {
+			c["types"] = webhookDefaultTypes
+		}
+	}
+
+	removed := []string{}
+
+	webhooksLock.Lock()
+	for name, hook := range webhooks {
+		// Changed settings apply to the events already queued too
+		c := config[name]
+		if c != nil && c["url"] != "" {
+			hook.lock.Lock()
+			hook.url = c["url"]
+			hook.types = c["types"]
+			hook.secret = c["secret"]
+			hook.lock.Unlock()
+			continue
+		}
+
+		close(hook.stop)
+		delete(webhooks, name)
+		removed = append(removed, name)
+	}
+
+	for name, c := range config {
+		_, ok := webhooks[name]
+		if ok || c["url"] == "" {
+			continue
+		}
+
+		hook := &webhook{
+			name:   name,
+			url:    c["url"],
+			types:  c["types"],
+			secret: c["secret"],
+
+			db: d.db,
+			client: &http.Client{
+				Timeout:   30 * time.Second,
+				Transport: &http.Transport{Proxy: d.proxy},
+			},
+			retryDelay: time.Second,
+
+			queue: make(chan []byte, webhookQueueSize),
+			stop:  make(chan struct{}),
+		}
+
+		webhooks[name] = hook
+		go hook.run()
+	}
+	webhooksLock.Unlock()
+
+	// Forget about the failures of the webhooks which went away
+	for _, name := range removed {
+		err := d.db.WebhookDelete(name)
+		if err != nil {
+			logger.Warn("Failed to clear webhook failures", log.Ctx{"webhook": name, "err": err})
+		}
+	}
+}
+
+// webhooksFailures returns the number of events which couldn't be delivered
+// to each of the configured webhooks, including the dropped events which
+// haven't been recorded yet.
+func webhooksFailures(d *Daemon) (map[string]int64, error) {
+	failures := map[string]int64{}
+
+	webhooksLock.Lock()
+	defer webhooksLock.Unlock()
+
+	for name, hook := range webhooks {
+		count, err := d.db.WebhookFailures(name)
+		if err != nil {
+			return nil, err
+		}
+
+		failures[name] = count + atomic.LoadInt64(&hook.dropped)
+	}
+
+	return failures, nil
+}
+
+// webhooksSend queues the given event for delivery to the matching webhooks.
+// This never blocks, an event which doesn't fit in a webhook's queue is
+// dropped and later counted as a failure by the webhook itself.
+func webhooksSend(entry *eventEntry) {
+	// Don't send the webhooks their own failures
+	logging, ok := entry.metadata.(api.EventLogging)
+	if ok && logging.Context["webhook"] != "" {
+		return
+	}
+
+	webhooksLock.Lock()
+	defer webhooksLock.Unlock()
+
+	for _, hook := range webhooks {
+		hook.lock.Lock()
+		types := hook.types
+		hook.lock.Unlock()
+
+		if !shared.StringInSlice(entry.messageType, strings.Split(types, ",")) {
+			continue
+		}
+
+		select {
+		case hook.queue <- entry.body:
+		default:
+			atomic.AddInt64(&hook.dropped, 1)
+		}
+	}
+}
+
+func (w *webhook) run() {
+	for {
+		select {
+		case <-w.stop:
+			return
+		case body := <-w.queue:
+			err := w.deliver(body)
+			if err != nil {
+				w.failed(err)
+			}
+
+			// Record the events which didn't fit in the queue meanwhile
+			for i := atomic.SwapInt64(&w.dropped, 0); i > 0; i-- {
+				w.failed(fmt.Errorf("Too many events waiting for delivery"))
+			}
+		}
+	}
+}
+
+// deliver sends the event, retrying with an exponential backoff if the
+// receiver can't be reached or returns an error.
+func (w *webhook) deliver(body []byte) error {
+	var err error
+
+	delay := w.retryDelay
+	for i := 0; i < webhookAttempts; i++ {
+		if i > 0 {
+			select {
+			case <-w.stop:
+				return err
+			case <-time.After(delay):
+			}
+
+			delay *= 2
+		}
+
+		err = w.post(body)
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+func (w *webhook) post(body []byte) error {
+	w.lock.Lock()
+	url := w.url
+	secret := w.secret
+	w.lock.Unlock()
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent)
+	if secret != "" {
+		req.Header.Set("X-LXD-Signature", fmt.Sprintf("sha256=%s", webhookSignature(secret, body)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Unexpected response from webhook: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// failed records an event which couldn't be delivered.
+func (w *webhook) failed(err error) {
+	failures, dbErr := w.db.WebhookFailureAdd(w.name)
+	if dbErr != nil {
+		logger.Warn("Failed to record webhook failure", log.Ctx{"webhook": w.name, "err": dbErr})
+	}
+
+	w.lock.Lock()
+	url := w.url
+	w.lock.Unlock()
+
+	logger.Warn("Failed to deliver event to webhook", log.Ctx{"webhook": w.name, "url": url, "err": err, "failures": failures})
+}
+
+// webhookSignature returns the hex encoded HMAC-SHA256 of the body, keyed
+// with the webhook secret.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/db"
+)
+
+func newTestWebhook(t *testing.T, url string) (*webhook, func()) {
+	node, cleanup := db.NewTestNode(t)
+
+	hook := &webhook{
+		name:       "test",
+		url:        url,
+		types:      webhookDefaultTypes,
+		secret:     "s3cr3t",
+		db:         node,
+		client:     &http.Client{},
+		retryDelay: time.Millisecond,
+		queue:      make(chan []byte, webhookQueueSize),
+		stop:       make(chan struct{}),
+	}
+
+	return hook, cleanup
+}
+
+// Events are signed with the webhook secret and retried until the receiver
+// accepts them.
+func TestWebhook_DeliverRetries(t *testing.T) {
+	body := []byte(`{"type": "lifecycle"}`)
+	attempts := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+
+		data, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, body, data)
+		assert.Equal(t, "sha256="+webhookSignature("s3cr3t", body), r.Header.Get("X-LXD-Signature"))
+
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}))
+	defer server.Close()
+
+	hook, cleanup := newTestWebhook(t, server.URL)
+	defer cleanup()
+
+	err := hook.deliver(body)
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+// Events which can't be delivered after all the attempts are counted as
+// failures.
+func TestWebhook_DeliverFailure(t *testing.T) {
+	attempts := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	hook, cleanup := newTestWebhook(t, server.URL)
+	defer cleanup()
+
+	err := hook.deliver([]byte(`{}`))
+	require.Error(t, err)
+	assert.Equal(t, webhookAttempts, attempts)
+
+	hook.failed(err)
+
+	failures, err := hook.db.WebhookFailures("test")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), failures)
+}
+
+// Events which don't fit in the queue of a webhook are dropped rather than
+// blocking the sender.
+func TestWebhooksSend_FullQueue(t *testing.T) {
+	hook, cleanup := newTestWebhook(t, "http://127.0.0.1:1")
+	defer cleanup()
+
+	webhooksLock.Lock()
+	webhooks["test"] = hook
+	webhooksLock.Unlock()
+
+	defer func() {
+		webhooksLock.Lock()
+		delete(webhooks, "test")
+		webhooksLock.Unlock()
+	}()
+
+	for i := 0; i < webhookQueueSize+1; i++ {
+		webhooksSend(&eventEntry{messageType: "lifecycle", body: []byte(`{}`)})
+	}
+
+	// Logging events aren't sent by default
+	webhooksSend(&eventEntry{messageType: "logging", body: []byte(`{}`)})
+
+	assert.Equal(t, webhookQueueSize, len(hook.queue))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&hook.dropped))
+}
@@ -0,0 +1,26 @@
+package api
+
+// ContainerProcess represents a process running inside a LXD container
+//
+// API extension: container_processes
+type ContainerProcess struct {
+	PID          int64    `json:"pid" yaml:"pid"`
+	ContainerPID int64    `json:"container_pid" yaml:"container_pid"`
+	UID          int64    `json:"uid" yaml:"uid"`
+	Command      []string `json:"command" yaml:"command"`
+
+	// CPU time in nanoseconds
+	CPUTime int64 `json:"cpu_time" yaml:"cpu_time"`
+
+	// Resident memory in bytes
+	MemoryRSS int64 `json:"memory_rss" yaml:"memory_rss"`
+}
+
+// ContainerProcessSignalPost represents a request to signal a container process
+//
+// API extension: container_processes
+type ContainerProcessSignalPost struct {
+	// PID of the process inside the container
+	PID    int64 `json:"pid" yaml:"pid"`
+	Signal int   `json:"signal" yaml:"signal"`
+}
@@ -8,6 +8,9 @@ import (
 type ContainerSnapshotsPost struct {
 	Name     string `json:"name" yaml:"name"`
 	Stateful bool   `json:"stateful" yaml:"stateful"`
+
+	// API extension: snapshot_scheduling
+	ExpiryDate *time.Time `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
 }
 
 // ContainerSnapshotPost represents the fields required to rename/move a LXD container snapshot
@@ -33,4 +36,7 @@ type ContainerSnapshot struct {
 	Name            string                       `json:"name" yaml:"name"`
 	Profiles        []string                     `json:"profiles" yaml:"profiles"`
 	Stateful        bool                         `json:"stateful" yaml:"stateful"`
+
+	// API extension: snapshot_scheduling
+	ExpiryDate time.Time `json:"expires_at" yaml:"expires_at"`
 }
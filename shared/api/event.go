@@ -10,6 +10,10 @@ type Event struct {
 	Type      string          `yaml:"type" json:"type"`
 	Timestamp time.Time       `yaml:"timestamp" json:"timestamp"`
 	Metadata  json.RawMessage `yaml:"metadata" json:"metadata"`
+
+	// API extension: event_history
+	ID     int64  `yaml:"id" json:"id"`
+	BootID string `yaml:"boot_id" json:"boot_id"`
 }
 
 // EventLogging represents a logging type event entry (admin only)
@@ -18,3 +22,26 @@ type EventLogging struct {
 	Level   string            `yaml:"level" json:"level"`
 	Context map[string]string `yaml:"context" json:"context"`
 }
+
+// EventLifecycle represents a lifecycle type event entry
+//
+// API extension: event_lifecycle
+type EventLifecycle struct {
+	Action    string                   `yaml:"action" json:"action"`
+	Source    string                   `yaml:"source" json:"source"`
+	Context   map[string]interface{}   `yaml:"context,omitempty" json:"context,omitempty"`
+	Requestor *EventLifecycleRequestor `yaml:"requestor,omitempty" json:"requestor,omitempty"`
+}
+
+// EventLifecycleRequestor represents the client which triggered a lifecycle event
+//
+// API extension: event_lifecycle
+type EventLifecycleRequestor struct {
+	// Fingerprint of the client certificate (empty for the unix socket)
+	Username string `yaml:"username" json:"username"`
+
+	// One of "unix", "tls" or "http"
+	Protocol string `yaml:"protocol" json:"protocol"`
+
+	Address string `yaml:"address" json:"address"`
+}
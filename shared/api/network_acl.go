@@ -0,0 +1,58 @@
+package api
+
+// NetworkACLsPost represents the fields of a new LXD network ACL
+//
+// API extension: network_acl
+type NetworkACLsPost struct {
+	NetworkACLPut `yaml:",inline"`
+
+	Name string `json:"name" yaml:"name"`
+}
+
+// NetworkACLPost represents the fields required to rename a LXD network ACL
+//
+// API extension: network_acl
+type NetworkACLPost struct {
+	Name string `json:"name" yaml:"name"`
+}
+
+// NetworkACLPut represents the modifiable fields of a LXD network ACL
+//
+// API extension: network_acl
+type NetworkACLPut struct {
+	Description string `json:"description" yaml:"description"`
+
+	// Rules for the traffic going to the containers
+	Ingress []NetworkACLRule `json:"ingress" yaml:"ingress"`
+
+	// Rules for the traffic coming from the containers
+	Egress []NetworkACLRule `json:"egress" yaml:"egress"`
+}
+
+// NetworkACL represents a LXD network ACL
+//
+// API extension: network_acl
+type NetworkACL struct {
+	NetworkACLPut `yaml:",inline"`
+
+	Name   string   `json:"name" yaml:"name"`
+	UsedBy []string `json:"used_by" yaml:"used_by"`
+}
+
+// Writable converts a full NetworkACL struct into a NetworkACLPut struct (filters read-only fields)
+func (acl *NetworkACL) Writable() NetworkACLPut {
+	return acl.NetworkACLPut
+}
+
+// NetworkACLRule represents a single rule of a LXD network ACL
+//
+// API extension: network_acl
+type NetworkACLRule struct {
+	Action          string `json:"action" yaml:"action"`
+	Protocol        string `json:"protocol" yaml:"protocol"`
+	Source          string `json:"source" yaml:"source"`
+	SourcePort      string `json:"source_port" yaml:"source_port"`
+	Destination     string `json:"destination" yaml:"destination"`
+	DestinationPort string `json:"destination_port" yaml:"destination_port"`
+	Description     string `json:"description" yaml:"description"`
+}
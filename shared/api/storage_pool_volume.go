@@ -41,6 +41,9 @@ type StorageVolumePut struct {
 
 	// API extension: entity_description
 	Description string `json:"description" yaml:"description"`
+
+	// API extension: storage_api_volume_snapshots
+	Restore string `json:"restore,omitempty" yaml:"restore,omitempty"`
 }
 
 // StorageVolumeSource represents the creation source for a new storage volume.
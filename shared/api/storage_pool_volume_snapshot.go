@@ -0,0 +1,29 @@
+package api
+
+import "time"
+
+// StorageVolumeSnapshotsPost represents the fields available for a new LXD
+// storage volume snapshot
+//
+// API extension: storage_api_volume_snapshots
+type StorageVolumeSnapshotsPost struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description" yaml:"description"`
+}
+
+// StorageVolumeSnapshotPost represents the fields required to rename a LXD
+// storage volume snapshot
+//
+// API extension: storage_api_volume_snapshots
+type StorageVolumeSnapshotPost struct {
+	Name string `json:"name" yaml:"name"`
+}
+
+// StorageVolumeSnapshot represents a LXD storage volume snapshot
+//
+// API extension: storage_api_volume_snapshots
+type StorageVolumeSnapshot struct {
+	Name         string    `json:"name" yaml:"name"`
+	Description  string    `json:"description" yaml:"description"`
+	CreationDate time.Time `json:"created_at" yaml:"created_at"`
+}
@@ -849,6 +849,46 @@ func TimeIsSet(ts time.Time) bool {
 	return true
 }
 
+// GetSnapshotExpiry returns the expiry date of a snapshot taken at refDate
+// given an expression like "1w 2d 3H". The supported units are M (minutes),
+// H (hours), d (days), w (weeks), m (months) and y (years). An empty
+// expression means that the snapshot doesn't expire.
+func GetSnapshotExpiry(refDate time.Time, s string) (time.Time, error) {
+	expr := strings.TrimSpace(s)
+	if expr == "" {
+		return time.Time{}, nil
+	}
+
+	re := regexp.MustCompile(`^(\d+)(M|H|d|w|m|y)$`)
+	expiry := map[string]int{
+		"M": 0,
+		"H": 0,
+		"d": 0,
+		"w": 0,
+		"m": 0,
+		"y": 0,
+	}
+
+	for _, value := range strings.Fields(expr) {
+		fields := re.FindStringSubmatch(value)
+		if fields == nil {
+			return time.Time{}, fmt.Errorf("Invalid expiry expression '%s'", value)
+		}
+
+		val, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		expiry[fields[2]] += val
+	}
+
+	t := refDate.AddDate(expiry["y"], expiry["m"], expiry["d"]+expiry["w"]*7)
+	t = t.Add(time.Duration(expiry["H"])*time.Hour + time.Duration(expiry["M"])*time.Minute)
+
+	return t, nil
+}
+
 // WriteTempFile creates a temp file with the specified content
 func WriteTempFile(dir string, prefix string, content string) (string, error) {
 	f, err := ioutil.TempFile(dir, prefix)
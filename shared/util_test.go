@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestURLEncode(t *testing.T) {
@@ -105,3 +106,35 @@ func TestReaderToChannel(t *testing.T) {
 		}
 	}
 }
+
+func TestGetSnapshotExpiry(t *testing.T) {
+	refDate := time.Date(2018, time.January, 31, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]time.Time{
+		"":          {},
+		"30M":       time.Date(2018, time.January, 31, 12, 30, 0, 0, time.UTC),
+		"1H 2d":     time.Date(2018, time.February, 2, 13, 0, 0, 0, time.UTC),
+		"2w":        time.Date(2018, time.February, 14, 12, 0, 0, 0, time.UTC),
+		"1y 1m":     time.Date(2019, time.March, 3, 12, 0, 0, 0, time.UTC),
+		" 1d  1d  ": time.Date(2018, time.February, 2, 12, 0, 0, 0, time.UTC),
+	}
+
+	for expr, expected := range tests {
+		expiry, err := GetSnapshotExpiry(refDate, expr)
+		if err != nil {
+			t.Errorf("Failed to parse '%s': %v", expr, err)
+			continue
+		}
+
+		if !expiry.Equal(expected) {
+			t.Errorf("Wrong expiry for '%s': %s != %s", expr, expiry, expected)
+		}
+	}
+
+	for _, expr := range []string{"1", "2x", "d", "1d2w", "-1d"} {
+		_, err := GetSnapshotExpiry(refDate, expr)
+		if err == nil {
+			t.Errorf("Invalid expression '%s' was accepted", expr)
+		}
+	}
+}
@@ -19,4 +19,32 @@ var APIExtensions = []string{
 	"id_map",
 	"id_map_base",
 	"resource_limits",
+	"storage",
+	"container_storage_pool",
+	"storage_api_volume_rename",
+	"container_backup",
+	"snapshot_scheduling",
+	"images_auto_update_cron",
+	"container_incremental_copy",
+	"storage_api_volume_snapshots",
+	"console",
+	"container_edit_metadata",
+	"directory_manipulation",
+	"file_symlinks",
+	"file_delete",
+	"file_append",
+	"file_atomic_write",
+	"container_exec_recording",
+	"container_exec_user_group_cwd",
+	"container_processes",
+	"event_lifecycle",
+	"event_filters",
+	"event_history",
+	"webhooks",
+	"metrics",
+	"network",
+	"network_leases",
+	"network_filtering",
+	"network_acl",
+	"proxy",
 }
@@ -147,15 +147,29 @@ run_test test_basic_usage "basic usage"
 run_test test_security "security features"
 run_test test_image_expiry "image expiry"
 run_test test_image_auto_update "image auto-update"
+run_test test_exec "exec"
+run_test test_container_processes "container processes"
+run_test test_metrics "metrics"
+run_test test_network "network management"
+run_test test_network_acl "network ACLs"
+run_test test_proxy_device "proxy device"
 run_test test_concurrent_exec "concurrent exec"
+run_test test_console "console"
 run_test test_concurrent "concurrent startup"
 run_test test_snapshots "container snapshots"
 run_test test_snap_restore "snapshot restores"
+run_test test_snap_schedule "snapshot scheduling"
+run_test test_container_backup_export_import "container backup export and import"
 run_test test_config_profiles "profiles and configuration"
 run_test test_server_config "server configuration"
+run_test test_event_lifecycle "lifecycle events"
+run_test test_storage_pools "storage pools"
+run_test test_storage_dir_reflink "dir storage reflinks"
+run_test test_storage_dir_quota "dir storage quotas"
 run_test test_filemanip "file manipulations"
 run_test test_idmap "id mapping"
 run_test test_template "file templating"
+run_test test_container_metadata "container metadata and templates"
 run_test test_devlxd "/dev/lxd"
 run_test test_fuidshift "fuidshift"
 run_test test_migration "migration"
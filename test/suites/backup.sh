@@ -0,0 +1,70 @@
+test_container_backup_export_import() {
+  ensure_import_testimage
+  ensure_has_localhost_remote "${LXD_ADDR}"
+
+  # shellcheck disable=2039
+  local lxd_backend
+  lxd_backend=$(storage_backend "$LXD_DIR")
+
+  lxc launch testimage b1
+  lxc exec b1 -- touch /root/before
+  lxc snapshot b1
+  lxc exec b1 -- touch /root/after
+
+  # Backups through the API
+  my_curl -f -X POST "https://${LXD_ADDR}/1.0/containers/b1/backups" -d '{"name": "mybackup"}'
+  my_curl -f "https://${LXD_ADDR}/1.0/containers/b1/backups" | jq -r ".metadata[]" | grep -q "/1.0/containers/b1/backups/mybackup"
+  ! my_curl -f -X POST "https://${LXD_ADDR}/1.0/containers/b1/backups" -d '{"name": "in/valid"}' || false
+  [ -f "${LXD_DIR}/backups/b1/mybackup" ]
+
+  my_curl -f -X POST "https://${LXD_ADDR}/1.0/containers/b1/backups/mybackup" -d '{"name": "renamed"}'
+  [ -f "${LXD_DIR}/backups/b1/renamed" ]
+  [ ! -f "${LXD_DIR}/backups/b1/mybackup" ]
+  my_curl -f -D - -o /dev/null "https://${LXD_ADDR}/1.0/containers/b1/backups/renamed/export" | grep -q "filename=renamed.tar.gz"
+  my_curl -f -X DELETE "https://${LXD_ADDR}/1.0/containers/b1/backups/renamed"
+  [ ! -f "${LXD_DIR}/backups/b1/renamed" ]
+
+  if [ "${lxd_backend}" != "btrfs" ] && [ "${lxd_backend}" != "zfs" ]; then
+    ! my_curl -f -X POST "https://${LXD_ADDR}/1.0/containers/b1/backups" -d '{"optimized_storage": true}' || false
+  fi
+
+  # Export and re-import the container along with its snapshot
+  lxc export b1 "${LXD_DIR}/b1.tar.gz"
+  [ -f "${LXD_DIR}/b1.tar.gz" ]
+  [ "$(my_curl -f "https://${LXD_ADDR}/1.0/containers/b1/backups" | jq -r '.metadata | length')" = "0" ]
+  tar -tzf "${LXD_DIR}/b1.tar.gz" | grep -q "backup/index.yaml"
+  tar -tzf "${LXD_DIR}/b1.tar.gz" | grep -q "backup/snapshots/snap0"
+
+  lxc export b1 "${LXD_DIR}/b1-only.tar.gz" --container-only
+  ! tar -tzf "${LXD_DIR}/b1-only.tar.gz" | grep -q "backup/snapshots/snap0" || false
+
+  # Importing a container which already exists fails
+  ! lxc import "${LXD_DIR}/b1.tar.gz" || false
+
+  lxc delete -f b1
+  [ ! -d "${LXD_DIR}/backups/b1" ]
+
+  lxc import "${LXD_DIR}/b1.tar.gz"
+  lxc info b1 | grep -q snap0
+  lxc start b1
+  lxc exec b1 -- test -f /root/after
+  lxc restore b1 snap0
+  lxc exec b1 -- test -f /root/before
+  ! lxc exec b1 -- test -f /root/after || false
+  lxc delete -f b1
+
+  if [ "${lxd_backend}" = "btrfs" ] || [ "${lxd_backend}" = "zfs" ]; then
+    lxc launch testimage b2
+    lxc snapshot b2
+    lxc export b2 "${LXD_DIR}/b2.tar.gz" --optimized-storage
+    tar -tzf "${LXD_DIR}/b2.tar.gz" | grep -q "backup/container.bin"
+    lxc delete -f b2
+
+    lxc import "${LXD_DIR}/b2.tar.gz"
+    lxc info b2 | grep -q snap0
+    lxc start b2
+    lxc delete -f b2
+  fi
+
+  rm -f "${LXD_DIR}/b1.tar.gz" "${LXD_DIR}/b1-only.tar.gz" "${LXD_DIR}/b2.tar.gz"
+}
@@ -295,6 +295,8 @@ test_basic_usage() {
   echo "new content" | lxc file edit foo/tmp/edit_test
   [ "$(lxc exec foo -- cat /tmp/edit_test)" = "new content" ]
   [ "$(lxc exec foo -- stat -c \"%u %g %a\" /tmp/edit_test)" = "55 55 555" ]
+  echo "new file" | lxc file edit foo/tmp/edit_new
+  [ "$(lxc exec foo -- cat /tmp/edit_new)" = "new file" ]
 
   # make sure stdin is chowned to our container root uid (Issue #590)
   [ -t 0 ] && [ -t 1 ] && lxc exec foo -- chown 1000:1000 /proc/self/fd/0
@@ -0,0 +1,33 @@
+test_console() {
+  lxc_version=$(lxc info | grep "driver_version: " | cut -d' ' -f4)
+  lxc_major=$(echo "${lxc_version}" | cut -d. -f1)
+  if [ "${lxc_major}" -lt 3 ]; then
+    echo "==> SKIP: The console ring buffer requires liblxc >= 3.0"
+    return
+  fi
+
+  ensure_import_testimage
+
+  lxc init testimage cons1
+
+  # Attaching requires a running container
+  ! my_curl -f -X POST "https://${LXD_ADDR}/1.0/containers/cons1/console" -d '{"width": 80, "height": 25}' || false
+
+  lxc start cons1
+
+  # The ring buffer can be read and cleared while the container runs
+  lxc exec cons1 -- sh -c "echo console-test > /dev/console"
+  lxc console cons1 --show-log | grep -q console-test
+  my_curl -f -X DELETE "https://${LXD_ADDR}/1.0/containers/cons1/console"
+  ! lxc console cons1 --show-log | grep -q console-test || false
+
+  # The log is dumped to a file when the container stops
+  lxc exec cons1 -- sh -c "echo console-stopped > /dev/console"
+  lxc stop cons1 --force
+  lxc console cons1 --show-log | grep -q console-stopped
+
+  # Attaching needs a terminal
+  ! lxc console cons1 < /dev/null || false
+
+  lxc delete --force cons1
+}
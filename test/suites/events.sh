@@ -0,0 +1,34 @@
+test_event_lifecycle() {
+  ensure_import_testimage
+
+  lxc monitor --type=lifecycle > "${TEST_DIR}/lifecycle.log" 2>&1 &
+  monitor_pid=$!
+  sleep 1
+
+  lxc init testimage lifecycle
+  lxc start lifecycle
+  lxc pause lifecycle
+  lxc start lifecycle
+  lxc snapshot lifecycle snap0
+  lxc stop lifecycle --force
+  lxc move lifecycle lifecycle1
+  lxc delete lifecycle1
+  lxc profile create lifecycle
+  lxc profile delete lifecycle
+  sleep 1
+
+  kill -9 "${monitor_pid}" || true
+
+  for action in container-created container-started container-paused container-resumed container-snapshot-created container-stopped container-renamed container-deleted profile-created profile-deleted; do
+    grep -q "action: ${action}" "${TEST_DIR}/lifecycle.log"
+  done
+
+  # Events come with the identity of the requesting client
+  grep -q "source: /1.0/containers/lifecycle$" "${TEST_DIR}/lifecycle.log"
+  grep -q "protocol: unix" "${TEST_DIR}/lifecycle.log"
+
+  # Logging events aren't included
+  ! grep -q "type: logging" "${TEST_DIR}/lifecycle.log" || false
+
+  rm -f "${TEST_DIR}/lifecycle.log"
+}
@@ -27,3 +27,64 @@ test_concurrent_exec() {
   lxc stop "${name}" --force
   lxc delete "${name}"
 }
+
+test_exec() {
+  ensure_import_testimage
+
+  name=x1
+  lxc launch testimage x1
+  lxc list ${name} | grep RUNNING
+
+  # working directory, user and group
+  [ "$(lxc exec "${name}" --cwd /tmp -- pwd)" = "/tmp" ]
+  [ "$(lxc exec "${name}" --user 1000 --group 1001 -- id -u)" = "1000" ]
+  [ "$(lxc exec "${name}" --user 1000 --group 1001 -- id -g)" = "1001" ]
+
+  # recorded output
+  op=$(my_curl -X POST "https://${LXD_ADDR}/1.0/containers/${name}/exec" -d '{"command": ["sh", "-c", "echo foo; echo bar >&2; exit 3"], "record-output": true}' | jq -r .operation)
+  meta=$(my_curl "https://${LXD_ADDR}${op}/wait" | jq .metadata.metadata)
+  [ "$(echo "${meta}" | jq -r .return)" = "3" ]
+
+  stdout=$(echo "${meta}" | jq -r '.output["1"]')
+  stderr=$(echo "${meta}" | jq -r '.output["2"]')
+  [ "$(my_curl "https://${LXD_ADDR}${stdout}")" = "foo" ]
+  [ "$(my_curl "https://${LXD_ADDR}${stderr}")" = "bar" ]
+  my_curl "https://${LXD_ADDR}/1.0/containers/${name}/logs" | jq -r ".metadata[]" | grep -q "${stdout}"
+
+  my_curl -X DELETE "https://${LXD_ADDR}${stdout}"
+  my_curl -X DELETE "https://${LXD_ADDR}${stderr}"
+  ! my_curl "https://${LXD_ADDR}/1.0/containers/${name}/logs" | jq -r ".metadata[]" | grep -q "${stdout}" || false
+
+  # recordings left behind go away with the container
+  my_curl -X POST "https://${LXD_ADDR}/1.0/containers/${name}/exec" -d '{"command": ["echo", "foo"], "record-output": true}'
+
+  lxc stop "${name}" --force
+  lxc delete "${name}"
+  [ ! -d "${LXD_DIR}/logs/${name}" ]
+}
+
+test_container_processes() {
+  ensure_import_testimage
+
+  name=p1
+  lxc launch testimage ${name}
+  lxc exec ${name} -- sh -c "nohup sleep 1234 >/dev/null 2>&1 &"
+
+  lxc ps ${name} | grep "sleep 1234"
+  lxc top ${name} --count=1 | grep "sleep 1234"
+
+  # processes are listed with their pid inside the container
+  [ "$(my_curl "https://${LXD_ADDR}/1.0/containers/${name}/processes" | jq -r '.metadata[] | select(.container_pid == 1) | .uid')" = "0" ]
+  pid=$(my_curl "https://${LXD_ADDR}/1.0/containers/${name}/processes" | jq -r '.metadata[] | select(.command == ["sleep", "1234"]) | .container_pid')
+
+  # and can be signalled
+  my_curl -X POST "https://${LXD_ADDR}/1.0/containers/${name}/processes" -d "{\"pid\": ${pid}, \"signal\": 9}"
+  sleep 1
+  ! lxc ps ${name} | grep "sleep 1234" || false
+
+  # unknown processes are reported as such
+  err=$(my_curl -o /dev/null -w "%{http_code}" -X POST "https://${LXD_ADDR}/1.0/containers/${name}/processes" -d '{"pid": 99999, "signal": 9}')
+  [ "${err}" -eq "404" ]
+
+  lxc delete ${name} --force
+}
@@ -17,6 +17,54 @@ test_filemanip() {
   err=$(my_curl -o /dev/null -w "%{http_code}" -X GET "https://${LXD_ADDR}/1.0/containers/filemanip/files?path=/tmp/foo")
   [ "${err}" -eq "404" ]
 
+  # recursive push and pull of a tree, creating the parent directories
+  mkdir -p "${TEST_DIR}/source/foo/bar"
+  echo "foo" > "${TEST_DIR}/source/foo/foo"
+  ln -s foo "${TEST_DIR}/source/foo/link"
+  lxc file push -r -p "${TEST_DIR}/source" filemanip/tmp/ptest/dest
+  [ "$(lxc exec filemanip -- cat /tmp/ptest/dest/source/foo/foo)" = "foo" ]
+  [ "$(lxc exec filemanip -- readlink /tmp/ptest/dest/source/foo/link)" = "foo" ]
+  lxc exec filemanip -- test -d /tmp/ptest/dest/source/foo/bar
+
+  # directory listings are returned as JSON
+  my_curl "https://${LXD_ADDR}/1.0/containers/filemanip/files?path=/tmp/ptest/dest/source/foo" | jq -r ".metadata | sort | .[]" | tr '\n' ' ' | grep -q "bar foo link"
+
+  mkdir "${TEST_DIR}/dest"
+  lxc file pull -r filemanip/tmp/ptest/dest/source "${TEST_DIR}/dest"
+  [ "$(cat "${TEST_DIR}/dest/source/foo/foo")" = "foo" ]
+  [ "$(readlink "${TEST_DIR}/dest/source/foo/link")" = "foo" ]
+  [ -d "${TEST_DIR}/dest/source/foo/bar" ]
+
+  # symlinks are only returned as such when asked to
+  my_curl -D - -o /dev/null "https://${LXD_ADDR}/1.0/containers/filemanip/files?path=/tmp/ptest/dest/source/foo/link" | grep -qi "^X-LXD-type: file"
+  my_curl -D - -o /dev/null "https://${LXD_ADDR}/1.0/containers/filemanip/files?path=/tmp/ptest/dest/source/foo/link&follow=false" | grep -qi "^X-LXD-type: symlink"
+
+  # non-recursive pulls follow symlinks
+  lxc file pull filemanip/tmp/ptest/dest/source/foo/link "${TEST_DIR}/link"
+  [ "$(cat "${TEST_DIR}/link")" = "foo" ]
+
+  # files can be pushed into missing directories
+  lxc file push -p "${TEST_DIR}"/filemanip filemanip/tmp/ptest/missing/dir/
+  [ "$(lxc exec filemanip -- cat /tmp/ptest/missing/dir/filemanip)" = "test" ]
+
+  # and deleted again
+  lxc file delete filemanip/tmp/ptest/missing/dir/filemanip
+  ! lxc exec filemanip -- test -e /tmp/ptest/missing/dir/filemanip
+  my_curl -X DELETE "https://${LXD_ADDR}/1.0/containers/filemanip/files?path=/tmp/ptest/dest/source/foo/foo"
+  ! lxc exec filemanip -- test -e /tmp/ptest/dest/source/foo/foo
+
+  rm -rf "${TEST_DIR}/source" "${TEST_DIR}/dest" "${TEST_DIR}/link"
+
+  # append and atomic write modes
+  echo "foo" | lxc file push - filemanip/tmp/writemode
+  lxc exec filemanip -- chmod 0604 /tmp/writemode
+  my_curl -X POST -H "X-LXD-write: append" --data-binary "bar" "https://${LXD_ADDR}/1.0/containers/filemanip/files?path=/tmp/writemode"
+  [ "$(lxc exec filemanip -- cat /tmp/writemode)" = "foobar" ]
+  my_curl -X POST -H "X-LXD-write: atomic" --data-binary "baz" "https://${LXD_ADDR}/1.0/containers/filemanip/files?path=/tmp/writemode"
+  [ "$(lxc exec filemanip -- cat /tmp/writemode)" = "baz" ]
+  [ "$(lxc exec filemanip -- stat -c %a /tmp/writemode)" = "604" ]
+  [ "$(lxc exec filemanip -- ls -a /tmp | grep -c lxd_put)" = "0" ]
+
   lxc delete filemanip -f
 
   if [ "$(storage_backend "$LXD_DIR")" != "lvm" ]; then
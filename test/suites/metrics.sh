@@ -0,0 +1,31 @@
+test_metrics() {
+  ensure_import_testimage
+  ensure_has_localhost_remote "${LXD_ADDR}"
+
+  lxc launch testimage metrics
+
+  # The trusted endpoint covers the containers and the daemon itself
+  my_curl -f "https://${LXD_ADDR}/1.0/metrics" > "${TEST_DIR}/metrics.txt"
+  grep -q '^lxd_container_running{name="metrics"} 1$' "${TEST_DIR}/metrics.txt"
+  grep -q '^lxd_container_memory_usage_bytes{name="metrics"} ' "${TEST_DIR}/metrics.txt"
+  grep -q '^lxd_container_processes{name="metrics"} ' "${TEST_DIR}/metrics.txt"
+  grep -q '^lxd_goroutines ' "${TEST_DIR}/metrics.txt"
+  grep -q '^lxd_images{cached="false"} ' "${TEST_DIR}/metrics.txt"
+  grep -q '^lxd_api_requests_total{method="GET",route="/1.0"} ' "${TEST_DIR}/metrics.txt"
+  tail -n1 "${TEST_DIR}/metrics.txt" | grep -q "^# EOF$"
+
+  # Untrusted clients aren't allowed on it
+  ! curl -k -s -f "https://${LXD_ADDR}/1.0/metrics" || false
+
+  # The metrics listener doesn't require authentication
+  ! lxc config set core.metrics_address 127.0.0.1 || false
+  port=$(local_tcp_port)
+  lxc config set core.metrics_address "127.0.0.1:${port}"
+  curl -s -f "http://127.0.0.1:${port}/1.0/metrics" | grep -q '^lxd_container_running{name="metrics"} 1$'
+
+  lxc config unset core.metrics_address
+  ! curl -s -f "http://127.0.0.1:${port}/1.0/metrics" || false
+
+  lxc delete -f metrics
+  rm -f "${TEST_DIR}/metrics.txt"
+}
@@ -75,6 +75,23 @@ test_migration() {
   lxc_remote copy l2:nonlive l1:nobase
   lxc_remote delete l1:nobase
 
+  # Refresh existing copies, only transferring what changed
+  lxc_remote snapshot l2:nonlive snap1
+  lxc_remote copy l2:nonlive l1:nonlive2 --refresh
+  lxc_remote info l1:nonlive2 | grep -q snap1
+  lxc_remote delete l2:nonlive/snap0
+  lxc_remote copy l2:nonlive l1:nonlive2 --refresh
+  lxc_remote info l1:nonlive2 | grep -q snap1
+  ! lxc_remote info l1:nonlive2 | grep -q snap0 || false
+  lxc_remote copy l2:nonlive l2:nonlive2 --refresh
+  lxc_remote info l2:nonlive2 | grep -q snap1
+  ! lxc_remote info l2:nonlive2 | grep -q snap0 || false
+
+  # Refreshing a missing container creates it
+  lxc_remote copy l2:nonlive l1:nonlive3 --refresh
+  lxc_remote info l1:nonlive3 | grep -q snap1
+  lxc_remote delete l1:nonlive3
+
   lxc_remote start l1:nonlive2
   lxc_remote list l1: | grep RUNNING | grep nonlive2
   lxc_remote delete l1:nonlive2 l2:nonlive2 --force
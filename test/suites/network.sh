@@ -0,0 +1,60 @@
+test_network() {
+  ensure_has_localhost_remote "${LXD_ADDR}"
+
+  name="lxdt$$"
+
+  # Invalid configuration is rejected
+  ! my_curl -f -X POST "https://${LXD_ADDR}/1.0/networks" -d "{\"name\": \"${name}\", \"config\": {\"ipv4.address\": \"10.0.3.1\"}}" || false
+  ! my_curl -f -X POST "https://${LXD_ADDR}/1.0/networks" -d "{\"name\": \"${name}\", \"config\": {\"foo\": \"bar\"}}" || false
+
+  # Create a bridge
+  my_curl -f -X POST "https://${LXD_ADDR}/1.0/networks" -d "{\"name\": \"${name}\", \"config\": {\"ipv4.address\": \"192.0.2.1/24\", \"ipv4.nat\": \"true\", \"ipv6.address\": \"none\"}}"
+  [ -d "/sys/class/net/${name}" ]
+  ip -4 addr show dev "${name}" | grep -q "192.0.2.1/24"
+  iptables -w -t nat -S | grep -q "generated for LXD network ${name}"
+  my_curl -f "https://${LXD_ADDR}/1.0/networks/${name}" | jq -r .metadata.managed | grep -q true
+
+  # Creating it again fails
+  ! my_curl -f -X POST "https://${LXD_ADDR}/1.0/networks" -d "{\"name\": \"${name}\"}" || false
+
+  # Update its configuration
+  my_curl -f -X PUT "https://${LXD_ADDR}/1.0/networks/${name}" -d '{"config": {"ipv4.address": "198.51.100.1/24"}}'
+  ip -4 addr show dev "${name}" | grep -q "198.51.100.1/24"
+  ! iptables -w -t nat -S | grep -q "generated for LXD network ${name}" || false
+
+  # Static addresses are handed to dnsmasq and listed as leases
+  ensure_import_testimage
+  lxc init testimage nettest
+  lxc config device add nettest eth0 nic nictype=bridged parent="${name}" ipv4.address=198.51.100.10
+  ! lxc config device set nettest eth0 ipv4.address fd42::10 || false
+  lxc config device set nettest eth0 security.mac_filtering true
+  lxc config device set nettest eth0 security.ipv4_filtering true
+  lxc start nettest
+  grep -q ",198.51.100.10,nettest$" "${LXD_DIR}/networks/${name}/dnsmasq.hosts/nettest"
+  my_curl -f "https://${LXD_ADDR}/1.0/networks/${name}/leases" | jq -r '.metadata[] | select(.type == "static") | .address' | grep -q "^198.51.100.10$"
+
+  # Spoofing protection rules are set on the host side veth
+  veth=$(lxc config get nettest volatile.eth0.host_name)
+  hwaddr=$(lxc config get nettest volatile.eth0.hwaddr)
+  ebtables -L --Lx | grep -- "-i ${veth}" | grep -q "${hwaddr}"
+  ebtables -L --Lx | grep -- "-i ${veth}" | grep -q "198.51.100.10"
+  lxc stop nettest --force
+  ! ebtables -L --Lx | grep -q -- "-i ${veth}" || false
+
+  # Networks in use can't be renamed or removed
+  ! my_curl -f -X POST "https://${LXD_ADDR}/1.0/networks/${name}" -d "{\"name\": \"${name}r\"}" || false
+  ! my_curl -f -X DELETE "https://${LXD_ADDR}/1.0/networks/${name}" || false
+
+  lxc delete -f nettest
+  [ ! -e "${LXD_DIR}/networks/${name}/dnsmasq.hosts/nettest" ]
+
+  # Rename it
+  my_curl -f -X POST "https://${LXD_ADDR}/1.0/networks/${name}" -d "{\"name\": \"${name}r\"}"
+  [ ! -d "/sys/class/net/${name}" ]
+  [ -d "/sys/class/net/${name}r" ]
+
+  # Delete it
+  my_curl -f -X DELETE "https://${LXD_ADDR}/1.0/networks/${name}r"
+  [ ! -d "/sys/class/net/${name}r" ]
+  ! iptables -w -S | grep -q "generated for LXD network ${name}r" || false
+}
@@ -0,0 +1,54 @@
+test_network_acl() {
+  ensure_has_localhost_remote "${LXD_ADDR}"
+
+  if ! which nft >/dev/null 2>&1; then
+    echo "==> SKIP: nft is required for network ACLs"
+    return
+  fi
+
+  name="lxdt$$"
+  acl="web$$"
+
+  # Invalid rules are rejected
+  ! my_curl -f -X POST "https://${LXD_ADDR}/1.0/network-acls" -d "{\"name\": \"${acl}\", \"ingress\": [{\"action\": \"accept\"}]}" || false
+  ! my_curl -f -X POST "https://${LXD_ADDR}/1.0/network-acls" -d "{\"name\": \"${acl}\", \"ingress\": [{\"action\": \"allow\", \"destination_port\": \"80\"}]}" || false
+
+  # Create an ACL
+  my_curl -f -X POST "https://${LXD_ADDR}/1.0/network-acls" -d "{\"name\": \"${acl}\", \"ingress\": [{\"action\": \"allow\", \"protocol\": \"tcp\", \"destination_port\": \"80\"}]}"
+  my_curl -f "https://${LXD_ADDR}/1.0/network-acls" | jq -r '.metadata[]' | grep -q "/1.0/network-acls/${acl}$"
+  ! my_curl -f -X POST "https://${LXD_ADDR}/1.0/network-acls" -d "{\"name\": \"${acl}\"}" || false
+
+  # Rename it
+  my_curl -f -X POST "https://${LXD_ADDR}/1.0/network-acls/${acl}" -d "{\"name\": \"${acl}r\"}"
+  ! my_curl -f "https://${LXD_ADDR}/1.0/network-acls/${acl}" || false
+  acl="${acl}r"
+
+  # Attach it to a container
+  my_curl -f -X POST "https://${LXD_ADDR}/1.0/networks" -d "{\"name\": \"${name}\", \"config\": {\"ipv4.address\": \"192.0.2.1/24\", \"ipv6.address\": \"none\"}}"
+  ensure_import_testimage
+  lxc init testimage acltest
+  lxc config device add acltest eth0 nic nictype=bridged parent="${name}" security.acls="${acl}"
+  lxc start acltest
+
+  veth=$(lxc config get acltest volatile.eth0.host_name)
+  chain="acl_$(echo "${veth}" | tr -c 'a-zA-Z0-9_\n' '_')_ingress"
+  nft list chain bridge lxd "${chain}" | grep -q "dport 80 accept"
+  my_curl -f "https://${LXD_ADDR}/1.0/network-acls/${acl}" | jq -r '.metadata.used_by[]' | grep -q "/1.0/containers/acltest$"
+
+  # Updates apply to the running container
+  my_curl -f -X PUT "https://${LXD_ADDR}/1.0/network-acls/${acl}" -d '{"ingress": [{"action": "allow", "protocol": "tcp", "destination_port": "443"}]}'
+  nft list chain bridge lxd "${chain}" | grep -q "dport 443 accept"
+  ! nft list chain bridge lxd "${chain}" | grep -q "dport 80 accept" || false
+
+  # ACLs in use can't be renamed or removed
+  ! my_curl -f -X POST "https://${LXD_ADDR}/1.0/network-acls/${acl}" -d '{"name": "foo"}' || false
+  ! my_curl -f -X DELETE "https://${LXD_ADDR}/1.0/network-acls/${acl}" || false
+
+  # The chains go away with the container
+  lxc stop acltest --force
+  ! nft list chain bridge lxd "${chain}" >/dev/null 2>&1 || false
+  lxc delete acltest
+
+  my_curl -f -X DELETE "https://${LXD_ADDR}/1.0/network-acls/${acl}"
+  my_curl -f -X DELETE "https://${LXD_ADDR}/1.0/networks/${name}"
+}
@@ -0,0 +1,42 @@
+test_proxy_device() {
+  ensure_import_testimage
+
+  port=$(local_tcp_port)
+
+  lxc launch testimage proxytest
+  lxc config device add proxytest web proxy "listen=tcp:127.0.0.1:${port}" connect=tcp:127.0.0.1:4321
+
+  # Invalid addresses are rejected
+  ! lxc config device add proxytest bad proxy listen=tcp:127.0.0.1 connect=tcp:127.0.0.1:4321 || false
+  ! lxc config device add proxytest bad proxy listen=udp:127.0.0.1:4321 connect=tcp:127.0.0.1:4321 || false
+
+  # Connections to the host port reach the container's loopback
+  (lxc exec proxytest -- sh -c "echo ping | nc -l -p 4321" > "${TEST_DIR}/proxy.out") &
+  sleep 1
+  echo pong | nc -w 2 127.0.0.1 "${port}" | grep -q ping
+  wait
+  grep -q pong "${TEST_DIR}/proxy.out"
+
+  # Unix sockets on the host work too
+  lxc config device add proxytest sock proxy "listen=unix:${TEST_DIR}/proxy.sock" connect=tcp:127.0.0.1:4321
+  [ -S "${TEST_DIR}/proxy.sock" ]
+  (lxc exec proxytest -- sh -c "echo ping | nc -l -p 4321" > /dev/null) &
+  sleep 1
+  echo pong | nc -w 2 -U "${TEST_DIR}/proxy.sock" | grep -q ping
+  wait
+
+  # Removing the device stops the proxy
+  lxc config device remove proxytest sock
+  [ ! -e "${TEST_DIR}/proxy.sock" ]
+  [ ! -e "${LXD_DIR}/devices/proxytest/proxy.sock" ]
+
+  # Proxies follow the container's lifecycle
+  lxc stop proxytest --force
+  [ ! -e "${LXD_DIR}/devices/proxytest/proxy.web" ]
+  lxc start proxytest
+  pid=$(cat "${LXD_DIR}/devices/proxytest/proxy.web")
+  [ -d "/proc/${pid}" ]
+
+  lxc delete -f proxytest
+  [ ! -d "/proc/${pid}" ]
+}
@@ -12,6 +12,29 @@ test_server_config() {
   lxc config unset core.trust_password
   lxc config show | grep -q -v "trust_password"
 
+  # images.auto_update_interval takes either hours or a cron expression
+  lxc config set images.auto_update_interval 12
+  lxc config set images.auto_update_interval "0 2 * * 6"
+  lxc config show | grep -q "0 2 \* \* 6"
+  ! lxc config set images.auto_update_interval "0 2 * *" || false
+  ! lxc config set images.auto_update_interval "@fortnightly" || false
+  lxc config unset images.auto_update_interval
+
+  # webhooks are configured with per-webhook keys
+  lxc config set core.webhooks.test.url http://127.0.0.1:1/events
+  lxc config set core.webhooks.test.types lifecycle
+  lxc config set core.webhooks.test.secret foo
+  config=$(lxc config show)
+  echo "${config}" | grep -q "core.webhooks.test.url: http://127.0.0.1:1/events"
+  echo "${config}" | grep -q "core.webhooks.test.secret: true"
+  ! lxc config set core.webhooks.test.url ftp://127.0.0.1/ || false
+  ! lxc config set core.webhooks.test.types foo || false
+  ! lxc config set core.webhooks.test.foo bar || false
+  lxc config unset core.webhooks.test.url
+  lxc config unset core.webhooks.test.types
+  lxc config unset core.webhooks.test.secret
+  ! lxc config show | grep -q "webhooks" || false
+
   # test untrusted server GET
   my_curl -X GET "https://$(cat "${LXD_SERVERCONFIG_DIR}/lxd.addr")/1.0" | grep -v -q environment
 }
@@ -174,3 +174,47 @@ restore_and_compare_fs() {
     diff -r "${LXD_DIR}/containers/bar/rootfs" "${LXD_DIR}/snapshots/bar/${snap}/rootfs"
   fi
 }
+
+test_snap_schedule() {
+  ensure_import_testimage
+  ensure_has_localhost_remote "${LXD_ADDR}"
+
+  lxc init testimage c1
+
+  # Invalid values are rejected
+  ! lxc config set c1 snapshots.schedule "not a schedule" || false
+  ! lxc config set c1 snapshots.expiry "2x" || false
+  ! lxc config set c1 snapshots.pattern "in/valid" || false
+
+  # Unnamed snapshots follow the pattern and get an expiry date
+  lxc config set c1 snapshots.pattern "auto%d"
+  lxc config set c1 snapshots.expiry "1w"
+  lxc snapshot c1
+  lxc snapshot c1
+  lxc info c1 | grep -q auto0
+  lxc info c1 | grep -q auto1
+  [ "$(my_curl -f "https://${LXD_ADDR}/1.0/containers/c1/snapshots/auto0" | jq -r '.metadata.expires_at')" != "0001-01-01T00:00:00Z" ]
+
+  # Snapshots without an expiry are kept
+  lxc config unset c1 snapshots.expiry
+  lxc snapshot c1 manual
+  [ "$(my_curl -f "https://${LXD_ADDR}/1.0/containers/c1/snapshots/manual" | jq -r '.metadata.expires_at')" = "0001-01-01T00:00:00Z" ]
+
+  # Scheduled snapshots get taken and expired ones get deleted
+  lxc config set c1 snapshots.schedule "* * * * *"
+  my_curl -f -X POST "https://${LXD_ADDR}/1.0/containers/c1/snapshots" -d '{"name": "expired", "expires_at": "2000-01-01T00:00:00Z"}'
+  lxc info c1 | grep -q expired
+
+  for _ in $(seq 30); do
+    if lxc info c1 | grep -q auto2 && ! lxc info c1 | grep -q expired; then
+      break
+    fi
+
+    sleep 5
+  done
+
+  lxc info c1 | grep -q auto2
+  ! lxc info c1 | grep -q expired || false
+
+  lxc delete c1
+}
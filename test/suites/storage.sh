@@ -0,0 +1,205 @@
+test_storage_pools() {
+  ensure_has_localhost_remote "${LXD_ADDR}"
+
+  # A dir pool can always be created
+  my_curl -f -X POST "https://${LXD_ADDR}/1.0/storage-pools" -d '{"name": "testpool", "driver": "dir", "description": "Test pool"}'
+  my_curl -f "https://${LXD_ADDR}/1.0/storage-pools" | jq -r ".metadata[]" | grep -q "/1.0/storage-pools/testpool"
+  [ "$(my_curl -f "https://${LXD_ADDR}/1.0/storage-pools/testpool" | jq -r .metadata.driver)" = "dir" ]
+  [ "$(my_curl -f "https://${LXD_ADDR}/1.0/storage-pools/testpool" | jq -r .metadata.description)" = "Test pool" ]
+
+  # Duplicate names and unknown drivers are rejected
+  ! my_curl -f -X POST "https://${LXD_ADDR}/1.0/storage-pools" -d '{"name": "testpool", "driver": "dir"}' || false
+  ! my_curl -f -X POST "https://${LXD_ADDR}/1.0/storage-pools" -d '{"name": "badpool", "driver": "foo"}' || false
+
+  # Driver specific keys are only valid for their driver
+  ! my_curl -f -X POST "https://${LXD_ADDR}/1.0/storage-pools" -d '{"name": "badpool", "driver": "dir", "config": {"zfs.pool_name": "foo"}}' || false
+  ! my_curl -f -X PUT "https://${LXD_ADDR}/1.0/storage-pools/testpool" -d '{"config": {"lvm.vg_name": "foo"}}' || false
+
+  my_curl -f -X PUT "https://${LXD_ADDR}/1.0/storage-pools/testpool" -d '{"description": "Updated"}'
+  [ "$(my_curl -f "https://${LXD_ADDR}/1.0/storage-pools/testpool" | jq -r .metadata.description)" = "Updated" ]
+
+  my_curl -f "https://${LXD_ADDR}/1.0/storage-pools/testpool/resources"
+
+  # Containers can have their root disk on a pool
+  ensure_import_testimage
+  lxc profile create pooltest
+  ! lxc profile device add pooltest root disk path=/ pool=missing || false
+  lxc profile device add pooltest root disk path=/ pool=testpool
+  lxc init testimage pooled -p default -p pooltest
+  my_curl -f "https://${LXD_ADDR}/1.0/storage-pools/testpool" | jq -r ".metadata.used_by[]" | grep -q "/1.0/containers/pooled"
+  my_curl -f "https://${LXD_ADDR}/1.0/storage-pools/testpool" | jq -r ".metadata.used_by[]" | grep -q "/1.0/profiles/pooltest"
+  ! my_curl -f -X DELETE "https://${LXD_ADDR}/1.0/storage-pools/testpool" || false
+
+  # The pool of an existing container can't be changed
+  ! lxc config device add pooled root disk path=/ pool=default || false
+
+  lxc delete pooled
+  lxc profile delete pooltest
+
+  # Custom volumes
+  my_curl -f -X POST "https://${LXD_ADDR}/1.0/storage-pools/testpool/volumes/custom" -d '{"name": "data", "type": "custom"}'
+  my_curl -f "https://${LXD_ADDR}/1.0/storage-pools/testpool/volumes" | jq -r ".metadata[]" | grep -q "/1.0/storage-pools/testpool/volumes/custom/data"
+  ! my_curl -f -X POST "https://${LXD_ADDR}/1.0/storage-pools/testpool/volumes/custom" -d '{"name": "data", "type": "custom"}' || false
+  ! my_curl -f -X POST "https://${LXD_ADDR}/1.0/storage-pools/testpool/volumes/custom" -d '{"name": "sized", "type": "custom", "config": {"size": "1GB"}}' || false
+  [ -d "${LXD_DIR}/storage-pools/testpool/custom/data" ]
+
+  lxc launch testimage withvolume
+  lxc config device add withvolume data disk pool=testpool source=data path=/mnt/data
+  lxc exec withvolume -- touch /mnt/data/foo
+  [ -f "${LXD_DIR}/storage-pools/testpool/custom/data/foo" ]
+  ! lxc config device add withvolume missing disk pool=testpool source=missing path=/mnt/missing || false
+  my_curl -f "https://${LXD_ADDR}/1.0/storage-pools/testpool/volumes/custom/data" | jq -r ".metadata.used_by[]" | grep -q "/1.0/containers/withvolume"
+  ! my_curl -f -X DELETE "https://${LXD_ADDR}/1.0/storage-pools/testpool/volumes/custom/data" || false
+  ! my_curl -f -X DELETE "https://${LXD_ADDR}/1.0/storage-pools/testpool" || false
+  lxc delete -f withvolume
+
+  # Custom volume snapshots
+  vol="https://${LXD_ADDR}/1.0/storage-pools/testpool/volumes/custom/data"
+  op=$(my_curl -f -X POST "${vol}/snapshots" -d '{"name": "before"}' | jq -r .operation)
+  my_curl -f "https://${LXD_ADDR}${op}/wait"
+  my_curl -f "${vol}/snapshots" | jq -r ".metadata[]" | grep -q "/1.0/storage-pools/testpool/volumes/custom/data/snapshots/before"
+  [ -f "${LXD_DIR}/storage-pools/testpool/custom-snapshots/data/before/foo" ]
+  ! my_curl -f -X POST "${vol}/snapshots" -d '{"name": "before"}' || false
+  ! my_curl -f -X POST "${vol}/snapshots" -d '{"name": "in/valid"}' || false
+
+  rm "${LXD_DIR}/storage-pools/testpool/custom/data/foo"
+  op=$(my_curl -f -X POST "${vol}/snapshots" -d '{}' | jq -r .operation)
+  my_curl -f "https://${LXD_ADDR}${op}/wait"
+  [ "$(my_curl -f "${vol}/snapshots/snap0" | jq -r .metadata.name)" = "snap0" ]
+
+  my_curl -f -X POST "${vol}/snapshots/before" -d '{"name": "renamed"}'
+  ! my_curl -f "${vol}/snapshots/before" || false
+  ! my_curl -f -X POST "${vol}/snapshots/renamed" -d '{"name": "snap0"}' || false
+
+  my_curl -f -X PUT "${vol}" -d '{"restore": "renamed"}'
+  [ -f "${LXD_DIR}/storage-pools/testpool/custom/data/foo" ]
+  ! my_curl -f -X PUT "${vol}" -d '{"restore": "missing"}' || false
+
+  my_curl -f -X DELETE "${vol}/snapshots/snap0"
+  ! my_curl -f "${vol}/snapshots/snap0" || false
+  [ ! -d "${LXD_DIR}/storage-pools/testpool/custom-snapshots/data/snap0" ]
+
+  my_curl -f -X POST "https://${LXD_ADDR}/1.0/storage-pools/testpool/volumes/custom/data" -d '{"name": "renamed"}'
+  [ -f "${LXD_DIR}/storage-pools/testpool/custom/renamed/foo" ]
+  [ -d "${LXD_DIR}/storage-pools/testpool/custom-snapshots/renamed/renamed" ]
+  my_curl -f -X DELETE "https://${LXD_ADDR}/1.0/storage-pools/testpool/volumes/custom/renamed"
+  [ ! -d "${LXD_DIR}/storage-pools/testpool/custom/renamed" ]
+  [ ! -d "${LXD_DIR}/storage-pools/testpool/custom-snapshots/renamed" ]
+
+  my_curl -f -X DELETE "https://${LXD_ADDR}/1.0/storage-pools/testpool"
+  ! my_curl -f "https://${LXD_ADDR}/1.0/storage-pools/testpool" || false
+}
+
+test_storage_dir_reflink() {
+  # shellcheck disable=2153
+  if [ "$(storage_backend "$LXD_DIR")" != "dir" ]; then
+    echo "==> SKIP: reflink copies are specific to the dir backend"
+    return
+  fi
+
+  if ! which mkfs.xfs >/dev/null 2>&1; then
+    echo "==> SKIP: reflink copies require mkfs.xfs"
+    return
+  fi
+
+  # shellcheck disable=2039
+  local LXD_REFLINK_DIR loop_file loop_device
+  configure_loop_device loop_file loop_device
+  if ! mkfs.xfs -q -m reflink=1 "${loop_device}"; then
+    deconfigure_loop_device "${loop_file}" "${loop_device}"
+    echo "==> SKIP: the XFS tools don't support reflinks"
+    return
+  fi
+
+  LXD_REFLINK_DIR=$(mktemp -d -p "${TEST_DIR}" XXX)
+  mount "${loop_device}" "${LXD_REFLINK_DIR}"
+  chmod +x "${LXD_REFLINK_DIR}"
+  LXD_BACKEND=dir spawn_lxd "${LXD_REFLINK_DIR}"
+
+  (
+    set -e
+    # shellcheck disable=2030
+    LXD_DIR="${LXD_REFLINK_DIR}"
+
+    ensure_import_testimage
+
+    # The image is kept unpacked so that containers can be cloned from it
+    fingerprint=$(lxc image info testimage | awk '/^Fingerprint/ {print $2}')
+    [ -d "${LXD_DIR}/images/${fingerprint}.dir" ]
+
+    lxc init testimage c1
+    lxc snapshot c1
+    lxc copy c1 c2
+    [ -d "${LXD_DIR}/containers/c2/rootfs/bin" ]
+    [ -d "${LXD_DIR}/snapshots/c1/snap0/rootfs/bin" ]
+
+    # Cloned files share their extents with the original ones
+    filefrag -v "${LXD_DIR}/containers/c2/rootfs/bin/busybox" | grep -q shared
+
+    lxc start c2
+    lxc exec c2 -- touch /root/foo
+    [ ! -e "${LXD_DIR}/containers/c1/rootfs/root/foo" ]
+    lxc delete -f c1 c2
+
+    lxc image delete testimage
+    [ ! -d "${LXD_DIR}/images/${fingerprint}.dir" ]
+  )
+
+  # shellcheck disable=2031
+  kill_lxd "${LXD_REFLINK_DIR}"
+  deconfigure_loop_device "${loop_file}" "${loop_device}"
+}
+
+test_storage_dir_quota() {
+  # shellcheck disable=2153
+  if [ "$(storage_backend "$LXD_DIR")" != "dir" ]; then
+    echo "==> SKIP: project quotas are specific to the dir backend"
+    return
+  fi
+
+  if ! which mkfs.ext4 >/dev/null 2>&1; then
+    echo "==> SKIP: project quotas require mkfs.ext4"
+    return
+  fi
+
+  # shellcheck disable=2039
+  local LXD_QUOTA_DIR loop_file loop_device
+  configure_loop_device loop_file loop_device
+  if ! mkfs.ext4 -q -O quota,project -E quotatype=prjquota "${loop_device}"; then
+    deconfigure_loop_device "${loop_file}" "${loop_device}"
+    echo "==> SKIP: the ext4 tools don't support project quotas"
+    return
+  fi
+
+  LXD_QUOTA_DIR=$(mktemp -d -p "${TEST_DIR}" XXX)
+  mount -o prjquota "${loop_device}" "${LXD_QUOTA_DIR}"
+  chmod +x "${LXD_QUOTA_DIR}"
+  LXD_BACKEND=dir spawn_lxd "${LXD_QUOTA_DIR}"
+
+  (
+    set -e
+    # shellcheck disable=2030
+    LXD_DIR="${LXD_QUOTA_DIR}"
+
+    ensure_import_testimage
+
+    lxc init testimage c1
+    lxc config device add c1 root disk path=/ size=50MB
+    lxc start c1
+
+    # Writes are limited by the quota and the usage gets reported
+    ! lxc exec c1 -- dd if=/dev/zero of=/root/big bs=1M count=60 || false
+    lxc info c1 | grep -q "Disk usage"
+
+    # The quota can be raised
+    lxc config device set c1 root size 100MB
+    lxc exec c1 -- rm -f /root/big
+    lxc exec c1 -- dd if=/dev/zero of=/root/big bs=1M count=60
+
+    lxc delete -f c1
+  )
+
+  # shellcheck disable=2031
+  kill_lxd "${LXD_QUOTA_DIR}"
+  deconfigure_loop_device "${loop_file}" "${loop_device}"
+}
@@ -96,3 +96,36 @@ test_template() {
   lxc image delete template-test
   lxc delete template template1 --force
 }
+
+test_container_metadata() {
+  ensure_import_testimage
+  lxc init testimage c
+
+  url="https://${LXD_ADDR}/1.0/containers/c/metadata"
+
+  # The metadata of the image is exposed
+  [ "$(my_curl -f "${url}" | jq -r .metadata.architecture)" != "null" ]
+
+  # Edit the metadata
+  my_curl -f -X PUT "${url}" -d '{"architecture": "x86_64", "properties": {"description": "edited"}}'
+  [ "$(my_curl -f "${url}" | jq -r .metadata.properties.description)" = "edited" ]
+  ! my_curl -f -X PUT "${url}" -d 'not json' || false
+
+  # Add, update and remove a template
+  [ "$(my_curl -f "${url}/templates" | jq -r ".metadata | length")" = "0" ]
+  my_curl -f -X POST "${url}/templates?path=hostname.tpl" --data-binary "{{ container.name }}"
+  ! my_curl -f -X POST "${url}/templates?path=hostname.tpl" --data-binary "{{ container.name }}" || false
+  my_curl -f "${url}/templates" | jq -r ".metadata[]" | grep -q "^hostname.tpl$"
+  [ "$(my_curl -f "${url}/templates?path=hostname.tpl")" = "{{ container.name }}" ]
+
+  my_curl -f -X PUT "${url}/templates?path=hostname.tpl" --data-binary "name: {{ container.name }}"
+  [ "$(my_curl -f "${url}/templates?path=hostname.tpl")" = "name: {{ container.name }}" ]
+  ! my_curl -f -X PUT "${url}/templates?path=missing.tpl" --data-binary "foo" || false
+  ! my_curl -f -X POST "${url}/templates?path=../escape.tpl" --data-binary "foo" || false
+
+  my_curl -f -X DELETE "${url}/templates?path=hostname.tpl"
+  ! my_curl -f "${url}/templates?path=hostname.tpl" || false
+  ! my_curl -f -X DELETE "${url}/templates?path=hostname.tpl" || false
+
+  lxc delete c
+}